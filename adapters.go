@@ -0,0 +1,577 @@
+// Code generated by adapters_gen.go. DO NOT EDIT.
+
+package cli
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/alecthomas/kong"
+
+	v2 "github.com/apexlang/cli/v2"
+)
+
+// UserConfig is [v2.UserConfig].
+type UserConfig = v2.UserConfig
+
+// AliasCmd is [v2.AliasCmd].
+type AliasCmd = v2.AliasCmd
+
+// AliasListCmd is [v2.AliasListCmd].
+type AliasListCmd = v2.AliasListCmd
+
+// AliasSetCmd is [v2.AliasSetCmd].
+type AliasSetCmd = v2.AliasSetCmd
+
+// AliasRemoveCmd is [v2.AliasRemoveCmd].
+type AliasRemoveCmd = v2.AliasRemoveCmd
+
+// ProjectConfig is [v2.ProjectConfig].
+type ProjectConfig = v2.ProjectConfig
+
+// AstyleOption is [v2.AstyleOption].
+type AstyleOption = v2.AstyleOption
+
+// AuditEntry is [v2.AuditEntry].
+type AuditEntry = v2.AuditEntry
+
+// AuditModule is [v2.AuditModule].
+type AuditModule = v2.AuditModule
+
+// AuditCmd is [v2.AuditCmd].
+type AuditCmd = v2.AuditCmd
+
+// AuditShowCmd is [v2.AuditShowCmd].
+type AuditShowCmd = v2.AuditShowCmd
+
+// AuditExportCmd is [v2.AuditExportCmd].
+type AuditExportCmd = v2.AuditExportCmd
+
+// BundleCmd is [v2.BundleCmd].
+type BundleCmd = v2.BundleCmd
+
+// BundleExportCmd is [v2.BundleExportCmd].
+type BundleExportCmd = v2.BundleExportCmd
+
+// BundleImportCmd is [v2.BundleImportCmd].
+type BundleImportCmd = v2.BundleImportCmd
+
+// CacheCmd is [v2.CacheCmd].
+type CacheCmd = v2.CacheCmd
+
+// CachePruneCmd is [v2.CachePruneCmd].
+type CachePruneCmd = v2.CachePruneCmd
+
+// ChangelogCmd is [v2.ChangelogCmd].
+type ChangelogCmd = v2.ChangelogCmd
+
+// CIConfig is [v2.CIConfig].
+type CIConfig = v2.CIConfig
+
+// CICmd is [v2.CICmd].
+type CICmd = v2.CICmd
+
+// ConflictError is [v2.ConflictError].
+type ConflictError = v2.ConflictError
+
+// CommandSet is [v2.CommandSet].
+type CommandSet = v2.CommandSet
+
+// VersionCmd is [v2.VersionCmd].
+type VersionCmd = v2.VersionCmd
+
+// ConfigOverrides is [v2.ConfigOverrides].
+type ConfigOverrides = v2.ConfigOverrides
+
+// VersionInfo is [v2.VersionInfo].
+type VersionInfo = v2.VersionInfo
+
+// VersionFlag is [v2.VersionFlag].
+type VersionFlag = v2.VersionFlag
+
+// ProbeCmd is [v2.ProbeCmd].
+type ProbeCmd = v2.ProbeCmd
+
+// ProbeResult is [v2.ProbeResult].
+type ProbeResult = v2.ProbeResult
+
+// ProbeCheck is [v2.ProbeCheck].
+type ProbeCheck = v2.ProbeCheck
+
+// LoginCmd is [v2.LoginCmd].
+type LoginCmd = v2.LoginCmd
+
+// LogoutCmd is [v2.LogoutCmd].
+type LogoutCmd = v2.LogoutCmd
+
+// FormatError is [v2.FormatError].
+type FormatError = v2.FormatError
+
+// Context is [v2.Context].
+type Context = v2.Context
+
+// GenerateCmd is [v2.GenerateCmd].
+type GenerateCmd = v2.GenerateCmd
+
+// Config is [v2.Config].
+type Config = v2.Config
+
+// Target is [v2.Target].
+type Target = v2.Target
+
+// Visitor is [v2.Visitor].
+type Visitor = v2.Visitor
+
+// Command is [v2.Command].
+type Command = v2.Command
+
+// GenerationError is [v2.GenerationError].
+type GenerationError = v2.GenerationError
+
+// Errors is [v2.Errors].
+type Errors = v2.Errors
+
+// DependencyOptions is [v2.DependencyOptions].
+type DependencyOptions = v2.DependencyOptions
+
+// HooksCmd is [v2.HooksCmd].
+type HooksCmd = v2.HooksCmd
+
+// HooksInstallCmd is [v2.HooksInstallCmd].
+type HooksInstallCmd = v2.HooksInstallCmd
+
+// InfoCmd is [v2.InfoCmd].
+type InfoCmd = v2.InfoCmd
+
+// InitCmd is [v2.InitCmd].
+type InitCmd = v2.InitCmd
+
+// InstallCmd is [v2.InstallCmd].
+type InstallCmd = v2.InstallCmd
+
+// InstallInfo is [v2.InstallInfo].
+type InstallInfo = v2.InstallInfo
+
+// InstallHook is [v2.InstallHook].
+type InstallHook = v2.InstallHook
+
+// ListCmd is [v2.ListCmd].
+type ListCmd = v2.ListCmd
+
+// ListDefinitionsCmd is [v2.ListDefinitionsCmd].
+type ListDefinitionsCmd = v2.ListDefinitionsCmd
+
+// ListTemplatesCmd is [v2.ListTemplatesCmd].
+type ListTemplatesCmd = v2.ListTemplatesCmd
+
+// Lockfile is [v2.Lockfile].
+type Lockfile = v2.Lockfile
+
+// LockedModule is [v2.LockedModule].
+type LockedModule = v2.LockedModule
+
+// Template is [v2.Template].
+type Template = v2.Template
+
+// Variable is [v2.Variable].
+type Variable = v2.Variable
+
+// NewCmd is [v2.NewCmd].
+type NewCmd = v2.NewCmd
+
+// PackCmd is [v2.PackCmd].
+type PackCmd = v2.PackCmd
+
+// InstallPolicy is [v2.InstallPolicy].
+type InstallPolicy = v2.InstallPolicy
+
+// ExtractionPolicy is [v2.ExtractionPolicy].
+type ExtractionPolicy = v2.ExtractionPolicy
+
+// PolicyViolation is [v2.PolicyViolation].
+type PolicyViolation = v2.PolicyViolation
+
+// ProgressEvent is [v2.ProgressEvent].
+type ProgressEvent = v2.ProgressEvent
+
+// GenerateReport is [v2.GenerateReport].
+type GenerateReport = v2.GenerateReport
+
+// ReportFile is [v2.ReportFile].
+type ReportFile = v2.ReportFile
+
+// ReportOutput is [v2.ReportOutput].
+type ReportOutput = v2.ReportOutput
+
+// VerifyCmd is [v2.VerifyCmd].
+type VerifyCmd = v2.VerifyCmd
+
+// RestoreCmd is [v2.RestoreCmd].
+type RestoreCmd = v2.RestoreCmd
+
+// SchemaCmd is [v2.SchemaCmd].
+type SchemaCmd = v2.SchemaCmd
+
+// ServeDocsCmd is [v2.ServeDocsCmd].
+type ServeDocsCmd = v2.ServeDocsCmd
+
+// Shrinkwrap is [v2.Shrinkwrap].
+type Shrinkwrap = v2.Shrinkwrap
+
+// Package is [v2.Package].
+type Package = v2.Package
+
+// SpecCmd is [v2.SpecCmd].
+type SpecCmd = v2.SpecCmd
+
+// SpecAddCmd is [v2.SpecAddCmd].
+type SpecAddCmd = v2.SpecAddCmd
+
+// SpecAddInterfaceCmd is [v2.SpecAddInterfaceCmd].
+type SpecAddInterfaceCmd = v2.SpecAddInterfaceCmd
+
+// SpecAddTypeCmd is [v2.SpecAddTypeCmd].
+type SpecAddTypeCmd = v2.SpecAddTypeCmd
+
+// SpecChange is [v2.SpecChange].
+type SpecChange = v2.SpecChange
+
+// SpecFormatCmd is [v2.SpecFormatCmd].
+type SpecFormatCmd = v2.SpecFormatCmd
+
+// SpecStatsCmd is [v2.SpecStatsCmd].
+type SpecStatsCmd = v2.SpecStatsCmd
+
+// SpecStats is [v2.SpecStats].
+type SpecStats = v2.SpecStats
+
+// StoreCmd is [v2.StoreCmd].
+type StoreCmd = v2.StoreCmd
+
+// StoreMoveCmd is [v2.StoreMoveCmd].
+type StoreMoveCmd = v2.StoreMoveCmd
+
+// Summary is [v2.Summary].
+type Summary = v2.Summary
+
+// TemplateData is [v2.TemplateData].
+type TemplateData = v2.TemplateData
+
+// TimeoutError is [v2.TimeoutError].
+type TimeoutError = v2.TimeoutError
+
+// UninstallCmd is [v2.UninstallCmd].
+type UninstallCmd = v2.UninstallCmd
+
+// UpdateCmd is [v2.UpdateCmd].
+type UpdateCmd = v2.UpdateCmd
+
+// UpgradeCmd is [v2.UpgradeCmd].
+type UpgradeCmd = v2.UpgradeCmd
+
+// VendorCmd is [v2.VendorCmd].
+type VendorCmd = v2.VendorCmd
+
+// WatchCmd is [v2.WatchCmd].
+type WatchCmd = v2.WatchCmd
+
+// Workspace is [v2.Workspace].
+type Workspace = v2.Workspace
+
+// WorkspaceModule is [v2.WorkspaceModule].
+type WorkspaceModule = v2.WorkspaceModule
+
+// ProjectConfigFile is [v2.ProjectConfigFile].
+const ProjectConfigFile = v2.ProjectConfigFile
+
+// ProfileEnv is [v2.ProfileEnv].
+const ProfileEnv = v2.ProfileEnv
+
+// AuditLogFile is [v2.AuditLogFile].
+const AuditLogFile = v2.AuditLogFile
+
+// AuditSuccess is [v2.AuditSuccess].
+const AuditSuccess = v2.AuditSuccess
+
+// AuditFailure is [v2.AuditFailure].
+const AuditFailure = v2.AuditFailure
+
+// CIStepValidate is [v2.CIStepValidate].
+const CIStepValidate = v2.CIStepValidate
+
+// CIStepCheck is [v2.CIStepCheck].
+const CIStepCheck = v2.CIStepCheck
+
+// CIStepLint is [v2.CIStepLint].
+const CIStepLint = v2.CIStepLint
+
+// CompatNodeCLI is [v2.CompatNodeCLI].
+const CompatNodeCLI = v2.CompatNodeCLI
+
+// ConfigEnv is [v2.ConfigEnv].
+const ConfigEnv = v2.ConfigEnv
+
+// HomeEnv is [v2.HomeEnv].
+const HomeEnv = v2.HomeEnv
+
+// NoColorEnv is [v2.NoColorEnv].
+const NoColorEnv = v2.NoColorEnv
+
+// DotenvFile is [v2.DotenvFile].
+const DotenvFile = v2.DotenvFile
+
+// FormatErrorFail is [v2.FormatErrorFail].
+const FormatErrorFail = v2.FormatErrorFail
+
+// FormatErrorRaw is [v2.FormatErrorRaw].
+const FormatErrorRaw = v2.FormatErrorRaw
+
+// EngineVisitor is [v2.EngineVisitor].
+const EngineVisitor = v2.EngineVisitor
+
+// EngineTemplate is [v2.EngineTemplate].
+const EngineTemplate = v2.EngineTemplate
+
+// GenerationPhaseConfig is [v2.GenerationPhaseConfig].
+const GenerationPhaseConfig = v2.GenerationPhaseConfig
+
+// GenerationPhaseGenerate is [v2.GenerationPhaseGenerate].
+const GenerationPhaseGenerate = v2.GenerationPhaseGenerate
+
+// GenerationPhaseFormat is [v2.GenerationPhaseFormat].
+const GenerationPhaseFormat = v2.GenerationPhaseFormat
+
+// GenerationPhaseMerge is [v2.GenerationPhaseMerge].
+const GenerationPhaseMerge = v2.GenerationPhaseMerge
+
+// GenerationPhaseWrite is [v2.GenerationPhaseWrite].
+const GenerationPhaseWrite = v2.GenerationPhaseWrite
+
+// GenerationPhaseCommand is [v2.GenerationPhaseCommand].
+const GenerationPhaseCommand = v2.GenerationPhaseCommand
+
+// GenerationPhaseDependency is [v2.GenerationPhaseDependency].
+const GenerationPhaseDependency = v2.GenerationPhaseDependency
+
+// HookPreCommit is [v2.HookPreCommit].
+const HookPreCommit = v2.HookPreCommit
+
+// HookPrePush is [v2.HookPrePush].
+const HookPrePush = v2.HookPrePush
+
+// PartCode is [v2.PartCode].
+const PartCode = v2.PartCode
+
+// PartDefinitions is [v2.PartDefinitions].
+const PartDefinitions = v2.PartDefinitions
+
+// PartTemplates is [v2.PartTemplates].
+const PartTemplates = v2.PartTemplates
+
+// DefaultLockfile is [v2.DefaultLockfile].
+const DefaultLockfile = v2.DefaultLockfile
+
+// ModeReplace is [v2.ModeReplace].
+const ModeReplace = v2.ModeReplace
+
+// ModeMerge is [v2.ModeMerge].
+const ModeMerge = v2.ModeMerge
+
+// RequestedTag is [v2.RequestedTag].
+const RequestedTag = v2.RequestedTag
+
+// RequestedVersion is [v2.RequestedVersion].
+const RequestedVersion = v2.RequestedVersion
+
+// RequestedRange is [v2.RequestedRange].
+const RequestedRange = v2.RequestedRange
+
+// NPMTokenEnv is [v2.NPMTokenEnv].
+const NPMTokenEnv = v2.NPMTokenEnv
+
+// NPMMirrorsEnv is [v2.NPMMirrorsEnv].
+const NPMMirrorsEnv = v2.NPMMirrorsEnv
+
+// OfflineEnv is [v2.OfflineEnv].
+const OfflineEnv = v2.OfflineEnv
+
+// PhaseResolve is [v2.PhaseResolve].
+const PhaseResolve = v2.PhaseResolve
+
+// PhaseDownload is [v2.PhaseDownload].
+const PhaseDownload = v2.PhaseDownload
+
+// PhaseVerify is [v2.PhaseVerify].
+const PhaseVerify = v2.PhaseVerify
+
+// PhaseExtract is [v2.PhaseExtract].
+const PhaseExtract = v2.PhaseExtract
+
+// PhaseBuild is [v2.PhaseBuild].
+const PhaseBuild = v2.PhaseBuild
+
+// PhaseCopy is [v2.PhaseCopy].
+const PhaseCopy = v2.PhaseCopy
+
+// PhaseDone is [v2.PhaseDone].
+const PhaseDone = v2.PhaseDone
+
+// ProjectHomeDir is [v2.ProjectHomeDir].
+const ProjectHomeDir = v2.ProjectHomeDir
+
+// SchemaConfig is [v2.SchemaConfig].
+const SchemaConfig = v2.SchemaConfig
+
+// SchemaTemplate is [v2.SchemaTemplate].
+const SchemaTemplate = v2.SchemaTemplate
+
+// IncludeDev is [v2.IncludeDev].
+const IncludeDev = v2.IncludeDev
+
+// IncludeOptional is [v2.IncludeOptional].
+const IncludeOptional = v2.IncludeOptional
+
+// ChangeAdded is [v2.ChangeAdded].
+const ChangeAdded = v2.ChangeAdded
+
+// ChangeRemoved is [v2.ChangeRemoved].
+const ChangeRemoved = v2.ChangeRemoved
+
+// ChangeChanged is [v2.ChangeChanged].
+const ChangeChanged = v2.ChangeChanged
+
+// SummaryText is [v2.SummaryText].
+const SummaryText = v2.SummaryText
+
+// SummaryJSON is [v2.SummaryJSON].
+const SummaryJSON = v2.SummaryJSON
+
+// SummaryNone is [v2.SummaryNone].
+const SummaryNone = v2.SummaryNone
+
+// TmpDirEnv is [v2.TmpDirEnv].
+const TmpDirEnv = v2.TmpDirEnv
+
+// CABundleEnv is [v2.CABundleEnv].
+const CABundleEnv = v2.CABundleEnv
+
+// VendorDir is [v2.VendorDir].
+const VendorDir = v2.VendorDir
+
+// ErrAstyleSourceTooLarge is [v2.ErrAstyleSourceTooLarge].
+var ErrAstyleSourceTooLarge = v2.ErrAstyleSourceTooLarge
+
+// ErrInputTooLarge is [v2.ErrInputTooLarge].
+var ErrInputTooLarge = v2.ErrInputTooLarge
+
+// ErrScriptTimeout is [v2.ErrScriptTimeout].
+var ErrScriptTimeout = v2.ErrScriptTimeout
+
+// AddModuleAliases calls [v2.AddModuleAliases].
+func AddModuleAliases(aliases map[string]string) {
+	syncSettings()
+	v2.AddModuleAliases(aliases)
+}
+
+// DefaultsResolver calls [v2.DefaultsResolver].
+func DefaultsResolver() kong.Resolver {
+	syncSettings()
+	return v2.DefaultsResolver()
+}
+
+// Astyle calls [v2.Astyle].
+func Astyle(source, options string) (string, error) {
+	syncSettings()
+	return v2.Astyle(source, options)
+}
+
+// AstyleStream calls [v2.AstyleStream].
+func AstyleStream(w io.Writer, r io.Reader, options string) error {
+	syncSettings()
+	return v2.AstyleStream(w, r, options)
+}
+
+// AstyleStyles calls [v2.AstyleStyles].
+func AstyleStyles() []AstyleOption {
+	syncSettings()
+	return v2.AstyleStyles()
+}
+
+// AstylePresets calls [v2.AstylePresets].
+func AstylePresets() []string {
+	syncSettings()
+	return v2.AstylePresets()
+}
+
+// AstylePreset calls [v2.AstylePreset].
+func AstylePreset(name string) (string, bool) {
+	syncSettings()
+	return v2.AstylePreset(name)
+}
+
+// ValidateAstyleOptions calls [v2.ValidateAstyleOptions].
+func ValidateAstyleOptions(options string) error {
+	syncSettings()
+	return v2.ValidateAstyleOptions(options)
+}
+
+// RecordAudit calls [v2.RecordAudit].
+func RecordAudit(ctx *kong.Context, started time.Time, err error) {
+	syncSettings()
+	v2.RecordAudit(ctx, started, err)
+}
+
+// Commands calls [v2.Commands].
+func Commands() *CommandSet {
+	syncSettings()
+	return v2.Commands()
+}
+
+// ConfigureOutput calls [v2.ConfigureOutput].
+func ConfigureOutput() {
+	syncSettings()
+	v2.ConfigureOutput()
+}
+
+// CurrentVersion calls [v2.CurrentVersion].
+func CurrentVersion() VersionInfo {
+	syncSettings()
+	return v2.CurrentVersion()
+}
+
+// WriteVersion calls [v2.WriteVersion].
+func WriteVersion(w io.Writer, asJSON bool) error {
+	syncSettings()
+	return v2.WriteVersion(w, asJSON)
+}
+
+// AddDependencies calls [v2.AddDependencies].
+func AddDependencies(dependencies map[string][]string) {
+	syncSettings()
+	v2.AddDependencies(dependencies)
+}
+
+// EnsureDependencies calls [v2.EnsureDependencies].
+func EnsureDependencies(ctx context.Context, homeDir string, deps map[string][]string, opts DependencyOptions) error {
+	syncSettings()
+	return v2.EnsureDependencies(ctx, homeDir, deps, opts)
+}
+
+// RegisterInstallHook calls [v2.RegisterInstallHook].
+func RegisterInstallHook(pre, post InstallHook) {
+	syncSettings()
+	v2.RegisterInstallHook(pre, post)
+}
+
+// AddMessages calls [v2.AddMessages].
+func AddMessages(locale string, messages map[string]string) {
+	syncSettings()
+	v2.AddMessages(locale, messages)
+}
+
+// WriteJSONSchema calls [v2.WriteJSONSchema].
+func WriteJSONSchema(w io.Writer, kind string) error {
+	syncSettings()
+	return v2.WriteJSONSchema(w, kind)
+}
@@ -0,0 +1,252 @@
+//go:build ignore
+
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// adapters_gen writes adapters.go, which keeps the exported identifiers
+// of this package in sync with those of github.com/apexlang/cli/v2.
+// Types and constants are aliased, functions are wrapped so that the
+// variables in settings.go are copied first, and identifiers declared
+// by hand in this package are skipped.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	v2Dir    = "v2"
+	v2Import = "github.com/apexlang/cli/v2"
+	output   = "adapters.go"
+)
+
+func main() {
+	fset := token.NewFileSet()
+	declared, err := declaredNames(fset)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var types, consts, vars []string
+	var funcs []*ast.FuncDecl
+	imports := map[string]string{}
+	files, err := packageFiles(v2Dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, filename := range files {
+		file, err := parser.ParseFile(fset, filename, nil, 0)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fileImports := importNames(file)
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv != nil || !decl.Name.IsExported() || declared[decl.Name.Name] {
+					continue
+				}
+				funcs = append(funcs, decl)
+				ast.Inspect(decl.Type, func(n ast.Node) bool {
+					if sel, ok := n.(*ast.SelectorExpr); ok {
+						if ident, ok := sel.X.(*ast.Ident); ok {
+							imports[ident.Name] = fileImports[ident.Name]
+						}
+					}
+					return true
+				})
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						if spec.Name.IsExported() && !declared[spec.Name.Name] {
+							types = append(types, spec.Name.Name)
+						}
+					case *ast.ValueSpec:
+						for _, name := range spec.Names {
+							if !name.IsExported() || declared[name.Name] {
+								continue
+							}
+							if decl.Tok == token.CONST {
+								consts = append(consts, name.Name)
+							} else {
+								vars = append(vars, name.Name)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by adapters_gen.go. DO NOT EDIT.\n\npackage cli\n\nimport (\n")
+	names := make([]string, 0, len(imports))
+	for name := range imports {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return imports[names[i]] < imports[names[j]] })
+	// The standard library is imported first.
+	for _, std := range []bool{true, false} {
+		for _, name := range names {
+			path := imports[name]
+			if strings.Contains(strings.Split(path, "/")[0], ".") == std {
+				continue
+			}
+			if filepath.Base(path) == name {
+				fmt.Fprintf(&b, "\t%q\n", path)
+			} else {
+				fmt.Fprintf(&b, "\t%s %q\n", name, path)
+			}
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "\tv2 %q\n)\n", v2Import)
+
+	for _, name := range types {
+		fmt.Fprintf(&b, "\n// %[1]s is [v2.%[1]s].\ntype %[1]s = v2.%[1]s\n", name)
+	}
+	for _, name := range consts {
+		fmt.Fprintf(&b, "\n// %[1]s is [v2.%[1]s].\nconst %[1]s = v2.%[1]s\n", name)
+	}
+	for _, name := range vars {
+		// Variables that are not errors would not be shared, so they
+		// are declared in settings.go.
+		if !strings.HasPrefix(name, "Err") {
+			log.Fatalf("%s must be declared in settings.go", name)
+		}
+		fmt.Fprintf(&b, "\n// %[1]s is [v2.%[1]s].\nvar %[1]s = v2.%[1]s\n", name)
+	}
+	for _, decl := range funcs {
+		writeFunc(&b, fset, decl)
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = os.WriteFile(output, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// writeFunc writes a function that copies the settings
+// and calls the function of the same name in v2.
+func writeFunc(b *bytes.Buffer, fset *token.FileSet, decl *ast.FuncDecl) {
+	var args []string
+	variadic := false
+	n := 0
+	for _, field := range decl.Type.Params.List {
+		if len(field.Names) == 0 {
+			field.Names = []*ast.Ident{ast.NewIdent("p" + strconv.Itoa(n))}
+		}
+		for _, name := range field.Names {
+			args = append(args, name.Name)
+			n++
+		}
+		_, variadic = field.Type.(*ast.Ellipsis)
+	}
+	call := fmt.Sprintf("v2.%s(%s", decl.Name.Name, strings.Join(args, ", "))
+	if variadic {
+		call += "..."
+	}
+	call += ")"
+	if decl.Type.Results != nil {
+		call = "return " + call
+	}
+
+	var signature bytes.Buffer
+	if err := printer.Fprint(&signature, fset, decl.Type); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(b, "\n// %[1]s calls [v2.%[1]s].\nfunc %[1]s%[2]s {\n\tsyncSettings()\n\t%[3]s\n}\n",
+		decl.Name.Name, strings.TrimPrefix(signature.String(), "func"), call)
+}
+
+// declaredNames returns the identifiers declared by
+// hand in this package, which are not generated.
+func declaredNames(fset *token.FileSet) (map[string]bool, error) {
+	files, err := packageFiles(".")
+	if err != nil {
+		return nil, err
+	}
+	declared := map[string]bool{}
+	for _, filename := range files {
+		if filename == output {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filename, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		for name, obj := range file.Scope.Objects {
+			if obj.Kind != ast.Bad {
+				declared[name] = true
+			}
+		}
+	}
+	return declared, nil
+}
+
+// packageFiles returns the non-test Go files of the package
+// in dir, leaving out files such as this one.
+func packageFiles(dir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, filename := range matches {
+		if strings.HasSuffix(filename, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Contains(src, []byte("//go:build ignore")) {
+			continue
+		}
+		files = append(files, filename)
+	}
+	return files, nil
+}
+
+// importNames maps the names of a file's imports to their paths.
+func importNames(file *ast.File) map[string]string {
+	names := map[string]string{}
+	for _, spec := range file.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		name := filepath.Base(path)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		names[name] = path
+	}
+	return names
+}
@@ -17,36 +17,29 @@ limitations under the License.
 package main
 
 import (
+	"errors"
 	"fmt"
-	"runtime"
+	"os"
+	"time"
 
 	"github.com/alecthomas/kong"
 
-	"github.com/apexlang/cli"
+	"github.com/apexlang/cli/v2"
 )
 
 var version = "edge"
 
 var commands struct {
-	// Install installs a module into the module directory.
-	Install cli.InstallCmd `cmd:"" help:"Install a module."`
-	// Generate generates code driven by a configuration file.
-	Generate cli.GenerateCmd `cmd:"" help:"Generate code from a configuration file."`
-	// Watch watches configuration files for changes and triggers generate.
-	Watch cli.WatchCmd `cmd:"" help:"Watch configuration files for changes and trigger code generation."`
-	// List lists installed modules.
-	List cli.ListCmd `cmd:"" help:"Lists installed modules."`
-	// New creates a new project from a template.
-	New cli.NewCmd `cmd:"" help:"Creates a new project from a template."`
-	// Init initializes an existing project directory from a template.
-	Init cli.InitCmd `cmd:"" help:"Initializes an existing project directory from a template."`
-	// Upgrade reinstalls the base module dependencies.
-	Upgrade cli.UpgradeCmd `cmd:"" help:"Upgrades to the latest base modules dependencies."`
-	// Version prints out the version of this program and runtime info.
-	Version versionCmd `cmd:""`
+	// JSON selects machine-readable output, such as for container probes.
+	JSON bool `name:"json" help:"Print JSON from commands that support it, such as --version, version, probe, spec stats, and install --list-versions."`
+	// VersionFlag prints the version without running a command.
+	VersionFlag cli.VersionFlag `name:"version" help:"Print the version and exit."`
 }
 
 func main() {
+	cli.Version = version
+	// Colors are left out of logs when not run in a terminal.
+	cli.ConfigureOutput()
 	cli.AddDependencies(map[string][]string{
 		"@apexlang/codegen": {
 			"node_modules/@apexlang/codegen",
@@ -54,15 +47,21 @@ func main() {
 			"definitions/@apexlang",
 		},
 	})
-	ctx := kong.Parse(&commands)
+	// Commands are shared with other distributions of the CLI. Flags
+	// not given default to the values in .apexrc.yaml and
+	// ~/.apex/config.yaml.
+	options := append(cli.Commands().Options(), kong.Resolvers(cli.DefaultsResolver()))
+	ctx := kong.Parse(&commands, options...)
+	started := time.Now()
 	// Call the Run() method of the selected parsed command.
-	err := ctx.Run(&cli.Context{})
+	err := ctx.Run(&cli.Context{JSON: commands.JSON})
+	// The command is recorded when an audit log is enabled.
+	cli.RecordAudit(ctx, started, err)
+	// Commands such as ci report which steps failed in the exit code.
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		fmt.Fprintf(os.Stderr, "%s: error: %v\n", ctx.Model.Name, err)
+		os.Exit(exitErr.ExitCode())
+	}
 	ctx.FatalIfErrorf(err)
 }
-
-type versionCmd struct{}
-
-func (c *versionCmd) Run() error {
-	fmt.Printf("apex version %s %s/%s\n", version, runtime.GOOS, runtime.GOARCH)
-	return nil
-}
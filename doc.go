@@ -0,0 +1,36 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cli is version 1 of the Go API of the apex command line tool,
+// which is implemented by github.com/apexlang/cli/v2.
+//
+// The types and constants of this package are aliases of those of v2,
+// so values are shared between the two, and its functions call those of
+// v2. adapters.go is generated from v2 with "go generate", so this
+// package gains what is added to v2 with the same names.
+//
+// Go cannot alias variables, so the variables that embedders set, such
+// as Version and HomeDirName, are declared again in settings.go. The
+// functions of this package copy them to v2 when they have been changed,
+// so set them before calling Commands or another function.
+//
+// # API stability
+//
+// This package follows the policy described in v2 for version 1 of the
+// API, which is reported by APIVersion.
+//
+// Deprecated: Import github.com/apexlang/cli/v2, which has the same API.
+package cli
@@ -17,9 +17,11 @@ limitations under the License.
 package js
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync/atomic"
 
 	"rogchap.com/v8go"
 )
@@ -75,6 +77,46 @@ func (js *JS) Dispose() {
 	js.iso.Dispose()
 }
 
+// InvokeContext invokes a function like Invoke, terminating it
+// when ctx is done and returning ctx's error.
+func (js *JS) InvokeContext(ctx context.Context, function string, args ...interface{}) (interface{}, error) {
+	if ctx.Done() == nil {
+		return js.Invoke(function, args...)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var terminated int32
+	finished := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			atomic.StoreInt32(&terminated, 1)
+			js.iso.TerminateExecution()
+		case <-finished:
+		}
+	}()
+	res, err := js.Invoke(function, args...)
+	close(finished)
+	<-stopped
+	if atomic.LoadInt32(&terminated) == 1 {
+		// The termination may have raced the call completing.
+		js.cancelTermination()
+		return nil, ctx.Err()
+	}
+	return res, err
+}
+
+// cancelTermination clears a termination that did not end a call, so
+// that it does not end the next call on the isolate. v8go does not
+// expose CancelTerminateExecution, but V8 clears a termination once it
+// propagates out of a script, so a script is run to consume it.
+func (js *JS) cancelTermination() {
+	js.ctx.RunScript("undefined", "cancel.js")
+}
+
 func (js *JS) Invoke(function string, args ...interface{}) (interface{}, error) {
 	global := js.ctx.Global()
 	var argList strings.Builder
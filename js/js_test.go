@@ -0,0 +1,47 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package js
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSource = `
+js_exports.add = function(a, b) { return a + b; };
+js_exports.spin = function() { for (;;) {} };
+`
+
+func TestInvokeContextTimeout(t *testing.T) {
+	j, err := Compile(testSource)
+	require.NoError(t, err)
+	defer j.Dispose()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = j.InvokeContext(ctx, "spin")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The isolate is reused after a call is terminated.
+	res, err := j.InvokeContext(context.Background(), "add", 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), res)
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+//go:generate go run adapters_gen.go
+
+import (
+	"sync"
+	"time"
+
+	v2 "github.com/apexlang/cli/v2"
+)
+
+// Variables of v2 that embedders set before running commands. Go cannot
+// alias variables, so these are copies that the functions of this
+// package copy to v2 when they have been changed. Set them before
+// calling Commands or another function of this package, or set the
+// variables of v2 directly.
+var (
+	// Version is [v2.Version].
+	Version = v2.Version
+	// HomeDirName is [v2.HomeDirName].
+	HomeDirName = v2.HomeDirName
+	// MaxAstyleSourceSize is [v2.MaxAstyleSourceSize].
+	MaxAstyleSourceSize = v2.MaxAstyleSourceSize
+	// MaxConfigSize is [v2.MaxConfigSize].
+	MaxConfigSize = v2.MaxConfigSize
+	// MaxSpecSize is [v2.MaxSpecSize].
+	MaxSpecSize = v2.MaxSpecSize
+	// MaxYAMLNodes is [v2.MaxYAMLNodes].
+	MaxYAMLNodes = v2.MaxYAMLNodes
+	// MaxYAMLDepth is [v2.MaxYAMLDepth].
+	MaxYAMLDepth = v2.MaxYAMLDepth
+	// ScriptTimeout is [v2.ScriptTimeout].
+	ScriptTimeout = v2.ScriptTimeout
+)
+
+// settings are the values last copied to v2, so that
+// variables only set in v2 are not overwritten.
+var settings = struct {
+	sync.Mutex
+	version             string
+	homeDirName         string
+	maxAstyleSourceSize int
+	maxConfigSize       int64
+	maxSpecSize         int64
+	maxYAMLNodes        int
+	maxYAMLDepth        int
+	scriptTimeout       time.Duration
+}{
+	version:             Version,
+	homeDirName:         HomeDirName,
+	maxAstyleSourceSize: MaxAstyleSourceSize,
+	maxConfigSize:       MaxConfigSize,
+	maxSpecSize:         MaxSpecSize,
+	maxYAMLNodes:        MaxYAMLNodes,
+	maxYAMLDepth:        MaxYAMLDepth,
+	scriptTimeout:       ScriptTimeout,
+}
+
+// syncSettings copies the variables changed in this package to v2.
+func syncSettings() {
+	settings.Lock()
+	defer settings.Unlock()
+	copySetting(&v2.Version, Version, &settings.version)
+	copySetting(&v2.HomeDirName, HomeDirName, &settings.homeDirName)
+	copySetting(&v2.MaxAstyleSourceSize, MaxAstyleSourceSize, &settings.maxAstyleSourceSize)
+	copySetting(&v2.MaxConfigSize, MaxConfigSize, &settings.maxConfigSize)
+	copySetting(&v2.MaxSpecSize, MaxSpecSize, &settings.maxSpecSize)
+	copySetting(&v2.MaxYAMLNodes, MaxYAMLNodes, &settings.maxYAMLNodes)
+	copySetting(&v2.MaxYAMLDepth, MaxYAMLDepth, &settings.maxYAMLDepth)
+	copySetting(&v2.ScriptTimeout, ScriptTimeout, &settings.scriptTimeout)
+}
+
+func copySetting[T comparable](dst *T, value T, copied *T) {
+	if value != *copied {
+		*dst = value
+		*copied = value
+	}
+}
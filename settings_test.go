@@ -0,0 +1,54 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	v2 "github.com/apexlang/cli/v2"
+)
+
+func TestSyncSettings(t *testing.T) {
+	homeDirName, maxSpecSize := v2.HomeDirName, v2.MaxSpecSize
+	defer func() {
+		HomeDirName, v2.HomeDirName = homeDirName, homeDirName
+		v2.MaxSpecSize = maxSpecSize
+		syncSettings()
+	}()
+
+	// Variables changed in this package are copied by its functions.
+	HomeDirName = ".iota"
+	AstylePresets()
+	assert.Equal(t, ".iota", v2.HomeDirName)
+
+	// Variables only changed in v2 are kept.
+	v2.MaxSpecSize = 1 << 10
+	AstylePresets()
+	assert.Equal(t, int64(1<<10), v2.MaxSpecSize)
+	assert.Equal(t, maxSpecSize, MaxSpecSize)
+}
+
+func TestAdapters(t *testing.T) {
+	// Types are aliases, so values are shared with v2.
+	var config *v2.Config = &Config{}
+	assert.NotNil(t, config)
+	assert.Equal(t, v2.ProjectConfigFile, ProjectConfigFile)
+	assert.Equal(t, v2.ErrInputTooLarge, ErrInputTooLarge)
+	assert.Equal(t, v2.AstylePresets(), AstylePresets())
+}
@@ -0,0 +1,258 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	"gopkg.in/yaml.v3"
+)
+
+// moduleAliases are short names for modules registered by
+// applications embedding the CLI.
+var moduleAliases = map[string]string{}
+
+// AddModuleAliases registers short names for modules. User and
+// project aliases take precedence over these.
+func AddModuleAliases(aliases map[string]string) {
+	for alias, module := range aliases {
+		moduleAliases[alias] = module
+	}
+}
+
+// UserConfig is the user-level configuration stored in ~/.apex/config.yaml.
+type UserConfig struct {
+	Aliases map[string]string `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	// Defaults and Profiles are flag defaults that take precedence
+	// over those of a project, laid out as in ProjectConfig.
+	Defaults map[string]interface{}            `json:"defaults,omitempty" yaml:"defaults,omitempty"`
+	Profiles map[string]map[string]interface{} `json:"profiles,omitempty" yaml:"profiles,omitempty"`
+	// CABundle is a PEM file of extra root certificates to trust.
+	CABundle string `json:"caBundle,omitempty" yaml:"caBundle,omitempty"`
+	// Scopes are the registries of scoped modules, taking
+	// precedence over those of a project.
+	Scopes map[string]string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+	// Mirrors are tried before those of a project.
+	Mirrors []string `json:"mirrors,omitempty" yaml:"mirrors,omitempty"`
+	// Audit records every command in ~/.apex/audit.log.
+	Audit bool `json:"audit,omitempty" yaml:"audit,omitempty"`
+}
+
+func userConfigPath() (string, error) {
+	homeDir, err := ensureHomeDirectory()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, "config.yaml"), nil
+}
+
+// readUserConfig returns the user configuration, which
+// is empty if the file does not exist.
+func readUserConfig() (*UserConfig, error) {
+	var config UserConfig
+	path, err := userConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &config, nil
+		}
+		return nil, err
+	}
+	if err = yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+func (c *UserConfig) write() error {
+	path, err := userConfigPath()
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// projectAliases returns the aliases of the apex.yaml in the current
+// directory, merging those of each of its configurations. It is nil
+// when there is no readable configuration.
+func projectAliases() map[string]string {
+	data, err := readLocalFile("apex.yaml", MaxConfigSize)
+	if err != nil {
+		return nil
+	}
+	var aliases map[string]string
+	for _, doc := range strings.Split(string(data), "---") {
+		var config struct {
+			Aliases map[string]string `yaml:"aliases"`
+		}
+		if checkYAML("apex.yaml", []byte(doc)) != nil || yaml.Unmarshal([]byte(doc), &config) != nil {
+			return nil
+		}
+		for alias, module := range config.Aliases {
+			if aliases == nil {
+				aliases = map[string]string{}
+			}
+			aliases[alias] = module
+		}
+	}
+	return aliases
+}
+
+// resolveModuleAlias returns the module for an alias, checking project
+// aliases, then user aliases, then aliases registered in code. Aliases
+// may refer to other aliases. Unknown names are returned unchanged.
+func resolveModuleAlias(name string, projectAliases map[string]string) string {
+	var userAliases map[string]string
+	if config, err := readUserConfig(); err == nil {
+		userAliases = config.Aliases
+	}
+
+	seen := map[string]struct{}{}
+	for {
+		if _, ok := seen[name]; ok {
+			return name
+		}
+		seen[name] = struct{}{}
+
+		if module, ok := projectAliases[name]; ok {
+			name = module
+		} else if module, ok := userAliases[name]; ok {
+			name = module
+		} else if module, ok := moduleAliases[name]; ok {
+			name = module
+		} else {
+			return name
+		}
+	}
+}
+
+type AliasCmd struct {
+	List   AliasListCmd   `cmd:"" help:"Lists module aliases."`
+	Set    AliasSetCmd    `cmd:"" help:"Sets a module alias."`
+	Remove AliasRemoveCmd `cmd:"" help:"Removes a module alias."`
+}
+
+type AliasListCmd struct{}
+
+type AliasSetCmd struct {
+	Alias  string `arg:"" help:"The alias name."`
+	Module string `arg:"" help:"The module the alias refers to."`
+}
+
+type AliasRemoveCmd struct {
+	Alias string `arg:"" help:"The alias name."`
+}
+
+func (c *AliasListCmd) Run(ctx *Context) error {
+	config, err := readUserConfig()
+	if err != nil {
+		return err
+	}
+
+	type alias struct {
+		name, module, source string
+	}
+	var aliases []alias
+	project := projectAliases()
+	for name, module := range moduleAliases {
+		_, user := config.Aliases[name]
+		if _, overridden := project[name]; !overridden && !user {
+			aliases = append(aliases, alias{name, module, "built-in"})
+		}
+	}
+	for name, module := range config.Aliases {
+		if _, overridden := project[name]; !overridden {
+			aliases = append(aliases, alias{name, module, "user"})
+		}
+	}
+	for name, module := range project {
+		aliases = append(aliases, alias{name, module, "project"})
+	}
+	sort.Slice(aliases, func(i, j int) bool {
+		return aliases[i].name < aliases[j].name
+	})
+
+	t := table.NewWriter()
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{
+			Name:   "Alias",
+			Colors: text.Colors{text.FgGreen},
+		},
+		{
+			Name:   "Module",
+			Colors: text.Colors{text.FgCyan},
+		},
+	})
+	t.AppendHeader(table.Row{"Alias", "Module", "Source"})
+	for _, a := range aliases {
+		t.AppendRow(table.Row{a.name, a.module, a.source})
+	}
+	fmt.Println(t.Render())
+
+	return nil
+}
+
+func (c *AliasSetCmd) Run(ctx *Context) error {
+	if c.Alias == "" || c.Module == "" {
+		return errors.New("alias and module are required")
+	}
+	config, err := readUserConfig()
+	if err != nil {
+		return err
+	}
+	if config.Aliases == nil {
+		config.Aliases = map[string]string{}
+	}
+	config.Aliases[c.Alias] = c.Module
+	if err = config.write(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Set alias %s to %s\n", c.Alias, c.Module)
+	return nil
+}
+
+func (c *AliasRemoveCmd) Run(ctx *Context) error {
+	config, err := readUserConfig()
+	if err != nil {
+		return err
+	}
+	if _, ok := config.Aliases[c.Alias]; !ok {
+		return fmt.Errorf("alias %s is not set", c.Alias)
+	}
+	delete(config.Aliases, c.Alias)
+	if err = config.write(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed alias %s\n", c.Alias)
+	return nil
+}
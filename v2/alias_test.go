@@ -0,0 +1,70 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectAliases(t *testing.T) {
+	withProjectConfig(t, "")
+	assert.Nil(t, projectAliases())
+
+	require.NoError(t, os.WriteFile("apex.yaml", []byte(`spec: a.apexlang
+aliases:
+  core: "@project/core"
+---
+spec: b.apexlang
+aliases:
+  codegen: "@project/codegen"
+`), 0644))
+	assert.Equal(t, map[string]string{
+		"core":    "@project/core",
+		"codegen": "@project/codegen",
+	}, projectAliases())
+}
+
+func TestResolveModuleAlias(t *testing.T) {
+	withProjectConfig(t, "")
+	home := os.Getenv(HomeEnv)
+	require.NoError(t, os.MkdirAll(home, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(home, "config.yaml"), []byte(`aliases:
+  core: "@user/core"
+  basic: "@user/basic"
+`), 0644))
+	AddModuleAliases(map[string]string{"basic": "@built-in/basic", "go": "@built-in/go"})
+	t.Cleanup(func() {
+		delete(moduleAliases, "basic")
+		delete(moduleAliases, "go")
+	})
+	project := map[string]string{"core": "@project/core", "latest": "core"}
+
+	for alias, expected := range map[string]string{
+		"core":         "@project/core",
+		"latest":       "@project/core",
+		"basic":        "@user/basic",
+		"go":           "@built-in/go",
+		"@apexlang/go": "@apexlang/go",
+	} {
+		assert.Equal(t, expected, resolveModuleAlias(alias, project), alias)
+	}
+}
@@ -0,0 +1,215 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/kong"
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectConfigFile is the per-repository configuration, found in
+// the working directory or its parents up to the repository root.
+const ProjectConfigFile = ".apexrc.yaml"
+
+// ProfileEnv selects a profile of flag defaults, such as ci.
+const ProfileEnv = "APEX_PROFILE"
+
+// ProjectConfig holds CLI defaults committed to a repository so
+// everyone working in it gets the same behavior:
+//
+//	defaults:
+//	  summary: json       # any command with a --summary flag
+//	  install:
+//	    concurrency: 8    # only install's --concurrency
+//	profiles:
+//	  ci:
+//	    generate:
+//	      on-format-error: fail
+//	registry: https://npm.example.com
+//	scopes:
+//	  "@myorg": https://npm.internal.example.com
+//	mirrors:
+//	  - https://registry.npmmirror.com
+//	pins:
+//	  "@apexlang/codegen": 0.1.2
+//	caBundle: certs/internal-ca.pem
+//	local: true
+//	audit: true
+type ProjectConfig struct {
+	// Defaults are flag values by flag name, either for every command
+	// with the flag or nested under a command name such as install
+	// or "bundle export".
+	Defaults map[string]interface{} `json:"defaults,omitempty" yaml:"defaults,omitempty"`
+	// Profiles are named sets of defaults applied over Defaults
+	// when selected with APEX_PROFILE.
+	Profiles map[string]map[string]interface{} `json:"profiles,omitempty" yaml:"profiles,omitempty"`
+	// Registry is the NPM registry used when neither NPM_REGISTRY
+	// nor an .npmrc sets one. APEX_NPM_TOKEN is not sent to it, so
+	// it needs credentials in .npmrc or from apex login.
+	Registry string `json:"registry,omitempty" yaml:"registry,omitempty"`
+	// Scopes are the registries of scoped modules, such as @myorg,
+	// used when .npmrc does not set one for the scope. As with
+	// Registry, APEX_NPM_TOKEN is not sent to them.
+	Scopes map[string]string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+	// Mirrors are registries tried in order when the default
+	// registry fails to serve metadata or a tarball.
+	Mirrors []string `json:"mirrors,omitempty" yaml:"mirrors,omitempty"`
+	// Pins are the releases installed for modules when none is given.
+	Pins map[string]string `json:"pins,omitempty" yaml:"pins,omitempty"`
+	// CABundle is a PEM file of extra root certificates to trust,
+	// relative to the directory of .apexrc.yaml.
+	CABundle string `json:"caBundle,omitempty" yaml:"caBundle,omitempty"`
+	// Local installs and uninstalls modules in the project's .apex
+	// directory, as with --project, so it does not share module
+	// versions with other projects.
+	Local bool `json:"local,omitempty" yaml:"local,omitempty"`
+	// Audit records commands run in the project in .apex/audit.log.
+	Audit bool `json:"audit,omitempty" yaml:"audit,omitempty"`
+
+	// dir is the directory .apexrc.yaml was read from.
+	dir string
+}
+
+var (
+	projectConfig     *ProjectConfig
+	projectConfigErr  error
+	projectConfigOnce sync.Once
+)
+
+// readProjectConfig returns the nearest .apexrc.yaml, which is
+// empty if there is none.
+func readProjectConfig() (*ProjectConfig, error) {
+	projectConfigOnce.Do(func() {
+		projectConfig = &ProjectConfig{}
+		path := findProjectFile(ProjectConfigFile, ProjectConfigFile)
+		if path == "" {
+			return
+		}
+		data, err := readLocalFile(path, MaxConfigSize)
+		if err != nil {
+			projectConfigErr = err
+			return
+		}
+		if err = yaml.Unmarshal(data, projectConfig); err != nil {
+			projectConfigErr = fmt.Errorf("could not parse %s: %w", path, err)
+		}
+		projectConfig.dir = filepath.Dir(path)
+	})
+	return projectConfig, projectConfigErr
+}
+
+// projectPin returns the release pinned for a module in .apexrc.yaml.
+func projectPin(location string) string {
+	config, err := readProjectConfig()
+	if err != nil {
+		return ""
+	}
+	return config.Pins[location]
+}
+
+// projectLocal reports whether .apexrc.yaml sets local, so modules
+// are installed in the project's .apex directory.
+func projectLocal() bool {
+	config, err := readProjectConfig()
+	return err == nil && config.Local
+}
+
+// DefaultsResolver returns a kong resolver for flags that are not given
+// on the command line. Values come from the defaults of .apexrc.yaml,
+// then ~/.apex/config.yaml, each followed by the profile selected with
+// APEX_PROFILE, with later values taking precedence. Defaults in struct
+// tags apply when none of these set a flag.
+func DefaultsResolver() kong.Resolver {
+	var (
+		once    sync.Once
+		layers  []map[string]interface{}
+		loadErr error
+	)
+	load := func() {
+		profile := os.Getenv(ProfileEnv)
+		project, err := readProjectConfig()
+		if err != nil {
+			loadErr = err
+			return
+		}
+		user, err := readUserConfig()
+		if err != nil {
+			loadErr = err
+			return
+		}
+		layers = []map[string]interface{}{
+			project.Defaults, project.Profiles[profile],
+			user.Defaults, user.Profiles[profile],
+		}
+	}
+
+	return kong.ResolverFunc(func(ctx *kong.Context, parent *kong.Path, flag *kong.Flag) (interface{}, error) {
+		if once.Do(load); loadErr != nil {
+			return nil, loadErr
+		}
+
+		command := commandName(parent.Node())
+		var selected interface{}
+		for _, layer := range layers {
+			if value, ok := layer[flag.Name]; ok {
+				if _, section := value.(map[string]interface{}); !section {
+					selected = value
+				}
+			}
+			if commandDefaults, ok := layer[command].(map[string]interface{}); ok && command != "" {
+				if value, ok := commandDefaults[flag.Name]; ok {
+					selected = value
+				}
+			}
+		}
+		return flagValue(selected), nil
+	})
+}
+
+// commandName returns the names of the commands leading to node,
+// such as "bundle export", or an empty string for the application.
+func commandName(node *kong.Node) string {
+	var names []string
+	for ; node != nil; node = node.Parent {
+		if node.Type == kong.CommandNode {
+			names = append([]string{node.Name}, names...)
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+// flagValue converts a YAML value to the string kong parses for
+// a flag, joining lists with commas. Maps are not supported.
+func flagValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, map[string]interface{}:
+		return nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value)
+}
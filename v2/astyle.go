@@ -0,0 +1,346 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+//go:embed astyle.wasm
+var astyleWasm []byte
+
+// MaxAstyleSourceSize is the largest part of a source, in bytes, that
+// Astyle will format at once. Sources are formatted in parts that end
+// between top-level declarations, so this limits the size of a single
+// declaration, such as a namespace or class, rather than the source.
+// Each part needs up to astyleMemoryFactor times its size in memory,
+// which WebAssembly limits to 4GiB.
+var MaxAstyleSourceSize = 128 << 20
+
+// ErrAstyleSourceTooLarge is returned when part of the source exceeds
+// MaxAstyleSourceSize or Astyle could not allocate memory for it.
+var ErrAstyleSourceTooLarge = errors.New("source is too large for astyle")
+
+// Astyle formats source using the given options.
+func Astyle(source, options string) (string, error) {
+	var b strings.Builder
+	b.Grow(len(source))
+	if err := AstyleStream(&b, strings.NewReader(source), options); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// AstyleStream formats the source read from r and writes the result to w.
+// The source is read and formatted in parts that end between top-level
+// declarations, and each formatted part is copied directly from
+// WebAssembly memory to w, so very large files are never held whole.
+func AstyleStream(w io.Writer, r io.Reader, options string) error {
+	if err := ValidateAstyleOptions(options); err != nil {
+		return err
+	}
+	optionsUTF8 := []byte(options)
+
+	ctx := context.Background()
+	pool, err := sharedAstylePool(ctx)
+	if err != nil {
+		return err
+	}
+	return astyleChunks(r, func(chunk []byte) error {
+		return pool.format(ctx, w, chunk, optionsUTF8)
+	})
+}
+
+// format formats one part of a source with an instance
+// whose memory is large enough for it.
+func (p *astylePool) format(ctx context.Context, w io.Writer, sourceUTF8, optionsUTF8 []byte) error {
+	bufferSize := uint64(len(sourceUTF8)) + 1 + uint64(len(optionsUTF8)) + 1 + 4
+	if bufferSize > math.MaxUint32 {
+		return fmt.Errorf("%w: %d bytes", ErrAstyleSourceTooLarge, len(sourceUTF8))
+	}
+	inst, err := p.acquire(ctx, p.memoryPages(len(sourceUTF8)))
+	if err != nil {
+		return err
+	}
+	err = inst.format(ctx, w, sourceUTF8, optionsUTF8, uint32(bufferSize))
+	// Instances that failed may have corrupt heaps so they are not reused.
+	p.release(ctx, inst, err == nil)
+	return err
+}
+
+// astylePool shares a single wazero runtime and compiled module across
+// goroutines. Each caller borrows its own module instance so concurrent
+// formatting never touches the same linear memory.
+//
+// Astyle does not grow its memory, which the module fixes at 16MiB, so
+// larger sources are formatted by instances of copies of the module
+// declaring more memory. Only instances with the module's own memory
+// are kept for reuse.
+type astylePool struct {
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	// pages is the memory of the compiled module, in 64KiB pages.
+	pages uint32
+
+	mu    sync.Mutex
+	idle  []*astyleInstance
+	count uint64
+
+	// larger are the copies of the module compiled with more memory.
+	largerMu sync.Mutex
+	larger   map[uint32]wazero.CompiledModule
+}
+
+type astyleInstance struct {
+	module  api.Module
+	pages   uint32
+	alloc   api.Function
+	free    api.Function
+	wastyle api.Function
+}
+
+const (
+	wasmPageSize = 64 << 10
+	maxWasmPages = 65536
+	// astyleMemoryFactor is how many times the size of a source Astyle
+	// is given in memory for its copies of the source and result.
+	astyleMemoryFactor = 32
+)
+
+var (
+	astylePoolOnce sync.Once
+	astylePoolErr  error
+	astyleShared   *astylePool
+)
+
+func sharedAstylePool(ctx context.Context) (*astylePool, error) {
+	astylePoolOnce.Do(func() {
+		rc := wazero.NewRuntimeConfig().WithCoreFeatures(api.CoreFeaturesV2)
+		rt := wazero.NewRuntimeWithConfig(ctx, rc)
+		if _, err := wasi_snapshot_preview1.Instantiate(ctx, rt); err != nil {
+			astylePoolErr = err
+			return
+		}
+		compiled, err := rt.CompileModule(ctx, astyleWasm)
+		if err != nil {
+			astylePoolErr = err
+			return
+		}
+		memory, ok := compiled.ExportedMemories()["memory"]
+		if !ok {
+			astylePoolErr = errors.New("astyle does not export its memory")
+			return
+		}
+		astyleShared = &astylePool{
+			runtime:  rt,
+			compiled: compiled,
+			pages:    memory.Min(),
+			larger:   map[uint32]wazero.CompiledModule{},
+		}
+	})
+	return astyleShared, astylePoolErr
+}
+
+// memoryPages returns the memory to format size bytes with, doubling
+// the module's own until it is astyleMemoryFactor times the size.
+func (p *astylePool) memoryPages(size int) uint32 {
+	need := uint64(size) * astyleMemoryFactor
+	pages := p.pages
+	for uint64(pages)*wasmPageSize < need && pages < maxWasmPages {
+		pages *= 2
+	}
+	if pages > maxWasmPages {
+		pages = maxWasmPages
+	}
+	return pages
+}
+
+// compiledWith returns the module compiled with the given memory.
+func (p *astylePool) compiledWith(ctx context.Context, pages uint32) (wazero.CompiledModule, error) {
+	if pages == p.pages {
+		return p.compiled, nil
+	}
+	p.largerMu.Lock()
+	defer p.largerMu.Unlock()
+	if compiled, ok := p.larger[pages]; ok {
+		return compiled, nil
+	}
+	module, err := wasmWithMemory(astyleWasm, pages)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := p.runtime.CompileModule(ctx, module)
+	if err != nil {
+		return nil, err
+	}
+	p.larger[pages] = compiled
+	return compiled, nil
+}
+
+func (p *astylePool) acquire(ctx context.Context, pages uint32) (*astyleInstance, error) {
+	p.mu.Lock()
+	if n := len(p.idle); n > 0 && pages == p.pages {
+		inst := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return inst, nil
+	}
+	p.count++
+	name := fmt.Sprintf("astyle-%d", p.count)
+	p.mu.Unlock()
+
+	compiled, err := p.compiledWith(ctx, pages)
+	if err != nil {
+		return nil, err
+	}
+
+	config := wazero.NewModuleConfig().
+		WithStartFunctions("_initialize").
+		WithStdin(os.Stdin).
+		WithStdout(os.Stdout).
+		WithStderr(os.Stderr).
+		WithSysWalltime().
+		WithSysNanotime()
+
+	module, err := p.runtime.InstantiateModule(ctx, compiled, config.WithName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	inst := &astyleInstance{
+		module:  module,
+		pages:   pages,
+		alloc:   module.ExportedFunction("alloc_buffer"),
+		free:    module.ExportedFunction("free_buffer"),
+		wastyle: module.ExportedFunction("wastyle"),
+	}
+	if inst.alloc == nil || inst.free == nil || inst.wastyle == nil {
+		module.Close(ctx)
+		return nil, errors.New("missing exported function alloc_buffer, free_buffer, or wastyle")
+	}
+
+	return inst, nil
+}
+
+func (p *astylePool) release(ctx context.Context, inst *astyleInstance, healthy bool) {
+	if !healthy || inst.pages != p.pages {
+		inst.module.Close(ctx)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.idle) >= runtime.GOMAXPROCS(0) {
+		inst.module.Close(ctx)
+		return
+	}
+	p.idle = append(p.idle, inst)
+}
+
+func (inst *astyleInstance) format(ctx context.Context, w io.Writer, sourceUTF8, optionsUTF8 []byte, bufferSize uint32) error {
+	res, err := inst.alloc.Call(ctx, uint64(bufferSize))
+	if err != nil {
+		return fmt.Errorf("astyle could not allocate %d bytes: %w", bufferSize, err)
+	}
+	bufferPointer := uint32(res[0])
+	if bufferPointer == 0 {
+		return fmt.Errorf("%w: astyle could not allocate %d bytes", ErrAstyleSourceTooLarge, bufferSize)
+	}
+	defer inst.free.Call(ctx, uint64(bufferPointer))
+
+	mem := inst.module.Memory()
+
+	resultPointer := bufferPointer
+	sourcePointer := resultPointer + 4
+	optionsPointer := sourcePointer + uint32(len(sourceUTF8)) + 1
+
+	if !mem.Write(ctx, sourcePointer, sourceUTF8) ||
+		!mem.WriteByte(ctx, sourcePointer+uint32(len(sourceUTF8)), 0) ||
+		!mem.Write(ctx, optionsPointer, optionsUTF8) ||
+		!mem.WriteByte(ctx, optionsPointer+uint32(len(optionsUTF8)), 0) {
+		return errors.New("could not write source to astyle memory")
+	}
+
+	result, err := inst.wastyle.Call(ctx,
+		uint64(sourcePointer), uint64(optionsPointer), uint64(resultPointer))
+	if err != nil {
+		return fmt.Errorf("astyle failed: %w", err)
+	}
+	success := result[0] == 1
+
+	formattedPointer, ok := mem.ReadUint32Le(ctx, resultPointer)
+	if !ok {
+		return errors.New("could not read result pointer")
+	}
+	if formattedPointer == 0 {
+		return fmt.Errorf("%w: astyle could not allocate its result", ErrAstyleSourceTooLarge)
+	}
+	defer inst.free.Call(ctx, uint64(formattedPointer))
+
+	resultBuf, ok := mem.Read(ctx, formattedPointer, mem.Size(ctx)-formattedPointer)
+	if !ok {
+		return errors.New("could not read formatted source")
+	}
+
+	end := bytes.IndexByte(resultBuf, 0)
+	if end == -1 {
+		return errors.New("formatted source is not terminated")
+	}
+	formattedBytes := resultBuf[:end]
+
+	if !success {
+		return errors.New(string(formattedBytes))
+	}
+
+	_, err = w.Write(formattedBytes)
+	return err
+}
+
+// wasmWithMemory returns a copy of a WebAssembly module whose memory
+// is the given number of pages instead of what the module declares.
+func wasmWithMemory(module []byte, pages uint32) ([]byte, error) {
+	const header = 8 // The magic number and version.
+	if len(module) < header {
+		return nil, errors.New("invalid WebAssembly module")
+	}
+	out := append(make([]byte, 0, len(module)+8), module[:header]...)
+	for b := module[header:]; len(b) > 0; {
+		id := b[0]
+		size, n := binary.Uvarint(b[1:])
+		if n <= 0 || uint64(len(b)-1-n) < size {
+			return nil, errors.New("invalid WebAssembly section")
+		}
+		content := b[1+n : 1+n+int(size)]
+		b = b[1+n+int(size):]
+		if id == 5 { // The memory section.
+			if count, n := binary.Uvarint(content); n <= 0 || count != 1 {
+				return nil, errors.New("WebAssembly module does not have one memory")
+			}
+			// One memory, with a maximum, of exactly pages.
+			content = appendUvarint([]byte{1, 1}, uint64(pages))
+			content = appendUvarint(content, uint64(pages))
+		}
+		out = append(out, id)
+		out = appendUvarint(out, uint64(len(content)))
+		out = append(out, content...)
+	}
+	return out, nil
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
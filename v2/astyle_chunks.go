@@ -0,0 +1,232 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// astyleChunkSize is the size at which sources are split into chunks
+// that Astyle formats one at a time.
+var astyleChunkSize = 256 << 10
+
+// astyleChunks reads the source from r in chunks that end between
+// top-level declarations and calls fn with each. Chunks grow past
+// astyleChunkSize until they can end, such as for a namespace or class
+// holding the whole source, and fail when larger than MaxAstyleSourceSize.
+// The chunk passed to fn is reused once it returns.
+func astyleChunks(r io.Reader, fn func(chunk []byte) error) error {
+	var (
+		br          = bufio.NewReader(r)
+		splitter    astyleSplitter
+		chunk, line []byte
+		// split is set when chunk ends between declarations.
+		split bool
+	)
+	flush := func() error {
+		err := fn(chunk)
+		chunk = chunk[:0]
+		return err
+	}
+	for {
+		piece, err := br.ReadSlice('\n')
+		line = append(line, piece...)
+		if len(chunk)+len(line) > MaxAstyleSourceSize {
+			if !split || len(chunk) == 0 || len(line) > MaxAstyleSourceSize {
+				return fmt.Errorf("%w: a declaration is larger than the limit of %d bytes",
+					ErrAstyleSourceTooLarge, MaxAstyleSourceSize)
+			}
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		if len(line) > 0 {
+			split = splitter.scan(line)
+			chunk = append(chunk, line...)
+			line = line[:0]
+			if split && len(chunk) >= astyleChunkSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		if err == io.EOF {
+			if len(chunk) == 0 {
+				return nil
+			}
+			return flush()
+		}
+	}
+}
+
+// astyleSplitter finds where C-family sources can be split so that
+// formatting the parts apart gives the same result as formatting them
+// together: after a line ending a top-level statement or block, outside
+// of comments, strings, and conditional preprocessor sections.
+type astyleSplitter struct {
+	braces, parens int
+	// ifs is the depth of #if sections.
+	ifs int
+	// comment is set within a block comment, directive within a
+	// preprocessor directive continued with a backslash, and raw to the
+	// end of a raw string literal spanning lines.
+	comment   bool
+	directive bool
+	raw       []byte
+	// last is the last byte of code, outside of comments and
+	// preprocessor directives.
+	last byte
+}
+
+// scan reads a line, including its line ending, and reports
+// whether a chunk can end after it.
+func (s *astyleSplitter) scan(line []byte) bool {
+	i := 0
+	switch {
+	case s.raw != nil:
+		end := bytes.Index(line, s.raw)
+		if end == -1 {
+			return false
+		}
+		i = end + len(s.raw)
+		s.raw = nil
+		s.last = '"'
+	case s.directive:
+		s.directive = continued(line)
+		return s.end()
+	default:
+		trimmed := bytes.TrimLeft(line, " \t")
+		if !s.comment && len(trimmed) > 0 && trimmed[0] == '#' {
+			s.preprocessor(trimmed[1:])
+			s.directive = continued(line)
+			return s.end()
+		}
+	}
+
+	for ; i < len(line); i++ {
+		if s.comment {
+			end := bytes.Index(line[i:], []byte("*/"))
+			if end == -1 {
+				return false
+			}
+			i += end + 1
+			s.comment = false
+			continue
+		}
+
+		c := line[i]
+		switch c {
+		case ' ', '\t', '\r', '\n', '\f', '\v':
+			continue
+		case '/':
+			if i+1 < len(line) && line[i+1] == '/' {
+				return s.end()
+			}
+			if i+1 < len(line) && line[i+1] == '*' {
+				s.comment = true
+				i++
+				continue
+			}
+		case '"':
+			if i > 0 && line[i-1] == 'R' {
+				// A raw string such as R"delim(...)delim".
+				open := bytes.IndexByte(line[i:], '(')
+				if open == -1 {
+					return false
+				}
+				s.raw = append(append([]byte{')'}, line[i+1:i+open]...), '"')
+				end := bytes.Index(line[i+open:], s.raw)
+				if end == -1 {
+					return false
+				}
+				i += open + end + len(s.raw) - 1
+				s.raw = nil
+			} else {
+				i = skipQuoted(line, i)
+			}
+		case '\'':
+			// Digit separators, as in 1'000, are not character literals.
+			if i == 0 || !isDigit(line[i-1]) {
+				i = skipQuoted(line, i)
+			}
+		case '{':
+			s.braces++
+		case '}':
+			s.braces--
+		case '(', '[':
+			s.parens++
+		case ')', ']':
+			s.parens--
+		}
+		s.last = c
+	}
+	return s.end()
+}
+
+// end reports whether a chunk can end after the line just scanned.
+func (s *astyleSplitter) end() bool {
+	return s.braces == 0 && s.parens == 0 && s.ifs == 0 &&
+		!s.comment && !s.directive && s.raw == nil &&
+		(s.last == 0 || s.last == ';' || s.last == '}')
+}
+
+// preprocessor tracks the depth of conditional
+// sections from a directive following the #.
+func (s *astyleSplitter) preprocessor(directive []byte) {
+	directive = bytes.TrimLeft(directive, " \t")
+	switch {
+	case bytes.HasPrefix(directive, []byte("if")):
+		s.ifs++
+	case bytes.HasPrefix(directive, []byte("endif")):
+		s.ifs--
+	}
+}
+
+// skipQuoted returns the index of the quote closing the string or
+// character literal opened at i, or the end of the line.
+func skipQuoted(line []byte, i int) int {
+	quote := line[i]
+	for i++; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case quote:
+			return i
+		}
+	}
+	return len(line) - 1
+}
+
+// continued reports whether a line ends with a backslash
+// that continues it onto the next line.
+func continued(line []byte) bool {
+	return bytes.HasSuffix(bytes.TrimRight(line, "\r\n"), []byte("\\"))
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// splitAstyle returns the chunks of source when split at every
+// top-level declaration.
+func splitAstyle(t *testing.T, source string) []string {
+	size := astyleChunkSize
+	defer func() { astyleChunkSize = size }()
+	astyleChunkSize = 1
+
+	var chunks []string
+	require.NoError(t, astyleChunks(strings.NewReader(source), func(chunk []byte) error {
+		chunks = append(chunks, string(chunk))
+		return nil
+	}))
+	return chunks
+}
+
+func TestAstyleChunks(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+	}{
+		{"declarations", []string{"int a;\n", "int b;"}},
+		{"functions", []string{"int f() {\n  return 1;\n}\n", "int g() { return 2; }\n"}},
+		{"leading comments and directives", []string{"// comment\n", "#include <stdio.h>\n", "int a;\n"}},
+		{"namespace", []string{"namespace a {\nint b;\n}\n", "int c;\n"}},
+		{"trailing comment", []string{"int a; // a\n", "int b; /* b */\n"}},
+		{"block comment", []string{"int a; /* {\n} */\n", "int b;\n"}},
+		{"statement spanning lines", []string{"int a = f(1,\n  2);\n", "int b;\n"}},
+		{"declaration spanning lines", []string{"template <typename T>\nstruct S {};\n", "int a;\n"}},
+		{"brace in string", []string{"const char *a = \"{\";\n", "char b = '{';\n", "char c = '\\'';\n"}},
+		{"raw string", []string{"auto a = R\"x(\n}\n)x\";\n", "int b;\n"}},
+		{"digit separators", []string{"int a = 1'000; void f() {\n}\n", "int b;\n"}},
+		{"conditional sections", []string{"#ifdef A\nint a;\n#else\nint b;\n#endif\n", "int c;\n"}},
+		{"continued directive", []string{"#define F(x) \\\n  int x;\n", "int a;\n"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.chunks, splitAstyle(t, strings.Join(tc.chunks, "")))
+		})
+	}
+}
+
+func TestAstyleChunksFormatAlike(t *testing.T) {
+	source := `#include <cstdio>
+#define MAX(a,b) \
+  ((a)>(b)?(a):(b))
+
+// add adds.
+int add(int a,int b){return a+b;}
+/* A struct
+ * with fields. */
+struct point{int x;int y;};
+#if defined(DEBUG)
+static int debug=1;
+#endif
+template<typename T> T twice(T v){
+if(v>0){return v*2;}else{return -v*2;}
+}
+const char *braces="{}";
+class shape{public:virtual ~shape(){}virtual int area()=0;};
+int main(){for(int i=0;i<10;i++){printf("%d\n",add(i,twice(i)));}return 0;}
+`
+	for _, options := range []string{
+		"pad-oper indent=tab style=google",
+		"indent-namespaces break-blocks pad-comma indent=tab style=1tbs",
+	} {
+		whole, err := Astyle(source, options)
+		require.NoError(t, err)
+
+		size := astyleChunkSize
+		astyleChunkSize = 1
+		chunked, err := Astyle(source, options)
+		astyleChunkSize = size
+		require.NoError(t, err)
+
+		assert.Greater(t, len(splitAstyle(t, source)), 5)
+		assert.Equal(t, whole, chunked, options)
+	}
+}
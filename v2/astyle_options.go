@@ -0,0 +1,220 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AstyleOption describes an option accepted by Astyle. Options with no
+// Values and Numeric false are flags. Numeric options take an integer.
+type AstyleOption struct {
+	Name     string
+	Values   []string
+	Numeric  bool
+	Optional bool // The value may be omitted.
+}
+
+var astyleStyles = []string{
+	"allman", "bsd", "break",
+	"java", "attach",
+	"kr", "k&r", "k/r",
+	"stroustrup",
+	"whitesmith",
+	"vtk",
+	"ratliff", "banner",
+	"gnu",
+	"linux", "knf",
+	"horstmann", "run-in",
+	"1tbs", "otbs",
+	"google",
+	"mozilla",
+	"webkit",
+	"pico",
+	"lisp", "python",
+}
+
+var astyleOptions = []AstyleOption{
+	{Name: "style", Values: astyleStyles},
+	{Name: "indent", Values: []string{"spaces", "tab", "force-tab", "force-tab-x"}},
+	{Name: "mode", Values: []string{"c", "cs", "java", "objc", "js"}},
+	{Name: "lineend", Values: []string{"windows", "linux", "macold"}},
+	{Name: "align-pointer", Values: []string{"type", "middle", "name"}},
+	{Name: "align-reference", Values: []string{"none", "type", "middle", "name"}},
+	{Name: "pad-method-colon", Values: []string{"none", "all", "after", "before"}},
+	{Name: "break-blocks", Values: []string{"all"}, Optional: true},
+	{Name: "indent-continuation", Numeric: true},
+	{Name: "min-conditional-indent", Numeric: true},
+	{Name: "max-continuation-indent", Numeric: true},
+	{Name: "max-code-length", Numeric: true},
+	{Name: "squeeze-lines", Numeric: true},
+	{Name: "attach-namespaces"},
+	{Name: "attach-classes"},
+	{Name: "attach-inlines"},
+	{Name: "attach-extern-c"},
+	{Name: "attach-closing-while"},
+	{Name: "indent-classes"},
+	{Name: "indent-modifiers"},
+	{Name: "indent-switches"},
+	{Name: "indent-cases"},
+	{Name: "indent-namespaces"},
+	{Name: "indent-after-parens"},
+	{Name: "indent-labels"},
+	{Name: "indent-preproc-block"},
+	{Name: "indent-preproc-define"},
+	{Name: "indent-preproc-cond"},
+	{Name: "indent-col1-comments"},
+	{Name: "indent-lambda"},
+	{Name: "pad-oper"},
+	{Name: "pad-comma"},
+	{Name: "pad-paren"},
+	{Name: "pad-paren-out"},
+	{Name: "pad-first-paren-out"},
+	{Name: "pad-paren-in"},
+	{Name: "pad-header"},
+	{Name: "pad-include"},
+	{Name: "pad-brackets"},
+	{Name: "unpad-paren"},
+	{Name: "unpad-brackets"},
+	{Name: "pad-method-prefix"},
+	{Name: "unpad-method-prefix"},
+	{Name: "pad-return-type"},
+	{Name: "unpad-return-type"},
+	{Name: "pad-param-type"},
+	{Name: "unpad-param-type"},
+	{Name: "align-method-colon"},
+	{Name: "delete-empty-lines"},
+	{Name: "fill-empty-lines"},
+	{Name: "squeeze-ws"},
+	{Name: "break-closing-braces"},
+	{Name: "break-elseifs"},
+	{Name: "break-one-line-headers"},
+	{Name: "add-braces"},
+	{Name: "add-one-line-braces"},
+	{Name: "remove-braces"},
+	{Name: "break-return-type"},
+	{Name: "break-return-type-decl"},
+	{Name: "attach-return-type"},
+	{Name: "attach-return-type-decl"},
+	{Name: "keep-one-line-blocks"},
+	{Name: "keep-one-line-statements"},
+	{Name: "convert-tabs"},
+	{Name: "close-templates"},
+	{Name: "remove-comment-prefix"},
+	{Name: "break-after-logical"},
+}
+
+// astylePresets are named option sets selectable from configuration.
+var astylePresets = map[string]string{
+	"google": "style=google pad-oper",
+	"k&r":    "style=kr pad-oper",
+	"allman": "style=allman pad-oper",
+	"1tbs":   "style=1tbs pad-oper",
+}
+
+// AstyleStyles returns the options supported by Astyle so that tooling
+// can offer completion or validation of configured option strings.
+func AstyleStyles() []AstyleOption {
+	options := make([]AstyleOption, len(astyleOptions))
+	copy(options, astyleOptions)
+	return options
+}
+
+// AstylePresets returns the names of the built-in option presets.
+func AstylePresets() []string {
+	names := make([]string, 0, len(astylePresets))
+	for name := range astylePresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AstylePreset returns the options for a named preset.
+func AstylePreset(name string) (string, bool) {
+	options, ok := astylePresets[strings.ToLower(name)]
+	return options, ok
+}
+
+// ValidateAstyleOptions checks that an options string, such as
+// "pad-oper indent=tab style=google", only contains supported
+// options and values.
+func ValidateAstyleOptions(options string) error {
+	for _, token := range strings.Fields(options) {
+		name, value, hasValue := strings.Cut(token, "=")
+		option, ok := findAstyleOption(name)
+		if !ok {
+			return fmt.Errorf("unknown astyle option %q", name)
+		}
+
+		switch {
+		case option.Numeric:
+			if !hasValue {
+				return fmt.Errorf("astyle option %q requires a number", name)
+			}
+			if _, err := strconv.Atoi(value); err != nil {
+				return fmt.Errorf("astyle option %q requires a number, got %q", name, value)
+			}
+		case len(option.Values) > 0:
+			if !hasValue {
+				if option.Optional {
+					continue
+				}
+				return fmt.Errorf("astyle option %q requires a value (one of %s)",
+					name, strings.Join(option.Values, ", "))
+			}
+			// Indent values may specify a size such as spaces=4.
+			base, size, hasSize := strings.Cut(value, "=")
+			if hasSize && name == "indent" {
+				if _, err := strconv.Atoi(size); err != nil {
+					return fmt.Errorf("astyle option %q has an invalid size %q", name, size)
+				}
+			} else {
+				base = value
+			}
+			if !containsString(option.Values, base) {
+				return fmt.Errorf("astyle option %q does not support %q (expected one of %s)",
+					name, value, strings.Join(option.Values, ", "))
+			}
+		case hasValue:
+			return fmt.Errorf("astyle option %q does not take a value", name)
+		}
+	}
+
+	return nil
+}
+
+func findAstyleOption(name string) (AstyleOption, bool) {
+	for _, option := range astyleOptions {
+		if option.Name == name {
+			return option, true
+		}
+	}
+	return AstyleOption{}, false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package cli_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/apexlang/cli/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAstyle(t *testing.T) {
+	expected := "#include <cstdio>\nint main() {\n    int 🦄, a, *b = a, c = 🦄 * 2, *d = nullptr;\n    return -1;\n}"
+	code := "#include <cstdio>\nint main(){int 🦄,a,*b=a,c=🦄*2,*d=nullptr;return -1;}"
+	formatted, err := cli.Astyle(code, "pad-oper style=google")
+	require.NoError(t, err)
+	assert.Equal(t, expected, formatted)
+}
+
+func TestValidateAstyleOptions(t *testing.T) {
+	for _, options := range []string{
+		"pad-oper style=google",
+		"indent-namespaces break-blocks pad-comma indent=tab style=1tbs",
+		"indent=spaces=2 max-code-length=100 break-blocks=all",
+	} {
+		assert.NoError(t, cli.ValidateAstyleOptions(options), options)
+	}
+
+	for _, options := range []string{
+		"pad-operator",
+		"style=fancy",
+		"indent",
+		"max-code-length=wide",
+		"pad-oper=true",
+	} {
+		assert.Error(t, cli.ValidateAstyleOptions(options), options)
+	}
+}
+
+func TestAstylePresets(t *testing.T) {
+	for _, name := range cli.AstylePresets() {
+		options, ok := cli.AstylePreset(name)
+		require.True(t, ok, name)
+		assert.NoError(t, cli.ValidateAstyleOptions(options), name)
+	}
+}
+
+// TestAstyleStreamLarge formats a 10MB source, and a 100MB
+// one when APEX_TEST_LARGE is set.
+func TestAstyleStreamLarge(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large astyle inputs in short mode")
+	}
+
+	sizes := []int{10 << 20}
+	if os.Getenv("APEX_TEST_LARGE") != "" {
+		sizes = append(sizes, 100<<20)
+	}
+	function := "// f adds two numbers together and returns their sum to the caller.\nint f(){int a=1,b=2;return a+b;}\n"
+	for _, size := range sizes {
+		t.Run(fmt.Sprintf("%dMB", size>>20), func(t *testing.T) {
+			count := size / len(function)
+			var out countingWriter
+			err := cli.AstyleStream(&out, strings.NewReader(strings.Repeat(function, count)), "pad-oper style=google")
+			require.NoError(t, err)
+			assert.Equal(t, count, out.count)
+		})
+	}
+}
+
+// countingWriter counts formatted copies of the function
+// in TestAstyleStreamLarge without keeping the output.
+type countingWriter struct {
+	count   int
+	partial []byte
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	// Formatted parts end with a line, so only a line can span writes.
+	data := append(w.partial, p...)
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	w.partial = append([]byte{}, lines[len(lines)-1]...)
+	for _, line := range lines[:len(lines)-1] {
+		if string(line) == "    return a + b;\n" {
+			w.count++
+		}
+	}
+	return len(p), nil
+}
+
+// TestAstyleLargeDeclaration formats a namespace larger than
+// the memory of the WebAssembly module, which cannot be split.
+func TestAstyleLargeDeclaration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large astyle inputs in short mode")
+	}
+
+	function := "int f(){return 1;}\n"
+	count := (2 << 20) / len(function)
+	code := "namespace apex {\n" + strings.Repeat(function, count) + "}\n"
+	formatted, err := cli.Astyle(code, "indent-namespaces style=google")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(formatted, "namespace apex {\n    int f() {\n        return 1;\n    }\n"))
+	assert.Equal(t, count, strings.Count(formatted, "        return 1;\n"))
+
+	// The instance with more memory is not kept, and the
+	// module's own memory formats smaller sources.
+	formatted, err = cli.Astyle("int a;\nint main(){return a+b;}", "style=google")
+	require.NoError(t, err)
+	assert.Equal(t, "int a;\nint main() {\n    return a+b;\n}", formatted)
+}
+
+func TestAstyleSourceTooLarge(t *testing.T) {
+	max := cli.MaxAstyleSourceSize
+	defer func() { cli.MaxAstyleSourceSize = max }()
+	cli.MaxAstyleSourceSize = 16
+
+	_, err := cli.Astyle("int main(){return 0;}", "style=google")
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, cli.ErrAstyleSourceTooLarge))
+
+	// Declarations are limited, not sources.
+	formatted, err := cli.Astyle("int a;\nint b;\nint c;\n", "style=google")
+	require.NoError(t, err)
+	assert.Equal(t, "int a;\nint b;\nint c;\n", formatted)
+
+	// Reading stops once a declaration is larger than the limit.
+	source := &countingReader{}
+	err = cli.AstyleStream(io.Discard, source, "style=google")
+	assert.True(t, errors.Is(err, cli.ErrAstyleSourceTooLarge))
+	assert.Less(t, source.n, 64<<10)
+}
+
+// countingReader is an endless source of spaces
+// that counts the bytes read from it.
+type countingReader struct {
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = ' '
+	}
+	r.n += len(p)
+	return len(p), nil
+}
+
+func TestAstyleConcurrent(t *testing.T) {
+	// Astyle writes the line endings of the source, so it needs one.
+	expected := "int a;\nint main() {\n    return a + b;\n}"
+	code := "int a;\nint main(){return a+b;}"
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			formatted, err := cli.Astyle(code, "pad-oper style=google")
+			if err == nil && formatted != expected {
+				err = fmt.Errorf("unexpected output %q", formatted)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
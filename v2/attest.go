@@ -0,0 +1,237 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const (
+	inTotoStatementType = "https://in-toto.io/Statement/v0.1"
+	inTotoPayloadType   = "application/vnd.in-toto+json"
+	slsaProvenanceType  = "https://slsa.dev/provenance/v0.2"
+	apexBuildType       = "https://apexlang.io/generate/v1"
+)
+
+type inTotoStatement struct {
+	Type          string          `json:"_type"`
+	Subject       []inTotoSubject `json:"subject"`
+	PredicateType string          `json:"predicateType"`
+	Predicate     slsaProvenance  `json:"predicate"`
+}
+
+type inTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+type slsaProvenance struct {
+	Builder struct {
+		ID string `json:"id"`
+	} `json:"builder"`
+	BuildType  string `json:"buildType"`
+	Invocation struct {
+		ConfigSource slsaMaterial           `json:"configSource"`
+		Parameters   map[string]interface{} `json:"parameters,omitempty"`
+	} `json:"invocation"`
+	Metadata struct {
+		BuildStartedOn  time.Time `json:"buildStartedOn"`
+		BuildFinishedOn time.Time `json:"buildFinishedOn"`
+		Reproducible    bool      `json:"reproducible"`
+	} `json:"metadata"`
+	Materials []slsaMaterial `json:"materials"`
+}
+
+type slsaMaterial struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest"`
+}
+
+type dsseEnvelope struct {
+	PayloadType string          `json:"payloadType"`
+	Payload     string          `json:"payload"`
+	Signatures  []dsseSignature `json:"signatures"`
+}
+
+type dsseSignature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+}
+
+// writeAttestation writes an in-toto statement with a SLSA provenance
+// predicate describing a generate run. When a signing key is provided the
+// statement is wrapped in a signed DSSE envelope. Keyless signing
+// delegates to the cosign CLI which writes a sigstore bundle next to it.
+func writeAttestation(filename string, report *GenerateReport, started, finished time.Time, signingKey string, keyless bool) error {
+	statement := inTotoStatement{
+		Type:          inTotoStatementType,
+		PredicateType: slsaProvenanceType,
+	}
+	for _, output := range report.Outputs {
+		statement.Subject = append(statement.Subject, inTotoSubject{
+			Name:   output.Path,
+			Digest: map[string]string{"sha256": output.SHA256},
+		})
+	}
+
+	p := &statement.Predicate
+	p.Builder.ID = "https://github.com/apexlang/cli@" + Version
+	p.BuildType = apexBuildType
+	p.Invocation.ConfigSource = slsaMaterial{
+		URI:    report.Config.Path,
+		Digest: map[string]string{"sha256": report.Config.SHA256},
+	}
+	generators := make(map[string]string)
+	for _, output := range report.Outputs {
+		if output.Module != "" {
+			generators[output.Path] = output.Module
+		} else if output.Template != "" {
+			generators[output.Path] = output.Template
+		}
+	}
+	p.Invocation.Parameters = map[string]interface{}{
+		"generators": generators,
+	}
+	p.Metadata.BuildStartedOn = started.UTC()
+	p.Metadata.BuildFinishedOn = finished.UTC()
+	p.Metadata.Reproducible = true
+	for _, input := range report.Inputs {
+		uri := input.Path
+		if input.Version != "" {
+			uri += "@" + input.Version
+		}
+		p.Materials = append(p.Materials, slsaMaterial{
+			URI:    uri,
+			Digest: map[string]string{"sha256": input.SHA256},
+		})
+	}
+
+	payload, err := json.Marshal(&statement)
+	if err != nil {
+		return err
+	}
+
+	data := payload
+	if signingKey != "" {
+		envelope, err := signEnvelope(payload, signingKey)
+		if err != nil {
+			return fmt.Errorf("could not sign attestation: %w", err)
+		}
+		if data, err = json.MarshalIndent(envelope, "", "  "); err != nil {
+			return err
+		}
+	}
+
+	if err = os.WriteFile(filename, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote attestation %s\n", filename)
+
+	if keyless {
+		cmd := exec.Command("cosign", "sign-blob", "--yes",
+			"--bundle", filename+".sigstore.json", filename)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err = cmd.Run(); err != nil {
+			return fmt.Errorf("keyless signing with cosign failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func signEnvelope(payload []byte, keyFile string) (*dsseEnvelope, error) {
+	keyBytes, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		return nil, fmt.Errorf("%s does not contain a PEM encoded key", keyFile)
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pae := dssePAE(inTotoPayloadType, payload)
+	digest := sha256.Sum256(pae)
+
+	var sig []byte
+	var public interface{}
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, pae)
+		public = k.Public()
+	case *ecdsa.PrivateKey:
+		sig, err = ecdsa.SignASN1(rand.Reader, k, digest[:])
+		public = k.Public()
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		public = k.Public()
+	default:
+		return nil, errors.New("unsupported private key type")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+	keyID := sha256.Sum256(publicDER)
+
+	return &dsseEnvelope{
+		PayloadType: inTotoPayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures: []dsseSignature{{
+			KeyID: hex.EncodeToString(keyID[:]),
+			Sig:   base64.StdEncoding.EncodeToString(sig),
+		}},
+	}, nil
+}
+
+// dssePAE is the DSSE pre-authentication encoding of a payload.
+func dssePAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s",
+		len(payloadType), payloadType, len(payload), payload))
+}
@@ -0,0 +1,387 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+// AuditLogFile is the append-only log of commands, kept in the Apex
+// home directory or the project's .apex directory when enabled with
+// audit: true in ~/.apex/config.yaml or .apexrc.yaml.
+const AuditLogFile = "audit.log"
+
+// AuditEntry is a command recorded in the audit log,
+// which holds one JSON entry per line.
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user,omitempty"`
+	Host    string    `json:"host,omitempty"`
+	Dir     string    `json:"dir,omitempty"`
+	Version string    `json:"version"`
+	Command string    `json:"command"`
+	// Args are the command line arguments with the values
+	// of secrets, such as tokens and passwords, redacted.
+	Args []string `json:"args"`
+	// Modules are those installed, or generated with, and their versions.
+	Modules        []AuditModule `json:"modules,omitempty"`
+	Outcome        string        `json:"outcome"`
+	Error          string        `json:"error,omitempty"`
+	DurationMillis int64         `json:"durationMs"`
+}
+
+// AuditModule is a module and the version used by a command.
+type AuditModule struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// Outcomes of audited commands.
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+const redacted = "REDACTED"
+
+// secretNamePattern matches flag and variable names whose values are redacted.
+var secretNamePattern = regexp.MustCompile(`(?i)(token|password|passwd|secret|credential|auth|api[-_]?key|private[-_]?key)`)
+
+// auditModules collects the modules used by the running command.
+var auditModules struct {
+	sync.Mutex
+	modules map[string]string
+}
+
+// recordAuditModule records a module and version used by the command.
+func recordAuditModule(name, version string) {
+	auditModules.Lock()
+	defer auditModules.Unlock()
+	if auditModules.modules == nil {
+		auditModules.modules = make(map[string]string)
+	}
+	if version != "" || auditModules.modules[name] == "" {
+		auditModules.modules[name] = version
+	}
+}
+
+// recordAuditInstalledModule records the package of an installed
+// module with the version declared in its package.json.
+func recordAuditInstalledModule(homeDir, module string) {
+	if module == "" || strings.HasPrefix(module, ".") || filepath.IsAbs(module) {
+		return
+	}
+	pkg := packageName(module)
+	if dir, ok := installedModuleDir(homeDir, pkg); ok {
+		recordAuditModule(pkg, readPackageVersion(dir))
+	}
+}
+
+// auditLogs returns the audit logs that are enabled.
+func auditLogs() []string {
+	var logs []string
+	if project, err := readProjectConfig(); err == nil && project.Audit {
+		if dir, err := filepath.Abs(ProjectHomeDir); err == nil {
+			logs = append(logs, filepath.Join(dir, AuditLogFile))
+		}
+	}
+	if config, err := readUserConfig(); err == nil && config.Audit {
+		if dir, err := apexHomeDir(); err == nil {
+			logs = append(logs, filepath.Join(dir, AuditLogFile))
+		}
+	}
+	return logs
+}
+
+// RecordAudit appends the command run by ctx, which started at started
+// and finished with err, to the audit logs that are enabled. Failing to
+// record it is reported but does not fail the command.
+func RecordAudit(ctx *kong.Context, started time.Time, err error) {
+	logs := auditLogs()
+	if len(logs) == 0 {
+		return
+	}
+
+	entry := AuditEntry{
+		Time:           started.UTC(),
+		Version:        Version,
+		Command:        commandName(ctx.Selected()),
+		Args:           sanitizeArgs(ctx.Args),
+		Outcome:        AuditSuccess,
+		DurationMillis: time.Since(started).Milliseconds(),
+	}
+	if u, err := user.Current(); err == nil {
+		entry.User = u.Username
+	}
+	entry.Host, _ = os.Hostname()
+	entry.Dir, _ = os.Getwd()
+	if err != nil {
+		entry.Outcome = AuditFailure
+		entry.Error = err.Error()
+	}
+	auditModules.Lock()
+	for name, version := range auditModules.modules {
+		entry.Modules = append(entry.Modules, AuditModule{Name: name, Version: version})
+	}
+	auditModules.Unlock()
+	sort.Slice(entry.Modules, func(i, j int) bool { return entry.Modules[i].Name < entry.Modules[j].Name })
+
+	line, merr := json.Marshal(entry)
+	if merr != nil {
+		fmt.Fprintf(os.Stderr, "Could not record audit entry: %v\n", merr)
+		return
+	}
+	for _, log := range logs {
+		if werr := appendAuditEntry(log, line); werr != nil {
+			fmt.Fprintf(os.Stderr, "Could not record audit entry in %s: %v\n", log, werr)
+		}
+	}
+}
+
+// appendAuditEntry appends a line to the log, which only its owner can read.
+func appendAuditEntry(log string, line []byte) error {
+	if err := os.MkdirAll(filepath.Dir(log), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(log, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// sanitizeArgs redacts the values of flags and NAME=VALUE variables
+// whose names look like secrets, and passwords in URLs.
+func sanitizeArgs(args []string) []string {
+	sanitized := make([]string, len(args))
+	redactNext := false
+	for i, arg := range args {
+		switch {
+		case redactNext:
+			arg = redacted
+			redactNext = false
+		case strings.HasPrefix(arg, "-"):
+			name, _, hasValue := strings.Cut(arg, "=")
+			if secretNamePattern.MatchString(name) {
+				if hasValue {
+					arg = name + "=" + redacted
+				} else {
+					redactNext = true
+				}
+			}
+		default:
+			if name, _, ok := strings.Cut(arg, "="); ok && secretNamePattern.MatchString(name) {
+				arg = name + "=" + redacted
+			}
+		}
+		sanitized[i] = redactURL(arg)
+	}
+	return sanitized
+}
+
+// redactURL removes the password from URLs, such as git+https remotes.
+func redactURL(arg string) string {
+	i := strings.Index(arg, "://")
+	if i < 0 {
+		return arg
+	}
+	start := strings.LastIndexAny(arg[:i], "=+") + 1
+	u, err := url.Parse(arg[start:])
+	if err != nil || u.User == nil {
+		return arg
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	return arg[:start] + u.String()
+}
+
+type AuditCmd struct {
+	Show   AuditShowCmd   `cmd:"" help:"Shows recent entries of the audit log."`
+	Export AuditExportCmd `cmd:"" help:"Exports the audit log as JSON or CSV."`
+}
+
+type AuditShowCmd struct {
+	Project bool   `help:"Show the project's audit log instead of the user's."`
+	Limit   int    `help:"The number of most recent entries to show." default:"20"`
+	Command string `help:"Only show entries for this command (e.g. install)."`
+}
+
+type AuditExportCmd struct {
+	Project bool   `help:"Export the project's audit log instead of the user's."`
+	Format  string `help:"The format to export (json or csv)." enum:"json,csv" default:"json"`
+	Output  string `help:"The file to write. Defaults to standard output." short:"o"`
+}
+
+func (c *AuditShowCmd) Run(ctx *Context) error {
+	entries, err := readAuditLog(c.Project)
+	if err != nil {
+		return err
+	}
+	if c.Command != "" {
+		filtered := entries[:0]
+		for _, entry := range entries {
+			if entry.Command == c.Command {
+				filtered = append(filtered, entry)
+			}
+		}
+		entries = filtered
+	}
+	if c.Limit > 0 && len(entries) > c.Limit {
+		entries = entries[len(entries)-c.Limit:]
+	}
+
+	if ctx != nil && ctx.JSON {
+		return writeJSON(os.Stdout, entries)
+	}
+
+	t := table.NewWriter()
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{
+			Name:   "Command",
+			Colors: text.Colors{text.FgGreen},
+		},
+	})
+	t.AppendHeader(table.Row{"Time", "User", "Command", "Arguments", "Modules", "Outcome"})
+	for _, entry := range entries {
+		outcome := text.FgGreen.Sprint(entry.Outcome)
+		if entry.Outcome != AuditSuccess {
+			outcome = text.FgRed.Sprint(entry.Outcome)
+		}
+		t.AppendRow(table.Row{
+			entry.Time.Local().Format(time.RFC3339),
+			entry.User,
+			entry.Command,
+			strings.Join(entry.Args, " "),
+			auditModuleList(entry.Modules),
+			outcome,
+		})
+	}
+	fmt.Println(t.Render())
+	return nil
+}
+
+func (c *AuditExportCmd) Run(ctx *Context) error {
+	entries, err := readAuditLog(c.Project)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if c.Output != "" {
+		f, err := os.Create(c.Output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if c.Format == "csv" {
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"time", "user", "host", "dir", "version", "command", "args", "modules", "outcome", "error", "durationMs"})
+		for _, e := range entries {
+			cw.Write([]string{
+				e.Time.Format(time.RFC3339), e.User, e.Host, e.Dir, e.Version, e.Command,
+				strings.Join(e.Args, " "), auditModuleList(e.Modules), e.Outcome, e.Error,
+				strconv.FormatInt(e.DurationMillis, 10),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	return writeJSON(w, entries)
+}
+
+// readAuditLog reads the entries of the user's or project's audit log.
+func readAuditLog(project bool) ([]AuditEntry, error) {
+	dir, err := apexHomeDir()
+	if project {
+		dir, err = filepath.Abs(ProjectHomeDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log := filepath.Join(dir, AuditLogFile)
+	f, err := os.Open(log)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%s does not exist; enable it with audit: true in %s", log, auditConfigFile(project))
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []AuditEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), int(MaxConfigSize))
+	for n := 1; scanner.Scan(); n++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var entry AuditEntry
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", log, n, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// auditConfigFile returns the configuration that enables an audit log.
+func auditConfigFile(project bool) string {
+	if project {
+		return ProjectConfigFile
+	}
+	return filepath.Join("~", HomeDirName, "config.yaml")
+}
+
+// auditModuleList formats modules as name@version.
+func auditModuleList(modules []AuditModule) string {
+	names := make([]string, len(modules))
+	for i, m := range modules {
+		names[i] = m.Name
+		if m.Version != "" {
+			names[i] += "@" + m.Version
+		}
+	}
+	return strings.Join(names, ", ")
+}
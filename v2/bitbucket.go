@@ -0,0 +1,187 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const bitbucketAPI = "https://api.bitbucket.org/2.0"
+
+// errBitbucketNotFound is returned for Bitbucket API requests that 404.
+var errBitbucketNotFound = errors.New("not found")
+
+type bitbucketRef struct {
+	Name string `json:"name"`
+}
+
+type bitbucketRefs struct {
+	Values []bitbucketRef `json:"values"`
+	Next   string         `json:"next"`
+}
+
+// bitbucketAuth holds the credentials for Bitbucket from
+// BITBUCKET_TOKEN, an access token, or BITBUCKET_USERNAME and
+// BITBUCKET_APP_PASSWORD, falling back to ~/.apex/credentials.yaml.
+type bitbucketAuth struct {
+	Token       string `yaml:"token,omitempty"`
+	Username    string `yaml:"username,omitempty"`
+	AppPassword string `yaml:"appPassword,omitempty"`
+}
+
+func bitbucketCredentials() bitbucketAuth {
+	auth := bitbucketAuth{
+		Token:       os.Getenv("BITBUCKET_TOKEN"),
+		Username:    os.Getenv("BITBUCKET_USERNAME"),
+		AppPassword: os.Getenv("BITBUCKET_APP_PASSWORD"),
+	}
+	if auth.Token == "" && (auth.Username == "" || auth.AppPassword == "") {
+		auth = readCredentials().Bitbucket
+	}
+	return auth
+}
+
+func (a bitbucketAuth) empty() bool {
+	return a.Token == "" && (a.Username == "" || a.AppPassword == "")
+}
+
+// bitbucketTransport adds credentials to requests sent to Bitbucket.
+type bitbucketTransport struct {
+	auth bitbucketAuth
+	base http.RoundTripper
+}
+
+func newBitbucketTransport(base http.RoundTripper) http.RoundTripper {
+	auth := bitbucketCredentials()
+	if auth.empty() {
+		return base
+	}
+	return &bitbucketTransport{auth: auth, base: base}
+}
+
+func (t *bitbucketTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	if req.URL.Scheme != "https" || req.Header.Get("Authorization") != "" ||
+		(host != "bitbucket.org" && host != "api.bitbucket.org") {
+		return t.base.RoundTrip(req)
+	}
+	// RoundTrippers must not modify the request.
+	req = req.Clone(req.Context())
+	if t.auth.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.auth.Token)
+	} else {
+		req.SetBasicAuth(t.auth.Username, t.auth.AppPassword)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// getReleaseInfoFromBitbucket resolves a tag of bitbucket.org/<workspace>/<repo>,
+// which may be given as <repo>@<tag>. Bitbucket has no releases so the latest
+// tag by commit date is used when none is requested. As for GitHub, a
+// semver range selects from the tags and a branch may be installed by name.
+func (c *InstallCmd) getReleaseInfoFromBitbucket(location, releaseTag string) (*releaseInfo, error) {
+	if i := strings.LastIndex(location, "@"); i != -1 {
+		if releaseTag == "" {
+			releaseTag = location[i+1:]
+		}
+		location = location[:i]
+	}
+	parts := strings.Split(location, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid repo syntax: %q", location)
+	}
+	workspace, repo := url.PathEscape(parts[0]), url.PathEscape(parts[1])
+	refs := fmt.Sprintf("/repositories/%s/%s/refs", workspace, repo)
+	info := releaseInfo{
+		Org:    parts[0],
+		Module: parts[1],
+	}
+
+	switch {
+	case isSemverRange(releaseTag):
+		var tags []string
+		next := bitbucketAPI + refs + "/tags?pagelen=100"
+		for next != "" {
+			var page bitbucketRefs
+			if err := c.bitbucketGet(next, &page); err != nil {
+				return nil, err
+			}
+			for _, tag := range page.Values {
+				tags = append(tags, tag.Name)
+			}
+			next = page.Next
+		}
+		best, found, err := maxSatisfying(tags, releaseTag)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, fmt.Errorf("%s has no tag matching %q", location, releaseTag)
+		}
+		info.Tag = best
+		info.Requested = releaseTag
+		info.RequestedType = RequestedRange
+	case releaseTag == "" || releaseTag == "latest":
+		var page bitbucketRefs
+		if err := c.bitbucketGet(bitbucketAPI+refs+"/tags?pagelen=1&sort=-target.date", &page); err != nil {
+			return nil, err
+		}
+		if len(page.Values) == 0 {
+			return nil, fmt.Errorf("there are no tags for %s", location)
+		}
+		info.Tag = page.Values[0].Name
+	default:
+		var ref bitbucketRef
+		err := c.bitbucketGet(bitbucketAPI+refs+"/tags/"+url.PathEscape(releaseTag), &ref)
+		if errors.Is(err, errBitbucketNotFound) {
+			err = c.bitbucketGet(bitbucketAPI+refs+"/branches/"+url.PathEscape(releaseTag), &ref)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not find tag or branch %s of %s: %w", releaseTag, location, err)
+		}
+		info.Tag = ref.Name
+	}
+
+	info.TarballURL = fmt.Sprintf("https://bitbucket.org/%s/%s/get/%s.tar.gz",
+		workspace, repo, url.PathEscape(info.Tag))
+
+	return &info, nil
+}
+
+func (c *InstallCmd) bitbucketGet(u string, v interface{}) error {
+	resp, err := c.netClient.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		if bitbucketCredentials().empty() {
+			return fmt.Errorf("%w; set BITBUCKET_TOKEN for private repositories", errBitbucketNotFound)
+		}
+		return errBitbucketNotFound
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("Bitbucket returned status %d for %s", resp.StatusCode, u)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,395 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// bundleManifestFile is the first entry of a bundle and
+// lists its modules and the integrity of every file.
+const bundleManifestFile = "apex-bundle.json"
+
+const bundleVersion = 1
+
+type BundleCmd struct {
+	Export BundleExportCmd `cmd:"" help:"Exports installed modules to a bundle for use on offline machines."`
+	Import BundleImportCmd `cmd:"" help:"Imports a bundle into the home directory without network access."`
+}
+
+type BundleExportCmd struct {
+	Output string `arg:"" help:"The bundle to write." default:"apex-bundle.tgz"`
+	From   string `help:"A workspace file listing the modules to export. Defaults to every installed module." type:"existingfile"`
+}
+
+type BundleImportCmd struct {
+	Bundle string `arg:"" help:"The bundle to import." type:"existingfile"`
+}
+
+type bundleManifest struct {
+	BundleVersion int            `json:"bundleVersion"`
+	Modules       []bundleModule `json:"modules"`
+	// Files maps each file, relative to the home directory and
+	// using forward slashes, to its sha512 subresource integrity.
+	Files map[string]string `json:"files"`
+}
+
+type bundleModule struct {
+	Name     string `json:"name"`
+	Version  string `json:"version,omitempty"`
+	Location string `json:"location,omitempty"`
+	// Paths are the installed paths, relative to the
+	// home directory, that the import replaces.
+	Paths []string `json:"paths"`
+}
+
+func (c *BundleExportCmd) Run(ctx *Context) error {
+	homeDir, err := ensureHomeDirectory()
+	if err != nil {
+		return err
+	}
+
+	installed, err := installedModules(homeDir)
+	if err != nil {
+		return err
+	}
+	modules, err := c.selectModules(homeDir, installed)
+	if err != nil {
+		return err
+	}
+
+	manifest := bundleManifest{
+		BundleVersion: bundleVersion,
+		Files:         map[string]string{},
+	}
+	var files []string
+	for _, module := range modules {
+		paths, err := uninstallPaths(homeDir, module.Name)
+		if err != nil {
+			return err
+		}
+		for i, path := range paths {
+			paths[i] = filepath.ToSlash(path)
+			if err = filepath.Walk(filepath.Join(homeDir, path), func(file string, info os.FileInfo, err error) error {
+				if err != nil || !info.Mode().IsRegular() {
+					return err
+				}
+				relPath, err := filepath.Rel(homeDir, file)
+				if err != nil {
+					return err
+				}
+				name := filepath.ToSlash(relPath)
+				if _, ok := manifest.Files[name]; ok {
+					return nil
+				}
+				if manifest.Files[name], err = fileIntegrity(file); err != nil {
+					return err
+				}
+				files = append(files, name)
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		module.Paths = paths
+		manifest.Modules = append(manifest.Modules, *module)
+		fmt.Printf("Bundling %s@%s\n", module.Name, module.Version)
+	}
+	sort.Strings(files)
+
+	manifestBytes, err := json.MarshalIndent(&manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(c.Output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gzw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gzw)
+
+	if err = tw.WriteHeader(&tar.Header{
+		Name:     bundleManifestFile,
+		Mode:     0644,
+		Size:     int64(len(manifestBytes)),
+		ModTime:  packModTime,
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	if _, err = tw.Write(manifestBytes); err != nil {
+		return err
+	}
+
+	for _, name := range files {
+		if err = writeBundleFile(tw, homeDir, name); err != nil {
+			return err
+		}
+	}
+
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	if err = gzw.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %s (%d modules, %d files)\n", c.Output, len(manifest.Modules), len(files))
+	return nil
+}
+
+// selectModules returns the installed modules listed in the workspace
+// file, or all of them, always including the base dependencies that
+// generation requires.
+func (c *BundleExportCmd) selectModules(homeDir string, installed []*installedModule) ([]*bundleModule, error) {
+	byName := make(map[string]*bundleModule, len(installed))
+	byLocation := make(map[string]*bundleModule, len(installed))
+	for _, module := range installed {
+		m := bundleModule{
+			Name:    module.Name,
+			Version: module.Installed,
+		}
+		moduleRoot := filepath.Join(homeDir, "node_modules", filepath.FromSlash(module.Name))
+		if manifest, err := readInstallManifest(moduleRoot); err == nil {
+			m.Location = manifest.Location
+			byLocation[manifest.Location] = &m
+		}
+		byName[module.Name] = &m
+	}
+
+	selected := map[string]*bundleModule{}
+	if c.From == "" {
+		selected = byName
+	} else {
+		workspace, err := readWorkspace(c.From)
+		if err != nil {
+			return nil, err
+		}
+		aliases := projectAliases()
+		for _, wm := range workspace.Modules {
+			location := resolveModuleAlias(wm.Location, aliases)
+			module, ok := byLocation[location]
+			if !ok {
+				module, ok = byName[location]
+			}
+			if !ok {
+				return nil, fmt.Errorf("%s is not installed; install it before exporting", wm.Location)
+			}
+			selected[module.Name] = module
+		}
+		for name := range baseDependencies {
+			if module, ok := byName[name]; ok {
+				selected[name] = module
+			}
+		}
+	}
+
+	names := make([]string, 0, len(selected))
+	for name := range selected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	modules := make([]*bundleModule, len(names))
+	for i, name := range names {
+		modules[i] = selected[name]
+	}
+	if len(modules) == 0 {
+		return nil, errors.New("there are no installed modules to export")
+	}
+	return modules, nil
+}
+
+func writeBundleFile(tw *tar.Writer, homeDir, name string) error {
+	path := filepath.Join(homeDir, filepath.FromSlash(name))
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err = tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     int64(info.Mode().Perm()),
+		Size:     info.Size(),
+		ModTime:  packModTime,
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, src)
+	return err
+}
+
+func (c *BundleImportCmd) Run(ctx *Context) error {
+	// The bundle provides the base dependencies
+	// so they are not downloaded first.
+	homeDir, err := ensureHomeDirectory()
+	if err != nil {
+		return err
+	}
+
+	staging, err := os.MkdirTemp(homeDir, "bundle-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	manifest, err := extractBundle(c.Bundle, staging)
+	if err != nil {
+		return err
+	}
+
+	for _, module := range manifest.Modules {
+		var existing []string
+		for _, path := range module.Paths {
+			path = filepath.FromSlash(path)
+			if _, err := os.Stat(filepath.Join(homeDir, path)); err == nil {
+				existing = append(existing, path)
+			}
+		}
+		if err = removeAtomically(homeDir, existing); err != nil {
+			return err
+		}
+		for _, path := range module.Paths {
+			path = filepath.FromSlash(path)
+			staged := filepath.Join(staging, path)
+			if _, err = os.Stat(staged); errors.Is(err, os.ErrNotExist) {
+				continue // Contained no files.
+			}
+			dest := filepath.Join(homeDir, path)
+			if err = os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+				return err
+			}
+			if err = os.Rename(staged, dest); err != nil {
+				return fmt.Errorf("could not import %s: %w", path, err)
+			}
+		}
+		fmt.Printf("Imported %s@%s\n", module.Name, module.Version)
+	}
+
+	return nil
+}
+
+// extractBundle extracts a bundle into dir, verifying every file
+// against the integrity recorded in the bundle's manifest.
+func extractBundle(bundle, dir string) (*bundleManifest, error) {
+	f, err := os.Open(bundle)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gzr.Close()
+	tr := tar.NewReader(gzr)
+
+	header, err := tr.Next()
+	if err != nil || header.Name != bundleManifestFile {
+		return nil, fmt.Errorf("%s is not an apex bundle", bundle)
+	}
+	manifestBytes, err := limitReader(bundleManifestFile, tr, MaxConfigSize)
+	if err != nil {
+		return nil, err
+	}
+	var manifest bundleManifest
+	if err = json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", bundleManifestFile, err)
+	}
+	if manifest.BundleVersion > bundleVersion {
+		return nil, fmt.Errorf("%s has bundle version %d; upgrade apex to import it", bundle, manifest.BundleVersion)
+	}
+	for _, module := range manifest.Modules {
+		for _, path := range module.Paths {
+			if !isBundlePath(path) {
+				return nil, fmt.Errorf("invalid path %q for %s in %s", path, module.Name, bundle)
+			}
+		}
+	}
+
+	seen := make(map[string]bool, len(manifest.Files))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		expected, ok := manifest.Files[header.Name]
+		if !ok || !isBundlePath(header.Name) {
+			return nil, fmt.Errorf("unexpected file %s in %s", header.Name, bundle)
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, err
+		}
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(header.Mode).Perm())
+		if err != nil {
+			return nil, err
+		}
+		h := sha512.New()
+		_, err = io.Copy(io.MultiWriter(out, h), tr)
+		out.Close()
+		if err != nil {
+			return nil, err
+		}
+		if integrity := "sha512-" + base64.StdEncoding.EncodeToString(h.Sum(nil)); integrity != expected {
+			return nil, fmt.Errorf("integrity check failed for %s: expected %s, got %s", header.Name, expected, integrity)
+		}
+		seen[header.Name] = true
+	}
+
+	for name := range manifest.Files {
+		if !seen[name] {
+			return nil, fmt.Errorf("%s is missing %s", bundle, name)
+		}
+	}
+
+	return &manifest, nil
+}
+
+// isBundlePath reports whether path stays within the home directory.
+func isBundlePath(path string) bool {
+	clean := filepath.Clean(filepath.FromSlash(path))
+	return clean != "." && clean != ".." &&
+		!strings.HasPrefix(clean, ".."+string(filepath.Separator)) &&
+		!filepath.IsAbs(clean)
+}
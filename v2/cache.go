@@ -0,0 +1,355 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apexlang/cli/v2/internal/extract"
+)
+
+// The content cache in ~/.apex/cache holds downloaded archives and the
+// module trees extracted from them by integrity hash, such as
+// content/sha512/<hex> and modules/sha512/<hex>, so identical content
+// is only downloaded once for every project and lockfile.
+const (
+	contentCacheDir = "content"
+	moduleCacheDir  = "modules"
+)
+
+type CacheCmd struct {
+	Prune CachePruneCmd `cmd:"" help:"Removes the least recently used downloads and modules from the cache until it fits a size limit."`
+}
+
+type CachePruneCmd struct {
+	MaxSize string `help:"The largest the cache may be (e.g. 500MB or 2GB)." default:"1GB"`
+	DryRun  bool   `help:"Show what would be removed without removing it."`
+}
+
+func (c *CachePruneCmd) Run(ctx *Context) error {
+	limit, err := parseByteSize(c.MaxSize)
+	if err != nil {
+		return err
+	}
+	root, err := sharedCacheDir()
+	if err != nil {
+		return err
+	}
+	entries, err := cacheEntries(root)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, entry := range entries {
+		total += entry.size
+	}
+	// Remove the least recently used first.
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].used.Before(entries[j].used)
+	})
+
+	removed, freed := 0, int64(0)
+	for _, entry := range entries {
+		if total <= limit {
+			break
+		}
+		rel, _ := filepath.Rel(root, entry.path)
+		if c.DryRun {
+			fmt.Printf("Would remove %s\n", filepath.ToSlash(rel))
+		} else if err = os.RemoveAll(entry.path); err != nil {
+			return err
+		}
+		total -= entry.size
+		freed += entry.size
+		removed++
+	}
+
+	verb := "Removed"
+	if c.DryRun {
+		verb = "Would remove"
+	}
+	fmt.Printf("%s %d entries (%d bytes); the cache is %d bytes\n", verb, removed, freed, total)
+	return nil
+}
+
+// cacheEntry is a cached download, registry metadata file,
+// or extracted module tree that is pruned as a whole.
+type cacheEntry struct {
+	path string
+	size int64
+	used time.Time
+}
+
+// cacheEntries lists what is in the cache. Each file is an entry,
+// except for the module trees, which are entries of their own.
+func cacheEntries(root string) ([]cacheEntry, error) {
+	var entries []cacheEntry
+	modules := filepath.Join(root, moduleCacheDir)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() && filepath.Dir(filepath.Dir(path)) == modules {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size, err := dirSize(path)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, cacheEntry{path, size, info.ModTime()})
+			return filepath.SkipDir
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entries = append(entries, cacheEntry{path, info.Size(), info.ModTime()})
+		return nil
+	})
+	return entries, err
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err == nil {
+			size += info.Size()
+		}
+		return err
+	})
+	return size, err
+}
+
+// sharedCacheDir returns the cache in the user's home directory,
+// which is shared even by installs into a project.
+func sharedCacheDir() (string, error) {
+	homeDir, err := ensureHomeDirectory()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, "cache"), nil
+}
+
+// contentKey returns the path in the content cache for the strongest
+// hash in a subresource integrity, or an empty string if it has none.
+func contentKey(integrity string) string {
+	hashes := strings.Fields(integrity)
+	for _, algorithm := range integrityAlgorithms {
+		for _, hash := range hashes {
+			if !strings.HasPrefix(hash, algorithm+"-") {
+				continue
+			}
+			if i := strings.IndexByte(hash, '?'); i != -1 {
+				hash = hash[:i]
+			}
+			sum, err := base64.StdEncoding.DecodeString(hash[len(algorithm)+1:])
+			if err != nil {
+				continue
+			}
+			return filepath.Join(algorithm, hex.EncodeToString(sum))
+		}
+	}
+	return ""
+}
+
+// touch marks a cache entry as used so it is pruned last.
+func touch(path string) {
+	now := time.Now()
+	os.Chtimes(path, now, now)
+}
+
+// fetchArchive returns an archive from the content cache when one with
+// the expected integrity was downloaded before, and otherwise downloads
+// it. The archive must still be verified.
+func (c *InstallCmd) fetchArchive(url, module, expected string) (string, func(), error) {
+	if key := contentKey(expected); key != "" {
+		if root, err := sharedCacheDir(); err == nil {
+			cached := filepath.Join(root, contentCacheDir, key)
+			if fi, err := os.Stat(cached); err == nil && !fi.IsDir() {
+				touch(cached)
+				c.summary.add(func(s *Summary) { s.CacheHits++ })
+				return cached, func() {}, nil
+			}
+		}
+	}
+	archive, cleanup, err := c.download(url, module)
+	if err == nil || c.offline() {
+		return archive, cleanup, err
+	}
+	// Mirrors serve the same tarballs, which are verified
+	// against the integrity from the registry.
+	for _, mirrorURL := range loadNPMConfig().mirrorURLs(url) {
+		if c.policy.checkURL(mirrorURL) != nil {
+			continue
+		}
+		fmt.Printf("Could not download %s, trying mirror %s: %v\n", module, mirrorURL, err)
+		var merr error
+		if archive, cleanup, merr = c.download(mirrorURL, module); merr == nil {
+			return archive, cleanup, nil
+		}
+		err = merr
+	}
+	return "", nil, err
+}
+
+// storeContent adds a verified archive to the content cache under its
+// integrity and the expected integrity, which may use another hash
+// algorithm. Failing to do so is not an error since the archive is
+// still installed.
+func storeContent(archive, integrity, expected string) {
+	root, err := sharedCacheDir()
+	if err != nil {
+		return
+	}
+	var stored string
+	for _, key := range []string{contentKey(integrity), contentKey(expected)} {
+		if key == "" {
+			continue
+		}
+		path := filepath.Join(root, contentCacheDir, key)
+		if _, err := os.Stat(path); err == nil {
+			if stored == "" {
+				stored = path
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return
+		}
+		src := archive
+		if stored != "" {
+			src = stored
+		}
+		if err := linkOrCopy(src, path); err != nil {
+			return
+		}
+		if stored == "" {
+			stored = path
+		}
+	}
+}
+
+// linkOrCopy hard links src to dst, copying it when
+// they are on different file systems.
+func linkOrCopy(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.CreateTemp(filepath.Dir(dst), "tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(out.Name(), dst)
+	}
+	if err != nil {
+		os.Remove(out.Name())
+	}
+	return err
+}
+
+// extractCached extracts an archive into dir, copying the module tree
+// extracted before from an archive with the same integrity instead
+// when there is one, and caching the tree otherwise.
+func (c *InstallCmd) extractCached(archive, fileType, integrity, dir string) error {
+	key := contentKey(integrity)
+	root, err := sharedCacheDir()
+	if key == "" || err != nil {
+		return c.extractArchive(archive, fileType, dir, "")
+	}
+	tree := filepath.Join(root, moduleCacheDir, key)
+	if fi, err := os.Stat(tree); err == nil && fi.IsDir() {
+		touch(tree)
+		return c.copyRecursive(tree, dir)
+	}
+
+	if err = c.extractArchive(archive, fileType, dir, ""); err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(tree), 0755); err != nil {
+		return nil
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(tree), "tmp-*")
+	if err != nil {
+		return nil
+	}
+	if err = c.copyRecursive(dir, tmp); err == nil {
+		err = os.Rename(tmp, tree)
+	}
+	if err != nil {
+		os.RemoveAll(tmp)
+	}
+	return nil
+}
+
+// extractArchive extracts a tar.gz or zip archive into dir, or only
+// subdir of the repository it holds when subdir is set.
+func (c *InstallCmd) extractArchive(archive, fileType, dir, subdir string) error {
+	policy, err := c.policy.extraction()
+	if err != nil {
+		return err
+	}
+	if subdir != "" {
+		policy.Rename = func(name string) (string, bool) {
+			return subdirectoryEntry(name, subdir)
+		}
+	}
+
+	switch fileType {
+	case "tar.gz":
+		err = extract.Tarball(archive, dir, policy)
+	case "zip":
+		err = extract.Zip(archive, dir, policy)
+	default:
+		return fmt.Errorf("unknown download type %s", fileType)
+	}
+	if err != nil {
+		return fmt.Errorf("could not extract %s: %w", filepath.Base(archive), err)
+	}
+	return nil
+}
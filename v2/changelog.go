@@ -0,0 +1,163 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+type ChangelogCmd struct {
+	Spec   string `arg:"" help:"The specification file." default:"spec.apex"`
+	From   string `help:"The git revision of the previous version (e.g. v1.2.0)." required:""`
+	To     string `help:"The git revision of the new version. Leave empty to use the working tree." default:"HEAD"`
+	Core   string `help:"The module used to parse the specification." default:"@apexlang/core"`
+	Output string `help:"Write the changelog to this file instead of standard output." short:"o"`
+}
+
+func (c *ChangelogCmd) Run(ctx *Context) error {
+	homeDir, err := getHomeDirectory()
+	if err != nil {
+		return err
+	}
+	core := resolveModuleAlias(c.Core, projectAliases())
+
+	fromSpec, err := readSpecAt(c.Spec, c.From)
+	if err != nil {
+		return err
+	}
+	toSpec, err := readSpecAt(c.Spec, c.To)
+	if err != nil {
+		return err
+	}
+
+	fromJSON, err := parseSpec(homeDir, core, fromSpec)
+	if err != nil {
+		return fmt.Errorf("could not parse %s at %s: %w", c.Spec, c.From, err)
+	}
+	toJSON, err := parseSpec(homeDir, core, toSpec)
+	if err != nil {
+		return fmt.Errorf("could not parse %s at %s: %w", c.Spec, revisionName(c.To), err)
+	}
+
+	changes, err := diffSpecs(fromJSON, toJSON)
+	if err != nil {
+		return err
+	}
+	changelog := formatChangelog(c.From, revisionName(c.To), changes)
+
+	if c.Output != "" {
+		return os.WriteFile(c.Output, []byte(changelog), 0644)
+	}
+	fmt.Print(changelog)
+	return nil
+}
+
+// readSpecAt reads a specification at a git revision,
+// or from the working tree when revision is empty.
+func readSpecAt(spec, revision string) (string, error) {
+	if revision == "" {
+		data, err := readLocalFile(spec, MaxSpecSize)
+		return string(data), err
+	}
+
+	// A "./" prefix makes git resolve the path relative to
+	// the working directory instead of the repository root.
+	path := filepath.ToSlash(spec)
+	if !filepath.IsAbs(spec) && !strings.HasPrefix(path, "./") && !strings.HasPrefix(path, "../") {
+		path = "./" + path
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "show", revision+":"+path)
+	cmd.Stderr = &stderr
+	data, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("could not read %s at %s: %s", spec, revision, strings.TrimSpace(stderr.String()))
+	}
+	if int64(len(data)) > MaxSpecSize {
+		return "", fmt.Errorf("%s at %s: %w (limit is %d bytes)", spec, revision, ErrInputTooLarge, MaxSpecSize)
+	}
+	return string(data), nil
+}
+
+func revisionName(revision string) string {
+	if revision == "" {
+		return "working tree"
+	}
+	return revision
+}
+
+// formatChangelog renders changes as a Markdown fragment
+// with a section for each changed definition.
+func formatChangelog(from, to string, changes []SpecChange) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## API changes from %s to %s\n\n", from, to)
+	if len(changes) == 0 {
+		b.WriteString("No API changes.\n")
+		return b.String()
+	}
+
+	breaking := 0
+	for _, change := range changes {
+		if change.Breaking {
+			breaking++
+		}
+	}
+	if breaking > 0 {
+		fmt.Fprintf(&b, "**%d breaking change(s).**\n\n", breaking)
+	}
+
+	heading := ""
+	for _, change := range changes {
+		if h := change.Definition + " " + change.Name; h != heading {
+			if heading != "" {
+				b.WriteString("\n")
+			}
+			heading = h
+			fmt.Fprintf(&b, "### %s `%s`\n\n", change.Definition, change.Name)
+		}
+
+		b.WriteString("- ")
+		if change.Breaking {
+			b.WriteString("**Breaking:** ")
+		}
+		switch {
+		case change.Member == "":
+			fmt.Fprintf(&b, "%s %s", capitalize(change.Change), change.Definition)
+		case change.Change == ChangeChanged:
+			fmt.Fprintf(&b, "Changed %s `%s` to `%s`", change.MemberKind, change.From, change.To)
+		case change.Change == ChangeRemoved:
+			fmt.Fprintf(&b, "Removed %s `%s`", change.MemberKind, change.From)
+		default:
+			fmt.Fprintf(&b, "Added %s `%s`", change.MemberKind, change.To)
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
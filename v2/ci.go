@@ -0,0 +1,329 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// CI steps in the order they run.
+const (
+	CIStepValidate = "validate"
+	CIStepCheck    = "check"
+	CIStepLint     = "lint"
+)
+
+// ciExitCodes are combined into the exit code of a failed
+// run so pipelines can tell which steps failed.
+var ciExitCodes = map[string]int{
+	CIStepValidate: 1,
+	CIStepCheck:    2,
+	CIStepLint:     4,
+}
+
+// CIConfig is the ci section of a configuration file.
+type CIConfig struct {
+	Skip        []string  `json:"skip,omitempty" yaml:"skip,omitempty"`
+	Lint        []Command `json:"lint,omitempty" yaml:"lint,omitempty"`
+	Annotations string    `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+}
+
+type CICmd struct {
+	Config      string   `arg:"" help:"The code generation configuration file" type:"existingfile" optional:""`
+	Skip        []string `help:"Steps to skip (validate, check, lint)." sep:","`
+	Annotations string   `help:"Format used to annotate problems (auto, github, none)." enum:"auto,github,none" default:"auto"`
+	Compat      string   `help:"Translate configuration written for other tooling (node-cli)." enum:",node-cli" default:""`
+}
+
+// ciError reports the steps that failed. Its exit code
+// combines the codes of each failed step.
+type ciError struct {
+	failed []string
+}
+
+func (e *ciError) Error() string {
+	return fmt.Sprintf("ci failed: %s", strings.Join(e.failed, ", "))
+}
+
+func (e *ciError) ExitCode() int {
+	code := 0
+	for _, step := range e.failed {
+		code |= ciExitCodes[step]
+	}
+	return code
+}
+
+func (c *CICmd) Run(ctx *Context) error {
+	if c.Config == "" {
+		c.Config = "apex.yaml"
+	}
+
+	configs, err := readConfigs(c.Config, c.Compat, ConfigOverrides{})
+	if err != nil {
+		return err
+	}
+
+	skip := make(map[string]bool)
+	for _, step := range c.Skip {
+		if _, ok := ciExitCodes[step]; !ok {
+			return fmt.Errorf("unknown step %q (expected validate, check, or lint)", step)
+		}
+		skip[step] = true
+	}
+	annotations := c.Annotations
+	var lint []Command
+	for _, config := range configs {
+		if config.CI == nil {
+			continue
+		}
+		for _, step := range config.CI.Skip {
+			skip[step] = true
+		}
+		lint = append(lint, config.CI.Lint...)
+		if annotations == "auto" && config.CI.Annotations != "" {
+			annotations = config.CI.Annotations
+		}
+	}
+	if annotations == "auto" {
+		annotations = "none"
+		if os.Getenv("GITHUB_ACTIONS") == "true" {
+			annotations = "github"
+		}
+	}
+	a := annotator{format: annotations}
+
+	steps := []struct {
+		name string
+		run  func() bool
+	}{
+		{CIStepValidate, func() bool { return c.validate(configs, a) }},
+		{CIStepCheck, func() bool { return c.check(ctx, a) }},
+		{CIStepLint, func() bool { return c.lint(lint, a) }},
+	}
+
+	var failed []string
+	results := make(map[string]string, len(steps))
+	for _, step := range steps {
+		if skip[step.name] {
+			results[step.name] = "skipped"
+			continue
+		}
+		a.group(step.name)
+		ok := step.run()
+		a.endGroup()
+		if ok {
+			results[step.name] = "ok"
+		} else {
+			results[step.name] = "failed"
+			failed = append(failed, step.name)
+		}
+	}
+
+	fmt.Println()
+	for _, step := range steps {
+		fmt.Printf("%-10s %s\n", step.name, results[step.name])
+	}
+
+	if len(failed) > 0 {
+		return &ciError{failed: failed}
+	}
+	return nil
+}
+
+// validate parses each specification with the core modules used
+// to generate from it.
+func (c *CICmd) validate(configs []Config, a annotator) bool {
+	homeDir, err := getHomeDirectory()
+	if err != nil {
+		a.error("", err.Error())
+		return false
+	}
+
+	ok := true
+	for _, config := range configs {
+		specBytes, err := readFile(config.Spec, MaxSpecSize)
+		if err != nil {
+			a.error(config.Spec, err.Error())
+			ok = false
+			continue
+		}
+
+		cores := map[string]struct{}{}
+		for _, target := range config.Generates {
+			core := target.Core
+			if core == "" {
+				core = config.Core
+			}
+			if core == "" {
+				core = defaultCoreModule
+			}
+			cores[core] = struct{}{}
+		}
+		valid := true
+		for core := range cores {
+			if _, err = parseSpec(homeDir, core, string(specBytes)); err != nil {
+				a.error(config.Spec, err.Error())
+				valid = false
+			}
+		}
+		if !valid {
+			ok = false
+		} else {
+			fmt.Printf("Valid: %s\n", config.Spec)
+		}
+	}
+
+	return ok
+}
+
+func (c *CICmd) check(ctx *Context, a annotator) bool {
+	drift, err := findDrift(ctx, &GenerateCmd{Config: c.Config, Compat: c.Compat})
+	if err != nil {
+		a.error(c.Config, err.Error())
+		return false
+	}
+	for _, filename := range drift {
+		a.error(filename, fmt.Sprintf("%s is out of date; run apex generate", filename))
+	}
+	return len(drift) == 0
+}
+
+func (c *CICmd) lint(commands []Command, a annotator) bool {
+	ok := true
+	for _, command := range commands {
+		if joined, err := runCommand(context.Background(), command); err != nil {
+			a.error("", fmt.Sprintf("lint command failed: %s: %v", joined, err))
+			ok = false
+		}
+	}
+	return ok
+}
+
+// check reports generated files that are out of date.
+func (c *GenerateCmd) check(ctx *Context) error {
+	drift, err := findDrift(ctx, c)
+	if err != nil {
+		return err
+	}
+	for _, filename := range drift {
+		fmt.Printf("Out of date: %s\n", filename)
+	}
+	if len(drift) > 0 {
+		return fmt.Errorf("%d generated file(s) are out of date", len(drift))
+	}
+	return nil
+}
+
+// findDrift generates into a temporary directory and returns the
+// generated files that differ from those in the working directory.
+// Files ignored by git are not expected to be committed so they
+// are skipped.
+func findDrift(ctx *Context, base *GenerateCmd) ([]string, error) {
+	ignore, err := loadGitIgnore(".")
+	if err != nil {
+		return nil, err
+	}
+
+	outputDir, err := os.MkdirTemp("", "apex-check-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(outputDir)
+
+	g := GenerateCmd{
+		Config:          base.Config,
+		Compat:          base.Compat,
+		Timeout:         base.Timeout,
+		Jobs:            base.Jobs,
+		Force:           base.Force,
+		Since:           base.Since,
+		NoDotenv:        base.NoDotenv,
+		ConfigOverrides: base.ConfigOverrides,
+		configData:      base.configData,
+		outputDir:       outputDir,
+		skipRunAfter:    true,
+		report:          &GenerateReport{},
+	}
+	if err = g.Run(ctx); err != nil {
+		return nil, err
+	}
+
+	var drift []string
+	for _, output := range g.report.Outputs {
+		if ignore.Ignored(output.Path, false) {
+			continue
+		}
+		data, err := os.ReadFile(output.Path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if err != nil || hashBytes(output.Path, data).SHA256 != output.SHA256 {
+			drift = append(drift, output.Path)
+		}
+	}
+	sort.Strings(drift)
+
+	return drift, nil
+}
+
+// annotator prints problems, using workflow commands
+// when running in GitHub Actions.
+type annotator struct {
+	format string
+}
+
+func (a annotator) error(file, message string) {
+	if a.format != "github" {
+		if file != "" {
+			fmt.Printf("Error: %s: %s\n", file, message)
+		} else {
+			fmt.Printf("Error: %s\n", message)
+		}
+		return
+	}
+	if file != "" {
+		fmt.Printf("::error file=%s::%s\n", propertyEscaper.Replace(file), messageEscaper.Replace(message))
+	} else {
+		fmt.Printf("::error::%s\n", messageEscaper.Replace(message))
+	}
+}
+
+func (a annotator) group(name string) {
+	if a.format == "github" {
+		fmt.Printf("::group::%s\n", name)
+	} else {
+		fmt.Printf("==> %s\n", name)
+	}
+}
+
+func (a annotator) endGroup() {
+	if a.format == "github" {
+		fmt.Println("::endgroup::")
+	}
+}
+
+// Workflow command values must escape characters that would
+// otherwise end the message or property.
+var (
+	messageEscaper  = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	propertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
@@ -0,0 +1,222 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// installedPaths returns the module that installed each path, relative
+// to homeDir, from the manifests of the installed modules.
+func installedPaths(homeDir string) (map[string]string, error) {
+	owners := make(map[string]string)
+	root := filepath.Join(homeDir, "node_modules")
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return owners, nil
+		}
+		return nil, err
+	}
+
+	var modules []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if !strings.HasPrefix(entry.Name(), "@") {
+			modules = append(modules, entry.Name())
+			continue
+		}
+		scoped, err := os.ReadDir(filepath.Join(root, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, module := range scoped {
+			if module.IsDir() {
+				modules = append(modules, entry.Name()+"/"+module.Name())
+			}
+		}
+	}
+
+	for _, module := range modules {
+		manifest, err := readInstallManifest(filepath.Join(root, filepath.FromSlash(module)))
+		if err != nil {
+			continue
+		}
+		for _, p := range manifest.Paths {
+			owners[p] = module
+		}
+	}
+	return owners, nil
+}
+
+// installConflict is a definition or template installed by
+// another module, or by hand, at the path a module installs to.
+type installConflict struct {
+	// Path is relative to the home directory, such
+	// as definitions/@myorg/common.apex.
+	Path  string
+	Owner string
+	// Files are those that would be overwritten.
+	Files []string
+}
+
+// ConflictError is returned when a module's definitions or templates
+// would overwrite those installed by other modules.
+type ConflictError struct {
+	Module    string
+	Conflicts []installConflict
+}
+
+func (e *ConflictError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s would overwrite files installed by other modules; install with --overwrite or --keep-existing:", e.Module)
+	for _, conflict := range e.Conflicts {
+		for _, file := range conflict.Files {
+			fmt.Fprintf(&b, "\n  %s (%s)", file, conflict.Owner)
+		}
+	}
+	return b.String()
+}
+
+// resolveConflicts checks the definitions and templates in src, which
+// install to <base>/<org> in dest, against what is already installed.
+// Conflicts fail the install by default. With --keep-existing, the paths
+// to skip are returned, and with --overwrite, the paths are removed
+// from the manifests of the modules that installed them.
+func (c *InstallCmd) resolveConflicts(src, dest, org, module string) (map[string]struct{}, error) {
+	if c.Overwrite && c.KeepExisting {
+		return nil, errors.New("--overwrite cannot be combined with --keep-existing")
+	}
+	owners, err := installedPaths(dest)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []installConflict
+	for _, base := range []string{PartDefinitions, PartTemplates} {
+		if !c.includes(base) {
+			continue
+		}
+		children, err := os.ReadDir(filepath.Join(src, base))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		for _, child := range children {
+			rel := path.Join(base, org, child.Name())
+			owner, owned := owners[rel]
+			if owned && owner == module || !owned && isBaseDependencyPath(module, rel) {
+				continue
+			}
+			files, err := existingFiles(filepath.Join(src, base, child.Name()), filepath.Join(dest, filepath.FromSlash(rel)), rel)
+			if err != nil {
+				return nil, err
+			}
+			if len(files) == 0 {
+				continue
+			}
+			if !owned {
+				owner = "unknown owner"
+			}
+			conflicts = append(conflicts, installConflict{Path: rel, Owner: owner, Files: files})
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil, nil
+	}
+
+	switch {
+	case c.KeepExisting:
+		skip := make(map[string]struct{}, len(conflicts))
+		for _, conflict := range conflicts {
+			fmt.Printf("Keeping %s installed by %s\n", conflict.Path, conflict.Owner)
+			skip[conflict.Path] = struct{}{}
+		}
+		return skip, nil
+	case c.Overwrite:
+		for _, conflict := range conflicts {
+			fmt.Printf("Overwriting %s installed by %s\n", conflict.Path, conflict.Owner)
+			if _, owned := owners[conflict.Path]; owned {
+				if err = disown(dest, conflict.Owner, conflict.Path); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return nil, nil
+	}
+	return nil, &ConflictError{Module: module, Conflicts: conflicts}
+}
+
+// isBaseDependencyPath reports whether p is under a path installed by a
+// base dependency, which may have been installed before manifests were.
+func isBaseDependencyPath(module, p string) bool {
+	for _, dep := range baseDependencies[module] {
+		if strings.HasPrefix(p, dep+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// existingFiles returns the files under src that also exist under
+// dest, relative to the home directory using the prefix rel.
+func existingFiles(src, dest, rel string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		sub, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(filepath.Join(dest, sub)); err == nil {
+			files = append(files, path.Join(rel, filepath.ToSlash(sub)))
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// disown removes a path from the manifest of the module that installed
+// it, so that uninstalling that module does not remove it.
+func disown(homeDir, module, p string) error {
+	moduleRoot := filepath.Join(homeDir, "node_modules", filepath.FromSlash(module))
+	manifest, err := readInstallManifest(moduleRoot)
+	if err != nil {
+		return err
+	}
+	paths := manifest.Paths[:0]
+	for _, owned := range manifest.Paths {
+		if owned != p {
+			paths = append(paths, owned)
+		}
+	}
+	manifest.Paths = paths
+	return manifest.write(moduleRoot)
+}
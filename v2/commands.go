@@ -0,0 +1,175 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"os"
+
+	"github.com/alecthomas/kong"
+)
+
+// CommandSet is the commands of a distribution of the CLI, registered
+// with kong as dynamic commands. Distributions start from Commands so
+// they gain the commands added upstream, then add, remove, or rename
+// commands and set their own branding:
+//
+//	commands := cli.Commands().
+//		Remove("serve-docs").
+//		Rename("generate", "gen").
+//		Add("deploy", "Deploys generated code.", &DeployCmd{}).
+//		Name("iota")
+//	ctx := kong.Parse(&flags, commands.Options()...)
+type CommandSet struct {
+	commands    []setCommand
+	name        string
+	description string
+}
+
+type setCommand struct {
+	name string
+	help string
+	cmd  interface{}
+	tags []string
+}
+
+// Commands returns the commands of the apex CLI in the order of its help.
+// Each call returns new command values.
+func Commands() *CommandSet {
+	return (&CommandSet{}).
+		Add("install", "Install a module.", &InstallCmd{}).
+		Add("update", "Update installed modules that are out of date.", &UpdateCmd{}).
+		Add("uninstall", "Uninstall a module.", &UninstallCmd{}).
+		Add("restore", "Install missing modules listed in the dependencies of a configuration.", &RestoreCmd{}).
+		Add("vendor", "Copies the modules a configuration uses into vendor/apex, which generate uses first.", &VendorCmd{}).
+		Add("info", "Shows the dist-tags and versions of a module.", &InfoCmd{}).
+		Add("login", "Stores a token for an NPM registry or GitHub in the OS keychain.", &LoginCmd{}).
+		Add("logout", "Removes a token stored with login.", &LogoutCmd{}).
+		Add("generate", "Generate code from a configuration file.", &GenerateCmd{}).
+		Add("verify", "Verify generated code matches a report from generate --report.", &VerifyCmd{}).
+		Add("ci", "Validates specifications, checks generated files are up to date, and runs linters.", &CICmd{}).
+		Add("hooks", "Manages git hooks checking specifications and generated files before commits and pushes.", &HooksCmd{}).
+		Add("changelog", "Writes a Markdown changelog of API changes between two git revisions of a specification.", &ChangelogCmd{}).
+		Add("watch", "Watch configuration files for changes and trigger code generation.", &WatchCmd{}).
+		Add("serve-docs", "Serves generated docs or other output locally, reloading pages when regenerated with --watch.", &ServeDocsCmd{}).
+		Add("pack", "Builds a distributable module tarball.", &PackCmd{}).
+		Add("alias", "Manages module aliases.", &AliasCmd{}).
+		Add("list", "Lists installed modules.", &ListCmd{}).
+		Add("new", "Creates a new project from a template.", &NewCmd{}).
+		Add("init", "Initializes an existing project directory from a template.", &InitCmd{}).
+		Add("cache", "Manages the cache of downloaded modules.", &CacheCmd{}).
+		Add("store", "Manages the home directory holding installed modules, caches, and configuration.", &StoreCmd{}).
+		Add("bundle", "Exports and imports bundles of installed modules for offline use.", &BundleCmd{}).
+		Add("spec", "Manages specification files.", &SpecCmd{}).
+		Add("schema", "Prints the JSON Schema of apex.yaml or .template files for editor validation.", &SchemaCmd{}).
+		Add("upgrade", "Upgrades to the latest base modules dependencies.", &UpgradeCmd{}).
+		Add("probe", "Checks that the home directory is writable and base modules are installed, exiting non-zero if not.", &ProbeCmd{}).
+		Add("audit", "Shows and exports the audit log of commands run on this machine or in this project.", &AuditCmd{}).
+		Add("version", "Prints the version of the CLI.", &VersionCmd{})
+}
+
+// Add adds a command, or replaces the command with the same name keeping
+// its place. cmd is a pointer to a kong command struct with a Run method
+// and tags are extra struct tags for it, such as `hidden:""`.
+func (s *CommandSet) Add(name, help string, cmd interface{}, tags ...string) *CommandSet {
+	command := setCommand{name: name, help: help, cmd: cmd, tags: tags}
+	if i := s.index(name); i != -1 {
+		s.commands[i] = command
+	} else {
+		s.commands = append(s.commands, command)
+	}
+	return s
+}
+
+// Remove removes commands by name. Names that are not in the set are ignored.
+func (s *CommandSet) Remove(names ...string) *CommandSet {
+	for _, name := range names {
+		if i := s.index(name); i != -1 {
+			s.commands = append(s.commands[:i], s.commands[i+1:]...)
+		}
+	}
+	return s
+}
+
+// Rename changes the name a command is run with.
+func (s *CommandSet) Rename(from, to string) *CommandSet {
+	if i := s.index(from); i != -1 {
+		s.commands[i].name = to
+	}
+	return s
+}
+
+// Command returns the command struct registered under a name, or nil.
+func (s *CommandSet) Command(name string) interface{} {
+	if i := s.index(name); i != -1 {
+		return s.commands[i].cmd
+	}
+	return nil
+}
+
+// Names returns the names of the commands in order.
+func (s *CommandSet) Names() []string {
+	names := make([]string, len(s.commands))
+	for i, command := range s.commands {
+		names[i] = command.name
+	}
+	return names
+}
+
+// Name sets the program name shown in help and errors,
+// which is otherwise the name of the executable.
+func (s *CommandSet) Name(name string) *CommandSet {
+	s.name = name
+	return s
+}
+
+// Description sets the description shown at the top of help.
+func (s *CommandSet) Description(description string) *CommandSet {
+	s.description = description
+	return s
+}
+
+// Options returns the kong options registering the commands, to pass to
+// kong.Parse with a struct of global flags.
+func (s *CommandSet) Options() []kong.Option {
+	var options []kong.Option
+	for _, command := range s.commands {
+		options = append(options, kong.DynamicCommand(command.name, command.help, "", command.cmd, command.tags...))
+	}
+	if s.name != "" {
+		options = append(options, kong.Name(s.name))
+	}
+	if s.description != "" {
+		options = append(options, kong.Description(s.description))
+	}
+	return options
+}
+
+func (s *CommandSet) index(name string) int {
+	for i, command := range s.commands {
+		if command.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// VersionCmd prints the version of the CLI and its runtime.
+type VersionCmd struct{}
+
+func (c *VersionCmd) Run(ctx *Context) error {
+	return WriteVersion(os.Stdout, ctx.JSON)
+}
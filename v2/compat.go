@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CompatNodeCLI translates configurations written for
+// the original Node.js based tooling.
+const CompatNodeCLI = "node-cli"
+
+// legacyConfigFields maps Node CLI config fields to their current names.
+var legacyConfigFields = map[string]string{
+	"schema":  "spec",
+	"options": "config",
+}
+
+// legacyTargetFields maps Node CLI target fields to their current names.
+var legacyTargetFields = map[string]string{
+	"package":     "module",
+	"visitor":     "visitorClass",
+	"options":     "config",
+	"runafter":    "runAfter",
+	"ifnotexists": "ifNotExists",
+}
+
+// legacyModulePrefixes are module shortcuts used by the Node CLI.
+var legacyModulePrefixes = map[string]string{
+	"@wapc/widl-codegen": "@apexlang/codegen",
+	"@wapc/widl":         "@apexlang/core",
+}
+
+// translateLegacyConfig rewrites legacy field names and module shortcuts
+// in a decoded configuration document. Translation happens when forced
+// or when legacy fields are detected. It returns deprecation warnings
+// describing each change.
+func translateLegacyConfig(doc map[string]interface{}, force bool) []string {
+	if !force && !hasLegacyFields(doc) {
+		return nil
+	}
+
+	var warnings []string
+	warnings = append(warnings, renameFields(doc, legacyConfigFields, "")...)
+
+	generates, _ := doc["generates"].(map[string]interface{})
+	filenames := make([]string, 0, len(generates))
+	for filename := range generates {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		target, ok := generates[filename].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		warnings = append(warnings, renameFields(target, legacyTargetFields, filename+": ")...)
+		if module, ok := target["module"].(string); ok {
+			if translated := translateLegacyModule(module); translated != module {
+				target["module"] = translated
+				warnings = append(warnings, fmt.Sprintf("%smodule %q is deprecated; use %q", filename+": ", module, translated))
+			}
+		}
+	}
+
+	return warnings
+}
+
+func hasLegacyFields(doc map[string]interface{}) bool {
+	for field := range legacyConfigFields {
+		if _, ok := doc[field]; ok {
+			return true
+		}
+	}
+	generates, _ := doc["generates"].(map[string]interface{})
+	for _, t := range generates {
+		target, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for field := range legacyTargetFields {
+			if _, ok := target[field]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func renameFields(m map[string]interface{}, fields map[string]string, prefix string) []string {
+	var warnings []string
+	for legacy, current := range fields {
+		value, ok := m[legacy]
+		if !ok {
+			continue
+		}
+		delete(m, legacy)
+		if _, exists := m[current]; exists {
+			warnings = append(warnings, fmt.Sprintf("%s%q is deprecated and ignored because %q is set", prefix, legacy, current))
+			continue
+		}
+		m[current] = value
+		warnings = append(warnings, fmt.Sprintf("%s%q is deprecated; use %q", prefix, legacy, current))
+	}
+	sort.Strings(warnings)
+	return warnings
+}
+
+func translateLegacyModule(module string) string {
+	for legacy, current := range legacyModulePrefixes {
+		if module == legacy || strings.HasPrefix(module, legacy+"/") {
+			return current + strings.TrimPrefix(module, legacy)
+		}
+	}
+	return module
+}
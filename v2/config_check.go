@@ -0,0 +1,318 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// checkConfigFields returns warnings for fields of a decoded configuration
+// document that are not recognized, such as a misspelled visitorClass that
+// would otherwise silently fall back to the default visitor. Config values
+// are checked against the config schema of a target's module when the
+// module declares one in its package.json:
+//
+//	"apex": { "config": { "package": { "description": "..." } } }
+func checkConfigFields(doc map[string]interface{}) []string {
+	warnings := unknownFields("", doc, reflect.TypeOf(Config{}))
+
+	schemas := moduleSchemas{}
+	aliases := map[string]string{}
+	if docAliases, ok := doc["aliases"].(map[string]interface{}); ok {
+		for alias, module := range docAliases {
+			if module, ok := module.(string); ok {
+				aliases[alias] = module
+			}
+		}
+	}
+	generates, _ := doc["generates"].(map[string]interface{})
+	filenames := make([]string, 0, len(generates))
+	for filename := range generates {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	// Top-level config applies to every target so a key is only
+	// unknown when no module of the configuration declares it.
+	allKeys := map[string]struct{}{}
+	allDeclared := len(filenames) > 0
+	for _, filename := range filenames {
+		target, _ := generates[filename].(map[string]interface{})
+		modules := map[string]map[string]interface{}{}
+		if module, ok := target["module"].(string); ok {
+			modules[module] = target
+		}
+		visitors, _ := target["visitors"].([]interface{})
+		for _, v := range visitors {
+			if visitor, ok := v.(map[string]interface{}); ok {
+				if module, ok := visitor["module"].(string); ok {
+					modules[module] = visitor
+				}
+			}
+		}
+		if len(modules) == 0 {
+			allDeclared = false
+		}
+		names := make([]string, 0, len(modules))
+		for module := range modules {
+			names = append(names, module)
+		}
+		sort.Strings(names)
+		for _, module := range names {
+			fields := modules[module]
+			keys, ok := schemas.configKeys(resolveModuleAlias(module, aliases))
+			if !ok {
+				allDeclared = false
+				continue
+			}
+			for key := range keys {
+				allKeys[key] = struct{}{}
+			}
+			config, _ := fields["config"].(map[string]interface{})
+			for _, key := range sortedKeys(config) {
+				if _, ok := keys[key]; !ok {
+					warnings = append(warnings, unknownWarning(
+						fmt.Sprintf("generates[%s].config.%s", filename, key),
+						fmt.Sprintf("config key for %s", module), key, keys))
+				}
+			}
+		}
+	}
+	if allDeclared {
+		config, _ := doc["config"].(map[string]interface{})
+		for _, key := range sortedKeys(config) {
+			if _, ok := allKeys[key]; !ok {
+				warnings = append(warnings, unknownWarning("config."+key,
+					"config key for any module", key, allKeys))
+			}
+		}
+	}
+
+	return warnings
+}
+
+// unknownFields compares the keys of a decoded document with the
+// YAML fields of t, recursing into nested structs, maps, and slices.
+func unknownFields(path string, value interface{}, t reflect.Type) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var warnings []string
+	switch t.Kind() {
+	case reflect.Struct:
+		doc, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		fields := yamlFields(t)
+		known := make(map[string]struct{}, len(fields))
+		for name := range fields {
+			known[name] = struct{}{}
+		}
+		for _, key := range sortedKeys(doc) {
+			field, ok := fields[key]
+			if !ok {
+				warnings = append(warnings, unknownWarning(joinPath(path, key), "field", key, known))
+				continue
+			}
+			warnings = append(warnings, unknownFields(joinPath(path, key), doc[key], field.Type)...)
+		}
+	case reflect.Map:
+		doc, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		for _, key := range sortedKeys(doc) {
+			warnings = append(warnings, unknownFields(fmt.Sprintf("%s[%s]", path, key), doc[key], t.Elem())...)
+		}
+	case reflect.Slice:
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil
+		}
+		for i, item := range items {
+			warnings = append(warnings, unknownFields(fmt.Sprintf("%s[%d]", path, i), item, t.Elem())...)
+		}
+	}
+	return warnings
+}
+
+// yamlFields returns the fields of a struct by their YAML names.
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func unknownWarning(path, kind, key string, known map[string]struct{}) string {
+	warning := fmt.Sprintf("%s: unknown %s %q", path, kind, key)
+	if suggestion, ok := closestMatch(key, known); ok {
+		warning += fmt.Sprintf("; did you mean %q?", suggestion)
+	}
+	return warning
+}
+
+// closestMatch returns the known name nearest to name by edit
+// distance, ignoring case, if it is close enough to be a typo.
+func closestMatch(name string, known map[string]struct{}) (string, bool) {
+	candidates := make([]string, 0, len(known))
+	for candidate := range known {
+		candidates = append(candidates, candidate)
+	}
+	sort.Strings(candidates)
+
+	best, bestDistance := "", -1
+	for _, candidate := range candidates {
+		d := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+		if bestDistance == -1 || d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	limit := len(name) / 3
+	if limit < 2 {
+		limit = 2
+	}
+	return best, bestDistance != -1 && bestDistance <= limit
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	curr := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		curr[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(br)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// moduleSchemas caches the config keys declared by installed modules.
+type moduleSchemas map[string]map[string]struct{}
+
+// configKeys returns the config keys declared in the package.json
+// of an installed module, or false if it declares none.
+func (s moduleSchemas) configKeys(module string) (map[string]struct{}, bool) {
+	root := packageRoot(module)
+	if root == "" {
+		return nil, false
+	}
+	if keys, ok := s[root]; ok {
+		return keys, keys != nil
+	}
+	s[root] = nil
+
+	homeDir, err := apexHomeDir()
+	if err != nil {
+		return nil, false
+	}
+	packageDir, ok := installedModuleDir(homeDir, root)
+	if !ok {
+		return nil, false
+	}
+	data, err := os.ReadFile(filepath.Join(packageDir, "package.json"))
+	if err != nil {
+		return nil, false
+	}
+	var pkg struct {
+		Apex struct {
+			Config map[string]json.RawMessage `json:"config"`
+		} `json:"apex"`
+	}
+	if err = json.Unmarshal(data, &pkg); err != nil || pkg.Apex.Config == nil {
+		return nil, false
+	}
+	keys := make(map[string]struct{}, len(pkg.Apex.Config))
+	for key := range pkg.Apex.Config {
+		keys[key] = struct{}{}
+	}
+	s[root] = keys
+	return keys, true
+}
+
+// packageRoot returns the installed package of a module import path,
+// such as @apexlang/codegen for @apexlang/codegen/go, or an empty
+// string for local modules.
+func packageRoot(module string) string {
+	if module == "" || strings.HasPrefix(module, ".") || filepath.IsAbs(module) {
+		return ""
+	}
+	parts := strings.Split(module, "/")
+	if strings.HasPrefix(module, "@") {
+		if len(parts) < 2 {
+			return ""
+		}
+		return parts[0] + "/" + parts[1]
+	}
+	return parts[0]
+}
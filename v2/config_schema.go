@@ -0,0 +1,431 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// configSchemaTemplate bundles a module only to read the
+// JSON Schema it exports as configSchema, if any.
+const configSchemaTemplate = `import * as apexModule from "{{module}}";
+
+export function configSchema() {
+  const entry = Object.entries(apexModule).find(([name]) => name === "configSchema");
+  return entry && entry[1] ? JSON.stringify(entry[1]) : "";
+}
+
+js_exports["configSchema"] = configSchema;`
+
+// validateConfigSchemas checks the merged config of each visitor target
+// against the JSON Schema of its module before anything is generated, so
+// a bad value is reported with where it was set rather than as an
+// exception from deep inside the module.
+func (c *GenerateCmd) validateConfigSchemas(homeDir string, config Config) error {
+	filenames := make([]string, 0, len(config.Generates))
+	for filename := range config.Generates {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	var problems []string
+	for _, filename := range filenames {
+		target := config.Generates[filename]
+		if target.Engine != "" && target.Engine != EngineVisitor {
+			continue
+		}
+		type use struct {
+			path, module string
+			config       map[string]interface{}
+		}
+		var uses []use
+		if len(target.Visitors) > 0 {
+			for i, visitor := range target.Visitors {
+				uses = append(uses, use{
+					path:   fmt.Sprintf("generates[%s].visitors[%d].config", filename, i),
+					module: visitor.Module,
+					config: visitor.Config,
+				})
+			}
+		} else if target.Module != "" {
+			uses = append(uses, use{
+				path:   fmt.Sprintf("generates[%s].config", filename),
+				module: target.Module,
+			})
+		}
+
+		for _, u := range uses {
+			module := resolveModuleAlias(u.module, config.Aliases)
+			schema, source, err := c.configSchema(homeDir, module)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", u.path, err))
+				continue
+			}
+			if schema == nil {
+				continue
+			}
+
+			merged := make(map[string]interface{}, len(config.Config)+len(target.Config)+len(u.config))
+			// Keys only set in the top-level config are shared by every
+			// target so they are not required to be known to this module.
+			properties, _ := schema["properties"].(map[string]interface{})
+			for k, v := range config.Config {
+				if _, ok := properties[k]; ok {
+					merged[k] = v
+				}
+			}
+			for _, m := range []map[string]interface{}{target.Config, u.config} {
+				for k, v := range m {
+					merged[k] = v
+				}
+			}
+			for _, problem := range validateSchema(u.path, merged, schema) {
+				problems = append(problems, fmt.Sprintf("%s (schema from %s)", problem, source))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config for %s:\n  %s", config.Spec, strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// moduleConfigSchema is a module's config schema
+// and the file or module it was read from.
+type moduleConfigSchema struct {
+	schema map[string]interface{}
+	source string
+}
+
+// configSchema returns the JSON Schema for a module's config from
+// "apex": {"configSchema": ...} in its package.json, either the schema
+// itself or a path to it within the package, or else from the module's
+// configSchema export. Modules without a schema return nil. Schemas are
+// cached by home directory, since watch generates projects with their
+// own homes.
+func (c *GenerateCmd) configSchema(homeDir, module string) (map[string]interface{}, string, error) {
+	key := filepath.Join(homeDir, module)
+	if cached, ok := c.schemas[key]; ok {
+		return cached.schema, cached.source, nil
+	}
+	if c.schemas == nil {
+		c.schemas = map[string]moduleConfigSchema{}
+	}
+
+	schema, source, err := packageConfigSchema(homeDir, module)
+	if err != nil {
+		return nil, "", err
+	}
+	if schema == nil {
+		schema = c.exportedConfigSchema(homeDir, module)
+	}
+	c.schemas[key] = moduleConfigSchema{schema, source}
+	return schema, source, nil
+}
+
+func packageConfigSchema(homeDir, module string) (map[string]interface{}, string, error) {
+	root := packageRoot(module)
+	if root == "" {
+		return nil, module, nil
+	}
+	packageDir, ok := installedModuleDir(homeDir, root)
+	if !ok {
+		// Modules resolved from the project are bundled as they are.
+		return nil, module, nil
+	}
+	data, err := os.ReadFile(filepath.Join(packageDir, "package.json"))
+	if err != nil {
+		return nil, module, nil
+	}
+	var pkg struct {
+		Apex struct {
+			ConfigSchema json.RawMessage `json:"configSchema"`
+		} `json:"apex"`
+	}
+	if err = json.Unmarshal(data, &pkg); err != nil || len(pkg.Apex.ConfigSchema) == 0 {
+		return nil, module, nil
+	}
+
+	source := root + "/package.json"
+	raw := pkg.Apex.ConfigSchema
+	var schemaFile string
+	if json.Unmarshal(raw, &schemaFile) == nil {
+		path := filepath.Join(packageDir, filepath.FromSlash(schemaFile))
+		if rel, err := filepath.Rel(packageDir, path); err != nil || strings.HasPrefix(rel, "..") {
+			return nil, "", fmt.Errorf("config schema %s of %s is outside the package", schemaFile, root)
+		}
+		if raw, err = readLocalFile(path, MaxConfigSize); err != nil {
+			return nil, "", fmt.Errorf("could not read config schema of %s: %w", root, err)
+		}
+		source = root + "/" + filepath.ToSlash(filepath.Clean(schemaFile))
+	}
+	var schema map[string]interface{}
+	if err = json.Unmarshal(raw, &schema); err != nil {
+		return nil, "", fmt.Errorf("invalid config schema %s: %w", source, err)
+	}
+	return schema, source, nil
+}
+
+// exportedConfigSchema bundles the module to read its configSchema
+// export. Modules that fail to bundle are left for generation to
+// report.
+func (c *GenerateCmd) exportedConfigSchema(homeDir, module string) map[string]interface{} {
+	workingDir, err := os.Getwd()
+	if err != nil {
+		workingDir = "."
+	}
+	source := strings.Replace(configSchemaTemplate, "{{module}}",
+		resolveModuleImport(homeDir, workingDir, module), 1)
+	res, err := runScript(c.context(), homeDir, false, source, "configSchema")
+	if err != nil {
+		return nil
+	}
+	data, _ := res.(string)
+	var schema map[string]interface{}
+	if data == "" || json.Unmarshal([]byte(data), &schema) != nil {
+		return nil
+	}
+	return schema
+}
+
+// validateSchema validates a decoded YAML value against the commonly
+// used subset of JSON Schema: type, enum, const, properties, required,
+// additionalProperties, items, the numeric, string, and array bounds,
+// pattern, allOf, anyOf, and oneOf. Other keywords are ignored.
+func validateSchema(path string, value interface{}, schema map[string]interface{}) []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 {
+		actual := jsonType(value)
+		ok := false
+		for _, t := range types {
+			if t == actual || (t == "number" && actual == "integer") {
+				ok = true
+			}
+		}
+		if !ok {
+			add("expected %s, got %s", strings.Join(types, " or "), actual)
+			// Further keywords would only repeat the mismatch.
+			return problems
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if jsonEqual(value, allowed) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			add("%s is not one of %s", jsonString(value), jsonString(enum))
+		}
+	}
+	if constant, ok := schema["const"]; ok && !jsonEqual(value, constant) {
+		add("must be %s", jsonString(constant))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				if name, ok := name.(string); ok {
+					if _, ok := v[name]; !ok {
+						add("missing required key %q", name)
+					}
+				}
+			}
+		}
+		for _, key := range sortedKeys(v) {
+			keyPath := joinPath(path, key)
+			if property, ok := properties[key].(map[string]interface{}); ok {
+				problems = append(problems, validateSchema(keyPath, v[key], property)...)
+				continue
+			}
+			if _, ok := properties[key]; ok {
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					known := make(map[string]struct{}, len(properties))
+					for name := range properties {
+						known[name] = struct{}{}
+					}
+					problems = append(problems, unknownWarning(keyPath, "config key", key, known))
+				}
+			case map[string]interface{}:
+				problems = append(problems, validateSchema(keyPath, v[key], additional)...)
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				problems = append(problems, validateSchema(fmt.Sprintf("%s[%d]", path, i), item, items)...)
+			}
+		}
+		if min, ok := schemaNumber(schema["minItems"]); ok && float64(len(v)) < min {
+			add("must have at least %v items", min)
+		}
+		if max, ok := schemaNumber(schema["maxItems"]); ok && float64(len(v)) > max {
+			add("must have at most %v items", max)
+		}
+	case string:
+		length := float64(len([]rune(v)))
+		if min, ok := schemaNumber(schema["minLength"]); ok && length < min {
+			add("must be at least %v characters", min)
+		}
+		if max, ok := schemaNumber(schema["maxLength"]); ok && length > max {
+			add("must be at most %v characters", max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				add("%q does not match %s", v, pattern)
+			}
+		}
+	default:
+		if n, ok := schemaNumber(value); ok {
+			if min, ok := schemaNumber(schema["minimum"]); ok && n < min {
+				add("must be at least %v", min)
+			}
+			if max, ok := schemaNumber(schema["maximum"]); ok && n > max {
+				add("must be at most %v", max)
+			}
+			if min, ok := schemaNumber(schema["exclusiveMinimum"]); ok && n <= min {
+				add("must be greater than %v", min)
+			}
+			if max, ok := schemaNumber(schema["exclusiveMaximum"]); ok && n >= max {
+				add("must be less than %v", max)
+			}
+		}
+	}
+
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			if sub, ok := sub.(map[string]interface{}); ok {
+				problems = append(problems, validateSchema(path, value, sub)...)
+			}
+		}
+	}
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		options, ok := schema[keyword].([]interface{})
+		if !ok {
+			continue
+		}
+		matches := 0
+		for _, sub := range options {
+			if sub, ok := sub.(map[string]interface{}); ok && len(validateSchema(path, value, sub)) == 0 {
+				matches++
+			}
+		}
+		switch {
+		case matches == 0:
+			add("does not match any of the allowed schemas")
+		case keyword == "oneOf" && matches > 1:
+			add("matches more than one of the allowed schemas")
+		}
+	}
+
+	return problems
+}
+
+func schemaTypes(t interface{}) []string {
+	switch t := t.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, name := range t {
+			if name, ok := name.(string); ok {
+				types = append(types, name)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// jsonType returns the JSON Schema type of a decoded YAML or JSON value.
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		if n, ok := schemaNumber(v); ok {
+			if n == math.Trunc(n) {
+				return "integer"
+			}
+			return "number"
+		}
+	}
+	return reflect.TypeOf(value).String()
+}
+
+func schemaNumber(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func jsonEqual(a, b interface{}) bool {
+	if an, ok := schemaNumber(a); ok {
+		bn, ok := schemaNumber(b)
+		return ok && an == bn
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func jsonString(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigEnv is the environment variable holding a configuration
+// that is used when no configuration file is given. It may be
+// YAML or JSON, either as is or encoded as base64.
+const ConfigEnv = "APEX_CONFIG"
+
+// loadConfig reads the configuration from stdin, the
+// environment, or the configuration file, in that order.
+func (c *GenerateCmd) loadConfig() error {
+	if c.configData != nil {
+		return nil
+	}
+
+	var err error
+	switch value, inEnv := os.LookupEnv(ConfigEnv); {
+	case c.StdinConfig:
+		if c.Config != "" {
+			return errors.New("--stdin-config cannot be combined with a configuration file")
+		}
+		c.Config = "<stdin>"
+		c.configData, err = limitReader(c.Config, os.Stdin, MaxConfigSize)
+	case c.Config == "" && inEnv:
+		c.Config = "$" + ConfigEnv
+		c.configData, err = decodeEnvConfig(value)
+	default:
+		if c.Config == "" {
+			c.Config = "apex.yaml"
+		}
+		c.configData, err = readFile(c.Config, MaxConfigSize)
+	}
+
+	return err
+}
+
+// decodeEnvConfig returns the configuration in the value of ConfigEnv.
+// Configurations always contain a colon, which base64 never does.
+func decodeEnvConfig(value string) ([]byte, error) {
+	if len(value) > int(MaxConfigSize) {
+		return nil, fmt.Errorf("%s: %w (limit is %d bytes)", ConfigEnv, ErrInputTooLarge, MaxConfigSize)
+	}
+	if strings.Contains(value, ":") {
+		return []byte(value), nil
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(value), ""))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not YAML, JSON, or base64: %w", ConfigEnv, err)
+	}
+	return data, nil
+}
+
+// ConfigOverrides replace values in every configuration
+// document without editing the configuration file.
+type ConfigOverrides struct {
+	Spec   string            `help:"Override the spec of every configuration." placeholder:"FILE"`
+	Config map[string]string `help:"Override a config value, such as --config=package=example. Nested keys are separated by dots." mapsep:"none" placeholder:"KEY=VALUE"`
+}
+
+// apply overrides the spec and config values of a configuration.
+// Config values also replace those set on individual targets.
+func (o *ConfigOverrides) apply(config *Config) error {
+	if o.Spec != "" {
+		config.Spec = o.Spec
+	}
+	if len(o.Config) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(o.Config))
+	for key := range o.Config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if config.Config == nil {
+		config.Config = map[string]interface{}{}
+	}
+	for _, key := range keys {
+		// Values are YAML so that numbers, booleans,
+		// and lists keep their types.
+		var value interface{} = o.Config[key]
+		if err := yaml.Unmarshal([]byte(o.Config[key]), &value); err != nil || value == nil {
+			value = o.Config[key]
+		}
+		if err := setConfigValue(config.Config, key, value); err != nil {
+			return err
+		}
+		for filename, target := range config.Generates {
+			if target.Config == nil {
+				continue
+			}
+			if err := setConfigValue(target.Config, key, value); err != nil {
+				return fmt.Errorf("%s: %w", filename, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// setConfigValue sets a value in a config map, creating
+// the maps for each part of a dotted key as needed.
+func setConfigValue(m map[string]interface{}, key string, value interface{}) error {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		child, ok := m[part].(map[string]interface{})
+		if !ok {
+			if _, exists := m[part]; exists {
+				return fmt.Errorf("cannot override %s: %s is not a map", key, part)
+			}
+			child = map[string]interface{}{}
+			m[part] = child
+		}
+		m = child
+	}
+	m[parts[len(parts)-1]] = value
+	return nil
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kong"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+// HomeEnv sets the Apex home directory used instead of ~/.apex, such
+// as a volume mounted into a container so that installed modules and
+// caches outlive it.
+const HomeEnv = "APEX_HOME"
+
+// NoColorEnv disables colored output when set to any value.
+const NoColorEnv = "NO_COLOR"
+
+// ConfigureOutput disables colored output when stdout is not a
+// terminal, as when running in a container or pipeline, or when
+// NO_COLOR is set. Programs embedding this package call it before
+// running commands.
+func ConfigureOutput() {
+	if os.Getenv(NoColorEnv) != "" || !isTerminal(os.Stdout) {
+		text.DisableColors()
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// VersionInfo identifies the CLI for scripts and container probes.
+type VersionInfo struct {
+	Version    string `json:"version"`
+	APIVersion string `json:"apiVersion"`
+	GoVersion  string `json:"goVersion"`
+	OS         string `json:"os"`
+	Arch       string `json:"arch"`
+}
+
+// CurrentVersion returns the version of the running CLI.
+func CurrentVersion() VersionInfo {
+	return VersionInfo{
+		Version:    Version,
+		APIVersion: APIVersion,
+		GoVersion:  runtime.Version(),
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+	}
+}
+
+// WriteVersion writes the version of the CLI as text, or as
+// JSON when asJSON is set.
+func WriteVersion(w io.Writer, asJSON bool) error {
+	info := CurrentVersion()
+	if asJSON {
+		return writeJSON(w, info)
+	}
+	_, err := fmt.Fprintf(w, "apex version %s %s/%s\n", info.Version, info.OS, info.Arch)
+	return err
+}
+
+// VersionFlag prints the version and exits, as JSON when the
+// global --json flag is also given.
+type VersionFlag bool
+
+// BeforeApply prints the version before a command is required.
+func (v VersionFlag) BeforeApply(app *kong.Kong, ctx *kong.Context) error {
+	if err := WriteVersion(app.Stdout, jsonFlag(ctx)); err != nil {
+		return err
+	}
+	app.Exit(0)
+	return nil
+}
+
+// jsonFlag returns whether --json was given, wherever it appears.
+func jsonFlag(ctx *kong.Context) bool {
+	for _, flag := range ctx.Flags() {
+		if flag.Name == "json" {
+			set, _ := ctx.FlagValue(flag).(bool)
+			return set
+		}
+	}
+	return false
+}
+
+// ProbeCmd checks that the CLI can run in its environment, such as
+// for the health check of a container. It fails when a check fails.
+type ProbeCmd struct{}
+
+// ProbeResult is the outcome of a probe, printed as JSON with --json.
+type ProbeResult struct {
+	VersionInfo
+	Home    string       `json:"home"`
+	Healthy bool         `json:"healthy"`
+	Checks  []ProbeCheck `json:"checks"`
+}
+
+// ProbeCheck is a single check of a probe.
+type ProbeCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+func (c *ProbeCmd) Run(ctx *Context) error {
+	result := ProbeResult{VersionInfo: CurrentVersion(), Healthy: true}
+	check := func(name string, err error) {
+		probe := ProbeCheck{Name: name, OK: err == nil}
+		if err != nil {
+			probe.Error = err.Error()
+			result.Healthy = false
+		}
+		result.Checks = append(result.Checks, probe)
+	}
+
+	// Probes only inspect the home directory so that they
+	// never install the base dependencies.
+	homeDir, err := ensureHomeDirectory()
+	result.Home = homeDir
+	if err == nil {
+		err = checkWritable(homeDir)
+	}
+	check("home", err)
+	if err == nil {
+		check("modules", checkBaseDependencies(homeDir))
+	}
+
+	if ctx != nil && ctx.JSON {
+		if err = writeJSON(os.Stdout, result); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("apex version %s %s/%s\n", result.Version, result.OS, result.Arch)
+		fmt.Printf("home: %s\n", result.Home)
+		for _, probe := range result.Checks {
+			if probe.OK {
+				fmt.Printf("%s: ok\n", probe.Name)
+			} else {
+				fmt.Printf("%s: %s\n", probe.Name, probe.Error)
+			}
+		}
+	}
+	if !result.Healthy {
+		return errors.New("probe failed")
+	}
+	return nil
+}
+
+// checkWritable checks that files can be created in dir, which
+// fails for read-only volumes.
+func checkWritable(dir string) error {
+	f, err := os.CreateTemp(dir, "probe-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// checkBaseDependencies returns an error naming the base
+// dependencies that are not installed.
+func checkBaseDependencies(homeDir string) error {
+	var missing []string
+	for dependency, checks := range baseDependencies {
+		for _, check := range checks {
+			if _, err := os.Stat(filepath.Join(homeDir, filepath.FromSlash(check))); err != nil {
+				missing = append(missing, dependency)
+				break
+			}
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("not installed: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
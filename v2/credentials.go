@@ -0,0 +1,301 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tcnksm/go-input"
+	"gopkg.in/yaml.v3"
+)
+
+// credentialsFile, in the home directory, holds tokens
+// used when they are not set in the environment.
+const credentialsFile = "credentials.yaml"
+
+// githubCredential is the name of the GitHub token among
+// those stored with apex login. Registry tokens are named
+// like .npmrc credentials, such as //npm.example.com/.
+const githubCredential = "github"
+
+type credentials struct {
+	GitHub struct {
+		Token string `yaml:"token,omitempty"`
+	} `yaml:"github,omitempty"`
+	GitLab struct {
+		Token string `yaml:"token,omitempty"`
+	} `yaml:"gitlab,omitempty"`
+	Bitbucket bitbucketAuth `yaml:"bitbucket,omitempty"`
+	// Registries are the tokens of NPM registries by URL
+	// without their scheme, such as //npm.example.com/.
+	Registries map[string]string `yaml:"registries,omitempty"`
+	// Keychain lists the tokens stored in the OS keychain,
+	// so it is only queried for those.
+	Keychain []string `yaml:"keychain,omitempty"`
+}
+
+// credentialsPath returns the path of ~/.apex/credentials.yaml.
+func credentialsPath() (string, error) {
+	homeDir, err := apexHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, credentialsFile), nil
+}
+
+// readCredentials reads ~/.apex/credentials.yaml,
+// returning no credentials when it does not exist.
+func readCredentials() *credentials {
+	var creds credentials
+	path, err := credentialsPath()
+	if err != nil {
+		return &creds
+	}
+	data, err := readLocalFile(path, MaxConfigSize)
+	if err != nil {
+		return &creds
+	}
+	if err = yaml.Unmarshal(data, &creds); err != nil {
+		fmt.Printf("Could not parse %s: %v\n", credentialsFile, err)
+	}
+	return &creds
+}
+
+// write replaces the credentials file, which only
+// the user can read since it may hold tokens.
+func (c *credentials) write() error {
+	path, err := credentialsPath()
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	// Temporary files are created with 0600.
+	f, err := os.CreateTemp(filepath.Dir(path), "credentials-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// token returns the token stored for name with apex login,
+// reading it from the OS keychain if it was stored there.
+func (c *credentials) token(name string) string {
+	if c.inKeychain(name) {
+		token, err := keychainGet(name)
+		if err != nil {
+			fmt.Printf("Could not read %s from the OS keychain: %v\n", name, err)
+		}
+		return token
+	}
+	if name == githubCredential {
+		return c.GitHub.Token
+	}
+	return c.Registries[name]
+}
+
+func (c *credentials) inKeychain(name string) bool {
+	for _, stored := range c.Keychain {
+		if stored == name {
+			return true
+		}
+	}
+	return false
+}
+
+// registries returns the names of the registries with stored tokens.
+func (c *credentials) registries() []string {
+	var names []string
+	for name := range c.Registries {
+		names = append(names, name)
+	}
+	for _, name := range c.Keychain {
+		if _, ok := c.Registries[name]; !ok && name != githubCredential {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setToken stores a token in the file, or removes it when empty.
+func (c *credentials) setToken(name, token string) {
+	if name == githubCredential {
+		c.GitHub.Token = token
+		return
+	}
+	if token == "" {
+		delete(c.Registries, name)
+		return
+	}
+	if c.Registries == nil {
+		c.Registries = make(map[string]string)
+	}
+	c.Registries[name] = token
+}
+
+// setKeychain records whether the token of name is in the OS keychain.
+func (c *credentials) setKeychain(name string, stored bool) {
+	keychain := c.Keychain[:0]
+	for _, existing := range c.Keychain {
+		if existing != name {
+			keychain = append(keychain, existing)
+		}
+	}
+	if stored {
+		keychain = append(keychain, name)
+	}
+	c.Keychain = keychain
+}
+
+// credentialName returns the name tokens for target are stored under:
+// githubCredential for github, and otherwise the URL of the registry,
+// which defaults to the one modules are installed from, without its
+// scheme and with a trailing slash, as npm matches credentials.
+func credentialName(target string) (string, error) {
+	if target == githubCredential {
+		return githubCredential, nil
+	}
+	if target == "" {
+		target = loadNPMConfig().registryFor("")
+	}
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return "", fmt.Errorf("%s is not github or the URL of a registry, such as https://npm.example.com", target)
+	}
+	return "//" + u.Host + strings.TrimSuffix(u.Path, "/") + "/", nil
+}
+
+type LoginCmd struct {
+	Target     string `arg:"" optional:"" help:"github, or the URL of an NPM registry. Defaults to the registry modules are installed from."`
+	TokenStdin bool   `help:"Read the token from stdin instead of prompting for it."`
+	Store      string `help:"Where to store the token: the OS keychain when there is one and otherwise ~/.apex/credentials.yaml (auto), only the keychain, or only the file." enum:"auto,keychain,file" default:"auto"`
+}
+
+func (c *LoginCmd) Run(ctx *Context) error {
+	name, err := credentialName(c.Target)
+	if err != nil {
+		return err
+	}
+
+	var token string
+	if c.TokenStdin {
+		data, err := io.ReadAll(io.LimitReader(os.Stdin, MaxConfigSize))
+		if err != nil {
+			return err
+		}
+		token = strings.TrimSpace(string(data))
+	} else {
+		ui := &input.UI{
+			Writer: os.Stdout,
+			Reader: os.Stdin,
+		}
+		if token, err = ui.Ask("Token for "+name, &input.Options{
+			Required:  true,
+			Mask:      true,
+			HideOrder: true,
+		}); err != nil {
+			return err
+		}
+		token = strings.TrimSpace(token)
+	}
+	if token == "" {
+		return errors.New("no token was given")
+	}
+
+	creds := readCredentials()
+	if c.Store != "file" {
+		err = keychainSet(name, token)
+		if err == nil {
+			creds.setToken(name, "")
+			creds.setKeychain(name, true)
+			if err = creds.write(); err != nil {
+				return err
+			}
+			fmt.Printf("Logged in to %s; the token is stored in the OS keychain\n", name)
+			return nil
+		}
+		if c.Store == "keychain" {
+			return err
+		}
+		fmt.Printf("Could not store the token in the OS keychain: %v\n", err)
+	}
+
+	if creds.inKeychain(name) {
+		if err = keychainDelete(name); err != nil {
+			return err
+		}
+		creds.setKeychain(name, false)
+	}
+	creds.setToken(name, token)
+	if err = creds.write(); err != nil {
+		return err
+	}
+	path, _ := credentialsPath()
+	fmt.Printf("Logged in to %s; the token is stored in %s\n", name, path)
+	return nil
+}
+
+type LogoutCmd struct {
+	Target string `arg:"" optional:"" help:"github, or the URL of an NPM registry. Defaults to the registry modules are installed from."`
+}
+
+func (c *LogoutCmd) Run(ctx *Context) error {
+	name, err := credentialName(c.Target)
+	if err != nil {
+		return err
+	}
+	creds := readCredentials()
+	inKeychain := creds.inKeychain(name)
+	if !inKeychain && creds.token(name) == "" {
+		return fmt.Errorf("not logged in to %s", name)
+	}
+	if inKeychain {
+		if err = keychainDelete(name); err != nil {
+			return err
+		}
+		creds.setKeychain(name, false)
+	}
+	creds.setToken(name, "")
+	if err = creds.write(); err != nil {
+		return err
+	}
+	fmt.Printf("Logged out of %s\n", name)
+	return nil
+}
@@ -0,0 +1,360 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// generateAll generates configs in dependency order. A config depends
+// on another when its spec, or a file listed in its own or one of its
+// targets' dependsOn, is generated by the other config. Independent
+// configs generate in parallel.
+func (c *GenerateCmd) generateAll(configs []Config) error {
+	if err := checkSharedOutputs(configs); err != nil {
+		return err
+	}
+	deps, err := configDependencies(configs)
+	if err != nil {
+		return err
+	}
+
+	if len(configs) == 1 {
+		return c.generate(configs[0])
+	}
+
+	// Install base dependencies once rather than racing in each worker.
+	if _, err = getHomeDirectory(); err != nil {
+		return err
+	}
+
+	var (
+		mu   sync.Mutex
+		merr error
+		wg   sync.WaitGroup
+	)
+	done := make([]chan struct{}, len(configs))
+	failed := make([]bool, len(configs))
+	for i := range configs {
+		done[i] = make(chan struct{})
+	}
+
+	for i := range configs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer close(done[i])
+
+			for _, dep := range deps[i] {
+				<-done[dep]
+			}
+			mu.Lock()
+			for _, dep := range deps[i] {
+				if failed[dep] {
+					failed[i] = true
+					merr = appendTargetError(merr, "", GenerationPhaseDependency, "skipping config for %s: dependency %s failed", configs[i].Spec, configs[dep].Spec)
+					mu.Unlock()
+					c.summary.failTargets(configs[i])
+					return
+				}
+			}
+			mu.Unlock()
+
+			// Each worker has its own formatter since the
+			// JavaScript runtime is not safe for concurrent use.
+			worker := &GenerateCmd{
+				ShowEntrypoints: c.ShowEntrypoints,
+				OnFormatError:   c.OnFormatError,
+				Jobs:            c.Jobs,
+				ctx:             c.ctx,
+				outputDir:       c.outputDir,
+				skipRunAfter:    c.skipRunAfter,
+				report:          c.report,
+				summary:         c.summary,
+				state:           c.state,
+			}
+			if err := worker.generateConfig(configs[i]); err != nil {
+				mu.Lock()
+				failed[i] = true
+				merr = appendError(merr, err)
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	return merr
+}
+
+// runTargets calls generate with the index of each target in order,
+// the order of targetOrder, running up to --jobs at once. A target
+// starts once the targets it depends on are done.
+func (c *GenerateCmd) runTargets(config Config, order []string, generate func(i int)) {
+	jobs := c.Jobs
+	if jobs <= 1 || len(order) == 1 {
+		for i := range order {
+			generate(i)
+		}
+		return
+	}
+
+	index := make(map[string]int, len(order))
+	for i, filename := range order {
+		index[filepath.Clean(filename)] = i
+	}
+	done := make([]chan struct{}, len(order))
+	for i := range order {
+		done[i] = make(chan struct{})
+	}
+
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, filename := range order {
+		wg.Add(1)
+		go func(i int, filename string) {
+			defer wg.Done()
+			defer close(done[i])
+
+			for _, file := range config.Generates[filename].DependsOn {
+				if dep, ok := index[filepath.Clean(file)]; ok {
+					<-done[dep]
+				}
+			}
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			generate(i)
+		}(i, filename)
+	}
+	wg.Wait()
+}
+
+// checkSharedOutputs fails when more than one config generates the same
+// file, unless every target writing it sets append. Appending targets
+// after the first are marked to append to the file rather than replace
+// it, in config order. Targets within a config always write different
+// files since a config's visitors already combine outputs.
+func checkSharedOutputs(configs []Config) error {
+	type output struct {
+		config   int
+		filename string
+	}
+	outputs := make(map[string][]output)
+	var paths []string
+	for i, config := range configs {
+		filenames := make([]string, 0, len(config.Generates))
+		for filename := range config.Generates {
+			filenames = append(filenames, filename)
+		}
+		sort.Strings(filenames)
+		for _, filename := range filenames {
+			path := filepath.Clean(filename)
+			for _, other := range outputs[path] {
+				if other.config == i {
+					return fmt.Errorf("%s and %s in config for %s are the same file", other.filename, filename, config.Spec)
+				}
+			}
+			if _, ok := outputs[path]; !ok {
+				paths = append(paths, path)
+			}
+			outputs[path] = append(outputs[path], output{i, filename})
+		}
+	}
+
+	for _, path := range paths {
+		shared := outputs[path]
+		if len(shared) < 2 {
+			continue
+		}
+		specs := make([]string, len(shared))
+		appendAll := true
+		for i, o := range shared {
+			specs[i] = configs[o.config].Spec
+			if !configs[o.config].Generates[o.filename].Append {
+				appendAll = false
+			}
+		}
+		if !appendAll {
+			return fmt.Errorf("%s is generated by more than one config (%s); set append: true on each target to concatenate them", path, strings.Join(specs, ", "))
+		}
+		for i, o := range shared {
+			target := configs[o.config].Generates[o.filename]
+			if target.IfNotExists {
+				return fmt.Errorf("%s in config for %s cannot set both append and ifNotExists", o.filename, specs[i])
+			}
+			switch strings.ToLower(target.Encoding) {
+			case "", "utf-8", "utf8":
+			default:
+				return fmt.Errorf("%s in config for %s cannot append with encoding %q", o.filename, specs[i], target.Encoding)
+			}
+			if i > 0 {
+				target.appending = true
+				configs[o.config].Generates[o.filename] = target
+			}
+		}
+	}
+
+	return nil
+}
+
+// configDependencies returns the indexes of the configs each config
+// depends on, failing when a dependency is unknown or the configs
+// depend on each other in a cycle. Configs appending to a file depend
+// on the configs that write it before them.
+func configDependencies(configs []Config) ([][]int, error) {
+	producers := make(map[string][]int)
+	for i, config := range configs {
+		for filename := range config.Generates {
+			path := filepath.Clean(filename)
+			producers[path] = append(producers[path], i)
+		}
+	}
+
+	deps := make([][]int, len(configs))
+	for i, config := range configs {
+		seen := make(map[int]bool)
+		depend := func(j int) {
+			if j != i && !seen[j] {
+				seen[j] = true
+				deps[i] = append(deps[i], j)
+			}
+		}
+		add := func(file string, required bool) error {
+			js, ok := producers[filepath.Clean(file)]
+			if !ok {
+				if required {
+					if _, err := os.Stat(file); err != nil {
+						return fmt.Errorf("unknown dependency %q for %s: not a generated file and %w", file, config.Spec, err)
+					}
+				}
+				return nil
+			}
+			for _, j := range js {
+				depend(j)
+			}
+			return nil
+		}
+
+		for filename := range config.Generates {
+			for _, j := range producers[filepath.Clean(filename)] {
+				if j < i {
+					depend(j)
+				}
+			}
+		}
+		if err := add(config.Spec, false); err != nil {
+			return nil, err
+		}
+		for _, file := range config.DependsOn {
+			if err := add(file, true); err != nil {
+				return nil, err
+			}
+		}
+		for _, target := range config.Generates {
+			for _, file := range target.DependsOn {
+				if err := add(file, true); err != nil {
+					return nil, err
+				}
+			}
+		}
+		sort.Ints(deps[i])
+	}
+
+	// Visit each config depth first to detect cycles.
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(configs))
+	var path []string
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s -> %s", strings.Join(path, " -> "), configs[i].Spec)
+		case visited:
+			return nil
+		}
+		state[i] = visiting
+		path = append(path, configs[i].Spec)
+		for _, dep := range deps[i] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+		return nil
+	}
+	for i := range configs {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+
+	return deps, nil
+}
+
+// targetOrder returns a config's target filenames sorted so that
+// targets come after the targets they depend on.
+func targetOrder(config Config) ([]string, error) {
+	filenames := make([]string, 0, len(config.Generates))
+	byPath := make(map[string]string, len(config.Generates))
+	for filename := range config.Generates {
+		filenames = append(filenames, filename)
+		byPath[filepath.Clean(filename)] = filename
+	}
+	sort.Strings(filenames)
+
+	order := make([]string, 0, len(filenames))
+	visiting := make(map[string]bool)
+	visited := make(map[string]bool)
+	var visit func(filename string, path []string) error
+	visit = func(filename string, path []string) error {
+		if visited[filename] {
+			return nil
+		}
+		path = append(path, filename)
+		if visiting[filename] {
+			return fmt.Errorf("dependency cycle: %s", strings.Join(path, " -> "))
+		}
+		visiting[filename] = true
+		for _, file := range config.Generates[filename].DependsOn {
+			if dep, ok := byPath[filepath.Clean(file)]; ok {
+				if err := visit(dep, path); err != nil {
+					return err
+				}
+			}
+		}
+		visiting[filename] = false
+		visited[filename] = true
+		order = append(order, filename)
+		return nil
+	}
+	for _, filename := range filenames {
+		if err := visit(filename, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cli implements the apex command line tool and can be embedded
+// by other programs to install modules and generate code.
+//
+// # API stability
+//
+// The exported identifiers of this package follow semantic versioning,
+// reported by APIVersion. Within a major version:
+//
+//   - Configuration types (Config, Target, Visitor, Command, Template,
+//     Variable and CIConfig) only gain fields. Their JSON and YAML field
+//     names do not change, so files written for an earlier minor version
+//     keep working.
+//   - Command types (the types ending in Cmd) can be constructed and run
+//     with Run. Their exported fields mirror command line flags and may
+//     gain fields, so construct them with field names.
+//   - Exported functions and variables keep their signatures and behavior.
+//   - Unexported identifiers and the package's output text are not part
+//     of the API.
+//
+// Code that is not part of the API, such as the extraction of module
+// archives, is in packages under internal. Some exported identifiers of
+// this package are only there for the apex command and are not covered
+// beyond what is listed above; they move to internal packages as they
+// are separated from the commands.
+//
+// The package github.com/apexlang/cli is version 1 of this API. It
+// aliases the types and constants of this package and wraps its
+// functions, so that programs written for it keep working unchanged.
+//
+// # Deprecation
+//
+// Identifiers that will be removed are marked with a "Deprecated:"
+// paragraph naming their replacement. They keep working for at least two
+// minor releases and are only removed in the next major version.
+// Deprecated configuration fields are translated with a warning, as done
+// for configurations written for the Node.js CLI.
+//
+// # Containers
+//
+// Images that run the CLI without a terminal set APEX_HOME to a mounted
+// volume so installed modules and caches are kept between runs, and call
+// ConfigureOutput so that output is not colored. "apex --version --json"
+// and "apex probe --json" report the CLI and the health of its
+// environment for scripts and health checks.
+//
+// # Distributions
+//
+// Programs that distribute the CLI under their own name register the
+// commands of Commands with kong, adding, removing, or renaming commands
+// on the returned CommandSet, and set HomeDirName and AddDependencies
+// for their own home directory and base modules. They then gain the
+// commands added upstream without declaring them again.
+//
+// # Exit status
+//
+// The apex command exits with 0 when it succeeds and 1 when a command
+// fails or its arguments are invalid. Commands whose errors implement
+// ExitCode() int exit with that code instead: ci combines the codes of
+// the steps that failed (1 validate, 2 check, 4 lint).
+package cli
@@ -0,0 +1,177 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// DotenvFile is loaded from the working directory before the
+// envFiles of a configuration.
+const DotenvFile = ".env"
+
+var (
+	// envNamePattern matches the names of variables in .env files.
+	envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// interpolationPattern matches ${NAME} in configuration values.
+	interpolationPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+)
+
+// loadDotenv sets environment variables from .env and then the envFiles
+// of each config, with later files overriding earlier ones. Variables
+// already in the environment take precedence over all of them. A
+// missing .env is ignored but listed envFiles must exist.
+func loadDotenv(configs []Config) error {
+	values := make(map[string]string)
+	var order []string
+	load := func(filename string, required bool) error {
+		data, err := readLocalFile(filename, MaxConfigSize)
+		if err != nil {
+			if !required && errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		parsed, err := parseDotenv(filename, data)
+		if err != nil {
+			return err
+		}
+		for _, kv := range parsed {
+			if _, seen := values[kv[0]]; !seen {
+				order = append(order, kv[0])
+			}
+			values[kv[0]] = kv[1]
+		}
+		return nil
+	}
+
+	if err := load(DotenvFile, false); err != nil {
+		return err
+	}
+	for _, config := range configs {
+		for _, filename := range config.EnvFiles {
+			if err := load(filename, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, name := range order {
+		if _, set := os.LookupEnv(name); set {
+			continue
+		}
+		if err := os.Setenv(name, values[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseDotenv parses NAME=value lines, which may start with export.
+// Values may be single quoted, taken literally, or double quoted,
+// where \n, \t, \" and \\ are unescaped. Unquoted values end at a
+// comment starting with " #".
+func parseDotenv(filename string, data []byte) ([][2]string, error) {
+	var values [][2]string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, found := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !found || !envNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("%s:%d: expected NAME=value", filename, n)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case len(value) >= 2 && value[0] == '\'' && strings.HasSuffix(value, "'"):
+			value = value[1 : len(value)-1]
+		case len(value) >= 2 && value[0] == '"' && strings.HasSuffix(value, `"`):
+			value = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\"`, `"`, `\\`, `\`).Replace(value[1 : len(value)-1])
+		default:
+			if i := strings.Index(value, " #"); i != -1 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		values = append(values, [2]string{name, value})
+	}
+	return values, scanner.Err()
+}
+
+// interpolateConfig replaces ${NAME} with environment variables in the
+// config values and runAfter commands of a config. References to
+// variables that are not set are left as they are.
+func interpolateConfig(config *Config) {
+	config.Config = interpolateMap(config.Config)
+	for filename, target := range config.Generates {
+		target.Config = interpolateMap(target.Config)
+		for i := range target.Visitors {
+			target.Visitors[i].Config = interpolateMap(target.Visitors[i].Config)
+		}
+		runAfter := make([]Command, len(target.RunAfter))
+		for i, command := range target.RunAfter {
+			runAfter[i] = Command{
+				Command: interpolate(command.Command),
+				Dir:     interpolate(command.Dir),
+			}
+		}
+		if target.RunAfter != nil {
+			target.RunAfter = runAfter
+		}
+		config.Generates[filename] = target
+	}
+}
+
+func interpolate(s string) string {
+	return interpolationPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		if value, ok := os.LookupEnv(ref[2 : len(ref)-1]); ok {
+			return value
+		}
+		return ref
+	})
+}
+
+func interpolateMap(m map[string]interface{}) map[string]interface{} {
+	for k, v := range m {
+		m[k] = interpolateValue(v)
+	}
+	return m
+}
+
+func interpolateValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return interpolate(v)
+	case map[string]interface{}:
+		return interpolateMap(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = interpolateValue(v[i])
+		}
+		return v
+	}
+	return v
+}
@@ -0,0 +1,247 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// download fetches url to a local file. When a cache directory is
+// configured the file is kept in the cache and an interrupted download
+// is resumed on the next attempt; otherwise a temporary file is used
+// and removed by the returned cleanup function, after saving a copy
+// for offline installs. Offline, only cached downloads are used.
+func (c *InstallCmd) download(url, module string) (string, func(), error) {
+	noop := func() {}
+	if c.offline() {
+		cached, err := c.cachedDownload(url, module)
+		return cached, noop, err
+	}
+
+	if c.cacheDir == "" {
+		f, err := os.CreateTemp("", "install-*")
+		if err != nil {
+			return "", nil, err
+		}
+		cleanup := func() {
+			f.Close()
+			os.Remove(f.Name())
+		}
+		if err = c.fetchWithRetries(url, module, f); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+		f.Close()
+		c.saveDownload(url, f.Name())
+		return f.Name(), cleanup, nil
+	}
+
+	cached := cachePath(c.cacheDir, url)
+	if fi, err := os.Stat(cached); err == nil && !fi.IsDir() {
+		c.summary.add(func(s *Summary) { s.CacheHits++ })
+		return cached, noop, nil
+	}
+
+	if err := os.MkdirAll(c.cacheDir, 0755); err != nil {
+		return "", nil, err
+	}
+	partial := cached + ".part"
+	f, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", nil, err
+	}
+	if err = c.fetchWithRetries(url, module, f); err != nil {
+		f.Close()
+		return "", nil, err
+	}
+	if err = f.Close(); err != nil {
+		return "", nil, err
+	}
+	if err = os.Rename(partial, cached); err != nil {
+		return "", nil, err
+	}
+
+	return cached, noop, nil
+}
+
+// fetchWithRetries appends the contents of url to f, which may already
+// hold the start of it. Interrupted downloads are resumed from where
+// they stopped, waiting longer before each retry.
+func (c *InstallCmd) fetchWithRetries(url, module string, f *os.File) error {
+	for attempt := 0; ; attempt++ {
+		offset, err := f.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		var status *downloadStatusError
+		err = c.fetch(url, module, f, offset)
+		if err == nil || errors.As(err, &status) || attempt >= c.httpRetries() {
+			return err
+		}
+		wait := backoff(attempt + 1)
+		fmt.Printf("Download of %s interrupted, resuming in %s: %v\n", module, wait, err)
+		time.Sleep(wait)
+	}
+}
+
+// downloadStatusError is returned for responses that are not
+// retried by resuming, having already been retried if transient.
+type downloadStatusError struct {
+	url    string
+	status int
+}
+
+func (e *downloadStatusError) Error() string {
+	return fmt.Sprintf("could not download %s: got status %d", e.url, e.status)
+}
+
+// fetch writes the contents of url to f. A non-zero offset requests
+// the remaining bytes with a Range header; if the server ignores it
+// the file is truncated and downloaded from the beginning.
+func (c *InstallCmd) fetch(url, module string, f *os.File, offset int64) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	loadNPMConfig().authorize(req)
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+	resp, err := c.netClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if offset > 0 {
+			if err = f.Truncate(0); err != nil {
+				return err
+			}
+			if _, err = f.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+		}
+	case http.StatusPartialContent:
+		fmt.Printf("Resuming download of %s at %d bytes\n", module, offset)
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial file already holds the complete download.
+		return nil
+	default:
+		return &downloadStatusError{url: url, status: resp.StatusCode}
+	}
+
+	stall := newStallReader(resp.Body, c.httpTimeout(), cancel)
+	defer stall.stop()
+	var body io.Reader = stall
+	if c.limiter != nil {
+		body = c.limiter.reader(body)
+	}
+	body = c.progress.downloadReader(body, module, resp.ContentLength)
+	_, err = io.Copy(f, body)
+	return err
+}
+
+// rateLimiter is a byte budget shared by concurrent downloads.
+type rateLimiter struct {
+	mu          sync.Mutex
+	bytesPerSec int64
+	next        time.Time
+}
+
+func newRateLimiter(bytesPerSec int64) *rateLimiter {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	return &rateLimiter{bytesPerSec: bytesPerSec}
+}
+
+// wait blocks until n bytes fit within the budget.
+func (l *rateLimiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.bytesPerSec))
+	l.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+func (l *rateLimiter) reader(r io.Reader) io.Reader {
+	return &limitedReader{r: r, limiter: l}
+}
+
+type limitedReader struct {
+	r       io.Reader
+	limiter *rateLimiter
+}
+
+func (r *limitedReader) Read(b []byte) (int, error) {
+	// Keep reads small so the budget is shared fairly.
+	if len(b) > 32*1024 {
+		b = b[:32*1024]
+	}
+	n, err := r.r.Read(b)
+	if n > 0 {
+		r.limiter.wait(n)
+	}
+	return n, err
+}
+
+// parseByteSize parses sizes such as 512KB, 2MB or 1048576.
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		value  int64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			multiplier = unit.value
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,206 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Project formatter configuration files, in order of precedence.
+var (
+	astyleConfigFiles      = []string{".astylerc", "_astylerc"}
+	clangFormatConfigFiles = []string{".clang-format", "_clang-format"}
+	rustfmtConfigFiles     = []string{"rustfmt.toml", ".rustfmt.toml"}
+	yapfConfigFiles        = []string{".style.yapf"}
+)
+
+// findProjectFile searches for one of names in the directory of
+// filename and its parents, stopping at the root of the repository
+// containing it. It returns an empty string when none are found.
+func findProjectFile(filename string, names ...string) string {
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return ""
+	}
+	for {
+		for _, name := range names {
+			path := filepath.Join(dir, name)
+			if stat, err := os.Stat(path); err == nil && !stat.IsDir() {
+				return path
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// projectAstyleOptions returns Astyle options from the nearest .astylerc,
+// or translated from the nearest .clang-format, for a generated file.
+func projectAstyleOptions(filename string) (string, bool, error) {
+	if path := findProjectFile(filename, astyleConfigFiles...); path != "" {
+		options, err := readAstylerc(path)
+		return options, true, err
+	}
+	if path := findProjectFile(filename, clangFormatConfigFiles...); path != "" {
+		options, err := readClangFormat(path, clangFormatLanguage(filepath.Ext(filename)))
+		return options, options != "", err
+	}
+	return "", false, nil
+}
+
+// readAstylerc reads an Astyle options file, which has long
+// options separated by whitespace and # comments.
+func readAstylerc(path string) (string, error) {
+	data, err := readLocalFile(path, MaxConfigSize)
+	if err != nil {
+		return "", err
+	}
+
+	var options []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i != -1 {
+			line = line[:i]
+		}
+		for _, token := range strings.Fields(line) {
+			if !strings.HasPrefix(token, "--") && strings.HasPrefix(token, "-") {
+				return "", fmt.Errorf("%s: short option %q is not supported; use the long form", path, token)
+			}
+			options = append(options, strings.TrimPrefix(token, "--"))
+		}
+	}
+	joined := strings.Join(options, " ")
+	if err := ValidateAstyleOptions(joined); err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return joined, nil
+}
+
+// clangFormatLanguage maps a file extension to its clang-format Language.
+func clangFormatLanguage(ext string) string {
+	switch ext {
+	case ".cs":
+		return "CSharp"
+	case ".java":
+		return "Java"
+	case ".m":
+		return "ObjC"
+	}
+	return "Cpp"
+}
+
+var (
+	clangFormatStyles = map[string]string{
+		"LLVM":      "attach",
+		"Google":    "google",
+		"Chromium":  "google",
+		"Mozilla":   "mozilla",
+		"WebKit":    "webkit",
+		"GNU":       "gnu",
+		"Microsoft": "allman",
+	}
+	clangFormatBraces = map[string]string{
+		"Attach":      "attach",
+		"Linux":       "linux",
+		"Mozilla":     "mozilla",
+		"Stroustrup":  "stroustrup",
+		"Allman":      "allman",
+		"Whitesmiths": "whitesmith",
+		"GNU":         "gnu",
+		"WebKit":      "webkit",
+	}
+	clangFormatPointers = map[string]string{
+		"Left":   "type",
+		"Middle": "middle",
+		"Right":  "name",
+	}
+)
+
+// readClangFormat translates the clang-format options that have Astyle
+// equivalents. Options for another language are ignored.
+func readClangFormat(path, language string) (string, error) {
+	data, err := readLocalFile(path, MaxConfigSize)
+	if err != nil {
+		return "", err
+	}
+
+	var style map[string]interface{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc map[string]interface{}
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		lang, _ := doc["Language"].(string)
+		if lang == language || (lang == "" && style == nil) {
+			style = doc
+		}
+	}
+	if style == nil {
+		return "", nil
+	}
+
+	var options []string
+	str := func(key string) string {
+		s, _ := style[key].(string)
+		return s
+	}
+	if s, ok := clangFormatBraces[str("BreakBeforeBraces")]; ok {
+		options = append(options, "style="+s)
+	} else if s, ok := clangFormatStyles[str("BasedOnStyle")]; ok {
+		options = append(options, "style="+s)
+	}
+
+	indent := "spaces"
+	switch str("UseTab") {
+	case "Always", "ForIndentation", "ForContinuationAndIndentation", "AlignWithSpaces":
+		indent = "tab"
+	}
+	if width, ok := style["IndentWidth"].(int); ok && width > 0 {
+		indent += "=" + strconv.Itoa(width)
+	}
+	options = append(options, "indent="+indent)
+
+	if limit, ok := style["ColumnLimit"].(int); ok && limit > 0 {
+		options = append(options, fmt.Sprintf("max-code-length=%d", limit))
+	}
+	if s, ok := clangFormatPointers[str("PointerAlignment")]; ok {
+		options = append(options, "align-pointer="+s)
+	}
+
+	return strings.Join(options, " "), nil
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// What to do with a generated file when its formatter fails.
+const (
+	// FormatErrorFail reports an error and does not keep the file.
+	FormatErrorFail = "fail"
+	// FormatErrorRaw keeps the unformatted output and warns.
+	FormatErrorRaw = "raw"
+)
+
+// snippetContext is the number of lines shown
+// before and after the line a formatter rejected.
+const snippetContext = 2
+
+// FormatError is returned when a formatter fails on generated source.
+// It includes the formatter's error output and, when the formatter
+// reported a location, a snippet of the source around it.
+type FormatError struct {
+	Language string
+	Filename string
+	// Output is what the formatter wrote to stderr, if it is a CLI.
+	Output string
+	// Line and Column are 1-based, or zero when unknown.
+	Line    int
+	Column  int
+	Snippet string
+	Err     error
+}
+
+func (e *FormatError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Error formatting %s %s: %v", e.Language, e.Filename, e.Err)
+	if e.Output != "" {
+		b.WriteString("\n")
+		b.WriteString(e.Output)
+	}
+	if e.Snippet != "" {
+		fmt.Fprintf(&b, "\nat line %d:\n%s", e.Line, e.Snippet)
+	}
+	return b.String()
+}
+
+func (e *FormatError) Unwrap() error {
+	return e.Err
+}
+
+// Locations as written by the supported formatters, such as
+// prettier's "(3:14)", gofmt and rustfmt's "file.go:3:14",
+// and Python's "line 3".
+var formatErrorLocations = []*regexp.Regexp{
+	regexp.MustCompile(`\((\d+):(\d+)\)`),
+	regexp.MustCompile(`:(\d+):(\d+)`),
+	regexp.MustCompile(`\bline (\d+)`),
+}
+
+func newFormatError(language, filename, source, output string, err error) *FormatError {
+	e := FormatError{
+		Language: language,
+		Filename: filename,
+		Output:   strings.TrimSpace(output),
+		Err:      err,
+	}
+	text := e.Output + "\n" + err.Error()
+	for _, re := range formatErrorLocations {
+		if m := re.FindStringSubmatch(text); m != nil {
+			e.Line, _ = strconv.Atoi(m[1])
+			if len(m) > 2 {
+				e.Column, _ = strconv.Atoi(m[2])
+			}
+			break
+		}
+	}
+	e.Snippet = sourceSnippet(source, e.Line, e.Column)
+	return &e
+}
+
+// sourceSnippet returns the lines around line, numbered, with
+// a caret under column when it is known.
+func sourceSnippet(source string, line, column int) string {
+	lines := strings.Split(source, "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	first, last := line-snippetContext, line+snippetContext
+	if first < 1 {
+		first = 1
+	}
+	if last > len(lines) {
+		last = len(lines)
+	}
+	width := len(strconv.Itoa(last))
+
+	var b strings.Builder
+	for n := first; n <= last; n++ {
+		marker := " "
+		if n == line {
+			marker = ">"
+		}
+		fmt.Fprintf(&b, "%s %*d | %s\n", marker, width, n, strings.TrimRight(lines[n-1], "\r"))
+		if n == line && column > 0 {
+			fmt.Fprintf(&b, "  %s | %s^\n", strings.Repeat(" ", width), strings.Repeat(" ", column-1))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// runFormatter runs a formatter CLI on outPath, capturing its error
+// output so a failure can be reported with the unformatted source.
+// The command is killed when ctx, which it was created with, is done.
+func runFormatter(ctx context.Context, language, filename, outPath string, cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return withFilename(timeoutError(ctx, phaseFormatting, err), filename)
+		}
+		source, _ := os.ReadFile(outPath)
+		return newFormatError(language, filename, string(source), stderr.String(), err)
+	}
+	return nil
+}
+
+// formatErrorPolicy returns what to do when formatting a target fails.
+// The --on-format-error flag takes precedence over the configuration.
+func (c *GenerateCmd) formatErrorPolicy(config Config, target Target) string {
+	for _, policy := range []string{c.OnFormatError, target.OnFormatError, config.OnFormatError} {
+		if policy != "" {
+			return policy
+		}
+	}
+	return FormatErrorFail
+}
@@ -0,0 +1,1411 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/go-sourcemap/sourcemap"
+	"gopkg.in/yaml.v3"
+	"rogchap.com/v8go"
+
+	"github.com/apexlang/cli/js"
+)
+
+// Context is passed to the Run method of each command.
+type Context struct {
+	// JSON is set by the global --json flag for commands
+	// that can print machine-readable output.
+	JSON bool
+}
+
+type GenerateCmd struct {
+	Config          string `arg:"" help:"The code generation configuration file" type:"existingfile" optional:""`
+	ShowEntrypoints bool   `help:"Print the entry file esbuild selected for each imported module."`
+	Report          string `help:"Write a JSON report with checksums of generation inputs and outputs to this file."`
+	Attest          bool   `help:"Write an in-toto provenance attestation alongside the report."`
+	SigningKey      string `help:"PEM private key used to sign the attestation." type:"existingfile"`
+	Keyless         bool   `help:"Sign the attestation keylessly with sigstore using the cosign CLI."`
+	Compat          string `help:"Translate configuration written for other tooling (node-cli). Legacy configurations are also detected automatically." enum:",node-cli" default:""`
+	Check           bool   `help:"Check that generated files are up to date without writing them."`
+	StdinConfig     bool   `help:"Read the configuration from stdin instead of a file."`
+	Strict          bool   `help:"Fail on unknown configuration fields and config keys instead of warning."`
+	OnFormatError   string `help:"When a formatter fails, fail the target or write the unformatted output with a warning (fail or raw). Overrides onFormatError in the configuration." enum:",fail,raw" default:""`
+	SummaryFormat   string `name:"summary" help:"How to print a summary when finished: text, json, or none." enum:"text,json,none" default:"text"`
+	// Timeout bounds the whole run, while a target's
+	// timeoutSeconds bounds each step of generating it.
+	Timeout time.Duration `help:"Fail if generating takes longer than this, such as 10m. Zero does not limit it."`
+	// NoRestore skips installing the modules listed in dependencies.
+	NoRestore bool `help:"Do not install missing modules listed in the dependencies of the configuration."`
+	// Since limits generation to targets affected by changed files.
+	Since string `help:"Only generate targets affected by files changed since this git revision (e.g. origin/main), skipping the others."`
+	// NoDotenv skips loading .env and the configuration's envFiles.
+	NoDotenv bool `name:"no-dotenv" help:"Do not load environment variables from .env or the envFiles of the configuration."`
+	// Force generates targets that would be skipped as unchanged
+	// or written from the output cache.
+	Force bool `help:"Generate every target, including those whose spec, configuration, and modules did not change since they were last generated or cached."`
+	// Jobs bounds the targets of each configuration generated at once.
+	// Targets wait for those they depend on.
+	Jobs int `short:"j" help:"The number of targets of a configuration to generate concurrently." default:"4"`
+	ConfigOverrides
+
+	prettier *js.JS
+	once     sync.Once
+	// prettierMu serializes formatting since targets are generated
+	// concurrently and the JavaScript runtime is not safe for
+	// concurrent use.
+	prettierMu sync.Mutex
+	// ctx is canceled when --timeout passes.
+	ctx context.Context
+
+	// outputDir, when set, is the root that generated files are
+	// written under instead of the working directory.
+	outputDir    string
+	skipRunAfter bool
+	report       *GenerateReport
+	// configData is the configuration once read from
+	// its file, stdin, or the environment.
+	configData []byte
+	summary    *Summary
+	// schemas caches the config schema of each module by home directory.
+	schemas map[string]moduleConfigSchema
+	// state holds the inputs each target was last generated from.
+	state *generateState
+}
+
+// Summary returns the outcome of the last run.
+func (c *GenerateCmd) Summary() *Summary {
+	return c.summary
+}
+
+// Config is a single document of a code generation configuration
+// file. A spec is parsed once and each of its targets is generated
+// from it.
+type Config struct {
+	Spec             string                 `json:"spec" yaml:"spec"`
+	Core             string                 `json:"core,omitempty" yaml:"core,omitempty"`
+	GenerateTemplate string                 `json:"generateTemplate,omitempty" yaml:"generateTemplate,omitempty"`
+	Aliases          map[string]string      `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	Config           map[string]interface{} `json:"config,omitempty" yaml:"config,omitempty"`
+	Generates        map[string]Target      `json:"generates" yaml:"generates"`
+	DependsOn        []string               `json:"dependsOn,omitempty" yaml:"dependsOn,omitempty"`
+	CI               *CIConfig              `json:"ci,omitempty" yaml:"ci,omitempty"`
+	// OnFormatError is the default for targets that do not set it.
+	OnFormatError string `json:"onFormatError,omitempty" yaml:"onFormatError,omitempty"`
+	// Dependencies are the modules the configuration needs by name,
+	// each with the release to install or a location to install it
+	// from, optionally followed by @ and the release.
+	Dependencies map[string]string `json:"dependencies,omitempty" yaml:"dependencies,omitempty"`
+	// EnvFiles are loaded into the environment after .env, for
+	// ${NAME} references in config values and runAfter commands.
+	EnvFiles []string `json:"envFiles,omitempty" yaml:"envFiles,omitempty"`
+}
+
+// Target configures how a generated file, named by its key
+// in Config.Generates, is produced and formatted.
+type Target struct {
+	Module           string                 `json:"module" yaml:"module"`
+	VisitorClass     string                 `json:"visitorClass" yaml:"visitorClass"`
+	IfNotExists      bool                   `json:"ifNotExists,omitempty" yaml:"ifNotExists,omitempty"`
+	Executable       bool                   `json:"executable,omitempty" yaml:"executable,omitempty"`
+	LineEndings      string                 `json:"lineEndings,omitempty" yaml:"lineEndings,omitempty"`
+	Encoding         string                 `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+	Config           map[string]interface{} `json:"config,omitempty" yaml:"config,omitempty"`
+	RunAfter         []Command              `json:"runAfter" yaml:"runAfter"`
+	Engine           string                 `json:"engine,omitempty" yaml:"engine,omitempty"`
+	Template         string                 `json:"template,omitempty" yaml:"template,omitempty"`
+	Astyle           string                 `json:"astyle,omitempty" yaml:"astyle,omitempty"`
+	Visitors         []Visitor              `json:"visitors,omitempty" yaml:"visitors,omitempty"`
+	Separator        *string                `json:"separator,omitempty" yaml:"separator,omitempty"`
+	Core             string                 `json:"core,omitempty" yaml:"core,omitempty"`
+	GenerateTemplate string                 `json:"generateTemplate,omitempty" yaml:"generateTemplate,omitempty"`
+	DependsOn        []string               `json:"dependsOn,omitempty" yaml:"dependsOn,omitempty"`
+	// OnFormatError is FormatErrorFail or FormatErrorRaw.
+	OnFormatError string `json:"onFormatError,omitempty" yaml:"onFormatError,omitempty"`
+	// TimeoutSeconds limits each step of generating the target: running
+	// its generator, formatting it, and each of its runAfter commands.
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty" yaml:"timeoutSeconds,omitempty"`
+	// Mode is ModeReplace, the default, or ModeMerge.
+	Mode string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	// Append allows other configs to generate the same file, which
+	// holds the outputs of each config in order.
+	Append bool `json:"append,omitempty" yaml:"append,omitempty"`
+
+	// appending is set on the targets after the first that
+	// generate a shared file so they do not replace it.
+	appending bool
+}
+
+// Visitor is one of several visitors whose outputs
+// are concatenated into a single target file.
+type Visitor struct {
+	Module       string                 `json:"module" yaml:"module"`
+	VisitorClass string                 `json:"visitorClass" yaml:"visitorClass"`
+	Config       map[string]interface{} `json:"config,omitempty" yaml:"config,omitempty"`
+}
+
+const (
+	// EngineVisitor generates a target using a JavaScript visitor.
+	EngineVisitor = "visitor"
+	// EngineTemplate generates a target by rendering a Go text/template.
+	EngineTemplate = "template"
+)
+
+// Command is a command line run from a directory,
+// such as a target's runAfter commands.
+//
+// The runAfter commands of a target share a temporary workspace,
+// created before they run and removed afterwards. Its path replaces
+// {{tmpdir}} in the command and directory and is set as APEX_TMPDIR.
+type Command struct {
+	Command string `json:"command" yaml:"command"`
+	Dir     string `json:"dir" yaml:"dir"`
+
+	// env is added to the environment the command runs with.
+	env []string
+}
+
+// defaultCoreModule provides the Apex parser and model used by
+// generation unless a config or target specifies another.
+const defaultCoreModule = "@apexlang/core"
+
+const generateTemplate = `import { parse } from "{{core}}";
+import { Context, Writer } from "{{core}}/model";
+import {{importClass}} from "{{module}}";
+
+function resolver(location, from) {
+  const source = resolverCallback(location, from);
+  if (source.startsWith("error: ")) {
+    throw source.substring(7);
+  }
+  return source;
+}
+
+export function generate(spec, config) {
+  const doc = parse(spec, resolver);
+  const context = new Context(config, doc);
+
+  const writer = new Writer();
+  const visitor = new {{visitorClass}}(writer);
+  context.accept(context, visitor);
+  let source = writer.string();
+
+  return source;
+}
+
+js_exports["generate"] = generate;`
+
+func (c *GenerateCmd) Run(ctx *Context) error {
+	defer func() {
+		if c.prettier != nil {
+			c.prettier.Dispose()
+		}
+	}()
+
+	if err := c.loadConfig(); err != nil {
+		return err
+	}
+	if c.Attest && c.Report == "" {
+		return errors.New("--attest requires --report")
+	}
+	if !c.NoRestore {
+		if _, err := restoreDependencies(ctx, c.configData, InstallCmd{}); err != nil {
+			return err
+		}
+	}
+	if c.Check {
+		if c.Report != "" {
+			return errors.New("--check cannot be combined with --report")
+		}
+		return c.check(ctx)
+	}
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		c.ctx, cancel = context.WithTimeout(context.Background(), c.Timeout)
+		defer cancel()
+	}
+	started := time.Now()
+	c.summary = newSummary("generate")
+	defer func() { c.summary.finish(c.SummaryFormat) }()
+
+	configs, err := parseConfigs(c.Config, c.configData, c.Compat, c.ConfigOverrides, c.Strict)
+	if err != nil {
+		return err
+	}
+	if !c.NoDotenv {
+		if err = loadDotenv(configs); err != nil {
+			return err
+		}
+	}
+	for i := range configs {
+		interpolateConfig(&configs[i])
+	}
+	if c.Since != "" {
+		if configs, err = c.affectedConfigs(configs); err != nil {
+			return err
+		}
+	}
+
+	if c.Report != "" {
+		c.report = &GenerateReport{}
+	}
+	if c.report != nil {
+		c.report.Config = hashBytes(c.Config, c.configData)
+	}
+
+	// Drift checks generate into another directory, so only
+	// targets generated in place are skipped while outputs are
+	// written from the cache either way.
+	if !c.Force {
+		if homeDir, err := getHomeDirectory(); err == nil {
+			if c.outputDir == "" {
+				c.state = loadGenerateState(homeDir, c.Config)
+			} else {
+				c.state = newGenerateState(homeDir)
+			}
+		}
+	}
+	merr := c.generateAll(configs)
+	c.state.save()
+	if merr != nil {
+		return appendError(nil, merr)
+	}
+
+	if c.Report != "" {
+		if err = c.report.write(c.Report); err != nil {
+			return err
+		}
+		fmt.Println(msg("generate.wrote_report", c.Report))
+
+		if c.Attest {
+			if err = writeAttestation(c.Report+".intoto.json", c.report,
+				started, time.Now(), c.SigningKey, c.Keyless); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// recordOutputs adds the checksums of written files and
+// the inputs used to generate them to the report.
+func (c *GenerateCmd) recordOutputs(homeDir string, config Config, written map[string]struct{}) {
+	for filename := range written {
+		target := config.Generates[filename]
+		data, err := os.ReadFile(c.outputPath(filename))
+		if err != nil {
+			fmt.Printf("Could not read %s for report: %v\n", filename, err)
+			continue
+		}
+		output := ReportOutput{
+			ReportFile: hashBytes(filename, data),
+			Engine:     target.Engine,
+		}
+		switch target.Engine {
+		case EngineTemplate:
+			output.Template = target.Template
+			if templateBytes, err := os.ReadFile(target.Template); err == nil {
+				c.report.addInput(hashBytes(target.Template, templateBytes))
+			}
+		default:
+			output.Module = target.Module
+			output.VisitorClass = target.VisitorClass
+			modules := []string{target.Module}
+			if len(target.Visitors) > 0 {
+				modules = modules[:0]
+				classes := make([]string, len(target.Visitors))
+				for i, visitor := range target.Visitors {
+					modules = append(modules, visitor.Module)
+					classes[i] = visitor.VisitorClass
+				}
+				output.Module = strings.Join(modules, ",")
+				output.VisitorClass = strings.Join(classes, ",")
+			}
+			for _, module := range modules {
+				if input, ok := hashModule(homeDir, module); ok {
+					c.report.addInput(input)
+				}
+			}
+		}
+		c.report.addOutput(output)
+	}
+}
+
+// outputPath returns where a generated file is written.
+func (c *GenerateCmd) outputPath(filename string) string {
+	if c.outputDir == "" {
+		return filename
+	}
+	return filepath.Join(c.outputDir, filename)
+}
+
+func (c *GenerateCmd) generateConfig(config Config) error {
+	defer func() {
+		if c.prettier != nil {
+			c.prettier.Dispose()
+		}
+	}()
+
+	return c.generate(config)
+}
+
+func (c *GenerateCmd) generate(config Config) error {
+	specBytes, err := readFile(config.Spec, MaxSpecSize)
+	if err != nil {
+		c.summary.failTargets(config)
+		return err
+	}
+	spec := string(specBytes)
+
+	homeDir, err := getHomeDirectory()
+	if err != nil {
+		c.summary.failTargets(config)
+		return err
+	}
+
+	if c.report != nil {
+		c.report.addInput(hashBytes(config.Spec, specBytes))
+	}
+
+	filenames, err := targetOrder(config)
+	if err != nil {
+		c.summary.failTargets(config)
+		return err
+	}
+
+	if err = c.validateConfigSchemas(homeDir, config); err != nil {
+		c.summary.failTargets(config)
+		return err
+	}
+
+	var merr error
+	reencode := make(map[string]struct{})
+	written := make(map[string]struct{})
+	// previous holds the contents of files that existed before
+	// generation, and failed the targets that failed formatting,
+	// so each target is counted in the summary.
+	previous := make(map[string][]byte)
+	failed := make(map[string]struct{})
+	// appended holds the targets that added to a file another config
+	// generated, which are counted as succeeded but not as files.
+	appended := make(map[string]struct{})
+	// modTimes holds the modification times of files that were
+	// rewritten, restored if their contents end up the same.
+	modTimes := make(map[string]time.Time)
+	// clean holds the targets skipped because their inputs and file
+	// did not change since they were last generated.
+	clean := make(map[string]struct{})
+	// cached holds the targets written from the output cache, which
+	// are already formatted.
+	cached := make(map[string]struct{})
+	attempted := 0
+
+	results := make([]targetResult, len(filenames))
+	errs := make([]error, len(filenames))
+	c.runTargets(config, filenames, func(i int) {
+		results[i], errs[i] = c.generateTarget(homeDir, spec, filenames[i], config)
+	})
+	for i, filename := range filenames {
+		result := results[i]
+		if errs[i] != nil {
+			merr = appendError(merr, errs[i])
+		}
+		if result.attempted {
+			attempted++
+		}
+		if result.written {
+			written[filename] = struct{}{}
+		}
+		if result.appended {
+			appended[filename] = struct{}{}
+		}
+		if result.reencode {
+			reencode[filename] = struct{}{}
+		}
+		if result.existed {
+			previous[filename] = result.previous
+		}
+		if !result.modTime.IsZero() {
+			modTimes[filename] = result.modTime
+		}
+		if result.clean {
+			clean[filename] = struct{}{}
+		}
+		if result.cached {
+			cached[filename] = struct{}{}
+		}
+	}
+
+	// Some CLI-based formatters actually check for types referenced in other files
+	// so we must call these after all the files are generated.
+	for filename, target := range config.Generates {
+		outPath := c.outputPath(filename)
+		if _, ok := written[filename]; !ok && c.outputDir != "" {
+			continue
+		}
+		if _, ok := clean[filename]; ok {
+			continue
+		}
+		if _, ok := cached[filename]; ok {
+			continue
+		}
+		ext := filepath.Ext(filename)
+		ctx, cancel := c.stepContext(target)
+		err = nil
+		switch ext {
+		case ".rs":
+			fmt.Println(msg("generate.formatting", filename))
+			err = formatRust(ctx, outPath, filename)
+		case ".go":
+			fmt.Println(msg("generate.formatting", filename))
+			err = formatGolang(ctx, outPath, filename)
+		case ".py":
+			fmt.Println(msg("generate.formatting", filename))
+			err = formatPython(ctx, outPath, filename)
+		}
+		cancel()
+		if err != nil {
+			// The unformatted output is already written, but
+			// a formatter that timed out may be stuck on it.
+			var terr *TimeoutError
+			if errors.As(err, &terr) || c.formatErrorPolicy(config, target) != FormatErrorRaw {
+				merr = appendTargetError(merr, filename, GenerationPhaseFormat, "%w", err)
+				failed[filename] = struct{}{}
+				continue
+			}
+			fmt.Println(msg("generate.unformatted", filename, err))
+		}
+		if _, ok := reencode[filename]; ok {
+			if err = reencodeFile(outPath, target); err != nil {
+				merr = appendTargetError(merr, filename, GenerationPhaseWrite, "Error encoding %s: %w", filename, err)
+				failed[filename] = struct{}{}
+				continue
+			}
+		}
+	}
+	// CLI-based formatters run on the unformatted output, which may
+	// format back to what was already there.
+	for filename, modTime := range modTimes {
+		if _, ok := failed[filename]; ok {
+			continue
+		}
+		if err = restoreModTime(c.outputPath(filename), previous[filename], modTime); err != nil {
+			merr = appendTargetError(merr, filename, GenerationPhaseWrite, "Error writing file: %w", err)
+		}
+	}
+	c.summarizeFiles(written, previous, failed, appended, attempted)
+	for i, filename := range filenames {
+		_, isClean := clean[filename]
+		_, isWritten := written[filename]
+		_, isFailed := failed[filename]
+		_, isAppended := appended[filename]
+		switch {
+		case isClean || isAppended || !results[i].attempted:
+		case isWritten && !isFailed:
+			if config.Generates[filename].Mode != ModeMerge {
+				c.state.store(results[i].inputs, c.outputPath(filename))
+			}
+			c.state.record(filename, results[i].inputs, c.outputPath(filename))
+		default:
+			c.state.record(filename, "", "")
+		}
+	}
+
+	if c.report != nil {
+		c.recordOutputs(homeDir, config, written)
+	}
+
+	if c.skipRunAfter {
+		return merr
+	}
+
+	for filename, target := range config.Generates {
+		if _, ok := clean[filename]; ok || len(target.RunAfter) == 0 {
+			continue
+		}
+		workspace, removeWorkspace, err := newTargetWorkspace(filename)
+		if err != nil {
+			merr = appendTargetError(merr, filename, GenerationPhaseCommand, "Error creating temporary workspace: %w", err)
+			continue
+		}
+		for _, command := range target.RunAfter {
+			ctx, cancel := c.stepContext(target)
+			joined, err := runCommand(ctx, command.inWorkspace(workspace))
+			cancel()
+			if err != nil {
+				merr = appendTargetError(merr, filename, GenerationPhaseCommand, "Error running command: %s, %w", joined, withFilename(err, filename))
+				continue
+			}
+		}
+		removeWorkspace()
+	}
+
+	return merr
+}
+
+// targetResult records what generating a target did to its file.
+type targetResult struct {
+	// attempted is false for targets skipped with ifNotExists.
+	attempted bool
+	written   bool
+	appended  bool
+	// reencode is set for files encoded after CLI-based formatters run.
+	reencode bool
+	// previous is the contents of the file if it existed before it
+	// was generated, and modTime its modification time if rewritten.
+	existed  bool
+	previous []byte
+	modTime  time.Time
+	// inputs is the checksum of the target's inputs, and clean
+	// is set when they and the file are unchanged since last run.
+	inputs string
+	clean  bool
+	// cached is set when the file is written from the output cache.
+	cached bool
+}
+
+// generateTarget generates, formats, and writes the file of a target.
+// Targets are generated concurrently, so it only records what it did
+// in the result.
+func (c *GenerateCmd) generateTarget(homeDir, spec, filename string, config Config) (targetResult, error) {
+	var result targetResult
+	target := config.Generates[filename]
+	if target.IfNotExists {
+		_, err := os.Stat(filename)
+		if err != nil && !os.IsNotExist(err) {
+			return result, err
+		}
+		if err == nil {
+			fmt.Println(msg("generate.skipping", filename))
+			c.summary.add(func(s *Summary) { s.FilesSkipped++ })
+			return result, nil
+		}
+	}
+	result.attempted = true
+	if err := checkMode(filename, target); err != nil {
+		return result, appendTargetError(nil, filename, GenerationPhaseConfig, "%w", err)
+	}
+
+	outPath := c.outputPath(filename)
+	if !target.appending {
+		result.inputs = c.targetInputs(homeDir, spec, filename, config)
+		if c.state.clean(filename, result.inputs, outPath) {
+			if existing, err := os.ReadFile(outPath); err == nil {
+				fmt.Println(msg("generate.unchanged", filename))
+				result.clean = true
+				result.written = true
+				result.existed = true
+				result.previous = existing
+				return result, nil
+			}
+		}
+		// Merged files depend on the file in the working
+		// directory, so they are always generated.
+		if data, ok := c.state.cached(result.inputs); ok && target.Mode != ModeMerge {
+			fmt.Println(msg("generate.cached", filename))
+			if dir := filepath.Dir(outPath); dir != "" {
+				if err := os.MkdirAll(dir, 0777); err != nil {
+					return result, appendTargetError(nil, filename, GenerationPhaseWrite, "Error creating directory: %w", err)
+				}
+			}
+			result.cached = true
+			c.summary.add(func(s *Summary) { s.CacheHits++ })
+			return result, writeTargetFile(&result, filename, outPath, data, targetFileMode(target))
+		}
+	}
+
+	// Merge global config into target config
+	if target.Config == nil && config.Config != nil {
+		target.Config = make(map[string]interface{}, len(config.Config))
+	}
+	for k, v := range config.Config {
+		if _, exists := target.Config[k]; !exists {
+			target.Config[k] = v
+		}
+	}
+
+	configMap := make(map[string]interface{}, len(config.Config)+len(target.Config))
+	for k, v := range config.Config {
+		configMap[k] = v
+	}
+	for k, v := range target.Config {
+		configMap[k] = v
+	}
+	configMap["$filename"] = filename
+
+	if target.Module != "" {
+		target.Module = resolveModuleAlias(target.Module, config.Aliases)
+	}
+	if len(target.Visitors) > 0 {
+		visitors := make([]Visitor, len(target.Visitors))
+		for i, visitor := range target.Visitors {
+			visitor.Module = resolveModuleAlias(visitor.Module, config.Aliases)
+			visitors[i] = visitor
+		}
+		target.Visitors = visitors
+	}
+
+	// The target's core module overrides the config's.
+	if target.Core == "" {
+		target.Core = config.Core
+	}
+	if target.Core == "" {
+		target.Core = defaultCoreModule
+	}
+	if target.GenerateTemplate == "" {
+		target.GenerateTemplate = config.GenerateTemplate
+	}
+
+	var (
+		source string
+		err    error
+	)
+	switch target.Engine {
+	case "", EngineVisitor:
+		if target.Module == "" && len(target.Visitors) == 0 {
+			return result, appendTargetError(nil, filename, GenerationPhaseConfig, "%s", msg("generate.module_required", filename))
+		}
+		fmt.Println(msg("generate.generating", filename))
+		ctx, cancel := c.stepContext(target)
+		if len(target.Visitors) > 0 {
+			source, err = c.runVisitors(ctx, homeDir, spec, target, configMap)
+		} else {
+			source, err = c.runVisitor(ctx, homeDir, spec, target, configMap)
+		}
+		cancel()
+	case EngineTemplate:
+		if target.Template == "" {
+			return result, appendTargetError(nil, filename, GenerationPhaseConfig, "%s", msg("generate.template_required", filename))
+		}
+		fmt.Println(msg("generate.generating", filename))
+		ctx, cancel := c.stepContext(target)
+		source, err = c.renderTemplate(ctx, homeDir, spec, filename, target, configMap)
+		cancel()
+	default:
+		return result, appendTargetError(nil, filename, GenerationPhaseConfig, "%s", msg("generate.unknown_engine", target.Engine, filename))
+	}
+	if err != nil {
+		return result, appendTargetError(nil, filename, GenerationPhaseGenerate, "%w", withFilename(err, filename))
+	}
+	recordAuditInstalledModule(homeDir, target.Module)
+	for _, visitor := range target.Visitors {
+		recordAuditInstalledModule(homeDir, visitor.Module)
+	}
+
+	ext := filepath.Ext(filename)
+	ctx, cancel := c.stepContext(target)
+	formatted, err := c.formatSource(ctx, target, filename, source)
+	cancel()
+	if err != nil {
+		var ferr *FormatError
+		if !errors.As(err, &ferr) || c.formatErrorPolicy(config, target) != FormatErrorRaw {
+			return result, appendTargetError(nil, filename, GenerationPhaseFormat, "%w", err)
+		}
+		fmt.Println(msg("generate.unformatted", filename, err))
+	} else {
+		source = formatted
+	}
+
+	// Files are merged with those in the working directory,
+	// even when checking for drift.
+	if target.Mode == ModeMerge {
+		merged, conflicts, err := mergeOutput(filename, source)
+		if err != nil {
+			return result, appendTargetError(nil, filename, GenerationPhaseMerge, "%w", err)
+		}
+		for _, path := range conflicts {
+			fmt.Println(msg("generate.merge_conflict", path, filename))
+		}
+		source = merged
+	}
+
+	dir := filepath.Dir(outPath)
+	if dir != "" {
+		if err = os.MkdirAll(dir, 0777); err != nil {
+			return result, appendTargetError(nil, filename, GenerationPhaseWrite, "Error creating directory: %w", err)
+		}
+	}
+
+	// CLI-based formatters expect UTF-8 input so encoding is
+	// deferred until after they run.
+	data := []byte(source)
+	if isPostFormatted(ext) {
+		result.reencode = true
+	} else if data, err = encodeOutput(source, target.LineEndings, target.Encoding); err != nil {
+		return result, appendTargetError(nil, filename, GenerationPhaseWrite, "Error encoding %s: %w", filename, err)
+	}
+
+	fileMode := targetFileMode(target)
+	if target.appending {
+		if err = appendFile(outPath, data, fileMode); err != nil {
+			return result, appendTargetError(nil, filename, GenerationPhaseWrite, "Error writing file: %w", err)
+		}
+		result.appended = true
+		result.written = true
+		return result, nil
+	}
+	return result, writeTargetFile(&result, filename, outPath, data, fileMode)
+}
+
+func targetFileMode(target Target) fs.FileMode {
+	if target.Executable {
+		return 0777
+	}
+	return 0666
+}
+
+// writeTargetFile replaces the file of a target, recording its
+// previous contents in result.
+func writeTargetFile(result *targetResult, filename, outPath string, data []byte, fileMode fs.FileMode) error {
+	if existing, err := os.ReadFile(outPath); err == nil {
+		result.existed = true
+		result.previous = existing
+		// Leaving identical files untouched keeps build tools
+		// from seeing them as changed.
+		if bytes.Equal(existing, data) {
+			result.written = true
+			return nil
+		}
+		if info, err := os.Stat(outPath); err == nil {
+			result.modTime = info.ModTime()
+		}
+	}
+	if err := os.WriteFile(outPath, data, fileMode); err != nil {
+		return appendTargetError(nil, filename, GenerationPhaseWrite, "Error writing file: %w", err)
+	}
+	result.written = true
+	return nil
+}
+
+// summarizeFiles counts the targets that were attempted by whether
+// their files were new, changed, unchanged, or failed.
+func (c *GenerateCmd) summarizeFiles(written map[string]struct{}, previous map[string][]byte, failed, appended map[string]struct{}, attempted int) {
+	if c.summary == nil {
+		return
+	}
+	var created, updated, unchanged, added int
+	for filename := range written {
+		if _, ok := failed[filename]; ok {
+			continue
+		}
+		if _, ok := appended[filename]; ok {
+			added++
+			continue
+		}
+		existing, existed := previous[filename]
+		if !existed {
+			created++
+			continue
+		}
+		if data, err := os.ReadFile(c.outputPath(filename)); err == nil && bytes.Equal(data, existing) {
+			unchanged++
+		} else {
+			updated++
+		}
+	}
+	succeeded := created + updated + unchanged + added
+	c.summary.add(func(s *Summary) {
+		s.FilesWritten += created
+		s.FilesUpdated += updated
+		s.FilesUnchanged += unchanged
+		s.TargetsSucceeded += succeeded
+		s.TargetsFailed += attempted - succeeded
+	})
+}
+
+// runCommand runs a configured command, joining multi-line commands
+// into one, and returns the command line that was run.
+func runCommand(ctx context.Context, command Command) (string, error) {
+	lines := strings.Split(strings.TrimSpace(command.Command), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	joined := strings.Join(lines, " ")
+	commandParts := strings.Split(joined, " ")
+	fmt.Println(msg("generate.running", joined))
+	cmd := exec.CommandContext(ctx, commandParts[0], commandParts[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Dir = command.Dir
+	if len(command.env) > 0 {
+		cmd.Env = append(os.Environ(), command.env...)
+	}
+	err := cmd.Run()
+	if err != nil {
+		err = timeoutError(ctx, phaseCommand, err)
+	}
+	return joined, err
+}
+
+// runVisitors runs each of the target's visitors and concatenates
+// their outputs using the target's separator, which defaults to a
+// newline. Visitor config is layered over the target config.
+func (c *GenerateCmd) runVisitors(ctx context.Context, homeDir, spec string, target Target, configMap map[string]interface{}) (string, error) {
+	separator := "\n"
+	if target.Separator != nil {
+		separator = *target.Separator
+	}
+
+	sources := make([]string, 0, len(target.Visitors))
+	for i, visitor := range target.Visitors {
+		if visitor.Module == "" {
+			return "", fmt.Errorf("module is required for visitor %d", i+1)
+		}
+		visitorConfig := make(map[string]interface{}, len(configMap)+len(visitor.Config))
+		for k, v := range configMap {
+			visitorConfig[k] = v
+		}
+		for k, v := range visitor.Config {
+			visitorConfig[k] = v
+		}
+
+		visitorTarget := target
+		visitorTarget.Module = visitor.Module
+		visitorTarget.VisitorClass = visitor.VisitorClass
+		source, err := c.runVisitor(ctx, homeDir, spec, visitorTarget, visitorConfig)
+		if err != nil {
+			return "", err
+		}
+		sources = append(sources, source)
+	}
+
+	return strings.Join(sources, separator), nil
+}
+
+// runVisitor generates source by running the target's visitor
+// class from its JavaScript module.
+func (c *GenerateCmd) runVisitor(ctx context.Context, homeDir, spec string, target Target, configMap map[string]interface{}) (string, error) {
+	importClass := "{ " + target.VisitorClass + " }"
+	visitorClass := target.VisitorClass
+	if target.VisitorClass == "" {
+		importClass = "DefaultVisitor"
+		visitorClass = importClass
+	}
+
+	// Get working directory so that modules can be loaded
+	// relative to the project's root directory.
+	workingDir, err := os.Getwd()
+	if err != nil {
+		workingDir = "."
+	}
+	module := resolveModuleImport(homeDir, workingDir, target.Module)
+
+	generateTS := generateTemplate
+	if target.GenerateTemplate != "" {
+		if generateTS, err = readGenerateTemplate(target.GenerateTemplate); err != nil {
+			return "", err
+		}
+	}
+	generateTS = strings.ReplaceAll(generateTS, "{{core}}", target.Core)
+	generateTS = strings.Replace(generateTS, "{{module}}", module, 1)
+	generateTS = strings.Replace(generateTS, "{{importClass}}", importClass, 1)
+	generateTS = strings.Replace(generateTS, "{{visitorClass}}", visitorClass, 1)
+
+	res, err := runScript(ctx, homeDir, c.ShowEntrypoints, generateTS, "generate", spec, configMap)
+	if err != nil {
+		return "", err
+	}
+
+	source, ok := res.(string)
+	if !ok {
+		template := "the embedded generate template"
+		if target.GenerateTemplate != "" {
+			template = "generate template " + target.GenerateTemplate
+		}
+		return "", fmt.Errorf("generate() of %s returned %T, not a string", template, res)
+	}
+	return source, nil
+}
+
+var generateExportRegexp = regexp.MustCompile(`js_exports\s*(\[\s*["'\x60]generate["'\x60]\s*\]|\.generate)\s*=`)
+
+// readGenerateTemplate reads a project-local generate template used in
+// place of the embedded one and checks that it exports the generate
+// function called by the CLI. Its imports are resolved from the project root.
+func readGenerateTemplate(filename string) (string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("could not read generate template: %w", err)
+	}
+	source := string(data)
+	if !generateExportRegexp.MatchString(source) {
+		return "", fmt.Errorf(`generate template %s must export generate() by assigning js_exports["generate"]`, filename)
+	}
+	return source, nil
+}
+
+// runScript bundles a TypeScript entrypoint with esbuild, compiles it in V8 and
+// invokes the exported function. JavaScript stack traces are translated using the
+// bundle's source map. A *TimeoutError is returned when ctx is done first.
+func runScript(ctx context.Context, homeDir string, showEntrypoints bool, source, function string, args ...interface{}) (interface{}, error) {
+	// Get working directory so that modules can be loaded
+	// relative to the project's root directory.
+	workingDir, err := os.Getwd()
+	if err != nil {
+		workingDir = "."
+	}
+	nodePaths := []string{workingDir}
+	for _, home := range moduleHomes(homeDir) {
+		nodePaths = append(nodePaths, filepath.Join(home, "node_modules"))
+	}
+
+	options := api.BuildOptions{
+		Stdin: &api.StdinOptions{
+			Contents:   source,
+			Sourcefile: "generate.ts",
+			ResolveDir: workingDir,
+		},
+		Outdir:        ".",
+		Sourcemap:     api.SourceMapExternal,
+		Bundle:        true,
+		AbsWorkingDir: workingDir,
+		NodePaths:     nodePaths,
+		LogLevel:      api.LogLevelWarning,
+		// V8 is neither a browser nor Node.js so resolve package
+		// entrypoints using only the "exports" map default/import/require
+		// conditions, then the "module" and "main" fields.
+		Platform:   api.PlatformNeutral,
+		Format:     api.FormatIIFE,
+		MainFields: []string{"module", "main"},
+		Metafile:   showEntrypoints,
+	}
+	// esbuild cannot be interrupted so a build that times
+	// out is left to finish in the background.
+	built := make(chan api.BuildResult, 1)
+	go func() { built <- api.Build(options) }()
+	var result api.BuildResult
+	select {
+	case result = <-built:
+	case <-ctx.Done():
+		return nil, timeoutError(ctx, phaseBundling, nil)
+	}
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("esbuild returned errors: %v", result.Errors)
+	}
+	if showEntrypoints {
+		printEntrypoints(result.Metafile)
+	}
+	if len(result.OutputFiles) != 2 {
+		return nil, errors.New("esbuild did not produce exactly 2 output files")
+	}
+
+	bundle := string(result.OutputFiles[1].Contents)
+	smapBytes := result.OutputFiles[0].Contents
+	smap, err := sourcemap.Parse(result.OutputFiles[1].Path, smapBytes)
+	if err != nil {
+		return nil, errors.New("could not parse sourcemap")
+	}
+
+	homes := moduleHomes(homeDir)
+	definitionsDirs := make([]string, len(homes))
+	for i, home := range homes {
+		definitionsDirs[i] = filepath.Join(home, "definitions")
+	}
+	j, err := js.Compile(bundle, map[string]v8go.FunctionCallback{
+		"resolverCallback": newResolverCallback(definitionsDirs...),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Compilation error: %w", err)
+	}
+	defer j.Dispose()
+
+	scriptCtx := ctx
+	if ScriptTimeout > 0 {
+		var cancel context.CancelFunc
+		scriptCtx, cancel = context.WithTimeout(ctx, ScriptTimeout)
+		defer cancel()
+	}
+	res, err := j.InvokeContext(scriptCtx, function, args...)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, timeoutError(ctx, "running "+function, err)
+		}
+		if scriptCtx.Err() != nil {
+			return nil, fmt.Errorf("%s did not finish within %s: %w", function, ScriptTimeout, ErrScriptTimeout)
+		}
+		if jserr, ok := err.(*v8go.JSError); ok {
+			return nil, errors.New(translateStackTrace(smap, jserr.StackTrace))
+		}
+		return nil, fmt.Errorf("Generation error: %w", err)
+	}
+
+	return res, nil
+}
+
+// printEntrypoints prints the file esbuild resolved for each
+// module imported directly by the generated entrypoint.
+func printEntrypoints(metafile string) {
+	var meta struct {
+		Inputs map[string]struct {
+			Imports []struct {
+				Path string `json:"path"`
+			} `json:"imports"`
+		} `json:"inputs"`
+	}
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		fmt.Printf("Could not read esbuild metafile: %v\n", err)
+		return
+	}
+
+	entry, ok := meta.Inputs["generate.ts"]
+	if !ok {
+		entry = meta.Inputs["<stdin>"]
+	}
+	for _, imp := range entry.Imports {
+		path := filepath.ToSlash(imp.Path)
+		module := path
+		if idx := strings.LastIndex(path, "node_modules/"); idx != -1 {
+			parts := strings.Split(path[idx+len("node_modules/"):], "/")
+			module = parts[0]
+			if strings.HasPrefix(module, "@") && len(parts) > 1 {
+				module += "/" + parts[1]
+			}
+		}
+		fmt.Printf("Resolved %s to %s\n", module, path)
+	}
+}
+
+// newResolverCallback returns the V8 callback used by the Apex parser
+// to load imported definitions from the first of the definitions
+// directories that has them.
+func newResolverCallback(definitionsDirs ...string) v8go.FunctionCallback {
+	return func(info *v8go.FunctionCallbackInfo) *v8go.Value {
+		iso := info.Context().Isolate()
+
+		if len(info.Args()) < 1 {
+			value, _ := v8go.NewValue(iso, "error: resolve: invalid arguments")
+			return value
+		}
+
+		location := info.Args()[0].String()
+
+		var (
+			data []byte
+			err  error
+		)
+		for _, definitionsDir := range definitionsDirs {
+			if data, err = readDefinition(definitionsDir, location); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			value, _ := v8go.NewValue(iso, fmt.Sprintf("error: %v", err))
+			return value
+		}
+
+		value, _ := v8go.NewValue(iso, string(data))
+		return value
+	}
+}
+
+// readDefinition reads an imported definition from a definitions
+// directory, where it may be a .apex file or a directory with an
+// index.apex.
+func readDefinition(definitionsDir, location string) ([]byte, error) {
+	loc := filepath.Join(definitionsDir, filepath.Join(strings.Split(location, "/")...))
+	if filepath.Ext(loc) != ".apex" {
+		specLoc := loc + ".apex"
+		found := false
+		stat, err := os.Stat(specLoc)
+		if err == nil && !stat.IsDir() {
+			found = true
+			loc = specLoc
+		}
+
+		if !found {
+			stat, err := os.Stat(loc)
+			if err != nil {
+				return nil, err
+			}
+			if stat.IsDir() {
+				loc = filepath.Join(loc, "index.apex")
+			} else {
+				loc += ".apex"
+			}
+		}
+	}
+
+	return readLocalFile(loc, MaxSpecSize)
+}
+
+//go:embed prettier.js
+var prettierSource string
+
+func (c *GenerateCmd) formatTypeScript(ctx context.Context, source string) (string, error) {
+	var err error
+	c.once.Do(func() {
+		c.prettier, err = js.Compile(prettierSource)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	c.prettierMu.Lock()
+	defer c.prettierMu.Unlock()
+	res, err := c.prettier.InvokeContext(ctx, "formatTypeScript", source)
+	if err != nil {
+		return "", err
+	}
+
+	return res.(string), nil
+}
+
+// formatSource formats generated source by the file extension of
+// filename. Formatter failures are returned as a *FormatError and
+// timeouts as a *TimeoutError.
+func (c *GenerateCmd) formatSource(ctx context.Context, target Target, filename, source string) (string, error) {
+	var (
+		language, formatted string
+		err                 error
+	)
+	switch filepath.Ext(filename) {
+	case ".ts":
+		language = "TypeScript"
+		formatted, err = c.formatTypeScript(ctx, source)
+	case ".cs":
+		language = "C#"
+		options, optionsErr := astyleOptionsFor(target, filename, "indent-namespaces break-blocks pad-comma indent=tab style=1tbs")
+		if optionsErr != nil {
+			return "", fmt.Errorf("Error formatting C#: %w", optionsErr)
+		}
+		formatted, err = Astyle(source, options)
+	case ".java", ".c", ".cpp", ".c++", ".h", ".hpp", ".h++", ".m":
+		language = "Java/C/C++/Objective-C"
+		options, optionsErr := astyleOptionsFor(target, filename, "pad-oper indent=tab style=google")
+		if optionsErr != nil {
+			return "", fmt.Errorf("Error formatting %s: %w", language, optionsErr)
+		}
+		formatted, err = Astyle(source, options)
+	default:
+		return source, nil
+	}
+	if err != nil && ctx.Err() != nil {
+		return "", withFilename(timeoutError(ctx, phaseFormatting, err), filename)
+	}
+	if err != nil {
+		return "", newFormatError(language, filename, source, "", err)
+	}
+	return formatted, nil
+}
+
+// astyleOptionsFor returns the target's Astyle options, which may
+// be a preset name or an options string. Otherwise, options from the
+// project's .astylerc or .clang-format are used, then the defaults.
+func astyleOptionsFor(target Target, filename, defaultOptions string) (string, error) {
+	if target.Astyle != "" {
+		if options, ok := AstylePreset(target.Astyle); ok {
+			return options, nil
+		}
+		return target.Astyle, nil
+	}
+	if options, ok, err := projectAstyleOptions(filename); ok || err != nil {
+		return options, err
+	}
+	return defaultOptions, nil
+}
+
+// isPostFormatted returns true for file extensions that are
+// formatted by an external CLI after all files are written.
+func isPostFormatted(ext string) bool {
+	switch ext {
+	case ".rs", ".go", ".py":
+		return true
+	}
+	return false
+}
+
+// formatRust formats a file with rustfmt. Project configuration is
+// looked up from the target's filename since outPath may be outside
+// the project, such as when checking for drift.
+func formatRust(ctx context.Context, outPath, filename string) error {
+	args := []string{"--edition", "2021"}
+	if config := findProjectFile(filename, rustfmtConfigFiles...); config != "" {
+		args = append(args, "--config-path", config)
+	}
+	return runFormatter(ctx, "Rust", filename, outPath, exec.CommandContext(ctx, "rustfmt", append(args, outPath)...))
+}
+
+func formatGolang(ctx context.Context, outPath, filename string) error {
+	return runFormatter(ctx, "Go", filename, outPath, exec.CommandContext(ctx, "gofmt", "-w", outPath))
+}
+
+func formatPython(ctx context.Context, outPath, filename string) error {
+	args := []string{"-i"}
+	if config := findProjectFile(filename, yapfConfigFiles...); config != "" {
+		args = append(args, "--style", config)
+	}
+	return runFormatter(ctx, "Python", filename, outPath, exec.CommandContext(ctx, "yapf", append(args, outPath)...))
+}
+
+// readFile reads a local file or URL, failing when it is larger
+// than limit bytes. A URL may pin a checksum of its contents, such
+// as https://example.com/spec.apex#sha256=<hex>.
+func readFile(file string, limit int64) ([]byte, error) {
+	if strings.HasPrefix(file, "http://") || strings.HasPrefix(file, "https://") {
+		url, checksum, err := parseURLChecksum(file)
+		if err != nil {
+			return nil, err
+		}
+		client, err := newHTTPClient()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Get(url)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		data, err := limitReader(url, resp.Body, limit)
+		if err != nil {
+			return nil, err
+		}
+		if checksum != nil {
+			if err = checksum.verify(url, data); err != nil {
+				return nil, err
+			}
+		}
+		return data, nil
+	}
+
+	return readLocalFile(file, limit)
+}
+
+// readConfigs reads the configurations from a file. Configurations written
+// for the Node CLI are translated when detected or when compat is CompatNodeCLI.
+func readConfigs(configFile, compat string, overrides ConfigOverrides) ([]Config, error) {
+	configBytes, err := readFile(configFile, MaxConfigSize)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfigs(configFile, configBytes, compat, overrides, false)
+}
+
+// parseConfigs parses the configuration documents read from configFile
+// and applies the overrides to each of them. Unknown fields are warned
+// about, or fail parsing when strict.
+func parseConfigs(configFile string, configBytes []byte, compat string, overrides ConfigOverrides, strict bool) ([]Config, error) {
+	configYAMLs := strings.Split(string(configBytes), "---")
+	configs := make([]Config, len(configYAMLs))
+	for i, configYAML := range configYAMLs {
+		if err := checkYAML(configFile, []byte(configYAML)); err != nil {
+			return nil, err
+		}
+		var doc map[string]interface{}
+		if err := yaml.Unmarshal([]byte(configYAML), &doc); err != nil {
+			return nil, err
+		}
+		for _, warning := range translateLegacyConfig(doc, compat == CompatNodeCLI) {
+			fmt.Printf("Deprecated: %s: %s\n", configFile, warning)
+		}
+		if warnings := checkConfigFields(doc); len(warnings) > 0 {
+			if strict {
+				return nil, fmt.Errorf("%s: %s", configFile, strings.Join(warnings, "; "))
+			}
+			for _, warning := range warnings {
+				fmt.Printf("Warning: %s: %s\n", configFile, warning)
+			}
+		}
+		translated, err := yaml.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+
+		var config Config
+		if err := yaml.Unmarshal(translated, &config); err != nil {
+			return nil, err
+		}
+		if err := overrides.apply(&config); err != nil {
+			return nil, err
+		}
+		if config.Spec == "" {
+			return nil, errors.New("spec is required")
+		}
+		if len(config.Generates) == 0 {
+			return nil, errors.New("generates is required")
+		}
+		configs[i] = config
+	}
+
+	return configs, nil
+}
+
+func translateStackTrace(smap *sourcemap.Consumer, stackTrace string) string {
+	lines := strings.Split(stackTrace, "\n")
+	for i := 1; i < len(lines); i++ {
+		l := strings.TrimRight(lines[i], " \t")
+		idx := strings.LastIndex(l, "(")
+		bundleIdx := strings.LastIndex(l, "bundle.js:")
+
+		if strings.HasSuffix(l, ")") && idx != -1 {
+			loc := l[idx+1:]
+			loc = loc[:len(loc)-1]
+			l = l[:idx]
+			if source, line, column, ok := translateLocation(smap, loc); ok {
+				lines[i] = fmt.Sprintf("%s(%s:%d:%d)", l, source, line, column)
+			}
+		} else if bundleIdx != -1 {
+			loc := l[bundleIdx+1:]
+			l = l[:bundleIdx]
+			if source, line, column, ok := translateLocation(smap, loc); ok {
+				lines[i] = fmt.Sprintf("%s(%s:%d:%d)", l, source, line, column)
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func translateLocation(smap *sourcemap.Consumer, location string) (string, int, int, bool) {
+	parts := strings.Split(location, ":")
+	if len(parts) != 3 {
+		return "", 0, 0, false
+	}
+	line, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return "", 0, 0, false
+	}
+	column, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return "", 0, 0, false
+	}
+
+	source, _, l, c, ok := smap.Source(line, column)
+	if !ok {
+		return "", 0, 0, false
+	}
+	if src, err := filepath.Abs(source); err == nil {
+		source = src
+	}
+
+	return source, l, c, true
+}
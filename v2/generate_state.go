@@ -0,0 +1,346 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// generateState records the inputs and output of each target generated
+// from a configuration, so that targets whose inputs and output did not
+// change since are skipped without bundling and running their modules.
+// Generated files are also cached by the checksum of their inputs, so
+// targets whose inputs were generated from before, in any working
+// directory, are written from the cache.
+type generateState struct {
+	mu  sync.Mutex
+	dir string
+	// path is the state of the configuration, or empty when generating
+	// into another directory, where only the output cache is used.
+	path string
+	// Targets are the fingerprints of targets by filename.
+	Targets map[string]targetState `json:"targets"`
+	// modules caches the checksum of each module package.
+	modules map[string]string
+	changed bool
+}
+
+type targetState struct {
+	// Inputs is a checksum of everything the output is generated from.
+	Inputs string `json:"inputs"`
+	// Output is the SHA-256 of the file as generated and formatted.
+	Output string `json:"output"`
+}
+
+// generateStateDir holds the state of configurations by
+// the checksum of their path.
+func generateStateDir(homeDir string) string {
+	return filepath.Join(homeDir, "cache", "generate")
+}
+
+// newGenerateState returns a state that only caches outputs.
+func newGenerateState(homeDir string) *generateState {
+	return &generateState{
+		dir:     generateStateDir(homeDir),
+		Targets: make(map[string]targetState),
+		modules: make(map[string]string),
+	}
+}
+
+// loadGenerateState reads the state of the configuration, which is
+// empty when it was not generated before or cannot be read.
+func loadGenerateState(homeDir, config string) *generateState {
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(workingDir + "\x00" + config))
+	state := newGenerateState(homeDir)
+	state.path = filepath.Join(state.dir, hex.EncodeToString(sum[:16])+".json")
+	if data, err := readLocalFile(state.path, MaxConfigSize); err == nil {
+		_ = json.Unmarshal(data, state)
+	}
+	if state.Targets == nil {
+		state.Targets = make(map[string]targetState)
+	}
+	return state
+}
+
+// cachedOutputPath is where the output generated from inputs is cached.
+func (s *generateState) cachedOutputPath(inputs string) string {
+	return filepath.Join(s.dir, "outputs", inputs)
+}
+
+// cached returns the file generated before from the same inputs.
+func (s *generateState) cached(inputs string) ([]byte, bool) {
+	if s == nil || inputs == "" {
+		return nil, false
+	}
+	path := s.cachedOutputPath(inputs)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	touch(path)
+	return data, true
+}
+
+// store caches a file generated and formatted from inputs. Failing to
+// do so only means the target is generated again, so it is not an error.
+func (s *generateState) store(inputs, outPath string) {
+	if s == nil || inputs == "" {
+		return
+	}
+	path := s.cachedOutputPath(inputs)
+	if _, err := os.Stat(path); err == nil {
+		touch(path)
+		return
+	}
+	data, err := os.ReadFile(outPath)
+	if err != nil || os.MkdirAll(filepath.Dir(path), 0755) != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// save writes the state if a target changed. Failing to write it only
+// means targets are generated again, so errors are printed.
+func (s *generateState) save() {
+	if s == nil || s.path == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.changed {
+		return
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(s.path), 0755)
+	}
+	if err == nil {
+		tmp := s.path + ".tmp"
+		if err = os.WriteFile(tmp, append(data, '\n'), 0644); err == nil {
+			err = os.Rename(tmp, s.path)
+		}
+	}
+	if err != nil {
+		fmt.Printf("Could not save generation state: %v\n", err)
+	}
+}
+
+// clean reports whether the target was generated from the same inputs
+// and its file is still as it was generated.
+func (s *generateState) clean(filename, inputs, outPath string) bool {
+	if s == nil || s.path == "" || inputs == "" {
+		return false
+	}
+	s.mu.Lock()
+	previous, ok := s.Targets[filename]
+	s.mu.Unlock()
+	if !ok || previous.Inputs != inputs {
+		return false
+	}
+	output, err := hashPath(outPath)
+	return err == nil && output == previous.Output
+}
+
+// record stores the fingerprint of a target once its file is written
+// and formatted, or forgets the target when inputs is empty.
+func (s *generateState) record(filename, inputs, outPath string) {
+	if s == nil || s.path == "" {
+		return
+	}
+	output, err := hashPath(outPath)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if inputs == "" || err != nil {
+		if _, ok := s.Targets[filename]; ok {
+			delete(s.Targets, filename)
+			s.changed = true
+		}
+		return
+	}
+	current := targetState{Inputs: inputs, Output: output}
+	if s.Targets[filename] != current {
+		s.Targets[filename] = current
+		s.changed = true
+	}
+}
+
+// targetInputs returns a checksum of the inputs of a target: the CLI
+// version, its filename, the spec and the definitions it imports, the configuration
+// of the target, and its modules, templates, and dependsOn files. It
+// returns an empty string when an input cannot be read, so the target
+// is always generated.
+func (c *GenerateCmd) targetInputs(homeDir, spec, filename string, config Config) string {
+	s := c.state
+	if s == nil {
+		return ""
+	}
+	target := config.Generates[filename]
+	h := sha256.New()
+	fmt.Fprintf(h, "apex %s\x00%s\x00", Version, filename)
+
+	settings, err := json.Marshal(struct {
+		Target           Target                 `json:"target"`
+		Config           map[string]interface{} `json:"config"`
+		Core             string                 `json:"core"`
+		GenerateTemplate string                 `json:"generateTemplate"`
+		Aliases          map[string]string      `json:"aliases"`
+		OnFormatError    []string               `json:"onFormatError"`
+	}{target, config.Config, config.Core, config.GenerateTemplate, config.Aliases,
+		[]string{config.OnFormatError, c.OnFormatError}})
+	if err != nil {
+		return ""
+	}
+	h.Write(settings)
+	fmt.Fprintf(h, "\x00%s", spec)
+
+	files := importedDefinitions(homeDir, spec)
+	files = append(files, target.Template, config.GenerateTemplate, target.GenerateTemplate)
+	files = append(files, target.DependsOn...)
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		// Missing files are inputs too, such as imports that
+		// resolve from another home directory.
+		sum, err := hashPath(file)
+		if err != nil && !os.IsNotExist(err) {
+			return ""
+		}
+		fmt.Fprintf(h, "\x00%s=%s", file, sum)
+	}
+
+	core := target.Core
+	if core == "" {
+		core = config.Core
+	}
+	if core == "" {
+		core = defaultCoreModule
+	}
+	modules := []string{core, target.Module}
+	for _, visitor := range target.Visitors {
+		modules = append(modules, visitor.Module)
+	}
+	for _, module := range modules {
+		if module == "" {
+			continue
+		}
+		sum, ok := s.moduleSum(homeDir, resolveModuleAlias(module, config.Aliases))
+		if !ok {
+			return ""
+		}
+		fmt.Fprintf(h, "\x00%s=%s", module, sum)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// importedDefinitions returns the paths the definitions imported by a
+// spec, and those they import in turn, may resolve to.
+func importedDefinitions(homeDir, spec string) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	sources := []string{spec}
+	for len(sources) > 0 {
+		source := sources[0]
+		sources = sources[1:]
+		for _, match := range importPattern.FindAllStringSubmatch(source, -1) {
+			if seen[match[1]] {
+				continue
+			}
+			seen[match[1]] = true
+			for _, home := range moduleHomes(homeDir) {
+				location := filepath.Join(home, "definitions", filepath.FromSlash(match[1]))
+				for _, path := range []string{location + ".apex", location, filepath.Join(location, "index.apex")} {
+					paths = append(paths, path)
+					if data, err := readLocalFile(path, MaxSpecSize); err == nil {
+						sources = append(sources, string(data))
+					}
+				}
+			}
+		}
+	}
+	return paths
+}
+
+// moduleSum returns the checksum of the package of a module,
+// computed once per run.
+func (s *generateState) moduleSum(homeDir, module string) (string, bool) {
+	if strings.HasPrefix(module, ".") || filepath.IsAbs(module) {
+		// Local module files import others that are not known,
+		// so only local packages, which are hashed whole, are.
+		if fi, err := os.Stat(module); err != nil || !fi.IsDir() {
+			return "", false
+		}
+	} else {
+		module = packageName(module)
+	}
+	s.mu.Lock()
+	sum, ok := s.modules[module]
+	s.mu.Unlock()
+	if ok {
+		return sum, sum != ""
+	}
+	if file, found := hashModule(homeDir, module); found {
+		sum = file.SHA256
+	}
+	s.mu.Lock()
+	s.modules[module] = sum
+	s.mu.Unlock()
+	return sum, sum != ""
+}
+
+// hashPath returns the SHA-256 of a file or, for a directory,
+// of the names and contents of the files in it.
+func hashPath(path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if fi.IsDir() {
+		file, err := hashDir(path, path)
+		return file.SHA256, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return hashBytes(path, data).SHA256, nil
+}
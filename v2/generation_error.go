@@ -0,0 +1,131 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The phases of generating a target reported by GenerationError.
+const (
+	// GenerationPhaseConfig is an invalid target configuration,
+	// such as a missing module or an unknown mode.
+	GenerationPhaseConfig = "config"
+	// GenerationPhaseGenerate is running the target's visitor
+	// or rendering its template.
+	GenerationPhaseGenerate = "generate"
+	// GenerationPhaseFormat is formatting the generated source.
+	GenerationPhaseFormat = "format"
+	// GenerationPhaseMerge is merging output with an existing file.
+	GenerationPhaseMerge = "merge"
+	// GenerationPhaseWrite is encoding and writing the file.
+	GenerationPhaseWrite = "write"
+	// GenerationPhaseCommand is running the target's runAfter commands.
+	GenerationPhaseCommand = "command"
+	// GenerationPhaseDependency is a configuration that was skipped
+	// because one it depends on failed.
+	GenerationPhaseDependency = "dependency"
+)
+
+// GenerationError is a failure to generate a target. Err may be
+// a *FormatError or *TimeoutError with more detail.
+type GenerationError struct {
+	// Target is the target's filename, or empty when the error
+	// concerns a whole configuration.
+	Target string
+	// Phase is one of the GenerationPhase constants.
+	Phase string
+	Err   error
+}
+
+func (e *GenerationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *GenerationError) Unwrap() error {
+	return e.Err
+}
+
+// Errors are the errors of a generate run in the order they occurred.
+// Errors of a target are *GenerationError, while others, such as a
+// spec that could not be read, are as they were returned. errors.Is
+// and errors.As match any of them.
+type Errors []error
+
+func (e Errors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return msg("generate.failed", len(e))
+}
+
+// Errors returns each error.
+func (e Errors) Errors() []error {
+	return e
+}
+
+// Unwrap returns each error, for errors.Is and errors.As
+// in Go 1.20 and later.
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+// Is reports whether any error matches target.
+func (e Errors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error that matches target.
+func (e Errors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendError adds err to the Errors in merr, flattening
+// err when it is Errors itself.
+func appendError(merr, err error) error {
+	var errs Errors
+	switch m := merr.(type) {
+	case nil:
+	case Errors:
+		errs = m
+	default:
+		errs = Errors{m}
+	}
+	if more, ok := err.(Errors); ok {
+		return append(errs, more...)
+	}
+	return append(errs, err)
+}
+
+// appendTargetError prints a failure to generate target
+// during phase and adds it to merr.
+func appendTargetError(merr error, target, phase, format string, a ...interface{}) error {
+	err := &GenerationError{Target: target, Phase: phase, Err: fmt.Errorf(format, a...)}
+	fmt.Println(err)
+	return appendError(merr, err)
+}
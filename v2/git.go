@@ -0,0 +1,161 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// gitPrefix marks a location as any git remote, such as
+// git+https://git.example.com/team/module.git#v1.2.0.
+const gitPrefix = "git+"
+
+var commitPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+
+// getReleaseInfoFromGit resolves the ref after the # of a git location,
+// a branch, tag, or commit SHA, to a commit with git ls-remote. The
+// remote's default branch is used when no ref is given. Remotes are
+// cloned rather than downloaded so the tag of the release is the SHA.
+func (c *InstallCmd) getReleaseInfoFromGit(location, releaseTag string) (*releaseInfo, error) {
+	remote := strings.TrimPrefix(location, gitPrefix)
+	ref := releaseTag
+	if i := strings.LastIndex(remote, "#"); i != -1 {
+		if ref == "" {
+			ref = remote[i+1:]
+		}
+		remote = remote[:i]
+	}
+	name := strings.TrimSuffix(path.Base(remote), ".git")
+	if remote == "" || name == "" || name == "." || name == "/" {
+		return nil, fmt.Errorf("invalid git location: %q", location)
+	}
+	if ref == "" || ref == "latest" {
+		ref = "HEAD"
+	}
+
+	sha, err := resolveGitRef(remote, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	info := releaseInfo{
+		Module:        name,
+		Tag:           sha,
+		Clone:         remote,
+		Requested:     ref,
+		RequestedType: RequestedTag,
+	}
+	if ref == "HEAD" {
+		info.Requested = ""
+	} else if strings.HasPrefix(sha, strings.ToLower(ref)) {
+		// Commits never move so they are pinned like versions.
+		info.RequestedType = RequestedVersion
+	}
+	return &info, nil
+}
+
+// resolveGitRef returns the commit SHA of ref in remote. Annotated
+// tags resolve to the commit they point to. A ref that is not a
+// branch or tag but looks like a SHA is assumed to be a commit.
+func resolveGitRef(remote, ref string) (string, error) {
+	if err := checkGitArgs(remote, ref); err != nil {
+		return "", err
+	}
+	out, err := runGit("", "ls-remote", "--", remote, ref, ref+"^{}")
+	if err != nil {
+		return "", err
+	}
+	var sha string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		// The peeled commit of an annotated tag takes precedence.
+		if strings.HasSuffix(fields[1], "^{}") || sha == "" {
+			sha = fields[0]
+		}
+	}
+	switch {
+	case sha != "":
+		return sha, nil
+	case commitPattern.MatchString(ref):
+		return strings.ToLower(ref), nil
+	}
+	return "", fmt.Errorf("could not find branch, tag, or commit %s of %s", ref, remote)
+}
+
+// checkoutGit checks out the commit sha of remote into dir. Only the
+// commit is fetched when the remote allows it. Otherwise, such as for
+// abbreviated SHAs, the whole repository is cloned.
+func checkoutGit(remote, sha, dir string) error {
+	if err := checkGitArgs(remote, sha); err != nil {
+		return err
+	}
+	if _, err := runGit("", "init", "--quiet", "--", dir); err != nil {
+		return err
+	}
+	if _, err := runGit(dir, "fetch", "--quiet", "--depth", "1", "--", remote, sha); err == nil {
+		_, err = runGit(dir, "checkout", "--quiet", "FETCH_HEAD")
+		return err
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if _, err := runGit("", "clone", "--quiet", "--no-checkout", "--", remote, dir); err != nil {
+		return err
+	}
+	_, err := runGit(dir, "checkout", "--quiet", sha)
+	return err
+}
+
+// checkGitArgs rejects remotes and refs that git would parse as
+// options, such as --upload-pack=<command>, which can come from the
+// dependencies of a configuration that is installed automatically.
+func checkGitArgs(remote, ref string) error {
+	if strings.HasPrefix(remote, "-") {
+		return fmt.Errorf("invalid git remote: %q", remote)
+	}
+	if strings.HasPrefix(ref, "-") {
+		return fmt.Errorf("invalid git ref: %q", ref)
+	}
+	return nil
+}
+
+func runGit(dir string, args ...string) (string, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return "", fmt.Errorf("git is required to install from git remotes: %w", err)
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	// Fail rather than wait for credentials that can never be entered.
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/google/go-github/v33/github"
+)
+
+// githubToken returns the token used for GitHub API and download
+// requests from GITHUB_TOKEN, GH_TOKEN, or apex login.
+func githubToken() string {
+	for _, env := range []string{"GITHUB_TOKEN", "GH_TOKEN"} {
+		if token := os.Getenv(env); token != "" {
+			return token
+		}
+	}
+	return readCredentials().token(githubCredential)
+}
+
+// newGitHubClient returns a GitHub API client that
+// is authenticated when a token is available.
+func newGitHubClient() (*github.Client, error) {
+	transport, err := newHTTPTransport()
+	if err != nil {
+		return nil, err
+	}
+	return github.NewClient(&http.Client{
+		Transport: newGitHubTransport(transport),
+	}), nil
+}
+
+// githubTransport adds the token to requests sent to GitHub,
+// including the redirects to codeload.github.com for archives.
+type githubTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func newGitHubTransport(base http.RoundTripper) http.RoundTripper {
+	token := githubToken()
+	if token == "" {
+		return base
+	}
+	return &githubTransport{token: token, base: base}
+}
+
+func (t *githubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	if req.URL.Scheme != "https" || req.Header.Get("Authorization") != "" ||
+		(host != "github.com" && !strings.HasSuffix(host, ".github.com")) {
+		return t.base.RoundTrip(req)
+	}
+	// RoundTrippers must not modify the request.
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "token "+t.token)
+	return t.base.RoundTrip(req)
+}
+
+// packedReleaseAsset returns the download URL of the .tgz asset of a
+// release, the module packed with npm pack, or an empty string when
+// there is none. When several are attached, the one named after the
+// repository is used, as npm pack names tarballs after the package.
+func packedReleaseAsset(release *github.RepositoryRelease, repo string) string {
+	var packed []*github.ReleaseAsset
+	for _, asset := range release.Assets {
+		if strings.HasSuffix(asset.GetName(), ".tgz") && asset.GetState() == "uploaded" &&
+			asset.GetBrowserDownloadURL() != "" {
+			packed = append(packed, asset)
+		}
+	}
+	if len(packed) == 1 {
+		return packed[0].GetBrowserDownloadURL()
+	}
+	for _, asset := range packed {
+		// Scoped packages are packed as <scope>-<name>-<version>.tgz.
+		name := strings.TrimSuffix(asset.GetName(), ".tgz")
+		if strings.HasPrefix(name, repo+"-") || strings.Contains(name, "-"+repo+"-") {
+			return asset.GetBrowserDownloadURL()
+		}
+	}
+	return ""
+}
+
+// githubError suggests setting a token when GitHub
+// rate limits or hides a repository from anonymous requests.
+func githubError(err error) error {
+	if err == nil || githubToken() != "" {
+		return err
+	}
+	var rateErr *github.RateLimitError
+	var respErr *github.ErrorResponse
+	if errors.As(err, &rateErr) ||
+		(errors.As(err, &respErr) && respErr.Response != nil && respErr.Response.StatusCode == http.StatusNotFound) {
+		return fmt.Errorf("%w; run apex login github or set GITHUB_TOKEN for private repositories and higher rate limits", err)
+	}
+	return err
+}
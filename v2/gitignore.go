@@ -0,0 +1,261 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// gitIgnore matches paths against the .gitignore files of a repository
+// and its .git/info/exclude file. A nil gitIgnore ignores nothing.
+type gitIgnore struct {
+	root     string
+	patterns []ignorePattern
+}
+
+type ignorePattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// loadGitIgnore loads the ignore rules of the repository containing dir,
+// or of dir itself when it is not in a repository.
+func loadGitIgnore(dir string) (*gitIgnore, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for d := root; ; {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			root = d
+			break
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+
+	g := &gitIgnore{root: root}
+	if err = g.addFile(filepath.Join(root, ".git", "info", "exclude"), ""); err != nil {
+		return nil, err
+	}
+
+	// Patterns in a directory's .gitignore only apply within it, and
+	// git does not read .gitignore files in ignored directories.
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			rel = ""
+		} else if d.Name() == ".git" || g.match(rel, true) {
+			return filepath.SkipDir
+		}
+		return g.addFile(filepath.Join(p, ".gitignore"), rel)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+func (g *gitIgnore) addFile(filename, base string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if pattern, ok := compileIgnorePattern(scanner.Text(), base); ok {
+			g.patterns = append(g.patterns, pattern)
+		}
+	}
+	return scanner.Err()
+}
+
+// Ignored reports whether a file or directory, given relative to the
+// working directory or as an absolute path, is ignored. Paths outside
+// the repository are not ignored.
+func (g *gitIgnore) Ignored(filename string, isDir bool) bool {
+	if g == nil {
+		return false
+	}
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(g.root, abs)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	// A file cannot be re-included when one of its parents is ignored.
+	for i := strings.Index(rel, "/"); i != -1; {
+		if g.match(rel[:i], true) {
+			return true
+		}
+		next := strings.Index(rel[i+1:], "/")
+		if next == -1 {
+			break
+		}
+		i += next + 1
+	}
+	return g.match(rel, isDir)
+}
+
+// match applies the patterns to a slash separated path relative
+// to the repository root. The last matching pattern wins.
+func (g *gitIgnore) match(rel string, isDir bool) bool {
+	ignored := false
+	for _, p := range g.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.re.MatchString(rel) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+// compileIgnorePattern converts a .gitignore line into a regular
+// expression matching paths relative to the repository root.
+func compileIgnorePattern(line, base string) (ignorePattern, bool) {
+	line = strings.TrimRight(line, " \t")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignorePattern{}, false
+	}
+
+	var p ignorePattern
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignorePattern{}, false
+	}
+
+	// Patterns containing a slash are relative to the .gitignore's
+	// directory. Others match a name at any depth below it.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	var re strings.Builder
+	re.WriteString("^")
+	if base != "" {
+		re.WriteString(regexp.QuoteMeta(base + "/"))
+	}
+	if !anchored {
+		re.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case strings.HasPrefix(line[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(line[i:], "/**") && i+3 == len(line):
+			re.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(line[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case ch == '*':
+			re.WriteString("[^/]*")
+		case ch == '?':
+			re.WriteString("[^/]")
+		case ch == '[':
+			end := strings.IndexByte(line[i+1:], ']')
+			if end == -1 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := line[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case ch == '\\' && i+1 < len(line):
+			i++
+			re.WriteString(regexp.QuoteMeta(string(line[i])))
+		default:
+			re.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	re.WriteString("$")
+
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return ignorePattern{}, false
+	}
+	p.re = compiled
+	return p, true
+}
+
+// findConfigs returns the configuration files named name under dir,
+// skipping directories ignored by git.
+func findConfigs(dir, name string) ([]string, error) {
+	ignore, err := loadGitIgnore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []string
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != dir && (d.Name() == ".git" || ignore.Ignored(p, true)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() == name && !ignore.Ignored(p, false) {
+			configs = append(configs, p)
+		}
+		return nil
+	})
+
+	return configs, err
+}
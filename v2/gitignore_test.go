@@ -0,0 +1,161 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileIgnorePattern(t *testing.T) {
+	for _, test := range []struct {
+		pattern, base string
+		path          string
+		isDir         bool
+		matched       bool
+	}{
+		// Patterns without a slash match at any depth.
+		{"*.log", "", "debug.log", false, true},
+		{"*.log", "", "a/b/debug.log", false, true},
+		{"*.log", "sub", "debug.log", false, false},
+		{"*.log", "sub", "sub/a/debug.log", false, true},
+		// Patterns with a slash are anchored to the base.
+		{"/build", "", "build", true, true},
+		{"/build", "", "sub/build", true, false},
+		{"docs/*.html", "", "docs/index.html", false, true},
+		{"docs/*.html", "", "docs/api/index.html", false, false},
+		{"docs/*.html", "", "sub/docs/index.html", false, false},
+		{"/anchored.txt", "sub", "sub/anchored.txt", false, true},
+		{"/anchored.txt", "sub", "anchored.txt", false, false},
+		// **/ matches any directories, including none.
+		{"**/tmp", "", "tmp", true, true},
+		{"**/tmp", "", "a/b/tmp", true, true},
+		{"a/**/b", "", "a/b", false, true},
+		{"a/**/b", "", "a/x/y/b", false, true},
+		{"a/**/b", "", "a/bc", false, false},
+		// A trailing /** matches everything inside, but not the directory.
+		{"out/**", "", "out/a/b", false, true},
+		{"out/**", "", "out", true, false},
+		// A trailing slash only matches directories.
+		{"cache/", "", "cache", true, true},
+		{"cache/", "", "cache", false, false},
+		{"cache/", "", "sub/cache", true, true},
+		// Wildcards do not match slashes.
+		{"a?c", "", "abc", false, true},
+		{"a?c", "", "a/c", false, false},
+		{"[!a]b", "", "cb", false, true},
+		{"[!a]b", "", "ab", false, false},
+		{`\#hash`, "", "#hash", false, true},
+		{`\!bang`, "", "!bang", false, true},
+	} {
+		p, ok := compileIgnorePattern(test.pattern, test.base)
+		require.True(t, ok, test.pattern)
+		matched := p.re.MatchString(test.path) && (!p.dirOnly || test.isDir)
+		assert.Equal(t, test.matched, matched, "%q in %q matching %q", test.pattern, test.base, test.path)
+	}
+
+	p, ok := compileIgnorePattern("!keep.log", "")
+	require.True(t, ok)
+	assert.True(t, p.negate)
+
+	for _, line := range []string{"", "   ", "# comment", "!", "/"} {
+		_, ok := compileIgnorePattern(line, "")
+		assert.False(t, ok, line)
+	}
+}
+
+// TestGitIgnore matches paths the way git does, which was checked with
+// git add --dry-run in a repository with the same files.
+func TestGitIgnore(t *testing.T) {
+	dir := t.TempDir()
+	for filename, content := range map[string]string{
+		".git/info/exclude": "excluded.txt\n",
+		".gitignore": `# comments and blank lines are skipped
+
+*.log
+!keep.log
+/build
+docs/*.html
+**/tmp/
+out/**
+cache/
+vendor/
+!vendor/keep.txt
+a/**/b
+\#hash
+`,
+		"sub/.gitignore": "local.txt\n/anchored.txt\n!important.log\n",
+		// Files in ignored directories are not read.
+		"build/.gitignore": "!*\n",
+	} {
+		filename = filepath.Join(dir, filepath.FromSlash(filename))
+		require.NoError(t, os.MkdirAll(filepath.Dir(filename), 0755))
+		require.NoError(t, os.WriteFile(filename, []byte(content), 0644))
+	}
+	ignore, err := loadGitIgnore(filepath.Join(dir, "sub"))
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"debug.log", false, true},
+		{"sub/debug.log", false, true},
+		{"keep.log", false, false},
+		{"sub/keep.log", false, false},
+		{"build", true, true},
+		{"build/x.go", false, true},
+		{"sub/build/x.go", false, false},
+		{"docs/index.html", false, true},
+		{"docs/api/index.html", false, false},
+		{"tmp/x", false, true},
+		{"a/c/tmp/x", false, true},
+		{"tmp2/tmp", false, false},
+		{"out", true, false},
+		{"out/x", false, true},
+		{"out/a/b", false, true},
+		{"cache/x", false, true},
+		{"sub/cache/x", false, true},
+		{"cachefile/cache", false, false},
+		// A file cannot be re-included when its parent is ignored.
+		{"vendor/keep.txt", false, true},
+		{"a/b", false, true},
+		{"a/x/y/b", false, true},
+		{"a/bc", false, false},
+		{"#hash", false, true},
+		{"excluded.txt", false, true},
+		// Nested .gitignore files apply below their directory.
+		{"local.txt", false, false},
+		{"sub/local.txt", false, true},
+		{"sub/deeper/local.txt", false, true},
+		{"sub/anchored.txt", false, true},
+		{"sub/deeper/anchored.txt", false, false},
+		{"sub/important.log", false, false},
+		{"important.log", false, true},
+		{"README.md", false, false},
+	} {
+		assert.Equal(t, test.ignored, ignore.Ignored(filepath.Join(dir, filepath.FromSlash(test.path)), test.isDir), test.path)
+	}
+
+	assert.False(t, ignore.Ignored(filepath.Dir(dir), true), "outside the repository")
+	assert.False(t, (*gitIgnore)(nil).Ignored("debug.log", false))
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const gitlabAPI = "https://gitlab.com/api/v4"
+
+// errGitLabNotFound is returned for GitLab API requests that 404.
+var errGitLabNotFound = errors.New("not found")
+
+type gitlabRelease struct {
+	TagName         string `json:"tag_name"`
+	UpcomingRelease bool   `json:"upcoming_release"`
+}
+
+// gitlabToken returns the token used for GitLab API and download
+// requests from GITLAB_TOKEN or ~/.apex/credentials.yaml.
+func gitlabToken() string {
+	if token := os.Getenv("GITLAB_TOKEN"); token != "" {
+		return token
+	}
+	return readCredentials().GitLab.Token
+}
+
+// gitlabTransport adds the token to requests sent to gitlab.com.
+type gitlabTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func newGitLabTransport(base http.RoundTripper) http.RoundTripper {
+	token := gitlabToken()
+	if token == "" {
+		return base
+	}
+	return &gitlabTransport{token: token, base: base}
+}
+
+func (t *gitlabTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" || req.URL.Hostname() != "gitlab.com" || req.Header.Get("PRIVATE-TOKEN") != "" {
+		return t.base.RoundTrip(req)
+	}
+	// RoundTrippers must not modify the request.
+	req = req.Clone(req.Context())
+	req.Header.Set("PRIVATE-TOKEN", t.token)
+	return t.base.RoundTrip(req)
+}
+
+// getReleaseInfoFromGitlab resolves a release of gitlab.com/<group>/<project>
+// the same way as for GitHub: the latest release, a semver range of release
+// tags, a release tag, or a branch.
+func (c *InstallCmd) getReleaseInfoFromGitlab(location, releaseTag string) (*releaseInfo, error) {
+	parts := strings.Split(location, "/")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid repo syntax: %q", location)
+	}
+	project := url.PathEscape(location)
+	info := releaseInfo{
+		Org:    parts[0],
+		Module: parts[len(parts)-1],
+	}
+
+	switch {
+	case isSemverRange(releaseTag):
+		releases, err := c.gitlabReleases(project, 0)
+		if err != nil {
+			return nil, err
+		}
+		tags := make([]string, len(releases))
+		for i, release := range releases {
+			tags[i] = release.TagName
+		}
+		best, found, err := maxSatisfying(tags, releaseTag)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, fmt.Errorf("%s has no release matching %q", location, releaseTag)
+		}
+		info.Tag = best
+		info.Requested = releaseTag
+		info.RequestedType = RequestedRange
+	case releaseTag == "" || releaseTag == "latest":
+		// Releases are sorted with the most recent first.
+		releases, err := c.gitlabReleases(project, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(releases) == 0 {
+			return nil, fmt.Errorf("there are no releases for %s", location)
+		}
+		info.Tag = releases[0].TagName
+	default:
+		var release gitlabRelease
+		err := c.gitlabGet(fmt.Sprintf("/projects/%s/releases/%s", project, url.PathEscape(releaseTag)), &release)
+		if errors.Is(err, errGitLabNotFound) {
+			// Install from a branch of the same name.
+			var branch struct {
+				Name string `json:"name"`
+			}
+			err = c.gitlabGet(fmt.Sprintf("/projects/%s/repository/branches/%s", project, url.PathEscape(releaseTag)), &branch)
+			release.TagName = branch.Name
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not find release or branch %s of %s: %w", releaseTag, location, err)
+		}
+		info.Tag = release.TagName
+	}
+
+	info.TarballURL = fmt.Sprintf("%s/projects/%s/repository/archive.tar.gz?sha=%s",
+		gitlabAPI, project, url.QueryEscape(info.Tag))
+
+	return &info, nil
+}
+
+// gitlabReleases lists a project's releases, most recent first,
+// excluding upcoming releases. A limit of 0 lists every release.
+func (c *InstallCmd) gitlabReleases(project string, limit int) ([]gitlabRelease, error) {
+	var releases []gitlabRelease
+	for page := 1; ; page++ {
+		var batch []gitlabRelease
+		if err := c.gitlabGet(fmt.Sprintf("/projects/%s/releases?per_page=100&page=%d", project, page), &batch); err != nil {
+			return nil, err
+		}
+		for _, release := range batch {
+			if !release.UpcomingRelease {
+				releases = append(releases, release)
+			}
+		}
+		if len(batch) < 100 || (limit > 0 && len(releases) >= limit) {
+			break
+		}
+	}
+	if limit > 0 && len(releases) > limit {
+		releases = releases[:limit]
+	}
+	return releases, nil
+}
+
+func (c *InstallCmd) gitlabGet(path string, v interface{}) error {
+	resp, err := c.netClient.Get(gitlabAPI + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		if gitlabToken() == "" {
+			return fmt.Errorf("%w; set GITLAB_TOKEN for private projects", errGitLabNotFound)
+		}
+		return errGitLabNotFound
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("GitLab returned status %d for %s", resp.StatusCode, path)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+var baseDependencies = map[string][]string{
+	"@apexlang/core": {
+		"node_modules/@apexlang/core",
+		"templates/@apexlang",
+	},
+}
+
+// AddDependencies adds modules installed on first use, each with the
+// paths relative to the home directory that show it is installed.
+func AddDependencies(dependencies map[string][]string) {
+	for name, paths := range dependencies {
+		baseDependencies[name] = paths
+	}
+}
+
+func getHomeDirectory() (string, error) {
+	homeDir, err := ensureHomeDirectory()
+	if err != nil {
+		return "", err
+	}
+
+	err = checkDependencies(homeDir, false, "")
+
+	return homeDir, err
+}
+
+// HomeDirName is the directory in the user's home directory holding
+// installed modules and configuration when APEX_HOME is not set.
+// Distributions of the CLI may set it at startup, such as to ".iota".
+var HomeDirName = ".apex"
+
+// apexHomeDir returns the Apex home directory, which is APEX_HOME
+// when set and otherwise ~/.apex, following the redirect left
+// there when it was moved with store move.
+func apexHomeDir() (string, error) {
+	dir, err := configuredHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return followHomeRedirect(dir)
+}
+
+// configuredHomeDir returns APEX_HOME when set and otherwise ~/.apex.
+func configuredHomeDir() (string, error) {
+	if dir := os.Getenv(HomeEnv); dir != "" {
+		dir, err := homedir.Expand(dir)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Abs(dir)
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	home, err = homedir.Expand(home)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, HomeDirName), nil
+}
+
+func ensureHomeDirectory() (string, error) {
+	homeDir, err := apexHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	srcDir := filepath.Join(homeDir, "node_modules")
+	templatesDir := filepath.Join(homeDir, "templates")
+	definitionsDir := filepath.Join(homeDir, "definitions")
+
+	if _, err := os.Stat(srcDir); os.IsNotExist(err) {
+		if err = os.MkdirAll(srcDir, 0700); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err := os.Stat(templatesDir); os.IsNotExist(err) {
+		if err = os.MkdirAll(templatesDir, 0700); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err := os.Stat(definitionsDir); os.IsNotExist(err) {
+		if err = os.MkdirAll(definitionsDir, 0700); err != nil {
+			return "", err
+		}
+	}
+
+	return homeDir, nil
+}
+
+func checkDependencies(homeDir string, forceDownload bool, progress string) error {
+	opts := DependencyOptions{Force: forceDownload}
+	if reporter := newProgressReporter(progress); reporter != nil {
+		opts.Progress = reporter.emit
+	}
+	return EnsureDependencies(context.Background(), homeDir, baseDependencies, opts)
+}
+
+// DependencyOptions configures how EnsureDependencies installs modules.
+type DependencyOptions struct {
+	// Progress, when set, receives the progress events of each install.
+	Progress func(ProgressEvent)
+	// Force reinstalls every module, even those already installed.
+	Force bool
+	// Offline installs only from previously downloaded archives and
+	// registry metadata, as with APEX_OFFLINE.
+	Offline bool
+	// Pins are the releases to install by module, such as "0.1.2" or
+	// "^0.1". Modules without a pin use .apexrc.yaml or the latest.
+	Pins map[string]string
+}
+
+// EnsureDependencies installs the modules of deps that are missing from
+// homeDir, which is APEX_HOME or ~/.apex when empty. Like AddDependencies,
+// deps maps each module to the paths relative to homeDir that show it is
+// installed, so programs embedding the CLI can manage their own set of
+// base modules. Modules are installed in name order and ctx is checked
+// before each one.
+func EnsureDependencies(ctx context.Context, homeDir string, deps map[string][]string, opts DependencyOptions) error {
+	if homeDir == "" {
+		var err error
+		if homeDir, err = ensureHomeDirectory(); err != nil {
+			return err
+		}
+	}
+
+	var missing []string
+	for dependency, checks := range deps {
+		if opts.Force {
+			missing = append(missing, dependency)
+			continue
+		}
+		for _, check := range checks {
+			check = strings.ReplaceAll(check, "/", string(filepath.Separator))
+			if _, err := os.Stat(filepath.Join(homeDir, check)); os.IsNotExist(err) {
+				missing = append(missing, dependency)
+				break
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+
+	fmt.Println(msg("home.installing_base"))
+	for _, dependency := range missing {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		cmd := InstallCmd{
+			Location:   dependency,
+			Release:    opts.Pins[dependency],
+			Force:      opts.Force,
+			Offline:    opts.Offline,
+			onProgress: opts.Progress,
+		}
+		if err := cmd.doRun(&Context{}, homeDir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
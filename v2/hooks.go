@@ -0,0 +1,170 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Git hooks installed by hooks install.
+const (
+	HookPreCommit = "pre-commit"
+	HookPrePush   = "pre-push"
+)
+
+// hookMarker identifies hooks written by hooks install,
+// which are replaced without --force.
+const hookMarker = "# Installed by apex hooks install."
+
+// hookScripts check the specifications and configurations changed by
+// a commit or push. Only changed specifications are format checked,
+// and generate --since only regenerates the targets they affect.
+var hookScripts = map[string]string{
+	HookPreCommit: `#!/bin/sh
+` + hookMarker + `
+# Checks the specifications and generated files of staged changes.
+set -e
+IFS='
+'
+changed=$(git diff --cached --name-only --diff-filter=ACMRD)
+[ -n "$changed" ] || exit 0
+specs=$(git diff --cached --name-only --diff-filter=ACMR -- '*.apex')
+if [ -n "$specs" ]; then
+  apex spec format --check $specs
+fi
+apex ci %[1]s --skip check,lint
+if git rev-parse --quiet --verify HEAD >/dev/null; then
+  apex generate %[1]s --check --since HEAD
+else
+  apex generate %[1]s --check
+fi
+`,
+	HookPrePush: `#!/bin/sh
+` + hookMarker + `
+# Checks the specifications and generated files of pushed changes,
+# or of every specification when the branch has no upstream.
+set -e
+IFS='
+'
+since=$(git rev-parse --abbrev-ref --symbolic-full-name '@{upstream}' 2>/dev/null || true)
+if [ -n "$since" ]; then
+  specs=$(git diff --name-only --diff-filter=ACMR "$since" -- '*.apex')
+else
+  specs=$(git ls-files -- '*.apex')
+fi
+if [ -n "$specs" ]; then
+  apex spec format --check $specs
+fi
+apex ci %[1]s --skip check,lint
+if [ -n "$since" ]; then
+  apex generate %[1]s --check --since "$since"
+else
+  apex generate %[1]s --check
+fi
+`,
+}
+
+// preCommitConfig is the snippet of .pre-commit-config.yaml running
+// the same checks with the pre-commit framework.
+const preCommitConfig = `repos:
+  - repo: local
+    hooks:
+      - id: apex-spec-format
+        name: apex spec format
+        entry: apex spec format --check
+        language: system
+        files: \.apex$
+      - id: apex-validate
+        name: apex validate
+        entry: apex ci %[1]s --skip check,lint
+        language: system
+        pass_filenames: false
+      - id: apex-generate-check
+        name: apex generate --check
+        entry: apex generate %[1]s --check --since HEAD
+        language: system
+        pass_filenames: false
+`
+
+type HooksCmd struct {
+	Install HooksInstallCmd `cmd:"" help:"Installs a git hook checking that changed specifications are formatted and valid and that generated files are up to date."`
+}
+
+type HooksInstallCmd struct {
+	Hook   string `arg:"" help:"The git hook to install (pre-commit or pre-push)." enum:"pre-commit,pre-push" default:"pre-commit"`
+	Config string `help:"The code generation configuration the hook checks." default:"apex.yaml"`
+	Force  bool   `help:"Replace an existing hook that was not installed by apex."`
+	// PreCommitConfig prints configuration for the pre-commit
+	// framework instead of writing a git hook.
+	PreCommitConfig bool `help:"Print a .pre-commit-config.yaml snippet running the checks with the pre-commit framework instead of installing a hook."`
+}
+
+func (c *HooksInstallCmd) Run(ctx *Context) error {
+	// Hooks run in the root of the repository.
+	config := c.Config
+	if !filepath.IsAbs(config) {
+		prefix, err := runGit("", "rev-parse", "--show-prefix")
+		if err != nil {
+			return err
+		}
+		config = filepath.Join(strings.TrimSpace(prefix), config)
+	}
+	config = shellQuote(filepath.ToSlash(config))
+	if c.PreCommitConfig {
+		fmt.Printf(preCommitConfig, config)
+		return nil
+	}
+
+	// Git resolves the hooks directory, which core.hooksPath
+	// and worktrees change.
+	out, err := runGit("", "rev-parse", "--git-path", filepath.ToSlash(filepath.Join("hooks", c.Hook)))
+	if err != nil {
+		return err
+	}
+	hookPath := filepath.FromSlash(strings.TrimSpace(out))
+
+	existing, err := os.ReadFile(hookPath)
+	switch {
+	case err == nil && !c.Force && !strings.Contains(string(existing), hookMarker):
+		return fmt.Errorf("%s already exists; run with --force to replace it", hookPath)
+	case err != nil && !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(hookPath), 0755); err != nil {
+		return err
+	}
+	if err = os.WriteFile(hookPath, []byte(fmt.Sprintf(hookScripts[c.Hook], config)), 0755); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of an existing file.
+	if err = os.Chmod(hookPath, 0755); err != nil {
+		return err
+	}
+
+	fmt.Printf("Installed the %s hook in %s\n", c.Hook, hookPath)
+	return nil
+}
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
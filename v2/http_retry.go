@@ -0,0 +1,153 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Used when InstallCmd.HTTPRetries or InstallCmd.HTTPTimeout are zero,
+// such as for installs started by other commands.
+const (
+	defaultHTTPRetries = 3
+	defaultHTTPTimeout = 30 * time.Second
+)
+
+// Retries wait twice as long as the previous attempt, up to a limit.
+const (
+	initialBackoff = 500 * time.Millisecond
+	maxBackoff     = 15 * time.Second
+)
+
+// errStalled is returned when a response body stops sending data.
+var errStalled = errors.New("download stalled")
+
+func (c *InstallCmd) httpRetries() int {
+	switch {
+	case c.HTTPRetries < 0:
+		return 0
+	case c.HTTPRetries == 0:
+		return defaultHTTPRetries
+	}
+	return c.HTTPRetries
+}
+
+func (c *InstallCmd) httpTimeout() time.Duration {
+	if c.HTTPTimeout <= 0 {
+		return defaultHTTPTimeout
+	}
+	return c.HTTPTimeout
+}
+
+// backoff returns how long to wait before retry n, counting from 1.
+func backoff(n int) time.Duration {
+	d := initialBackoff
+	for i := 1; i < n && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+// retryTransport retries GET and HEAD requests that fail to connect,
+// time out waiting for a response, or receive a 429 or 5xx status.
+// Failures while reading a response body are retried by the caller,
+// which can resume where the body stopped.
+type retryTransport struct {
+	retries int
+	base    http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return t.base.RoundTrip(req)
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if attempt >= t.retries || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		wait := backoff(attempt + 1)
+		switch {
+		case err != nil:
+			fmt.Printf("Retrying %s in %s: %v\n", req.URL.Redacted(), wait, err)
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+				if after := time.Duration(seconds) * time.Second; after <= maxBackoff {
+					wait = after
+				}
+			}
+			fmt.Printf("Retrying %s in %s: got status %d\n", req.URL.Redacted(), wait, resp.StatusCode)
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+			resp.Body.Close()
+		default:
+			return resp, nil
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// stallReader fails a response body that receives no data within
+// timeout by canceling its request, so a slow but progressing
+// download is never interrupted.
+type stallReader struct {
+	r       io.Reader
+	timer   *time.Timer
+	timeout time.Duration
+	stalled int32
+}
+
+// newStallReader wraps body, calling cancel to abort the request
+// if it stalls. stop must be called once the body has been read.
+func newStallReader(body io.Reader, timeout time.Duration, cancel context.CancelFunc) *stallReader {
+	s := &stallReader{r: body, timeout: timeout}
+	s.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&s.stalled, 1)
+		cancel()
+	})
+	return s
+}
+
+func (s *stallReader) Read(b []byte) (int, error) {
+	n, err := s.r.Read(b)
+	if n > 0 {
+		s.timer.Reset(s.timeout)
+	}
+	if err != nil && err != io.EOF && atomic.LoadInt32(&s.stalled) == 1 {
+		err = fmt.Errorf("%w: no data received for %s", errStalled, s.timeout)
+	}
+	return n, err
+}
+
+func (s *stallReader) stop() {
+	s.timer.Stop()
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+type InfoCmd struct {
+	Location string `arg:"" help:"The NPM module to show information for."`
+}
+
+func (c *InfoCmd) Run(ctx *Context) error {
+	install := InstallCmd{}
+	if err := install.createHTTPClient(); err != nil {
+		return err
+	}
+
+	p, err := fetchPackument(&install.netClient, c.Location)
+	if err != nil {
+		return err
+	}
+	p.updateDistTags(&install.netClient)
+
+	tags := make([]string, 0, len(p.DistTags))
+	for tag := range p.DistTags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	fmt.Printf("%s (%d versions)\n", p.Name, len(p.Versions))
+
+	t := table.NewWriter()
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{
+			Name:   "Dist-Tag",
+			Colors: text.Colors{text.FgGreen},
+		},
+		{
+			Name:   "Version",
+			Colors: text.Colors{text.FgCyan},
+		},
+	})
+	t.AppendHeader(table.Row{"Dist-Tag", "Version"})
+	for _, tag := range tags {
+		t.AppendRow(table.Row{tag, p.DistTags[tag]})
+	}
+	fmt.Println(t.Render())
+
+	return nil
+}
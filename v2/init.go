@@ -18,6 +18,7 @@ package cli
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -35,9 +36,18 @@ type InitCmd struct {
 	Dir       string            `type:"existingdir" help:"The project directory" default:"."`
 	Spec      string            `type:"existingfile" help:"An optional specification file to copy into the project"`
 	Variables map[string]string `arg:"" help:"Variables to pass to the template." optional:""`
+	// FromSpec writes an apex.yaml instead of using the template.
+	FromSpec string `type:"existingfile" help:"Write an apex.yaml with targets inferred from the interfaces, types, and annotations in a specification instead of using a template."`
+	Language string `help:"The language of the targets inferred with --from-spec (go or typescript)." enum:"go,typescript" default:"go"`
+	Yes      bool   `short:"y" help:"Accept every target inferred with --from-spec without prompting."`
 }
 
 func (c *InitCmd) Run(ctx *Context) error {
+	if c.FromSpec != "" {
+		return c.initFromSpec()
+	}
+
+	c.Template = resolveModuleAlias(c.Template, projectAliases())
 	if strings.Contains(c.Template, "..") {
 		return fmt.Errorf("invalid template %s", c.Template)
 	}
@@ -48,21 +58,21 @@ func (c *InitCmd) Run(ctx *Context) error {
 	}
 
 	templatePart := strings.ReplaceAll(c.Template, "/", string(filepath.Separator))
-	templatePath := filepath.Join(homeDir, "templates", templatePart)
+	templatePath := findTemplate(homeDir, templatePart)
 
 	// If a short name was provided and does not exist,
 	// assume its a first-party template and prepend "@apexlang/".
 	if !strings.HasPrefix(templatePart, "@") {
 		if _, err := os.Stat(templatePath); err != nil && os.IsNotExist(err) {
 			templatePart = filepath.Join("@apexlang", templatePart)
-			templatePath = filepath.Join(homeDir, "templates", templatePart)
+			templatePath = findTemplate(homeDir, templatePart)
 		}
 	}
 
 	templateDir, err := os.Stat(templatePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return fmt.Errorf("template %s is not installed", c.Template)
+			return errors.New(msg("init.template_not_installed", c.Template))
 		}
 		return err
 	}
@@ -77,7 +87,7 @@ func (c *InitCmd) Run(ctx *Context) error {
 			return fmt.Errorf("%s already exists", c.Dir)
 		}
 
-		fmt.Printf("Creating project directory %s\n", c.Dir)
+		fmt.Println(msg("init.creating_project", c.Dir))
 		if err = os.MkdirAll(c.Dir, 0777); err != nil {
 			return err
 		}
@@ -116,6 +126,16 @@ func (c *InitCmd) Run(ctx *Context) error {
 
 	for _, variable := range template.Variables {
 		if _, ok := c.Variables[variable.Name]; !ok {
+			if variable.DefaultFrom != "" {
+				value, err := variableDefault(variable.DefaultFrom)
+				if err != nil {
+					return fmt.Errorf("variable %s: %w", variable.Name, err)
+				}
+				if value != "" {
+					c.Variables[variable.Name] = value
+					continue
+				}
+			}
 			value, err := ui.Ask(variable.Prompt, &input.Options{
 				Default:   variable.Default,
 				Required:  variable.Required,
@@ -0,0 +1,212 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// restAnnotations mark a specification as describing an HTTP API.
+var restAnnotations = []string{"path", "GET", "POST", "PUT", "PATCH", "DELETE"}
+
+// specGenerators are the modules suggested for targets by language.
+var specGenerators = map[string]struct {
+	module     string
+	interfaces string
+	models     string
+	ext        string
+}{
+	"go":         {"@apexlang/codegen/go", "InterfacesVisitor", "StructVisitor", ".go"},
+	"typescript": {"@apexlang/codegen/typescript", "InterfacesVisitor", "ClassVisitor", ".ts"},
+}
+
+// suggestedTarget is a target inferred from the contents of a
+// specification along with why it was suggested.
+type suggestedTarget struct {
+	Filename     string                 `yaml:"-"`
+	Reason       string                 `yaml:"-"`
+	Module       string                 `yaml:"module"`
+	VisitorClass string                 `yaml:"visitorClass"`
+	Config       map[string]interface{} `yaml:"config,omitempty"`
+}
+
+// initFromSpec writes an apex.yaml to the project directory with
+// targets suggested for the definitions found in the specification,
+// asking to confirm each one unless Yes is set.
+func (c *InitCmd) initFromSpec() error {
+	configFile := filepath.Join(c.Dir, "apex.yaml")
+	if _, err := os.Stat(configFile); err == nil {
+		return fmt.Errorf("%s already exists", configFile)
+	}
+
+	homeDir, err := getHomeDirectory()
+	if err != nil {
+		return err
+	}
+	specBytes, err := readLocalFile(c.FromSpec, MaxSpecSize)
+	if err != nil {
+		return err
+	}
+	docJSON, err := parseSpec(homeDir, defaultCoreModule, string(specBytes))
+	if err != nil {
+		return fmt.Errorf("could not parse %s: %w", c.FromSpec, err)
+	}
+	suggested, err := suggestTargets(docJSON, c.Language)
+	if err != nil {
+		return err
+	}
+	if len(suggested) == 0 {
+		return fmt.Errorf("%s does not contain interfaces or types to generate code for", c.FromSpec)
+	}
+
+	generates := make(map[string]suggestedTarget, len(suggested))
+	reader := bufio.NewReader(os.Stdin)
+	for _, target := range suggested {
+		if !c.Yes {
+			fmt.Printf("Generate %s with %s (%s)? [Y/n] ", target.Filename, target.Module, target.Reason)
+			line, err := reader.ReadString('\n')
+			if err != nil && line == "" {
+				return errors.New("no answer given; use --yes to accept all targets")
+			}
+			if answer := strings.ToLower(strings.TrimSpace(line)); answer == "n" || answer == "no" {
+				continue
+			}
+		}
+		generates[target.Filename] = target
+	}
+	if len(generates) == 0 {
+		fmt.Println("No targets selected.")
+		return nil
+	}
+
+	// The spec is referenced relative to the project.
+	spec, err := relativeTo(c.Dir, c.FromSpec)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err = enc.Encode(struct {
+		Spec      string                     `yaml:"spec"`
+		Generates map[string]suggestedTarget `yaml:"generates"`
+	}{spec, generates}); err != nil {
+		return err
+	}
+	enc.Close()
+	if err = os.WriteFile(configFile, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s with %d target(s)\n", configFile, len(generates))
+	return nil
+}
+
+// suggestTargets infers targets from a parsed document: interfaces
+// become a service target, types, enums, and unions a models target,
+// and REST annotations an OpenAPI document.
+func suggestTargets(docJSON, language string) ([]suggestedTarget, error) {
+	generator, ok := specGenerators[language]
+	if !ok {
+		return nil, fmt.Errorf("unsupported language %q", language)
+	}
+	stats, err := specStats(docJSON)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Definitions []specDefinition `json:"definitions"`
+	}
+	if err = json.Unmarshal([]byte(docJSON), &doc); err != nil {
+		return nil, fmt.Errorf("could not decode document: %w", err)
+	}
+
+	// Outputs are placed in a directory named for the namespace,
+	// which is also the Go package.
+	pkg := "api"
+	for _, def := range doc.Definitions {
+		if def.Kind == "NamespaceDefinition" && def.Name != nil {
+			parts := strings.Split(def.Name.Value, ".")
+			pkg = strings.ToLower(parts[len(parts)-1])
+			break
+		}
+	}
+	var config map[string]interface{}
+	if language == "go" {
+		config = map[string]interface{}{"package": pkg}
+	}
+
+	var suggested []suggestedTarget
+	if stats.Interfaces > 0 {
+		suggested = append(suggested, suggestedTarget{
+			Filename:     path.Join(pkg, "interfaces"+generator.ext),
+			Reason:       fmt.Sprintf("%d interface(s)", stats.Interfaces),
+			Module:       generator.module,
+			VisitorClass: generator.interfaces,
+			Config:       config,
+		})
+	}
+	if models := stats.Types + stats.Enums + stats.Unions; models > 0 {
+		suggested = append(suggested, suggestedTarget{
+			Filename:     path.Join(pkg, "models"+generator.ext),
+			Reason:       fmt.Sprintf("%d type(s), enum(s), and union(s)", models),
+			Module:       generator.module,
+			VisitorClass: generator.models,
+			Config:       config,
+		})
+	}
+	rest := 0
+	for _, name := range restAnnotations {
+		rest += stats.Annotations[name]
+	}
+	if rest > 0 {
+		suggested = append(suggested, suggestedTarget{
+			Filename:     "openapi.yaml",
+			Reason:       fmt.Sprintf("%d REST annotation(s)", rest),
+			Module:       "@apexlang/codegen/openapiv3",
+			VisitorClass: "OpenAPIV3Visitor",
+		})
+	}
+	return suggested, nil
+}
+
+// relativeTo returns filename relative to dir, using
+// forward slashes as configurations do.
+func relativeTo(dir, filename string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	absFile, err := filepath.Abs(filename)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absDir, absFile)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
@@ -0,0 +1,1296 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v33/github"
+	"go.uber.org/multierr"
+)
+
+type InstallCmd struct {
+	// Modules are installed like a workspace when there are several.
+	Modules []string `arg:"" name:"location" help:"The NPM modules, GitHub, GitLab, or Bitbucket repositories, GitHub repository subdirectories (github.com/<org>/<repo>//<path>), git+<url>#<ref> remotes, or module archives (file:./module-1.2.3.tgz or https://host/path/module.tgz) to install, each optionally followed by @ and the release tag, version, or semver range (e.g. @apexlang/codegen@^0.1). The release of a single module may also be given as a second argument." optional:""`
+	// Location and Release are the module to install,
+	// from Modules or set by other commands.
+	Location    string `kong:"-"`
+	Release     string `kong:"-"`
+	Progress    string `help:"Emit machine-readable progress events on stderr (none or json)." enum:"none,json" default:"none"`
+	From        string `help:"Install all modules listed in a workspace file." type:"existingfile"`
+	Concurrency int    `help:"The number of modules to install concurrently with --from or several locations." default:"4"`
+	RateLimit   string `help:"Limit total download bandwidth with --from or several locations (e.g. 2MB per second)."`
+	Retries     int    `help:"The number of times to retry a failed module install with --from or several locations." default:"2"`
+	PolicyFile  string `help:"The install policy to enforce instead of ~/.apex/policy.yaml." type:"existingfile"`
+	Lockfile    string `help:"The lockfile recording installed modules." default:"apex.lock"`
+	NoLockfile  bool   `help:"Do not update the lockfile."`
+	Locked      bool   `help:"Install exactly the modules in the lockfile, verifying their integrity."`
+	NoVerify    bool   `help:"Do not verify the integrity of downloads, such as from local registries that repack tarballs."`
+	// HTTPRetries of zero uses the default and a negative number disables retries.
+	HTTPRetries int `help:"The number of times to retry a failed request or resume an interrupted download. Negative disables retries." default:"3"`
+	// HTTPTimeout of zero uses the default.
+	HTTPTimeout   time.Duration `help:"How long to wait for a response, or for more data during a download." default:"30s"`
+	SummaryFormat string        `name:"summary" help:"How to print a summary when finished: text, json, or none." enum:"text,json,none" default:"text"`
+	// Offline is also enabled by APEX_OFFLINE.
+	Offline bool `help:"Install only from previously downloaded archives and registry metadata, without network access."`
+	Project bool `help:"Install into the project's .apex directory instead of the user's home directory, so the project is self-contained. This is the default when .apexrc.yaml sets local."`
+	// Local is an alias of Project.
+	Local bool `help:"Alias for --project." hidden:""`
+	// Build selects how modules without a dist directory are built.
+	// The auto mode uses npm when it is installed.
+	Build     string `help:"How to build modules without a dist directory: auto, npm (npm install && npm run build), or esbuild (compile the TypeScript sources without Node.js)." enum:"auto,npm,esbuild" default:"auto"`
+	NoScripts bool   `help:"Never run package scripts. Modules without a dist directory are built with esbuild."`
+	Force     bool   `help:"Reinstall modules even when the requested release is already installed."`
+	// Overwrite and KeepExisting resolve conflicts with the definitions
+	// and templates of other modules, which otherwise fail the install.
+	Overwrite    bool `help:"Overwrite definitions and templates installed by other modules."`
+	KeepExisting bool `help:"Keep definitions and templates installed by other modules instead of installing the module's own."`
+	// Only selects parts of modules, or all of them when empty.
+	Only []string `help:"Only install these parts of modules: code, definitions, or templates. Without code, modules are not built." sep:","`
+	// Include, Omit, and ExcludePackages select the packages of a
+	// module's npm-shrinkwrap.json that are installed with it.
+	Include         []string `help:"Also install these kinds of packages from a module's npm-shrinkwrap.json: dev or optional." sep:","`
+	Omit            []string `help:"Do not install these kinds of packages from a module's npm-shrinkwrap.json: dev or optional. Optional packages are installed unless omitted." sep:","`
+	ExcludePackages []string `help:"Do not install packages from a module's npm-shrinkwrap.json with names matching these patterns (e.g. @types/*)." sep:","`
+	// ListVersions lists releases instead of installing,
+	// filtered by a semver range given as the release.
+	ListVersions bool `help:"List the available versions or release tags of the location instead of installing it, filtered by a semver range when given (e.g. @apexlang/codegen@^0.1)."`
+
+	netClient http.Client
+	progress  *progressReporter
+	homeDir   string
+	cacheDir  string
+	limiter   *rateLimiter
+	policy    *InstallPolicy
+	// lock, when set, records what is installed. locked is set
+	// when installing a module exactly as it was locked.
+	lock       *Lockfile
+	locked     *LockedModule
+	lockedName string
+	lockDeps   map[string]LockedModule
+	summary    *Summary
+	// modules are installed instead of locations given as
+	// arguments, such as by restore.
+	modules []WorkspaceModule
+	// onProgress, when set, receives progress events
+	// instead of them being printed.
+	onProgress func(ProgressEvent)
+}
+
+// Summary returns the outcome of the last run.
+func (c *InstallCmd) Summary() *Summary {
+	return c.summary
+}
+
+// Parts of a module that install --only selects.
+const (
+	PartCode        = "code"
+	PartDefinitions = "definitions"
+	PartTemplates   = "templates"
+)
+
+type releaseInfo struct {
+	Org        string
+	Module     string
+	Tag        string
+	Directory  string
+	ZipURL     string
+	TarballURL string
+	// Clone is the remote checked out for git locations.
+	Clone string
+	// Subdir is the directory of the module in a repository
+	// that holds several, such as packages/my-module.
+	Subdir string
+	// Integrity is the subresource integrity of
+	// the download, when the source provides it.
+	Integrity string
+	// Archive is a local or already downloaded archive
+	// installed instead of downloading TarballURL or ZipURL.
+	Archive string
+	// Requested is the tag or version asked for by the user
+	// and RequestedType records which of the two it was.
+	Requested     string
+	RequestedType string
+}
+
+func (c *InstallCmd) Run(ctx *Context) error {
+	if c.ListVersions {
+		return c.listVersions(ctx)
+	}
+
+	homeDir, err := getHomeDirectory()
+	if err != nil {
+		return err
+	}
+
+	c.summary = newSummary("install")
+	defer func() { c.summary.finish(c.SummaryFormat) }()
+
+	c.Project = c.Project || c.Local || projectLocal()
+	if c.Project {
+		if homeDir, err = ensureProjectHomeDirectory(); err != nil {
+			return err
+		}
+	}
+
+	if c.Locked && c.NoLockfile {
+		return errors.New("--locked requires a lockfile")
+	}
+	if err = c.checkShrinkwrapOptions(); err != nil {
+		return err
+	}
+	for _, part := range c.Only {
+		switch part {
+		case PartCode, PartDefinitions, PartTemplates:
+		default:
+			return fmt.Errorf("--only must be code, definitions, or templates, not %q", part)
+		}
+	}
+	if !c.NoLockfile {
+		if c.lock, err = readLockfile(c.Lockfile); err != nil {
+			return err
+		}
+	}
+	modules := c.modules
+	if len(c.Modules) > 0 {
+		if c.From != "" {
+			return errors.New("--from cannot be combined with locations")
+		}
+		modules = parseModuleArgs(c.Modules)
+	}
+	if len(modules) == 1 {
+		c.Location, c.Release = modules[0].Location, modules[0].Release
+		modules = nil
+	}
+	if c.Locked {
+		return c.installLocked(ctx, homeDir, modules)
+	}
+
+	if c.From != "" {
+		err = c.installWorkspace(ctx, homeDir)
+	} else if len(modules) > 0 {
+		err = c.installModules(ctx, homeDir, modules)
+	} else if c.Location == "" {
+		return errors.New(msg("install.location_required"))
+	} else {
+		err = c.doRun(ctx, homeDir)
+		c.summary.install(err)
+	}
+
+	// Record whatever was installed, even if some modules failed.
+	if c.lock != nil && len(c.lock.Modules) > 0 {
+		if lockErr := c.lock.write(c.Lockfile); lockErr != nil && err == nil {
+			err = lockErr
+		}
+	}
+	return err
+}
+
+// installLocked installs modules exactly as recorded in the lockfile:
+// the modules given, those in the workspace file, or all of them.
+func (c *InstallCmd) installLocked(ctx *Context, homeDir string, modules []WorkspaceModule) error {
+	var locations []string
+	switch {
+	case len(modules) > 0:
+		aliases := projectAliases()
+		for _, module := range modules {
+			locations = append(locations, resolveModuleAlias(module.Location, aliases))
+		}
+	case c.Location != "":
+		locations = []string{resolveModuleAlias(c.Location, projectAliases())}
+	case c.From != "":
+		workspace, err := readWorkspace(c.From)
+		if err != nil {
+			return err
+		}
+		for _, module := range workspace.Modules {
+			locations = append(locations, module.Location)
+		}
+	default:
+		for name := range c.lock.Modules {
+			locations = append(locations, name)
+		}
+		sort.Strings(locations)
+	}
+	if len(locations) == 0 {
+		return fmt.Errorf("%s does not list any modules", c.Lockfile)
+	}
+
+	for _, location := range locations {
+		name, locked, err := c.lock.locked(c.Lockfile, location)
+		if err != nil {
+			return err
+		}
+		install := InstallCmd{
+			Location:        locked.Location,
+			Progress:        c.Progress,
+			PolicyFile:      c.PolicyFile,
+			NoVerify:        c.NoVerify,
+			HTTPRetries:     c.HTTPRetries,
+			HTTPTimeout:     c.HTTPTimeout,
+			Offline:         c.Offline,
+			Project:         c.Project || locked.Project,
+			Build:           c.Build,
+			NoScripts:       c.NoScripts,
+			Force:           c.Force,
+			Only:            c.Only,
+			Overwrite:       c.Overwrite,
+			KeepExisting:    c.KeepExisting,
+			Include:         c.Include,
+			Omit:            c.Omit,
+			ExcludePackages: c.ExcludePackages,
+			locked:          &locked,
+			lockedName:      name,
+			summary:         c.summary,
+		}
+		// Modules locked in the project are installed there again.
+		installHome := homeDir
+		if install.Project && !c.Project {
+			if installHome, err = ensureProjectHomeDirectory(); err != nil {
+				return err
+			}
+		}
+		err = install.doRun(ctx, installHome)
+		c.summary.install(err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *InstallCmd) doRun(ctx *Context, homeDir string) error {
+	c.Location = resolveModuleAlias(c.Location, projectAliases())
+	if c.Release == "" && c.locked == nil {
+		c.Release = projectPin(c.Location)
+	}
+	if strings.Contains(c.Location, "..") {
+		return fmt.Errorf("invalid location %s", c.Location)
+	}
+
+	if err := c.createHTTPClient(); err != nil {
+		return err
+	}
+	c.homeDir = homeDir
+	if c.onProgress != nil {
+		c.progress = &progressReporter{fn: c.onProgress}
+	} else {
+		c.progress = newProgressReporter(c.Progress)
+	}
+
+	policy, err := loadInstallPolicy(c.PolicyFile)
+	if err != nil {
+		return err
+	}
+	c.policy = policy
+	if err = c.policy.checkSource(moduleSource(c.Location)); err != nil {
+		return err
+	}
+
+	fmt.Println(msg("install.getting_release", c.Location))
+	c.progress.phase(PhaseResolve, c.Location, "Getting release info")
+
+	var release *releaseInfo
+	if c.locked != nil && c.locked.Resolved != "" {
+		release = c.locked.release(c.lockedName)
+	} else {
+		// Local directories are installed as they are now.
+		if release, err = c.getReleaseInfo(c.Location, c.Release); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println(msg("install.installing", release.Org, release.Module, release.Tag))
+
+	if release.Directory != "" {
+		moduleSubDir := release.Module
+		if release.Org != "" {
+			moduleSubDir = filepath.Join(release.Org, release.Module)
+		}
+
+		if err = c.installDir(
+			release.Directory,
+			homeDir,
+			release.Org,
+			moduleSubDir,
+			release,
+		); err != nil {
+			return err
+		}
+		c.lockModule(release, "", "", "")
+		c.done(release)
+		return nil
+	}
+	if release.Clone != "" {
+		return c.installClone(homeDir, release)
+	}
+	if c.alreadyInstalled(homeDir, release) {
+		fmt.Println(msg("install.already_installed", c.Location, release.Tag))
+		c.done(release)
+		return nil
+	}
+
+	var downloadURL string
+	var fileType string
+	if release.TarballURL != "" {
+		downloadURL = release.TarballURL
+		fileType = "tar.gz"
+	} else if release.ZipURL != "" {
+		downloadURL = release.ZipURL
+		fileType = "zip"
+	} else {
+		return fmt.Errorf("release %s/%s %s does not contain a download URL",
+			release.Org, release.Module, release.Tag)
+	}
+
+	if err = c.policy.checkURL(downloadURL); err != nil {
+		return err
+	}
+	expected := release.Integrity
+	if c.locked != nil {
+		expected = c.locked.Integrity
+	}
+	expected = c.expectedIntegrity(expected)
+
+	archive := release.Archive
+	if archive == "" {
+		c.progress.phase(PhaseDownload, c.Location, downloadURL)
+		var cleanup func()
+		if archive, cleanup, err = c.fetchArchive(downloadURL, c.Location, expected); err != nil {
+			return err
+		}
+		defer cleanup()
+	}
+
+	integrity, err := verifyIntegrity(c.Location, archive, expected)
+	if err != nil {
+		return err
+	}
+	// Local archives, like directories, are not signed.
+	if !strings.HasPrefix(downloadURL, "file:") {
+		if err = c.verifySignature(downloadURL, archive); err != nil {
+			return err
+		}
+	}
+	storeContent(archive, integrity, expected)
+
+	downloadDir, err := os.MkdirTemp(homeDir, "dl-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(downloadDir)
+
+	c.progress.phase(PhaseExtract, c.Location, fileType)
+	if release.Subdir != "" {
+		// Extracted trees are shared whole, so a
+		// subdirectory is extracted on its own.
+		if err = c.extractArchive(archive, fileType, downloadDir, release.Subdir); err != nil {
+			return err
+		}
+		if entries, _ := os.ReadDir(downloadDir); len(entries) == 0 {
+			return fmt.Errorf("%s %s does not contain %s", c.Location, release.Tag, release.Subdir)
+		}
+	} else if err = c.extractCached(archive, fileType, integrity, downloadDir); err != nil {
+		return err
+	}
+	if release.Archive != "" {
+		if err = nestPackage(downloadDir); err != nil {
+			return err
+		}
+	}
+
+	if err = c.installContents(downloadDir, homeDir, release); err != nil {
+		return err
+	}
+
+	c.lockModule(release, downloadURL, fileType, integrity)
+	c.done(release)
+	return nil
+}
+
+// installContents builds and installs each module directory
+// extracted or checked out into downloadDir.
+func (c *InstallCmd) installContents(downloadDir, homeDir string, release *releaseInfo) error {
+	dirEntries, err := os.ReadDir(downloadDir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range dirEntries {
+		if entry.IsDir() {
+			contentsDir := filepath.Join(downloadDir, entry.Name())
+
+			// If the dist directory does not exist, build it
+			// unless the code is not being installed.
+			distDir := filepath.Join(contentsDir, "dist")
+			_, err := os.Stat(distDir)
+			if err != nil && os.IsNotExist(err) && c.includes(PartCode) {
+				if err = c.buildContents(contentsDir); err != nil {
+					return err
+				}
+			}
+
+			if err = readPackage(contentsDir, release); err != nil {
+				return err
+			}
+			moduleSubDir := release.Module
+			if release.Org != "" {
+				moduleSubDir = filepath.Join(release.Org, release.Module)
+			}
+
+			if err = c.installDir(
+				contentsDir,
+				homeDir,
+				release.Org,
+				moduleSubDir,
+				release,
+			); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// installClone installs a module from a checkout of its git remote.
+func (c *InstallCmd) installClone(homeDir string, release *releaseInfo) error {
+	if err := c.policy.checkURL(release.Clone); err != nil {
+		return err
+	}
+	if err := c.policy.checkUnsigned(moduleSource(c.Location), "git checkouts cannot be verified"); err != nil {
+		return err
+	}
+	if c.offline() {
+		return fmt.Errorf("cloning %s is %w", release.Clone, errOffline)
+	}
+
+	downloadDir, err := os.MkdirTemp(homeDir, "dl-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(downloadDir)
+
+	c.progress.phase(PhaseDownload, c.Location, release.Clone)
+	// The checkout is a directory of its own so it is
+	// installed like an extracted archive.
+	if err = checkoutGit(release.Clone, release.Tag, filepath.Join(downloadDir, release.Module)); err != nil {
+		return err
+	}
+	if err = c.installContents(downloadDir, homeDir, release); err != nil {
+		return err
+	}
+
+	c.lockModule(release, release.Clone, "git", "")
+	c.done(release)
+	return nil
+}
+
+// expectedIntegrity returns the integrity a download
+// must match, or nothing when verification is off.
+func (c *InstallCmd) expectedIntegrity(integrity string) string {
+	if c.NoVerify {
+		return ""
+	}
+	return integrity
+}
+
+// lockModule records an installed module in the lockfile.
+func (c *InstallCmd) lockModule(release *releaseInfo, resolved, archive, integrity string) {
+	if c.lock == nil {
+		return
+	}
+	name := release.Module
+	if release.Org != "" {
+		name = release.Org + "/" + release.Module
+	}
+	c.lock.set(name, LockedModule{
+		Location:      c.Location,
+		Requested:     release.Requested,
+		RequestedType: release.RequestedType,
+		Version:       release.Tag,
+		Resolved:      resolved,
+		Archive:       archive,
+		Integrity:     integrity,
+		Dependencies:  c.lockDeps,
+		Project:       c.Project,
+	})
+}
+
+// alreadyInstalled reports whether the release was already installed
+// from the same location, and is recorded in the lockfile if one is
+// kept, so that installing it again would not change anything.
+func (c *InstallCmd) alreadyInstalled(homeDir string, release *releaseInfo) bool {
+	if c.Force {
+		return false
+	}
+	moduleSubDir := release.Module
+	if release.Org != "" {
+		moduleSubDir = filepath.Join(release.Org, release.Module)
+	}
+	manifest, err := readInstallManifest(filepath.Join(homeDir, "node_modules", moduleSubDir))
+	if err != nil || manifest.Location != c.Location || manifest.Tag != release.Tag || !manifest.hasParts(c.Only) {
+		return false
+	}
+	if c.lock != nil {
+		if _, locked, ok := c.lock.find(c.Location); !ok || locked.Version != release.Tag {
+			return false
+		}
+	}
+	return true
+}
+
+// includes reports whether install --only selects the part.
+func (c *InstallCmd) includes(part string) bool {
+	return len(c.Only) == 0 || containsString(c.Only, part)
+}
+
+// done reports that the module is installed
+// and records it for the audit log.
+func (c *InstallCmd) done(release *releaseInfo) {
+	c.progress.phase(PhaseDone, c.Location, release.Tag)
+	recordAuditModule(c.Location, release.Tag)
+}
+
+// repositoryHosts are the prefixes of locations that install
+// from a git repository, such as github.com/<org>/<repo>.
+var repositoryHosts = []string{"github.com/", "gitlab.com/", "bitbucket.org/"}
+
+// parseModuleArgs returns the modules given on the command line, where
+// each location may end with @ and its release. For compatibility, a
+// single location may instead be followed by its release, which is
+// assumed when the second argument cannot be a location with a scope,
+// path, or release, such as "^1.2" or "main".
+func parseModuleArgs(args []string) []WorkspaceModule {
+	if len(args) == 2 && !strings.ContainsAny(args[1], "@/:") {
+		if _, release := splitModuleRelease(args[0]); release == "" {
+			return []WorkspaceModule{{Location: args[0], Release: args[1]}}
+		}
+	}
+	modules := make([]WorkspaceModule, len(args))
+	for i, arg := range args {
+		modules[i].Location, modules[i].Release = splitModuleRelease(arg)
+	}
+	return modules
+}
+
+// splitModuleRelease splits location@release, leaving the @ of
+// scoped NPM packages and of users in git remotes in the location.
+func splitModuleRelease(arg string) (string, string) {
+	i := strings.LastIndexByte(arg, '@')
+	if i <= 0 || strings.ContainsAny(arg[i+1:], "/:") {
+		return arg, ""
+	}
+	return arg[:i], arg[i+1:]
+}
+
+// splitSubdirectory splits repository//path, the location of a module
+// in a subdirectory of a repository, such as
+// github.com/org/monorepo//packages/my-module.
+func splitSubdirectory(location string) (string, string, error) {
+	repository, subdir, found := strings.Cut(location, "//")
+	if !found {
+		return location, "", nil
+	}
+	subdir = path.Clean(strings.Trim(subdir, "/"))
+	if subdir == "." || strings.HasPrefix(subdir, "../") || subdir == ".." {
+		return "", "", fmt.Errorf("invalid subdirectory in %s", location)
+	}
+	return repository, subdir, nil
+}
+
+// subdirectoryEntry returns where an archive entry is extracted when
+// only subdir, under the top-level directory of a repository archive,
+// is wanted, or false if the entry is outside of it.
+func subdirectoryEntry(name, subdir string) (string, bool) {
+	if subdir == "" {
+		return name, true
+	}
+	top, rest, found := strings.Cut(strings.TrimPrefix(name, "./"), "/")
+	if !found || !strings.HasPrefix(rest, subdir+"/") {
+		return "", false
+	}
+	return top + "/" + rest[len(subdir)+1:], true
+}
+
+func isRepositoryLocation(location string) bool {
+	for _, host := range repositoryHosts {
+		if strings.HasPrefix(location, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *InstallCmd) getReleaseInfo(location, releaseTag string) (*releaseInfo, error) {
+	if isArchiveLocation(location) {
+		return c.getReleaseInfoFromArchive(location, releaseTag)
+	}
+	if strings.HasPrefix(location, "file:") {
+		return c.getReleaseInfoFromDirectory(location[5:], releaseTag)
+	}
+	if strings.HasPrefix(location, gitPrefix) || isRepositoryLocation(location) {
+		if err := c.checkOnline(location); err != nil {
+			return nil, err
+		}
+	}
+	if strings.HasPrefix(location, gitPrefix) {
+		return c.getReleaseInfoFromGit(location, releaseTag)
+	}
+	if strings.HasPrefix(location, "github.com/") {
+		repository, subdir, err := splitSubdirectory(location)
+		if err != nil {
+			return nil, err
+		}
+		release, err := c.getReleaseInfoFromGithub(repository[11:], releaseTag)
+		if err != nil {
+			return nil, githubError(err)
+		}
+		release.Subdir = subdir
+		return release, nil
+	}
+	if strings.HasPrefix(location, "gitlab.com/") {
+		return c.getReleaseInfoFromGitlab(location[11:], releaseTag)
+	}
+	if strings.HasPrefix(location, "bitbucket.org/") {
+		return c.getReleaseInfoFromBitbucket(location[14:], releaseTag)
+	}
+
+	return c.getReleaseInfoFromNPM(location, releaseTag)
+}
+
+func (c *InstallCmd) getReleaseInfoFromDirectory(location, releaseTag string) (*releaseInfo, error) {
+	dir := filepath.Clean(location)
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	release := releaseInfo{
+		Directory: dir,
+	}
+	if err = readPackage(dir, &release); err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+func (c *InstallCmd) getReleaseInfoFromNPM(location, releaseTag string) (*releaseInfo, error) {
+	p, err := c.packument(location)
+	if err != nil {
+		return nil, err
+	}
+	var client *http.Client
+	if !c.offline() {
+		client = &c.netClient
+	}
+	v, requested, err := p.resolve(client, releaseTag)
+	if err != nil {
+		return nil, err
+	}
+	if requested == RequestedTag && releaseTag != "" {
+		fmt.Println(msg("install.resolved_tag", location, releaseTag, v.Version))
+	}
+
+	var org string
+	module := v.Name
+	if strings.Contains(module, "..") {
+		return nil, fmt.Errorf("invalid module name %s", module)
+	}
+
+	parts := strings.Split(v.Name, "/")
+	if len(parts) == 2 {
+		org = parts[0]
+		module = parts[1]
+	}
+
+	if releaseTag == "" {
+		releaseTag = "latest"
+	}
+
+	return &releaseInfo{
+		Org:           org,
+		Module:        module,
+		Tag:           v.Version,
+		TarballURL:    v.Dist.Tarball,
+		Integrity:     v.integrity(),
+		Requested:     releaseTag,
+		RequestedType: requested,
+	}, nil
+}
+
+func (c *InstallCmd) getReleaseInfoFromGithub(location, releaseTag string) (*releaseInfo, error) {
+	repoParts := strings.Split(location, "/")
+	if len(repoParts) != 2 {
+		return nil, fmt.Errorf("invalid repo syntax: %q", location)
+	}
+
+	org := repoParts[0]
+	repo := repoParts[1]
+
+	ct := context.Background()
+	client, err := newGitHubClient()
+	if err != nil {
+		return nil, err
+	}
+	var release *github.RepositoryRelease
+
+	if isSemverRange(releaseTag) {
+		var err error
+		if release, err = githubReleaseInRange(ct, client, org, repo, releaseTag); err != nil {
+			return nil, err
+		}
+	} else if releaseTag == "" || releaseTag == "latest" {
+		releases, _, err := client.Repositories.ListReleases(ct, org, repo, &github.ListOptions{
+			PerPage: 1,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(releases) == 0 {
+			return nil, fmt.Errorf("there are no releases for %s/%s", org, repo)
+		}
+
+		release = releases[0]
+	} else {
+		var err error
+		release, _, err = client.Repositories.GetReleaseByTag(ct, org, repo, c.Release)
+		if err != nil {
+			if ghe, ok := err.(*github.ErrorResponse); ok && ghe.Response.StatusCode == 404 {
+				branch, _, err := client.Repositories.GetBranch(ct, org, repo, c.Release)
+				if err != nil {
+					return nil, err
+				}
+
+				// Return download URL for a branch
+				return &releaseInfo{
+					Org:    org,
+					Module: repo,
+					Tag:    c.Release,
+					ZipURL: fmt.Sprintf("https://github.com/%s/%s/archive/refs/heads/%s.zip", org, repo, *branch.Name),
+				}, nil
+			}
+			return nil, err
+		}
+	}
+
+	if release.TagName == nil {
+		return nil, fmt.Errorf("release tag is missing for %s/%s", org, repo)
+	}
+
+	info := releaseInfo{
+		Org:    org,
+		Module: repo,
+		Tag:    *release.TagName,
+	}
+	if isSemverRange(releaseTag) {
+		info.Requested = releaseTag
+		info.RequestedType = RequestedRange
+	}
+
+	if release.ZipballURL != nil {
+		info.ZipURL = *release.ZipballURL
+	}
+	// Modules packed with npm pack and attached to the release are
+	// installed as published, without building their sources.
+	if asset := packedReleaseAsset(release, repo); asset != "" {
+		info.TarballURL = asset
+	} else if release.TarballURL != nil {
+		info.TarballURL = *release.TarballURL
+	}
+
+	return &info, nil
+}
+
+// githubReleaseInRange returns the release whose tag is the highest
+// version matching a semver range. Tags may have a "v" prefix.
+func githubReleaseInRange(ct context.Context, client *github.Client, org, repo, rangeSpec string) (*github.RepositoryRelease, error) {
+	byTag := make(map[string]*github.RepositoryRelease)
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		releases, resp, err := client.Repositories.ListReleases(ct, org, repo, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, release := range releases {
+			if release.TagName != nil && !release.GetDraft() {
+				byTag[*release.TagName] = release
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	tags := make([]string, 0, len(byTag))
+	for tag := range byTag {
+		tags = append(tags, tag)
+	}
+	best, found, err := maxSatisfying(tags, rangeSpec)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%s/%s has no release matching %q", org, repo, rangeSpec)
+	}
+	return byTag[best], nil
+}
+
+func (c *InstallCmd) installDir(src string, dest string, org, modulePart string, release *releaseInfo) error {
+	dirEntries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	info := c.installInfo(release, dest, src)
+	if err = runPreInstallHooks(info); err != nil {
+		return err
+	}
+
+	// Conflicts are found before the module's own manifest is removed.
+	skip, err := c.resolveConflicts(src, dest, org, filepath.ToSlash(modulePart))
+	if err != nil {
+		return err
+	}
+
+	moduleRoot := filepath.Join(dest, "node_modules", modulePart)
+	c.progress.phase(PhaseCopy, c.Location, moduleRoot)
+	if err = os.RemoveAll(moduleRoot); err != nil {
+		return err
+	}
+	if err = os.MkdirAll(moduleRoot, 0755); err != nil {
+		return err
+	}
+
+	// Record what is installed outside the module root so
+	// that uninstall can remove it.
+	manifest := installManifest{
+		Location:      c.Location,
+		Tag:           release.Tag,
+		Version:       readPackageVersion(src),
+		Requested:     release.Requested,
+		RequestedType: release.RequestedType,
+		InstalledAt:   time.Now().UTC(),
+		Parts:         c.Only,
+		Paths:         []string{filepath.ToSlash(filepath.Join("node_modules", modulePart))},
+	}
+	if manifest.Requested == "" {
+		manifest.Requested = c.Release
+	}
+
+	// Only the files the module publishes are installed, as when
+	// installed from the NPM registry.
+	files, err := loadPackageFiles(src)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range dirEntries {
+		base := filepath.Base(entry.Name())
+		destDir := filepath.Join(moduleRoot, base)
+
+		if isExcludedModuleEntry(entry.Name()) {
+			continue
+		}
+		if entry.IsDir() && files.skipDir(entry.Name()) {
+			continue
+		}
+		switch entry.Name() {
+		case "definitions", "templates":
+			if !c.includes(entry.Name()) {
+				continue
+			}
+			destDir = filepath.Join(dest, base, org)
+			children, err := os.ReadDir(filepath.Join(src, entry.Name()))
+			if err != nil {
+				return err
+			}
+			if err = os.MkdirAll(destDir, 0755); err != nil {
+				return err
+			}
+			// Each definition or template is copied on its own so
+			// that those kept from other modules are skipped.
+			for _, child := range children {
+				rel := filepath.ToSlash(filepath.Join(base, org, child.Name()))
+				if _, ok := skip[rel]; ok {
+					continue
+				}
+				childDest := filepath.Join(destDir, child.Name())
+				if err = c.copyPackageFiles(
+					files,
+					src,
+					filepath.Join(entry.Name(), child.Name()),
+					childDest,
+				); err != nil {
+					return err
+				}
+				// Definitions and templates that are not published
+				// are not copied at all.
+				if _, err = os.Lstat(childDest); err == nil {
+					manifest.Paths = append(manifest.Paths, rel)
+				}
+			}
+			continue
+		default:
+			// package.json identifies the module even without its code.
+			if !c.includes(PartCode) && entry.Name() != "package.json" {
+				continue
+			}
+		}
+		if err = c.copyPackageFiles(
+			files,
+			src,
+			entry.Name(),
+			destDir,
+		); err != nil {
+			return err
+		}
+	}
+
+	if err = manifest.write(moduleRoot); err != nil {
+		return err
+	}
+	if err = c.handleShrinkwrap(dest, moduleRoot); err != nil {
+		return err
+	}
+
+	info.Paths = manifest.Paths
+	return runPostInstallHooks(info)
+}
+
+func (c *InstallCmd) handleShrinkwrap(dest, moduleRoot string) error {
+	// Check for npm-shrinkwrap.json which contains transitive dependency info.
+	shrinkwrapFile := filepath.Join(moduleRoot, "npm-shrinkwrap.json")
+	fi, err := os.Stat(shrinkwrapFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if fi.IsDir() {
+		return nil
+	}
+
+	shrinkwrapBytes, err := os.ReadFile(shrinkwrapFile)
+	if err != nil {
+		return fmt.Errorf("could not read npm-shrinkwrap.json: %w", err)
+	}
+	var sw Shrinkwrap
+	if err = json.Unmarshal(shrinkwrapBytes, &sw); err != nil {
+		return fmt.Errorf("could not parse npm-shrinkwrap.json: %w", err)
+	}
+
+	// Sources and locks are checked before anything is downloaded.
+	var jobs []shrinkwrapJob
+	downloads := map[string]*sync.Mutex{}
+	for moduleName, pkg := range sw.Packages {
+		if !strings.HasPrefix(moduleName, "node_modules") {
+			continue
+		}
+		name := moduleName[strings.LastIndex(moduleName, "node_modules/")+len("node_modules/"):]
+		if !c.includesPackage(name, pkg) {
+			continue
+		}
+		if _, err := url.ParseRequestURI(pkg.Resolved); err != nil {
+			fmt.Println(msg("install.invalid_url", pkg.Resolved))
+			continue
+		}
+		if err = c.policy.checkSource(moduleSource(name)); err != nil {
+			return err
+		}
+		if err = c.policy.checkURL(pkg.Resolved); err != nil {
+			return err
+		}
+		expected := pkg.Integrity
+		if c.locked != nil {
+			if expected, err = c.locked.dependency(moduleName, pkg.Resolved); err != nil {
+				return err
+			}
+		}
+		jobs = append(jobs, shrinkwrapJob{
+			moduleName: moduleName,
+			name:       name,
+			pkg:        pkg,
+			expected:   c.expectedIntegrity(expected),
+		})
+		if _, ok := downloads[pkg.Resolved]; !ok {
+			downloads[pkg.Resolved] = &sync.Mutex{}
+		}
+	}
+	if len(jobs) > 0 && c.lockDeps == nil {
+		c.lockDeps = map[string]LockedModule{}
+	}
+
+	var (
+		mu   sync.Mutex
+		merr error
+		wg   sync.WaitGroup
+	)
+	sem := make(chan struct{}, shrinkwrapConcurrency)
+	for _, job := range jobs {
+		wg.Add(1)
+		go func(job shrinkwrapJob) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			// Packages resolved to the same URL share a cached download.
+			download := downloads[job.pkg.Resolved]
+			download.Lock()
+			integrity, err := c.installShrinkwrapPackage(dest, moduleRoot, job)
+			download.Unlock()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				merr = multierr.Append(merr, fmt.Errorf("%s: %w", job.moduleName, err))
+				return
+			}
+			c.lockDeps[job.moduleName] = LockedModule{
+				Location:  job.name,
+				Version:   job.pkg.Version,
+				Resolved:  job.pkg.Resolved,
+				Archive:   "tar.gz",
+				Integrity: integrity,
+			}
+		}(job)
+	}
+	wg.Wait()
+
+	return merr
+}
+
+// shrinkwrapConcurrency bounds the transitive
+// dependencies downloaded at the same time.
+const shrinkwrapConcurrency = 8
+
+// shrinkwrapJob is a transitive dependency to install, where moduleName
+// is its path in npm-shrinkwrap.json, such as node_modules/a/node_modules/b.
+type shrinkwrapJob struct {
+	moduleName string
+	name       string
+	pkg        Package
+	expected   string
+}
+
+// installShrinkwrapPackage downloads a transitive dependency and extracts
+// it under moduleRoot, returning the integrity of the download.
+func (c *InstallCmd) installShrinkwrapPackage(dest, moduleRoot string, job shrinkwrapJob) (string, error) {
+	// Create a temp directory for the download.
+	downloadDir, err := os.MkdirTemp(dest, "dl-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(downloadDir)
+
+	c.progress.phase(PhaseDownload, job.moduleName, job.pkg.Resolved)
+	archive, cleanup, err := c.fetchArchive(job.pkg.Resolved, job.moduleName, job.expected)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	integrity, err := verifyIntegrity(job.moduleName, archive, job.expected)
+	if err != nil {
+		return "", err
+	}
+	storeContent(archive, integrity, job.expected)
+
+	packageDest := filepath.Join(moduleRoot, job.moduleName)
+	if err = os.MkdirAll(packageDest, 0755); err != nil {
+		return "", err
+	}
+	if err = c.extractCached(archive, "tar.gz", integrity, downloadDir); err != nil {
+		return "", err
+	}
+
+	if err = c.copyRecursive(
+		filepath.Join(downloadDir, "package"),
+		packageDest,
+	); err != nil {
+		return "", err
+	}
+	return integrity, nil
+}
+
+func (c *InstallCmd) copyRecursive(source, destination string) error {
+	return filepath.Walk(source, func(path string, info os.FileInfo, ferr error) error {
+		relPath := strings.Replace(path, source, "", 1)
+		sourcePath := filepath.Join(source, relPath)
+		stat, err := os.Stat(sourcePath)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(destination, relPath), stat.Mode())
+		} else {
+			data, err := os.ReadFile(sourcePath)
+			if err != nil {
+				return err
+			}
+
+			return os.WriteFile(filepath.Join(destination, relPath), data, stat.Mode())
+		}
+	})
+}
+
+// buildContents builds a module that does not contain a prebuilt dist
+// directory with npm, or by compiling its TypeScript sources with esbuild
+// when npm is not installed or package scripts must not run.
+func (c *InstallCmd) buildContents(dir string) error {
+	entrypoint, hasTS := findTypeScriptEntrypoint(dir)
+	mode := c.Build
+	if mode == "" || mode == "auto" {
+		mode = "npm"
+		if hasTS && c.NoScripts {
+			mode = "esbuild"
+		} else if _, err := exec.LookPath("npm"); err != nil && hasTS {
+			fmt.Println(msg("install.npm_not_found"))
+			mode = "esbuild"
+		}
+	}
+
+	switch mode {
+	case "esbuild":
+		if !hasTS {
+			return fmt.Errorf("%s does not contain a dist directory or TypeScript sources to build with esbuild", c.Location)
+		}
+		c.progress.phase(PhaseBuild, c.Location, "esbuild")
+		return buildTypeScript(dir, entrypoint)
+	case "npm":
+		if c.NoScripts {
+			return fmt.Errorf("%s must be built with npm, which runs package scripts, but --no-scripts is set", c.Location)
+		}
+		c.progress.phase(PhaseBuild, c.Location, "npm run build")
+		return buildModule(dir, c.offline())
+	}
+	return fmt.Errorf("unknown build mode %q", mode)
+}
+
+// buildModule runs the NPM build for a module that
+// does not contain a prebuilt dist directory.
+func buildModule(dir string, offline bool) error {
+	commands := [][]string{
+		{"npm", "install"},
+		{"npm", "run", "build"},
+	}
+	if offline {
+		// npm installs from its own cache.
+		commands[0] = append(commands[0], "--offline")
+	}
+
+	for _, cmd := range commands {
+		cmd := exec.Command(cmd[0], cmd[1:]...)
+		cmd.Dir = dir
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isExcludedModuleEntry returns true for top-level module
+// entries that are never installed.
+func isExcludedModuleEntry(name string) bool {
+	switch name {
+	case ".git", ".github", ".gitignore", ".npmignore", "node_modules", ".DS_Store":
+		return true
+	}
+	return false
+}
+
+func (c *InstallCmd) createHTTPClient() error {
+	netTransport, err := newHTTPTransport()
+	if err != nil {
+		return err
+	}
+	// Each request times out waiting for a response rather than the
+	// client timing out whole downloads, which stop when they stall.
+	netTransport.DialContext = (&net.Dialer{
+		Timeout: 5 * time.Second,
+	}).DialContext
+	netTransport.TLSHandshakeTimeout = 5 * time.Second
+	netTransport.ResponseHeaderTimeout = c.httpTimeout()
+	c.netClient = http.Client{
+		Transport: newBitbucketTransport(newGitLabTransport(newGitHubTransport(
+			&retryTransport{retries: c.httpRetries(), base: netTransport}))),
+		// Hosts redirected to are checked against the install
+		// policy, as are those of the URLs requested.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return c.policy.checkURL(req.URL.String())
+		},
+	}
+	return nil
+}
+
+func readPackage(dir string, release *releaseInfo) error {
+	packageJSONPath := filepath.Join(dir, "package.json")
+	packageJSONBytes, err := os.ReadFile(packageJSONPath)
+	if err != nil {
+		return err
+	}
+
+	type packageJSON struct {
+		Name string `json:"name"`
+	}
+
+	var contents packageJSON
+	if err = json.Unmarshal(packageJSONBytes, &contents); err != nil {
+		return err
+	}
+
+	if contents.Name == "" {
+		return nil
+	}
+
+	parts := strings.Split(contents.Name, "/")
+	if len(parts) > 2 {
+		return nil
+	}
+
+	release.Org = parts[0]
+	if len(parts) == 2 {
+		release.Module = parts[1]
+	} else {
+		release.Module = ""
+	}
+
+	return nil
+}
@@ -0,0 +1,207 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// archiveType returns the type of archive a location or URL names by
+// its extension, tar.gz or zip, or an empty string if it is not one.
+func archiveType(location string) string {
+	location = strings.ToLower(location)
+	if i := strings.IndexAny(location, "?#"); i != -1 {
+		location = location[:i]
+	}
+	switch {
+	case strings.HasSuffix(location, ".tgz"), strings.HasSuffix(location, ".tar.gz"):
+		return "tar.gz"
+	case strings.HasSuffix(location, ".zip"):
+		return "zip"
+	}
+	return ""
+}
+
+// isArchiveLocation reports whether a location is a module archive,
+// such as file:./module-1.2.3.tgz or https://host/path/module.tgz.
+func isArchiveLocation(location string) bool {
+	if archiveType(location) == "" {
+		return false
+	}
+	return strings.HasPrefix(location, "file:") ||
+		strings.HasPrefix(location, "https://") ||
+		strings.HasPrefix(location, "http://")
+}
+
+// getReleaseInfoFromArchive reads the name and version of a module from
+// the package.json in its archive, which is downloaded first when it is
+// remote, instead of resolving them from a registry.
+func (c *InstallCmd) getReleaseInfoFromArchive(location, releaseTag string) (*releaseInfo, error) {
+	if releaseTag != "" {
+		return nil, fmt.Errorf("the release of %s is read from its package.json and cannot be given", location)
+	}
+	fileType := archiveType(location)
+	release := releaseInfo{}
+	if strings.HasPrefix(location, "file:") {
+		archive, err := filepath.Abs(filepath.Clean(location[5:]))
+		if err != nil {
+			return nil, err
+		}
+		release.Archive = archive
+	} else {
+		if err := c.policy.checkURL(location); err != nil {
+			return nil, err
+		}
+		// Downloads are cached so the archive is not
+		// downloaded again when it is installed.
+		if c.cacheDir == "" {
+			c.cacheDir = downloadCacheDir(c.homeDir)
+		}
+		c.progress.phase(PhaseDownload, location, location)
+		archive, _, err := c.download(location, location)
+		if err != nil {
+			return nil, err
+		}
+		release.Archive = archive
+	}
+	if fileType == "zip" {
+		release.ZipURL = location
+	} else {
+		release.TarballURL = location
+	}
+
+	name, version, err := archivePackage(release.Archive, fileType)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the package.json of %s: %w", location, err)
+	}
+	if name == "" || version == "" {
+		return nil, fmt.Errorf("the package.json of %s must have a name and version", location)
+	}
+	if strings.Contains(name, "..") {
+		return nil, fmt.Errorf("invalid module name %s", name)
+	}
+	release.Module = name
+	if org, module, found := strings.Cut(name, "/"); found {
+		release.Org, release.Module = org, module
+	}
+	release.Tag = version
+	return &release, nil
+}
+
+// archivePackage returns the name and version in the package.json of an
+// archive, found at its root or in its top-level directory, such as the
+// package directory of tarballs created by npm pack.
+func archivePackage(archive, fileType string) (string, string, error) {
+	isPackage := func(name string) bool {
+		name = path.Clean(strings.TrimPrefix(name, "./"))
+		return name == "package.json" || path.Base(name) == "package.json" && !strings.Contains(path.Dir(name), "/")
+	}
+
+	var r io.Reader
+	switch fileType {
+	case "zip":
+		zr, err := zip.OpenReader(archive)
+		if err != nil {
+			return "", "", err
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			if isPackage(f.Name) {
+				rc, err := f.Open()
+				if err != nil {
+					return "", "", err
+				}
+				defer rc.Close()
+				r = rc
+				break
+			}
+		}
+	default:
+		f, err := os.Open(archive)
+		if err != nil {
+			return "", "", err
+		}
+		defer f.Close()
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return "", "", err
+		}
+		tr := tar.NewReader(gz)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", "", err
+			}
+			if header.Typeflag == tar.TypeReg && isPackage(header.Name) {
+				r = tr
+				break
+			}
+		}
+	}
+	if r == nil {
+		return "", "", errors.New("package.json not found")
+	}
+
+	data, err := limitReader("package.json", r, MaxConfigSize)
+	if err != nil {
+		return "", "", err
+	}
+	var pkg struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	if err = json.Unmarshal(data, &pkg); err != nil {
+		return "", "", err
+	}
+	return pkg.Name, pkg.Version, nil
+}
+
+// nestPackage moves the contents of an archive extracted into dir into
+// a package directory when its package.json is at the root, so it is
+// installed like archives with a top-level directory.
+func nestPackage(dir string) error {
+	if _, err := os.Stat(filepath.Join(dir, "package.json")); err != nil {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	packageDir, err := os.MkdirTemp(dir, "package-*")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err = os.Rename(filepath.Join(dir, entry.Name()), filepath.Join(packageDir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// InstallInfo describes a module being installed and is passed to
+// install hooks.
+type InstallInfo struct {
+	// Location is what was asked to be installed, after resolving aliases.
+	Location string
+	Org      string
+	Module   string
+	// Tag is the release being installed, which for NPM is its version.
+	Tag           string
+	Requested     string
+	RequestedType string
+	// HomeDir is the directory modules are installed into.
+	HomeDir string
+	// SourceDir contains the module's files before they are copied.
+	// It is removed once the install completes.
+	SourceDir string
+	// Paths are the installed files and directories, relative to HomeDir.
+	// They are only set for post-install hooks.
+	Paths []string
+}
+
+// InstallHook is called around installing a module.
+// Returning an error stops the install.
+type InstallHook func(info InstallInfo) error
+
+var (
+	preInstallHooks  []InstallHook
+	postInstallHooks []InstallHook
+)
+
+// RegisterInstallHook registers hooks called before a module's files
+// are copied into the home directory and after they are installed.
+// Either may be nil. Hooks should be registered before running commands.
+//
+// A pre-install hook returning an error stops the install before anything
+// is changed. A post-install hook returning an error removes the installed
+// module and fails the install.
+func RegisterInstallHook(pre, post InstallHook) {
+	if pre != nil {
+		preInstallHooks = append(preInstallHooks, pre)
+	}
+	if post != nil {
+		postInstallHooks = append(postInstallHooks, post)
+	}
+}
+
+func (c *InstallCmd) installInfo(release *releaseInfo, homeDir, sourceDir string) InstallInfo {
+	return InstallInfo{
+		Location:      c.Location,
+		Org:           release.Org,
+		Module:        release.Module,
+		Tag:           release.Tag,
+		Requested:     release.Requested,
+		RequestedType: release.RequestedType,
+		HomeDir:       homeDir,
+		SourceDir:     sourceDir,
+	}
+}
+
+func runPreInstallHooks(info InstallInfo) error {
+	for _, hook := range preInstallHooks {
+		if err := hook(info); err != nil {
+			return fmt.Errorf("install of %s was rejected: %w", info.Location, err)
+		}
+	}
+	return nil
+}
+
+// runPostInstallHooks calls the post-install hooks, removing
+// the installed paths if one of them fails.
+func runPostInstallHooks(info InstallInfo) error {
+	for _, hook := range postInstallHooks {
+		if err := hook(info); err != nil {
+			paths := make([]string, len(info.Paths))
+			for i, path := range info.Paths {
+				paths[i] = filepath.FromSlash(path)
+			}
+			if rmErr := removeAtomically(info.HomeDir, paths); rmErr != nil {
+				return fmt.Errorf("install of %s was rejected: %w (and could not be removed: %v)", info.Location, err, rmErr)
+			}
+			return fmt.Errorf("install of %s was rejected: %w", info.Location, err)
+		}
+	}
+	return nil
+}
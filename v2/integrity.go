@@ -0,0 +1,162 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// integrityAlgorithms are the subresource integrity hash algorithms
+// that are verified, strongest first. NPM records sha512 for newer
+// packages and sha1 for older ones.
+var integrityAlgorithms = []string{"sha512", "sha1"}
+
+// fileIntegrity returns the sha512 subresource integrity hash of a
+// file, which matches the integrity NPM records for tarballs.
+func fileIntegrity(filename string) (string, error) {
+	digests, err := fileDigests(filename)
+	if err != nil {
+		return "", err
+	}
+	return digests["sha512"], nil
+}
+
+// fileDigests returns the subresource integrity hash of a
+// file for each of the integrity algorithms.
+func fileDigests(filename string) (map[string]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h512 := sha512.New()
+	h1 := sha1.New()
+	if _, err = io.Copy(io.MultiWriter(h512, h1), f); err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"sha512": "sha512-" + base64.StdEncoding.EncodeToString(h512.Sum(nil)),
+		"sha1":   "sha1-" + base64.StdEncoding.EncodeToString(h1.Sum(nil)),
+	}, nil
+}
+
+// shasumIntegrity converts the hex sha1 shasum in older NPM
+// metadata into a subresource integrity hash.
+func shasumIntegrity(shasum string) string {
+	sum, err := hex.DecodeString(shasum)
+	if err != nil || len(sum) != sha1.Size {
+		return ""
+	}
+	return "sha1-" + base64.StdEncoding.EncodeToString(sum)
+}
+
+// verifyIntegrity checks a download against an expected subresource
+// integrity and returns the download's sha512 integrity. As with
+// browsers, when expected lists several hashes only those using the
+// strongest algorithm are checked and any one of them may match.
+// Nothing is checked when expected has no supported hashes.
+func verifyIntegrity(name, filename, expected string) (string, error) {
+	digests, err := fileDigests(filename)
+	if err != nil {
+		return "", err
+	}
+
+	hashes := strings.Fields(expected)
+	for _, algorithm := range integrityAlgorithms {
+		var candidates []string
+		for _, hash := range hashes {
+			if strings.HasPrefix(hash, algorithm+"-") {
+				// Strip any options, such as sha512-<hash>?opt.
+				if i := strings.IndexByte(hash, '?'); i != -1 {
+					hash = hash[:i]
+				}
+				candidates = append(candidates, hash)
+			}
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+		for _, candidate := range candidates {
+			if candidate == digests[algorithm] {
+				return digests["sha512"], nil
+			}
+		}
+		return "", fmt.Errorf("integrity check failed for %s: expected %s, got %s",
+			name, strings.Join(candidates, " "), digests[algorithm])
+	}
+
+	return digests["sha512"], nil
+}
+
+// urlChecksumAlgorithms are the hashes that can be pinned in a URL.
+var urlChecksumAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+// urlChecksum is a hex checksum pinned in the fragment of
+// a remote spec or config URL, such as #sha256=<hex>.
+type urlChecksum struct {
+	algorithm string
+	sum       []byte
+}
+
+// parseURLChecksum splits a checksum from the fragment of rawURL,
+// returning the URL to fetch and the checksum, if there is one.
+// Other fragments are left in place.
+func parseURLChecksum(rawURL string) (string, *urlChecksum, error) {
+	i := strings.LastIndexByte(rawURL, '#')
+	if i == -1 {
+		return rawURL, nil, nil
+	}
+	algorithm, value, ok := strings.Cut(rawURL[i+1:], "=")
+	if !ok {
+		return rawURL, nil, nil
+	}
+	algorithm = strings.ToLower(algorithm)
+	newHash, supported := urlChecksumAlgorithms[algorithm]
+	if !supported {
+		return "", nil, fmt.Errorf("unsupported checksum algorithm %q in %s; use sha256, sha384, or sha512", algorithm, rawURL)
+	}
+	sum, err := hex.DecodeString(value)
+	if err != nil || len(sum) != newHash().Size() {
+		return "", nil, fmt.Errorf("invalid %s checksum in %s", algorithm, rawURL)
+	}
+	return rawURL[:i], &urlChecksum{algorithm: algorithm, sum: sum}, nil
+}
+
+// verify fails when data does not match the checksum.
+func (c *urlChecksum) verify(name string, data []byte) error {
+	h := urlChecksumAlgorithms[c.algorithm]()
+	h.Write(data)
+	if actual := h.Sum(nil); !bytes.Equal(actual, c.sum) {
+		return fmt.Errorf("checksum mismatch for %s: expected %s=%x, got %x",
+			name, c.algorithm, c.sum, actual)
+	}
+	return nil
+}
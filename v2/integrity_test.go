@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sri(algorithm string, sum []byte) string {
+	return algorithm + "-" + base64.StdEncoding.EncodeToString(sum)
+}
+
+func TestVerifyIntegrity(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "module.tgz")
+	contents := []byte("module contents")
+	require.NoError(t, os.WriteFile(archive, contents, 0644))
+	sum512 := sha512.Sum512(contents)
+	sum1 := sha1.Sum(contents)
+	other512 := sha512.Sum512([]byte("tampered"))
+	other1 := sha1.Sum([]byte("tampered"))
+	actual := sri("sha512", sum512[:])
+
+	tests := []struct {
+		name     string
+		expected string
+		valid    bool
+	}{
+		{"sha512", actual, true},
+		{"sha512 with options", actual + "?foo", true},
+		{"sha1", sri("sha1", sum1[:]), true},
+		{"any sha512 matches", sri("sha512", other512[:]) + " " + actual, true},
+		{"no supported hashes", "md5-abc", true},
+		{"nothing expected", "", true},
+		{"mismatched sha512", sri("sha512", other512[:]), false},
+		{"mismatched sha1", sri("sha1", other1[:]), false},
+		// Only the strongest algorithm is checked, so a matching
+		// weaker hash does not make up for a mismatched sha512.
+		{"mismatched sha512 with matching sha1", sri("sha512", other512[:]) + " " + sri("sha1", sum1[:]), false},
+		{"malformed sha512", "sha512-not-base64", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			integrity, err := verifyIntegrity("@apexlang/core", archive, tc.expected)
+			if !tc.valid {
+				assert.ErrorContains(t, err, "integrity check failed for @apexlang/core")
+				assert.Empty(t, integrity)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, actual, integrity)
+		})
+	}
+}
+
+func TestShasumIntegrity(t *testing.T) {
+	sum := sha1.Sum([]byte("module contents"))
+	assert.Equal(t, sri("sha1", sum[:]), shasumIntegrity(hex.EncodeToString(sum[:])))
+	assert.Empty(t, shasumIntegrity("not hex"))
+	assert.Empty(t, shasumIntegrity("abcd"))
+}
+
+func TestURLChecksum(t *testing.T) {
+	data := []byte("spec contents")
+	sum := sha256.Sum256(data)
+
+	rawURL, checksum, err := parseURLChecksum("https://example.com/spec.apexlang#sha256=" + hex.EncodeToString(sum[:]))
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/spec.apexlang", rawURL)
+	require.NotNil(t, checksum)
+	assert.NoError(t, checksum.verify("spec.apexlang", data))
+	assert.ErrorContains(t, checksum.verify("spec.apexlang", []byte("tampered")), "checksum mismatch for spec.apexlang")
+
+	_, _, err = parseURLChecksum("https://example.com/spec.apexlang#md5=abcd")
+	assert.ErrorContains(t, err, "unsupported checksum algorithm")
+	_, _, err = parseURLChecksum("https://example.com/spec.apexlang#sha256=abcd")
+	assert.ErrorContains(t, err, "invalid sha256 checksum")
+}
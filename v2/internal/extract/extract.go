@@ -0,0 +1,431 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package extract unpacks module archives under a policy that keeps
+// their contents from escaping the destination directory or
+// exhausting the disk.
+package extract
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// SymlinkPolicy is how symbolic and hard links in archives are handled.
+type SymlinkPolicy string
+
+const (
+	// SymlinksSkip does not extract links. It is the default.
+	SymlinksSkip SymlinkPolicy = "skip"
+	// SymlinksResolve creates links whose targets are
+	// inside the destination and rejects the others.
+	SymlinksResolve SymlinkPolicy = "resolve"
+	// SymlinksError rejects archives that contain links.
+	SymlinksError SymlinkPolicy = "error"
+)
+
+// Errors returned, wrapped in an *Error, for archives
+// that do not satisfy the policy.
+var (
+	ErrPathTraversal = errors.New("path is outside of the destination")
+	ErrTooManyFiles  = errors.New("archive contains too many files")
+	ErrFileTooLarge  = errors.New("file is too large")
+	ErrTooLarge      = errors.New("archive is too large")
+	ErrLink          = errors.New("archive contains a link")
+)
+
+// Policy limits what is extracted from an archive. Zero limits
+// are unlimited, so start from DefaultPolicy.
+type Policy struct {
+	// MaxFiles is the number of files and links that are extracted.
+	MaxFiles int
+	// MaxFileSize is the size of the largest file.
+	MaxFileSize int64
+	// MaxTotalSize is the combined size of all files.
+	MaxTotalSize int64
+	// Symlinks is SymlinksSkip when empty.
+	Symlinks SymlinkPolicy
+	// KeepModes keeps the permissions recorded in the archive.
+	// Otherwise directories are 0755 and files 0644, or 0755 if
+	// any execute bit is set. Special bits are always removed.
+	KeepModes bool
+	// Rename returns where an entry is extracted, relative to the
+	// destination, or false to skip it.
+	Rename func(name string) (string, bool)
+}
+
+// DefaultPolicy returns limits suitable for module archives.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxFiles:     100000,
+		MaxFileSize:  256 << 20,
+		MaxTotalSize: 1 << 30,
+		Symlinks:     SymlinksSkip,
+	}
+}
+
+// Error records the archive entry that could not be extracted.
+type Error struct {
+	Entry string
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %v", e.Entry, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Tarball extracts a gzipped tar archive into dest.
+func Tarball(src, dest string, policy Policy) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gzr.Close()
+
+	x, err := newExtractor(dest, policy)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gzr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return x.checkLinks()
+		}
+		if err != nil {
+			return err
+		}
+
+		var entryErr error
+		switch header.Typeflag {
+		case tar.TypeDir:
+			entryErr = x.dir(header.Name)
+		case tar.TypeReg, tar.TypeRegA:
+			entryErr = x.file(header.Name, tr, header.Size, header.FileInfo().Mode())
+		case tar.TypeSymlink:
+			entryErr = x.symlink(header.Name, header.Linkname)
+		case tar.TypeLink:
+			entryErr = x.hardlink(header.Name, header.Linkname)
+		}
+		// Devices, FIFOs, and extended headers are not extracted.
+		if entryErr != nil {
+			return &Error{Entry: header.Name, Err: entryErr}
+		}
+	}
+}
+
+// Zip extracts a zip archive into dest.
+func Zip(src, dest string, policy Policy) error {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	x, err := newExtractor(dest, policy)
+	if err != nil {
+		return err
+	}
+	for _, f := range r.File {
+		if err = x.zipEntry(f); err != nil {
+			return &Error{Entry: f.Name, Err: err}
+		}
+	}
+	return x.checkLinks()
+}
+
+func (x *extractor) zipEntry(f *zip.File) error {
+	mode := f.Mode()
+	switch {
+	case mode.IsDir():
+		return x.dir(f.Name)
+	case mode&fs.ModeSymlink != 0:
+		// The target of a link is stored as its contents.
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		target, err := io.ReadAll(io.LimitReader(rc, 4096))
+		if err != nil {
+			return err
+		}
+		return x.symlink(f.Name, string(target))
+	case mode.IsRegular():
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		return x.file(f.Name, rc, int64(f.UncompressedSize64), mode)
+	}
+	return nil
+}
+
+// extractor tracks what has been extracted into dest.
+type extractor struct {
+	dest   string
+	policy Policy
+	files  int
+	total  int64
+	// links are the symbolic links created.
+	links []string
+}
+
+func newExtractor(dest string, policy Policy) (*extractor, error) {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return nil, err
+	}
+	dest, err := filepath.Abs(dest)
+	if err != nil {
+		return nil, err
+	}
+	if dest, err = filepath.EvalSymlinks(dest); err != nil {
+		return nil, err
+	}
+	if policy.Symlinks == "" {
+		policy.Symlinks = SymlinksSkip
+	}
+	return &extractor{dest: dest, policy: policy}, nil
+}
+
+// target returns the path an entry is extracted to, or an empty
+// string when the policy's Rename skips it. Entries with absolute
+// paths or that would be outside of dest are rejected.
+func (x *extractor) target(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if x.policy.Rename != nil {
+		renamed, ok := x.policy.Rename(name)
+		if !ok {
+			return "", nil
+		}
+		name = renamed
+	}
+	if path.IsAbs(name) || filepath.VolumeName(filepath.FromSlash(name)) != "" {
+		return "", ErrPathTraversal
+	}
+	target := filepath.Join(x.dest, filepath.FromSlash(name))
+	if !x.inside(target) || !x.resolvesInside(filepath.Dir(target)) {
+		return "", ErrPathTraversal
+	}
+	return target, nil
+}
+
+// resolvesInside reports whether the directory path stays inside dest
+// when the links extracted so far are followed, which a lexical check
+// misses for links such as "a -> ." followed by "b -> a/..".
+func (x *extractor) resolvesInside(path string) bool {
+	if x.policy.Symlinks != SymlinksResolve {
+		return true
+	}
+	for dir := path; x.inside(dir); dir = filepath.Dir(dir) {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return x.inside(resolved)
+		}
+	}
+	return false
+}
+
+// inside reports whether path is dest or under it.
+func (x *extractor) inside(path string) bool {
+	rel, err := filepath.Rel(x.dest, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// count records an extracted file or link.
+func (x *extractor) count() error {
+	x.files++
+	if x.policy.MaxFiles > 0 && x.files > x.policy.MaxFiles {
+		return ErrTooManyFiles
+	}
+	return nil
+}
+
+func (x *extractor) dir(name string) error {
+	target, err := x.target(name)
+	if err != nil || target == "" {
+		return err
+	}
+	return os.MkdirAll(target, x.mode(fs.ModeDir|0755, true))
+}
+
+func (x *extractor) file(name string, r io.Reader, size int64, mode fs.FileMode) error {
+	target, err := x.target(name)
+	if err != nil || target == "" {
+		return err
+	}
+	if err = x.count(); err != nil {
+		return err
+	}
+
+	// Sizes recorded in the archive are checked first, and
+	// the contents are limited in case they are wrong.
+	if err = x.checkSize(size); err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	// Replace links from earlier entries rather than writing through them.
+	if fi, err := os.Lstat(target); err == nil && fi.Mode()&fs.ModeSymlink != 0 {
+		if err = os.Remove(target); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, x.mode(mode, false))
+	if err != nil {
+		return err
+	}
+	if x.policy.MaxFileSize > 0 {
+		r = io.LimitReader(r, x.policy.MaxFileSize+1)
+	}
+	if x.policy.MaxTotalSize > 0 {
+		r = io.LimitReader(r, x.policy.MaxTotalSize-x.total+1)
+	}
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err = x.checkSize(n); err != nil {
+		return err
+	}
+	x.total += n
+	return nil
+}
+
+// checkSize returns an error if a file of size bytes
+// is larger than the policy allows.
+func (x *extractor) checkSize(size int64) error {
+	switch {
+	case x.policy.MaxFileSize > 0 && size > x.policy.MaxFileSize:
+		return ErrFileTooLarge
+	case x.policy.MaxTotalSize > 0 && x.total+size > x.policy.MaxTotalSize:
+		return ErrTooLarge
+	}
+	return nil
+}
+
+// mode returns the permissions to create an entry with.
+func (x *extractor) mode(mode fs.FileMode, dir bool) fs.FileMode {
+	if x.policy.KeepModes {
+		return mode.Perm()
+	}
+	if dir || mode&0111 != 0 {
+		return 0755
+	}
+	return 0644
+}
+
+// link applies the symlink policy, returning false
+// if the link is skipped.
+func (x *extractor) link() (bool, error) {
+	switch x.policy.Symlinks {
+	case SymlinksResolve:
+		return true, x.count()
+	case SymlinksError:
+		return false, ErrLink
+	}
+	return false, nil
+}
+
+func (x *extractor) symlink(name, linkname string) error {
+	create, err := x.link()
+	if !create || err != nil {
+		return err
+	}
+	target, err := x.target(name)
+	if err != nil || target == "" {
+		return err
+	}
+	linkname = filepath.FromSlash(strings.ReplaceAll(linkname, "\\", "/"))
+	if filepath.IsAbs(linkname) || !x.inside(filepath.Join(filepath.Dir(target), linkname)) {
+		return ErrPathTraversal
+	}
+	if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	os.Remove(target)
+	if err = os.Symlink(linkname, target); err != nil {
+		return err
+	}
+	x.links = append(x.links, target)
+	return nil
+}
+
+// checkLinks rejects links that resolve outside of dest once every
+// link is extracted, as links can point through ones extracted later.
+func (x *extractor) checkLinks() error {
+	for _, link := range x.links {
+		resolved, err := filepath.EvalSymlinks(link)
+		if err != nil {
+			// Links to missing files are left dangling.
+			continue
+		}
+		if !x.inside(resolved) {
+			rel, _ := filepath.Rel(x.dest, link)
+			return &Error{Entry: filepath.ToSlash(rel), Err: ErrPathTraversal}
+		}
+	}
+	return nil
+}
+
+// hardlink links name to linkname, an entry earlier in the archive.
+func (x *extractor) hardlink(name, linkname string) error {
+	create, err := x.link()
+	if !create || err != nil {
+		return err
+	}
+	target, err := x.target(name)
+	if err != nil || target == "" {
+		return err
+	}
+	source, err := x.target(linkname)
+	if err != nil {
+		return err
+	}
+	if source == "" {
+		// The linked entry was not extracted.
+		return nil
+	}
+	if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	os.Remove(target)
+	return os.Link(source, target)
+}
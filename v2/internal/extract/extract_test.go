@@ -0,0 +1,327 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package extract_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apexlang/cli/v2/internal/extract"
+)
+
+type entry struct {
+	name string
+	// body is the contents of a file. Links have a target
+	// instead and directories have neither.
+	body     string
+	symlink  string
+	hardlink string
+	dir      bool
+}
+
+func file(name, body string) entry  { return entry{name: name, body: body} }
+func symlink(name, to string) entry { return entry{name: name, symlink: to} }
+
+// archiver writes entries to an archive, returning false
+// when the format cannot hold them.
+type archiver func(t *testing.T, path string, entries []entry) bool
+
+var archivers = map[string]archiver{
+	"tar": writeTarball,
+	"zip": writeZip,
+}
+
+func writeTarball(t *testing.T, path string, entries []entry) bool {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Mode: 0644}
+		switch {
+		case e.dir:
+			header.Typeflag, header.Mode = tar.TypeDir, 0755
+		case e.symlink != "":
+			header.Typeflag, header.Linkname = tar.TypeSymlink, e.symlink
+		case e.hardlink != "":
+			header.Typeflag, header.Linkname = tar.TypeLink, e.hardlink
+		default:
+			header.Typeflag, header.Size = tar.TypeReg, int64(len(e.body))
+		}
+		require.NoError(t, tw.WriteHeader(header))
+		_, err := tw.Write([]byte(e.body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+	return true
+}
+
+func writeZip(t *testing.T, path string, entries []entry) bool {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		body := e.body
+		switch {
+		case e.hardlink != "":
+			// Zip archives cannot hold hard links.
+			return false
+		case e.dir:
+			header.Name += "/"
+			header.SetMode(fs.ModeDir | 0755)
+		case e.symlink != "":
+			header.SetMode(fs.ModeSymlink | 0777)
+			body = e.symlink
+		default:
+			header.SetMode(0644)
+		}
+		w, err := zw.CreateHeader(header)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+	return true
+}
+
+func extractArchive(format, src, dest string, policy extract.Policy) error {
+	if format == "zip" {
+		return extract.Zip(src, dest, policy)
+	}
+	return extract.Tarball(src, dest, policy)
+}
+
+func TestRejected(t *testing.T) {
+	resolve := extract.DefaultPolicy()
+	resolve.Symlinks = extract.SymlinksResolve
+	forbid := extract.DefaultPolicy()
+	forbid.Symlinks = extract.SymlinksError
+	limited := extract.DefaultPolicy()
+	limited.MaxFiles = 2
+	limited.MaxFileSize = 8
+	limited.MaxTotalSize = 12
+
+	tests := []struct {
+		name     string
+		policy   extract.Policy
+		entries  []entry
+		expected error
+	}{
+		{
+			name:     "parent directory",
+			policy:   extract.DefaultPolicy(),
+			entries:  []entry{file("../outside/evil", "x")},
+			expected: extract.ErrPathTraversal,
+		},
+		{
+			name:     "nested parent directory",
+			policy:   extract.DefaultPolicy(),
+			entries:  []entry{file("package/../../outside/evil", "x")},
+			expected: extract.ErrPathTraversal,
+		},
+		{
+			name:     "backslashes",
+			policy:   extract.DefaultPolicy(),
+			entries:  []entry{file(`..\outside\evil`, "x")},
+			expected: extract.ErrPathTraversal,
+		},
+		{
+			name:     "absolute path",
+			policy:   extract.DefaultPolicy(),
+			entries:  []entry{file("/tmp/evil", "x")},
+			expected: extract.ErrPathTraversal,
+		},
+		{
+			name:     "symlink outside",
+			policy:   resolve,
+			entries:  []entry{symlink("link", "../outside")},
+			expected: extract.ErrPathTraversal,
+		},
+		{
+			name:     "absolute symlink",
+			policy:   resolve,
+			entries:  []entry{symlink("link", "/tmp")},
+			expected: extract.ErrPathTraversal,
+		},
+		{
+			name:   "symlink chain then write through",
+			policy: resolve,
+			entries: []entry{
+				symlink("a", "."),
+				symlink("b", "a/.."),
+				file("b/outside/evil", "x"),
+			},
+			expected: extract.ErrPathTraversal,
+		},
+		{
+			name:   "symlink through a later symlink",
+			policy: resolve,
+			entries: []entry{
+				symlink("link", "dir/.."),
+				symlink("dir", "../outside"),
+			},
+			expected: extract.ErrPathTraversal,
+		},
+		{
+			name:   "hardlink outside",
+			policy: resolve,
+			entries: []entry{
+				{name: "link", hardlink: "../outside/secret"},
+			},
+			expected: extract.ErrPathTraversal,
+		},
+		{
+			name:     "links forbidden",
+			policy:   forbid,
+			entries:  []entry{symlink("link", "file")},
+			expected: extract.ErrLink,
+		},
+		{
+			name:   "too many files",
+			policy: limited,
+			entries: []entry{
+				file("a", "1"), file("b", "2"), file("c", "3"),
+			},
+			expected: extract.ErrTooManyFiles,
+		},
+		{
+			name:     "file too large",
+			policy:   limited,
+			entries:  []entry{file("big", "123456789")},
+			expected: extract.ErrFileTooLarge,
+		},
+		{
+			name:     "archive too large",
+			policy:   limited,
+			entries:  []entry{file("a", "12345678"), file("b", "12345678")},
+			expected: extract.ErrTooLarge,
+		},
+	}
+
+	for format, write := range archivers {
+		for _, tc := range tests {
+			tc := tc
+			t.Run(format+"/"+tc.name, func(t *testing.T) {
+				root := t.TempDir()
+				outside := filepath.Join(root, "outside")
+				require.NoError(t, os.Mkdir(outside, 0755))
+				require.NoError(t, os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644))
+
+				src := filepath.Join(root, "archive")
+				if !write(t, src, tc.entries) {
+					t.Skipf("%s archives cannot hold these entries", format)
+				}
+				err := extractArchive(format, src, filepath.Join(root, "dest"), tc.policy)
+				require.ErrorIs(t, err, tc.expected)
+				var entryErr *extract.Error
+				assert.ErrorAs(t, err, &entryErr)
+
+				// Nothing was written outside of the destination.
+				entries, err := os.ReadDir(outside)
+				require.NoError(t, err)
+				require.Len(t, entries, 1)
+				secret, err := os.ReadFile(filepath.Join(outside, "secret"))
+				require.NoError(t, err)
+				assert.Equal(t, "secret", string(secret))
+			})
+		}
+	}
+}
+
+func TestExtracted(t *testing.T) {
+	for format, write := range archivers {
+		t.Run(format, func(t *testing.T) {
+			root := t.TempDir()
+			src := filepath.Join(root, "archive")
+			write(t, src, []entry{
+				{name: "package", dir: true},
+				file("package/index.js", "module.exports = {};"),
+				file("package/dist/index.d.ts", "export {};"),
+			})
+			dest := filepath.Join(root, "dest")
+			require.NoError(t, extractArchive(format, src, dest, extract.DefaultPolicy()))
+
+			data, err := os.ReadFile(filepath.Join(dest, "package", "dist", "index.d.ts"))
+			require.NoError(t, err)
+			assert.Equal(t, "export {};", string(data))
+		})
+	}
+}
+
+// Links are skipped by default, so entries written through
+// them land in the destination rather than the link target.
+func TestSymlinksSkipped(t *testing.T) {
+	for format, write := range archivers {
+		t.Run(format, func(t *testing.T) {
+			root := t.TempDir()
+			outside := filepath.Join(root, "outside")
+			require.NoError(t, os.Mkdir(outside, 0755))
+			src := filepath.Join(root, "archive")
+			write(t, src, []entry{
+				symlink("link", "../outside"),
+				file("link/evil", "x"),
+			})
+			dest := filepath.Join(root, "dest")
+			require.NoError(t, extractArchive(format, src, dest, extract.DefaultPolicy()))
+
+			fi, err := os.Lstat(filepath.Join(dest, "link"))
+			require.NoError(t, err)
+			assert.True(t, fi.IsDir())
+			entries, err := os.ReadDir(outside)
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
+
+// A file replaces a link extracted before it instead of
+// being written to the link's target.
+func TestFileReplacesSymlink(t *testing.T) {
+	policy := extract.DefaultPolicy()
+	policy.Symlinks = extract.SymlinksResolve
+	for format, write := range archivers {
+		t.Run(format, func(t *testing.T) {
+			root := t.TempDir()
+			src := filepath.Join(root, "archive")
+			write(t, src, []entry{
+				file("target", "original"),
+				symlink("link", "target"),
+				file("link", "replaced"),
+			})
+			dest := filepath.Join(root, "dest")
+			require.NoError(t, extractArchive(format, src, dest, policy))
+
+			data, err := os.ReadFile(filepath.Join(dest, "target"))
+			require.NoError(t, err)
+			assert.Equal(t, "original", string(data))
+			fi, err := os.Lstat(filepath.Join(dest, "link"))
+			require.NoError(t, err)
+			assert.True(t, fi.Mode().IsRegular())
+		})
+	}
+}
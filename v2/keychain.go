@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// keychainService names the items apex stores in the OS keychain.
+const keychainService = "apex"
+
+// errNoKeychain is returned when the OS has no keychain apex can use,
+// such as Linux without libsecret's secret-tool.
+var errNoKeychain = errors.New("no OS keychain is available")
+
+// keychainGet returns the secret stored for account in the macOS
+// Keychain, the Windows Credential Manager, or the Secret Service
+// through libsecret, or an empty string when there is none.
+func keychainGet(account string) (string, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		out, err := keychainCommand(nil, "security", "find-generic-password", "-s", keychainService, "-a", account, "-w")
+		if exitCode(err) == 44 {
+			// errSecItemNotFound
+			return "", nil
+		}
+		return strings.TrimSuffix(out, "\n"), err
+	case "windows":
+		return credRead(keychainService + ":" + account)
+	default:
+		out, err := keychainCommand(nil, "secret-tool", "lookup", "service", keychainService, "account", account)
+		if err != nil && out == "" && exitCode(err) == 1 {
+			// secret-tool exits with 1 when nothing matches.
+			return "", nil
+		}
+		return out, err
+	}
+}
+
+// keychainSet stores the secret for account, replacing any stored before.
+func keychainSet(account, secret string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		// Commands read from stdin keep the secret out of
+		// the process list.
+		if strings.Contains(secret, "\n") {
+			return errors.New("the token cannot be stored in the macOS Keychain")
+		}
+		command := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+			keychainService, shellQuote(account), shellQuote(secret))
+		_, err := keychainCommand([]byte(command), "security", "-i")
+		return err
+	case "windows":
+		return credWrite(keychainService+":"+account, account, secret)
+	default:
+		_, err := keychainCommand([]byte(secret), "secret-tool", "store",
+			"--label=apex "+account, "service", keychainService, "account", account)
+		return err
+	}
+}
+
+// keychainDelete removes the secret stored for account, if any.
+func keychainDelete(account string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		_, err := keychainCommand(nil, "security", "delete-generic-password", "-s", keychainService, "-a", account)
+		if exitCode(err) == 44 {
+			return nil
+		}
+		return err
+	case "windows":
+		return credDelete(keychainService + ":" + account)
+	default:
+		_, err := keychainCommand(nil, "secret-tool", "clear", "service", keychainService, "account", account)
+		return err
+	}
+}
+
+// keychainCommand runs a keychain CLI, returning what it printed
+// and errNoKeychain when the CLI is not installed.
+func keychainCommand(stdin []byte, name string, args ...string) (string, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return "", errNoKeychain
+	}
+	cmd := exec.Command(name, args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return stdout.String(), fmt.Errorf("%s: %w: %s", name, err, message)
+		}
+		return stdout.String(), fmt.Errorf("%s: %w", name, err)
+	}
+	return stdout.String(), nil
+}
+
+// exitCode returns the exit code of a command that failed,
+// or -1 when it did not run.
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
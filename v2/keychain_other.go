@@ -1,3 +1,5 @@
+//go:build !windows
+
 /*
 Copyright 2022 The Apex Authors.
 
@@ -16,14 +18,17 @@ limitations under the License.
 
 package cli
 
-type UpgradeCmd struct {
+// The Windows Credential Manager is only available on Windows,
+// where other keychains use CLIs.
+
+func credRead(target string) (string, error) {
+	return "", errNoKeychain
 }
 
-func (c *UpgradeCmd) Run(ctx *Context) error {
-	homeDir, err := ensureHomeDirectory()
-	if err != nil {
-		return err
-	}
+func credWrite(target, user, secret string) error {
+	return errNoKeychain
+}
 
-	return checkDependencies(homeDir, true)
+func credDelete(target string) error {
+	return errNoKeychain
 }
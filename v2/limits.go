@@ -0,0 +1,106 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Limits applied to configuration files and specifications, which may
+// come from untrusted sources such as pull requests. Embedders can
+// change them before running commands.
+var (
+	// MaxConfigSize is the largest configuration file that is read.
+	MaxConfigSize int64 = 1 << 20
+	// MaxSpecSize is the largest specification, or imported
+	// definition file, that is read.
+	MaxSpecSize int64 = 16 << 20
+	// MaxYAMLNodes is the number of nodes a configuration document
+	// may contain once aliases are expanded.
+	MaxYAMLNodes = 100000
+	// MaxYAMLDepth is how deeply a configuration document may nest.
+	MaxYAMLDepth = 64
+	// ScriptTimeout bounds how long parsing and generating from
+	// a specification may run.
+	ScriptTimeout = 2 * time.Minute
+)
+
+// ErrInputTooLarge is returned when a file exceeds its size limit.
+var ErrInputTooLarge = errors.New("input is too large")
+
+// ErrScriptTimeout is wrapped by the errors of scripts that are
+// terminated for running longer than ScriptTimeout.
+var ErrScriptTimeout = errors.New("script timed out")
+
+// limitReader reads all of r, failing with ErrInputTooLarge
+// instead of reading more than limit bytes.
+func limitReader(name string, r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s: %w (limit is %d bytes)", name, ErrInputTooLarge, limit)
+	}
+	return data, nil
+}
+
+// readLocalFile reads a file from disk within a size limit.
+func readLocalFile(filename string, limit int64) ([]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return limitReader(filename, f, limit)
+}
+
+// checkYAML rejects documents whose aliases expand to too many
+// nodes or that nest too deeply, before they are decoded.
+func checkYAML(name string, data []byte) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return err
+	}
+	nodes := 0
+	var walk func(n *yaml.Node, depth int) error
+	walk = func(n *yaml.Node, depth int) error {
+		nodes++
+		if nodes > MaxYAMLNodes {
+			return fmt.Errorf("%s: %w: more than %d nodes after expanding aliases", name, ErrInputTooLarge, MaxYAMLNodes)
+		}
+		if depth > MaxYAMLDepth {
+			return fmt.Errorf("%s: %w: nested more than %d levels", name, ErrInputTooLarge, MaxYAMLDepth)
+		}
+		if n.Kind == yaml.AliasNode && n.Alias != nil {
+			return walk(n.Alias, depth+1)
+		}
+		for _, child := range n.Content {
+			if err := walk(child, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(&root, 0)
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	"gopkg.in/yaml.v3"
+)
+
+type ListCmd struct {
+	Templates   ListTemplatesCmd   `cmd:"templates" help:"Lists installed templates"`
+	Definitions ListDefinitionsCmd `cmd:"definitions" help:"Lists installed definitions and the modules that installed them"`
+}
+
+type ListDefinitionsCmd struct {
+}
+
+func (c *ListDefinitionsCmd) Run(ctx *Context) error {
+	homeDir, err := getHomeDirectory()
+	if err != nil {
+		return err
+	}
+
+	t := table.NewWriter()
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{
+			Name:   "Definition",
+			Colors: text.Colors{text.FgGreen},
+		},
+		{
+			Name:   "Module",
+			Colors: text.Colors{text.FgCyan},
+		},
+	})
+	t.AppendHeader(table.Row{"Definition", "Module"})
+
+	// Definitions installed in the project hide
+	// those of the same name in the home directory.
+	seen := make(map[string]struct{})
+	for _, home := range moduleHomes(homeDir) {
+		owners, err := installedPaths(home)
+		if err != nil {
+			return err
+		}
+		definitionsPath := filepath.Join(home, "definitions")
+		if err = filepath.Walk(definitionsPath, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(home, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			name := strings.TrimPrefix(rel, "definitions/")
+			if _, ok := seen[name]; ok {
+				return nil
+			}
+			seen[name] = struct{}{}
+			t.AppendRow(table.Row{name, pathOwner(owners, rel)})
+			return nil
+		}); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	fmt.Println(t.Render())
+
+	return nil
+}
+
+// pathOwner returns the module that installed a file, whose
+// manifest lists the file or a directory containing it.
+func pathOwner(owners map[string]string, file string) string {
+	for p := file; p != "." && p != "/"; p = path.Dir(p) {
+		if owner, ok := owners[p]; ok {
+			return owner
+		}
+	}
+	return "unknown"
+}
+
+type ListTemplatesCmd struct {
+}
+
+func (c *ListTemplatesCmd) Run(ctx *Context) error {
+	homeDir, err := getHomeDirectory()
+	if err != nil {
+		return err
+	}
+
+	type template struct {
+		name string
+		file string
+	}
+	var templates []template
+
+	// Templates installed in the project hide
+	// those of the same name in the home directory.
+	seen := make(map[string]struct{})
+	for _, home := range moduleHomes(homeDir) {
+		templatesPath := filepath.Join(home, "templates")
+		if err = filepath.Walk(templatesPath, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				fmt.Println(err)
+				return nil
+			}
+
+			if !info.IsDir() && info.Name() == ".template" {
+				relPath, err := filepath.Rel(templatesPath, filepath.Dir(path))
+				if err != nil {
+					return err
+				}
+				templateName := strings.ReplaceAll(relPath, string(filepath.Separator), "/")
+				if _, ok := seen[templateName]; !ok {
+					seen[templateName] = struct{}{}
+					templates = append(templates, template{templateName, path})
+				}
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	t := table.NewWriter()
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{
+			Name:   "Name",
+			Colors: text.Colors{text.FgGreen},
+		},
+		{
+			Name:   "Description",
+			Colors: text.Colors{text.FgCyan},
+		},
+	})
+	t.AppendHeader(table.Row{"Name", "Description"})
+	for _, tmpl := range templates {
+		templateBytes, err := os.ReadFile(tmpl.file)
+		if err != nil {
+			return err
+		}
+
+		var template Template
+		if err = yaml.Unmarshal(templateBytes, &template); err != nil {
+			return err
+		}
+
+		t.AppendRow(table.Row{tmpl.name, template.Description})
+	}
+	fmt.Println(t.Render())
+
+	return nil
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v33/github"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+// moduleVersion is a release of a module listed by install --list-versions.
+type moduleVersion struct {
+	Version string `json:"version"`
+	// Tags are the NPM dist-tags of the version, or latest
+	// for the newest GitHub release.
+	Tags       []string `json:"tags,omitempty"`
+	Prerelease bool     `json:"prerelease,omitempty"`
+}
+
+// listVersions prints the releases of the module given to install,
+// filtered by the semver range given as its release, newest first.
+func (c *InstallCmd) listVersions(ctx *Context) error {
+	modules := parseModuleArgs(c.Modules)
+	if len(modules) != 1 {
+		return errors.New("--list-versions requires a single location")
+	}
+	location := resolveModuleAlias(modules[0].Location, projectAliases())
+	filter := modules[0].Release
+
+	var versions []moduleVersion
+	var err error
+	switch {
+	case strings.HasPrefix(location, "github.com/"):
+		repository, _, serr := splitSubdirectory(location)
+		if serr != nil {
+			return serr
+		}
+		if err = c.checkOnline(location); err != nil {
+			return err
+		}
+		versions, err = githubVersions(strings.TrimPrefix(repository, "github.com/"))
+	case strings.HasPrefix(location, "file:"), strings.HasPrefix(location, gitPrefix),
+		isRepositoryLocation(location), isArchiveLocation(location):
+		return fmt.Errorf("--list-versions supports NPM modules and GitHub repositories, not %s", location)
+	default:
+		if c.homeDir, err = apexHomeDir(); err != nil {
+			return err
+		}
+		if err = c.createHTTPClient(); err != nil {
+			return err
+		}
+		versions, err = c.npmVersions(location)
+	}
+	if err != nil {
+		return err
+	}
+
+	if filter != "" {
+		r, err := parseSemverRange(filter)
+		if err != nil {
+			return fmt.Errorf("--list-versions filters by a semver range, not %q: %w", filter, err)
+		}
+		filtered := versions[:0]
+		for _, version := range versions {
+			if v, ok := parseSemver(version.Version); ok && r.matches(v) {
+				filtered = append(filtered, version)
+			}
+		}
+		versions = filtered
+	}
+	sortVersions(versions)
+
+	if ctx != nil && ctx.JSON {
+		return writeJSON(os.Stdout, versions)
+	}
+
+	fmt.Printf("%s (%d versions)\n", location, len(versions))
+	t := table.NewWriter()
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{
+			Name:   "Version",
+			Colors: text.Colors{text.FgCyan},
+		},
+		{
+			Name:   "Tags",
+			Colors: text.Colors{text.FgGreen},
+		},
+	})
+	t.AppendHeader(table.Row{"Version", "Tags"})
+	for _, version := range versions {
+		t.AppendRow(table.Row{version.Version, strings.Join(version.Tags, ", ")})
+	}
+	fmt.Println(t.Render())
+	return nil
+}
+
+// npmVersions returns the published versions of an NPM package
+// with their dist-tags.
+func (c *InstallCmd) npmVersions(name string) ([]moduleVersion, error) {
+	p, err := c.packument(name)
+	if err != nil {
+		return nil, err
+	}
+	if !c.offline() {
+		p.updateDistTags(&c.netClient)
+	}
+	tags := make(map[string][]string)
+	for tag, version := range p.DistTags {
+		tags[version] = append(tags[version], tag)
+	}
+	versions := make([]moduleVersion, 0, len(p.Versions))
+	for version := range p.Versions {
+		sort.Strings(tags[version])
+		v, _ := parseSemver(version)
+		versions = append(versions, moduleVersion{
+			Version:    version,
+			Tags:       tags[version],
+			Prerelease: len(v.prerelease) > 0,
+		})
+	}
+	return versions, nil
+}
+
+// githubVersions returns the tags of the releases of a GitHub
+// repository, given as <org>/<repo>, skipping drafts.
+func githubVersions(repository string) ([]moduleVersion, error) {
+	org, repo, found := strings.Cut(repository, "/")
+	if !found || strings.Contains(repo, "/") {
+		return nil, fmt.Errorf("invalid repo syntax: %q", repository)
+	}
+	client, err := newGitHubClient()
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []moduleVersion
+	ct := context.Background()
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		releases, resp, err := client.Repositories.ListReleases(ct, org, repo, opts)
+		if err != nil {
+			return nil, githubError(err)
+		}
+		for _, release := range releases {
+			if release.TagName == nil || release.GetDraft() {
+				continue
+			}
+			version := moduleVersion{
+				Version:    *release.TagName,
+				Prerelease: release.GetPrerelease(),
+			}
+			// Releases are listed newest first, which
+			// install uses when no release is given.
+			if len(versions) == 0 {
+				version.Tags = []string{"latest"}
+			}
+			versions = append(versions, version)
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return versions, nil
+}
+
+// sortVersions sorts versions newest first, followed by
+// those that are not semantic versions by name.
+func sortVersions(versions []moduleVersion) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		vi, iok := parseSemver(versions[i].Version)
+		vj, jok := parseSemver(versions[j].Version)
+		switch {
+		case iok && jok:
+			return vi.compare(vj) > 0
+		case iok != jok:
+			return iok
+		}
+		return versions[i].Version < versions[j].Version
+	})
+}
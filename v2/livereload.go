@@ -0,0 +1,341 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LiveReload protocol served by watch --live-reload, which browser
+// extensions and the script at /livereload.js connect to.
+const (
+	liveReloadPath     = "/livereload"
+	liveReloadProtocol = "http://livereload.com/protocols/official-7"
+	// websocketGUID is appended to the key of a WebSocket
+	// handshake to compute the accept header (RFC 6455).
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// websocketVersion is the only version of RFC 6455.
+	websocketVersion = "13"
+	// maxLiveReloadMessage limits the messages read from clients,
+	// which only send small commands.
+	maxLiveReloadMessage = 64 * 1024
+)
+
+// WebSocket opcodes used by the server.
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xA
+)
+
+// liveReloadScript reloads the page, or only stylesheets when they
+// are all that changed, when told to by the server it was loaded from.
+const liveReloadScript = `(function () {
+  var src = document.currentScript ? document.currentScript.src : "";
+  var host = src ? new URL(src).host : "localhost:35729";
+  var socket = new WebSocket("ws://" + host + "` + liveReloadPath + `");
+  socket.onopen = function () {
+    socket.send(JSON.stringify({ command: "hello", protocols: ["` + liveReloadProtocol + `"] }));
+  };
+  socket.onmessage = function (event) {
+    var message = JSON.parse(event.data);
+    if (message.command !== "reload") {
+      return;
+    }
+    if (/\.css$/.test(message.path) && message.liveCSS) {
+      document.querySelectorAll('link[rel="stylesheet"]').forEach(function (link) {
+        var url = new URL(link.href);
+        url.searchParams.set("livereload", Date.now());
+        link.href = url.toString();
+      });
+      return;
+    }
+    location.reload();
+  };
+})();
+`
+
+type liveReloadMessage struct {
+	Command    string   `json:"command"`
+	Protocols  []string `json:"protocols,omitempty"`
+	ServerName string   `json:"serverName,omitempty"`
+	Path       string   `json:"path,omitempty"`
+	LiveCSS    bool     `json:"liveCSS,omitempty"`
+}
+
+// liveReloadServer tells connected browsers to reload.
+type liveReloadServer struct {
+	mu      sync.Mutex
+	clients map[*liveReloadClient]struct{}
+}
+
+type liveReloadClient struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func newLiveReloadServer() *liveReloadServer {
+	return &liveReloadServer{clients: map[*liveReloadClient]struct{}{}}
+}
+
+// listen serves the LiveReload protocol on addr until
+// the returned listener is closed.
+func (s *liveReloadServer) listen(addr string) (net.Listener, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle(liveReloadPath, s)
+	mux.HandleFunc("/livereload.js", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/javascript")
+		io.WriteString(w, liveReloadScript)
+	})
+	go http.Serve(listener, mux)
+	return listener, nil
+}
+
+func (s *liveReloadServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		http.Error(w, "expected a WebSocket connection", http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != websocketVersion {
+		w.Header().Set("Sec-WebSocket-Version", websocketVersion)
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return
+	}
+	if !liveReloadOriginAllowed(r.Header.Get("Origin")) {
+		http.Error(w, "origin is not allowed", http.StatusForbidden)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSockets are not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+	if err = rw.Flush(); err != nil {
+		return
+	}
+
+	client := &liveReloadClient{conn: conn}
+	s.mu.Lock()
+	s.clients[client] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, client)
+		s.mu.Unlock()
+	}()
+
+	for {
+		opcode, payload, err := readFrame(rw.Reader)
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case wsText:
+			var message liveReloadMessage
+			if json.Unmarshal(payload, &message) == nil && message.Command == "hello" {
+				client.send(liveReloadMessage{
+					Command:    "hello",
+					Protocols:  []string{liveReloadProtocol},
+					ServerName: "apex",
+				})
+			}
+		case wsPing:
+			client.writeFrame(wsPong, payload)
+		case wsClose:
+			client.writeFrame(wsClose, nil)
+			return
+		}
+	}
+}
+
+// liveReloadOriginAllowed reports whether a browser may connect from a
+// page with origin, so that other sites cannot follow what is being
+// generated. Pages served from this machine and the LiveReload browser
+// extensions are allowed, and clients that are not browsers send no
+// origin. Pages opened from files have the origin "null", which
+// sandboxed pages of any site share, so they are not allowed.
+func liveReloadOriginAllowed(origin string) bool {
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "chrome-extension", "moz-extension", "safari-web-extension":
+		return true
+	case "http", "https":
+	default:
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// reload tells every connected browser that files changed.
+func (s *liveReloadServer) reload(paths []string) {
+	s.mu.Lock()
+	clients := make([]*liveReloadClient, 0, len(s.clients))
+	for client := range s.clients {
+		clients = append(clients, client)
+	}
+	s.mu.Unlock()
+
+	for _, client := range clients {
+		for _, p := range paths {
+			if err := client.send(liveReloadMessage{
+				Command: "reload",
+				Path:    p,
+				LiveCSS: true,
+			}); err != nil {
+				// Reading fails too and removes the client.
+				client.conn.Close()
+				break
+			}
+		}
+	}
+}
+
+func (c *liveReloadClient) send(message liveReloadMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(wsText, data)
+}
+
+// writeFrame writes an unmasked frame, as servers do.
+func (c *liveReloadClient) writeFrame(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(n))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(payload)
+	return err
+}
+
+// readFrame reads a frame sent by a client, which is always masked.
+// Fragmented messages are not expected from LiveReload clients.
+func readFrame(r *bufio.Reader) (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := header[0] & 0x0F
+	if header[1]&0x80 == 0 {
+		return 0, nil, errors.New("client frames must be masked")
+	}
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > maxLiveReloadMessage {
+		return 0, nil, fmt.Errorf("message of %d bytes is too large", length)
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
+
+// liveReloadPaths returns the generated files of a configuration
+// that browsers are told about, limited to those matching one of
+// patterns, if any.
+func liveReloadPaths(config Config, patterns []string) []string {
+	var paths []string
+	for filename := range config.Generates {
+		filename = filepath.ToSlash(filepath.Clean(filename))
+		matched := len(patterns) == 0
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, filename); ok {
+				matched = true
+				break
+			}
+		}
+		if matched {
+			paths = append(paths, "/"+filename)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
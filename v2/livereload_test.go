@@ -0,0 +1,166 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveReloadRoundTrip(t *testing.T) {
+	s := newLiveReloadServer()
+	listener, err := s.listen("127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	// The example handshake of RFC 6455.
+	req, err := http.NewRequest(http.MethodGet, "http://"+listener.Addr().String()+liveReloadPath, nil)
+	require.NoError(t, err)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Origin", "http://localhost:8080")
+	require.NoError(t, req.Write(conn))
+	resp, err := http.ReadResponse(r, req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+
+	writeClientFrame(t, conn, wsText, []byte(`{"command":"hello","protocols":["`+liveReloadProtocol+`"]}`))
+	var hello liveReloadMessage
+	require.NoError(t, json.Unmarshal(readServerFrame(t, r, wsText), &hello))
+	assert.Equal(t, liveReloadMessage{Command: "hello", Protocols: []string{liveReloadProtocol}, ServerName: "apex"}, hello)
+
+	s.reload([]string{"/docs/index.html"})
+	var reload liveReloadMessage
+	require.NoError(t, json.Unmarshal(readServerFrame(t, r, wsText), &reload))
+	assert.Equal(t, liveReloadMessage{Command: "reload", Path: "/docs/index.html", LiveCSS: true}, reload)
+
+	// Payloads with 16 bit lengths are read and echoed in pongs.
+	ping := make([]byte, 200)
+	for i := range ping {
+		ping[i] = byte(i)
+	}
+	writeClientFrame(t, conn, wsPing, ping)
+	assert.Equal(t, ping, readServerFrame(t, r, wsPong))
+
+	writeClientFrame(t, conn, wsClose, nil)
+	assert.Empty(t, readServerFrame(t, r, wsClose))
+	_, err = r.ReadByte()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestLiveReloadHandshakeRejected(t *testing.T) {
+	s := newLiveReloadServer()
+	listener, err := s.listen("127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	for _, test := range []struct {
+		name, version, origin string
+		status                int
+	}{
+		{"other site", "13", "https://example.com", http.StatusForbidden},
+		{"file", "13", "null", http.StatusForbidden},
+		{"look-alike host", "13", "http://localhost.example.com", http.StatusForbidden},
+		{"old version", "8", "http://localhost", http.StatusUpgradeRequired},
+		{"no version", "", "", http.StatusUpgradeRequired},
+	} {
+		req, err := http.NewRequest(http.MethodGet, "http://"+listener.Addr().String()+liveReloadPath, nil)
+		require.NoError(t, err)
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Sec-WebSocket-Version", test.version)
+		req.Header.Set("Origin", test.origin)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err, test.name)
+		resp.Body.Close()
+		assert.Equal(t, test.status, resp.StatusCode, test.name)
+		if test.status == http.StatusUpgradeRequired {
+			assert.Equal(t, "13", resp.Header.Get("Sec-WebSocket-Version"), test.name)
+		}
+	}
+}
+
+func TestLiveReloadOriginAllowed(t *testing.T) {
+	for origin, allowed := range map[string]bool{
+		"":                                   true,
+		"http://localhost:8080":              true,
+		"https://docs.localhost":             true,
+		"http://127.0.0.1:3000":              true,
+		"http://[::1]:3000":                  true,
+		"chrome-extension://jnihajbhpnppcgg": true,
+		"moz-extension://0b7d-4a2c":          true,
+		"null":                               false,
+		"https://example.com":                false,
+		"http://192.168.1.10:8080":           false,
+		"ftp://localhost":                    false,
+	} {
+		assert.Equal(t, allowed, liveReloadOriginAllowed(origin), origin)
+	}
+}
+
+// writeClientFrame writes a masked frame, as clients do.
+func writeClientFrame(t *testing.T, w io.Writer, opcode byte, payload []byte) {
+	frame := []byte{0x80 | opcode}
+	if n := len(payload); n < 126 {
+		frame = append(frame, 0x80|byte(n))
+	} else {
+		frame = append(frame, 0x80|126, byte(n>>8), byte(n))
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := w.Write(frame)
+	require.NoError(t, err)
+}
+
+// readServerFrame reads an unmasked frame of the given opcode.
+func readServerFrame(t *testing.T, r *bufio.Reader, opcode byte) []byte {
+	var header [2]byte
+	_, err := io.ReadFull(r, header[:])
+	require.NoError(t, err)
+	require.Equal(t, 0x80|opcode, header[0])
+	require.Zero(t, header[1]&0x80, "server frames are not masked")
+	length := int(header[1])
+	if length == 126 {
+		var ext [2]byte
+		_, err = io.ReadFull(r, ext[:])
+		require.NoError(t, err)
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	_, err = io.ReadFull(r, payload)
+	require.NoError(t, err)
+	return payload
+}
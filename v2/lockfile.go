@@ -0,0 +1,180 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultLockfile is the lockfile written by install in the
+// current directory.
+const DefaultLockfile = "apex.lock"
+
+const lockfileVersion = 1
+
+// Lockfile records the exact modules installed so installs can be
+// reproduced with install --locked.
+type Lockfile struct {
+	LockfileVersion int `json:"lockfileVersion"`
+	// Modules are keyed by package name, such as @apexlang/codegen.
+	Modules map[string]LockedModule `json:"modules"`
+
+	mu sync.Mutex
+}
+
+// LockedModule is a module resolved to an exact download.
+type LockedModule struct {
+	// Location is what was installed, such as an NPM package or a
+	// git repository, and Requested is the tag, version,
+	// or range asked for.
+	Location      string `json:"location"`
+	Requested     string `json:"requested,omitempty"`
+	RequestedType string `json:"requestedType,omitempty"`
+	Version       string `json:"version,omitempty"`
+	Resolved      string `json:"resolved,omitempty"`
+	// Archive is the format of the download, tar.gz or zip,
+	// or git when Resolved is a remote that was cloned.
+	Archive string `json:"archive,omitempty"`
+	// Integrity is a subresource integrity hash of the download.
+	Integrity string `json:"integrity,omitempty"`
+	// Dependencies are the modules installed from the module's
+	// npm-shrinkwrap.json, keyed by their node_modules path.
+	Dependencies map[string]LockedModule `json:"dependencies,omitempty"`
+	// Project is set for modules installed in the
+	// project's .apex directory with --project.
+	Project bool `json:"project,omitempty"`
+}
+
+// readLockfile reads a lockfile, returning an empty
+// lockfile when it does not exist.
+func readLockfile(filename string) (*Lockfile, error) {
+	lock := Lockfile{
+		LockfileVersion: lockfileVersion,
+		Modules:         map[string]LockedModule{},
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &lock, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", filename, err)
+	}
+	if lock.LockfileVersion > lockfileVersion {
+		return nil, fmt.Errorf("%s has lockfile version %d; upgrade apex to use it", filename, lock.LockfileVersion)
+	}
+	if lock.Modules == nil {
+		lock.Modules = map[string]LockedModule{}
+	}
+	return &lock, nil
+}
+
+func (l *Lockfile) set(name string, module LockedModule) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Modules[name] = module
+}
+
+// find returns the locked module installed from location.
+func (l *Lockfile) find(location string) (string, LockedModule, bool) {
+	if module, ok := l.Modules[location]; ok {
+		return location, module, true
+	}
+	names := make([]string, 0, len(l.Modules))
+	for name := range l.Modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if l.Modules[name].Location == location {
+			return name, l.Modules[name], true
+		}
+	}
+	return "", LockedModule{}, false
+}
+
+// locked returns the locked module installed from location, failing
+// when the lockfile, read from filename, does not have it.
+func (l *Lockfile) locked(filename, location string) (string, LockedModule, error) {
+	name, module, ok := l.find(location)
+	if !ok {
+		return "", LockedModule{}, fmt.Errorf("%s is not in %s; install it without --locked first", location, filename)
+	}
+	return name, module, nil
+}
+
+func (l *Lockfile) write(filename string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.LockfileVersion = lockfileVersion
+	// Map keys are sorted when marshaled so the output is stable.
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0644)
+}
+
+// dependency returns the integrity locked for a dependency in the
+// module's npm-shrinkwrap.json, keyed by its node_modules path,
+// failing when it is not locked or now resolves to another download.
+func (m LockedModule) dependency(moduleName, resolved string) (string, error) {
+	dep, ok := m.Dependencies[moduleName]
+	if !ok {
+		return "", fmt.Errorf("%s is not locked for %s", moduleName, m.Location)
+	}
+	if dep.Resolved != resolved {
+		return "", fmt.Errorf("%s resolved to %s but is locked to %s", moduleName, resolved, dep.Resolved)
+	}
+	return dep.Integrity, nil
+}
+
+// release returns the release to download for a locked module.
+func (m LockedModule) release(name string) *releaseInfo {
+	release := releaseInfo{
+		Module:        name,
+		Tag:           m.Version,
+		Requested:     m.Requested,
+		RequestedType: m.RequestedType,
+	}
+	if i := strings.Index(name, "/"); i != -1 {
+		release.Org, release.Module = name[:i], name[i+1:]
+	}
+	if isRepositoryLocation(m.Location) {
+		_, release.Subdir, _ = splitSubdirectory(m.Location)
+	}
+	switch m.Archive {
+	case "git":
+		release.Clone = m.Resolved
+	case "zip":
+		release.ZipURL = m.Resolved
+	default:
+		release.TarballURL = m.Resolved
+	}
+	if strings.HasPrefix(m.Resolved, "file:") {
+		release.Archive = m.Resolved[5:]
+	}
+	return &release
+}
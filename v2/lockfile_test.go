@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testLockfile(t *testing.T) (string, *Lockfile) {
+	filename := filepath.Join(t.TempDir(), DefaultLockfile)
+	lock, err := readLockfile(filename)
+	require.NoError(t, err)
+	lock.set("@apexlang/codegen", LockedModule{
+		Location:  "@apexlang/codegen",
+		Version:   "1.0.0",
+		Resolved:  "https://registry.npmjs.org/@apexlang/codegen/-/codegen-1.0.0.tgz",
+		Integrity: "sha512-codegen",
+		Dependencies: map[string]LockedModule{
+			"node_modules/@apexlang/core": {
+				Location:  "@apexlang/core",
+				Version:   "1.0.0",
+				Resolved:  "https://registry.npmjs.org/@apexlang/core/-/core-1.0.0.tgz",
+				Integrity: "sha512-core",
+			},
+		},
+	})
+	lock.set("apexlang/template", LockedModule{
+		Location: "github.com/apexlang/template",
+		Version:  "v1.0.0",
+		Resolved: "https://github.com/apexlang/template.git",
+		Archive:  "git",
+	})
+	require.NoError(t, lock.write(filename))
+	return filename, lock
+}
+
+func TestLockfile(t *testing.T) {
+	filename, lock := testLockfile(t)
+	read, err := readLockfile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, lock.Modules, read.Modules)
+
+	name, module, err := read.locked(filename, "@apexlang/codegen")
+	require.NoError(t, err)
+	assert.Equal(t, "@apexlang/codegen", name)
+	assert.Equal(t, "sha512-codegen", module.Integrity)
+
+	name, module, err = read.locked(filename, "github.com/apexlang/template")
+	require.NoError(t, err)
+	assert.Equal(t, "apexlang/template", name)
+	release := module.release(name)
+	assert.Equal(t, "apexlang", release.Org)
+	assert.Equal(t, "template", release.Module)
+	assert.Equal(t, "https://github.com/apexlang/template.git", release.Clone)
+	assert.Empty(t, release.TarballURL)
+
+	_, _, err = read.locked(filename, "@apexlang/other")
+	assert.EqualError(t, err, "@apexlang/other is not in "+filename+"; install it without --locked first")
+}
+
+func TestLockfileVersion(t *testing.T) {
+	dir := t.TempDir()
+	lock, err := readLockfile(filepath.Join(dir, "missing.lock"))
+	require.NoError(t, err)
+	assert.Empty(t, lock.Modules)
+
+	filename := filepath.Join(dir, DefaultLockfile)
+	require.NoError(t, os.WriteFile(filename, []byte(`{"lockfileVersion": 2, "modules": {}}`), 0644))
+	_, err = readLockfile(filename)
+	assert.ErrorContains(t, err, "has lockfile version 2; upgrade apex to use it")
+
+	require.NoError(t, os.WriteFile(filename, []byte(`{"lockfileVersion":`), 0644))
+	_, err = readLockfile(filename)
+	assert.ErrorContains(t, err, "could not parse")
+}
+
+func TestLockedDependencyDrift(t *testing.T) {
+	_, lock := testLockfile(t)
+	module := lock.Modules["@apexlang/codegen"]
+
+	integrity, err := module.dependency("node_modules/@apexlang/core",
+		"https://registry.npmjs.org/@apexlang/core/-/core-1.0.0.tgz")
+	require.NoError(t, err)
+	assert.Equal(t, "sha512-core", integrity)
+
+	_, err = module.dependency("node_modules/@apexlang/core",
+		"https://registry.npmjs.org/@apexlang/core/-/core-1.1.0.tgz")
+	assert.EqualError(t, err, "node_modules/@apexlang/core resolved to "+
+		"https://registry.npmjs.org/@apexlang/core/-/core-1.1.0.tgz but is locked to "+
+		"https://registry.npmjs.org/@apexlang/core/-/core-1.0.0.tgz")
+
+	_, err = module.dependency("node_modules/@apexlang/added",
+		"https://registry.npmjs.org/@apexlang/added/-/added-1.0.0.tgz")
+	assert.EqualError(t, err, "node_modules/@apexlang/added is not locked for @apexlang/codegen")
+}
+
+func TestLockedIntegrityDrift(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "codegen-1.0.0.tgz")
+	require.NoError(t, os.WriteFile(archive, []byte("locked contents"), 0644))
+	locked, err := fileIntegrity(archive)
+	require.NoError(t, err)
+
+	// The same download is installed again.
+	_, err = verifyIntegrity("@apexlang/codegen", archive, locked)
+	require.NoError(t, err)
+
+	// The download at the locked URL changed since it was locked.
+	require.NoError(t, os.WriteFile(archive, []byte("republished contents"), 0644))
+	_, err = verifyIntegrity("@apexlang/codegen", archive, locked)
+	assert.ErrorContains(t, err, "integrity check failed for @apexlang/codegen")
+}
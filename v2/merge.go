@@ -0,0 +1,221 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// How a target's output is written to its file.
+const (
+	// ModeReplace writes the generated output as the whole file.
+	ModeReplace = "replace"
+	// ModeMerge deep merges the generated JSON or YAML into the
+	// existing file, keeping keys that were not generated.
+	ModeMerge = "merge"
+)
+
+// checkMode fails for unknown modes and for merging
+// into files that are not JSON or YAML.
+func checkMode(filename string, target Target) error {
+	switch target.Mode {
+	case "", ModeReplace:
+		return nil
+	case ModeMerge:
+		if mergeFormat(filename) == "" {
+			return fmt.Errorf("mode merge requires a .json, .yaml, or .yml file, not %s", filename)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown mode %q for %s; use replace or merge", target.Mode, filename)
+}
+
+func mergeFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+	return ""
+}
+
+// mergeOutput merges generated source into the existing file, if there
+// is one. Objects are merged key by key, keeping the order and
+// comments of the existing file. Any other value that differs is
+// replaced by the generated one and reported as a conflict.
+func mergeOutput(filename, source string) (string, []string, error) {
+	existing, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return source, nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	var dst, src yaml.Node
+	if err = yaml.Unmarshal(existing, &dst); err != nil {
+		return "", nil, fmt.Errorf("could not parse %s to merge into it: %w", filename, err)
+	}
+	if err = yaml.Unmarshal([]byte(source), &src); err != nil {
+		return "", nil, fmt.Errorf("could not parse generated %s to merge it: %w", filename, err)
+	}
+	if len(dst.Content) == 0 {
+		return source, nil, nil
+	}
+	if len(src.Content) == 0 {
+		return string(existing), nil, nil
+	}
+
+	var conflicts []string
+	if err = mergeNodes(dst.Content[0], src.Content[0], "", &conflicts); err != nil {
+		return "", nil, err
+	}
+
+	var buf bytes.Buffer
+	if mergeFormat(filename) == "json" {
+		writeJSONNode(&buf, dst.Content[0], "")
+		buf.WriteByte('\n')
+	} else {
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err = enc.Encode(&dst); err != nil {
+			return "", nil, err
+		}
+		enc.Close()
+	}
+	return buf.String(), conflicts, nil
+}
+
+// mergeNodes merges src into dst, recording the path
+// of each value in dst that src replaced.
+func mergeNodes(dst, src *yaml.Node, path string, conflicts *[]string) error {
+	if dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			key, value := src.Content[i], src.Content[i+1]
+			found := false
+			for j := 0; j+1 < len(dst.Content); j += 2 {
+				if dst.Content[j].Value == key.Value {
+					if err := mergeNodes(dst.Content[j+1], value, mergePath(path, key.Value), conflicts); err != nil {
+						return err
+					}
+					found = true
+					break
+				}
+			}
+			if !found {
+				dst.Content = append(dst.Content, key, value)
+			}
+		}
+		return nil
+	}
+
+	var before, after interface{}
+	if err := dst.Decode(&before); err != nil {
+		return err
+	}
+	if err := src.Decode(&after); err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(before, after) {
+		if path == "" {
+			path = "the document"
+		}
+		*conflicts = append(*conflicts, path)
+		comment := dst.HeadComment
+		*dst = *src
+		if dst.HeadComment == "" {
+			dst.HeadComment = comment
+		}
+	}
+	return nil
+}
+
+func mergePath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// writeJSONNode writes a node parsed from JSON back out as JSON,
+// indented like json.MarshalIndent but keeping the order of keys.
+func writeJSONNode(buf *bytes.Buffer, node *yaml.Node, indent string) {
+	inner := indent + "  "
+	switch node.Kind {
+	case yaml.MappingNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("{}")
+			return
+		}
+		buf.WriteString("{\n")
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			buf.WriteString(inner)
+			key, _ := json.Marshal(node.Content[i].Value)
+			buf.Write(key)
+			buf.WriteString(": ")
+			writeJSONNode(buf, node.Content[i+1], inner)
+			if i+2 < len(node.Content) {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(indent + "}")
+	case yaml.SequenceNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("[]")
+			return
+		}
+		buf.WriteString("[\n")
+		for i, item := range node.Content {
+			buf.WriteString(inner)
+			writeJSONNode(buf, item, inner)
+			if i+1 < len(node.Content) {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(indent + "]")
+	case yaml.AliasNode:
+		writeJSONNode(buf, node.Alias, indent)
+	default:
+		switch node.ShortTag() {
+		case "!!null":
+			buf.WriteString("null")
+		case "!!bool", "!!int", "!!float":
+			// Values such as YAML's 0x1F or .inf are not valid JSON.
+			if _, err := strconv.ParseFloat(node.Value, 64); err == nil || node.ShortTag() == "!!bool" {
+				buf.WriteString(node.Value)
+				return
+			}
+			fallthrough
+		default:
+			value, _ := json.Marshal(node.Value)
+			buf.Write(value)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultLocale is used for any message missing from the selected catalog.
+const defaultLocale = "en"
+
+//go:embed locales/*.yaml
+var embeddedLocales embed.FS
+
+var (
+	catalogsMu   sync.RWMutex
+	catalogs     = map[string]map[string]string{}
+	localeOnce   sync.Once
+	activeLocale string
+)
+
+func init() {
+	entries, err := embeddedLocales.ReadDir("locales")
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		data, err := embeddedLocales.ReadFile("locales/" + entry.Name())
+		if err != nil {
+			continue
+		}
+		loadCatalog(strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())), data)
+	}
+}
+
+// AddMessages registers or overrides messages for a locale. This allows
+// applications embedding the CLI to ship additional catalogs.
+func AddMessages(locale string, messages map[string]string) {
+	locale = normalizeLocale(locale)
+	catalogsMu.Lock()
+	defer catalogsMu.Unlock()
+	catalog, ok := catalogs[locale]
+	if !ok {
+		catalog = make(map[string]string, len(messages))
+		catalogs[locale] = catalog
+	}
+	for key, message := range messages {
+		catalog[key] = message
+	}
+}
+
+func loadCatalog(locale string, data []byte) error {
+	var messages map[string]string
+	if err := yaml.Unmarshal(data, &messages); err != nil {
+		return err
+	}
+	AddMessages(locale, messages)
+	return nil
+}
+
+// loadInstalledCatalogs loads catalogs from the locales
+// directory of the Apex home directory, if it exists.
+func loadInstalledCatalogs() {
+	homeDir, err := apexHomeDir()
+	if err != nil {
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(homeDir, "locales", "*.yaml"))
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		if err = loadCatalog(strings.TrimSuffix(filepath.Base(file), ".yaml"), data); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load message catalog %s: %v\n", file, err)
+		}
+	}
+}
+
+// currentLocale selects the locale from APEX_LANG or
+// the standard POSIX locale environment variables.
+func currentLocale() string {
+	localeOnce.Do(func() {
+		loadInstalledCatalogs()
+		activeLocale = defaultLocale
+		for _, env := range []string{"APEX_LANG", "LC_ALL", "LC_MESSAGES", "LANG"} {
+			value := os.Getenv(env)
+			if value == "" || value == "C" || value == "POSIX" {
+				continue
+			}
+			activeLocale = normalizeLocale(value)
+			break
+		}
+	})
+	return activeLocale
+}
+
+// normalizeLocale converts values like "pt_BR.UTF-8" to "pt-br".
+func normalizeLocale(locale string) string {
+	if idx := strings.IndexAny(locale, ".@"); idx != -1 {
+		locale = locale[:idx]
+	}
+	return strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+}
+
+// msg returns the formatted message for key in the current locale,
+// falling back to the language without its region, then English.
+func msg(key string, args ...interface{}) string {
+	locale := currentLocale()
+	candidates := []string{locale}
+	if idx := strings.Index(locale, "-"); idx != -1 {
+		candidates = append(candidates, locale[:idx])
+	}
+	candidates = append(candidates, defaultLocale)
+
+	format := key
+	catalogsMu.RLock()
+	for _, candidate := range candidates {
+		if message, ok := catalogs[candidate][key]; ok {
+			format = message
+			break
+		}
+	}
+	catalogsMu.RUnlock()
+
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
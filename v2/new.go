@@ -21,6 +21,9 @@ import (
 	"path/filepath"
 )
 
+// Template describes a project template and the
+// variables it prompts for. It is read from a template's
+// .template file.
 type Template struct {
 	Name         string     `json:"name" yaml:"name"`
 	Description  string     `json:"description" yaml:"description"`
@@ -28,11 +31,16 @@ type Template struct {
 	SpecLocation string     `json:"specLocation" yaml:"specLocation"`
 }
 
+// Variable is a value substituted into a project template.
 type Variable struct {
 	Name        string `json:"name" yaml:"name"`
 	Description string `json:"description" yaml:"description"`
 	Prompt      string `json:"prompt" yaml:"prompt"`
 	Default     string `json:"default" yaml:"default"`
+	// DefaultFrom lists built-in sources, such as git.user.name,
+	// env.AUTHOR, github.user, or os.user, tried in order before
+	// prompting. The first that is set is used without a prompt.
+	DefaultFrom string `json:"defaultFrom,omitempty" yaml:"defaultFrom,omitempty"`
 	Required    bool   `json:"required" yaml:"required"`
 	Loop        bool   `json:"loop" yaml:"loop"`
 }
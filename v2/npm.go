@@ -0,0 +1,236 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// How the requested release of a module was specified.
+const (
+	RequestedTag     = "tag"
+	RequestedVersion = "version"
+	RequestedRange   = "range"
+)
+
+// npmPackument is the abbreviated package metadata document
+// returned by NPM registries.
+type npmPackument struct {
+	Name     string                       `json:"name"`
+	DistTags map[string]string            `json:"dist-tags"`
+	Versions map[string]npmPackageVersion `json:"versions"`
+}
+
+type npmPackageVersion struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Dist    struct {
+		Tarball   string `json:"tarball"`
+		Integrity string `json:"integrity"`
+		Shasum    string `json:"shasum"`
+	} `json:"dist"`
+}
+
+// integrity returns the subresource integrity of the
+// tarball, falling back to its sha1 shasum.
+func (v *npmPackageVersion) integrity() string {
+	if v.Dist.Integrity != "" {
+		return v.Dist.Integrity
+	}
+	return shasumIntegrity(v.Dist.Shasum)
+}
+
+// fetchPackument retrieves the metadata for an NPM package
+// including its dist-tags and published versions, trying each
+// mirror in turn when the registry fails.
+func fetchPackument(client *http.Client, name string) (*npmPackument, error) {
+	config := loadNPMConfig()
+	registries := append([]string{config.registryFor(name)}, config.mirrorsFor(name)...)
+	var err error
+	for i, registry := range registries {
+		if i > 0 {
+			fmt.Printf("Could not get NPM package info for %s, trying mirror %s: %v\n", name, registry, err)
+		}
+		var p *npmPackument
+		if p, err = fetchPackumentFrom(client, config, registry, name); err == nil {
+			return p, nil
+		}
+	}
+	return nil, err
+}
+
+// fetchPackumentFrom retrieves the metadata for an NPM package from registry.
+func fetchPackumentFrom(client *http.Client, config *npmConfig, registry, name string) (*npmPackument, error) {
+	// Scoped packages must have their slash escaped.
+	escaped := strings.Replace(name, "/", "%2f", 1)
+	req, err := http.NewRequest(http.MethodGet, registry+"/"+escaped, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.npm.install-v1+json; q=1.0, application/json; q=0.8")
+	config.authorize(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return nil, fmt.Errorf("could not get NPM package info for %s: got status %d; run apex login or configure credentials in .npmrc or %s",
+			name, resp.StatusCode, NPMTokenEnv)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("could not get NPM package info for %s: got status %d, expected 200", name, resp.StatusCode)
+	}
+
+	var p npmPackument
+	if err = json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return nil, fmt.Errorf("could not decode NPM package info: %w", err)
+	}
+
+	return &p, nil
+}
+
+// resolve finds the version for a dist-tag, exact version, or semver
+// range, such as ^1.2, and reports which of these was requested. An
+// empty tagOrVersion is the latest tag. Unless client is nil, tags are
+// resolved with the registry's current dist-tags.
+func (p *npmPackument) resolve(client *http.Client, tagOrVersion string) (*npmPackageVersion, string, error) {
+	if tagOrVersion == "" {
+		tagOrVersion = "latest"
+	}
+	if client != nil && isDistTag(tagOrVersion) {
+		p.updateDistTags(client)
+	}
+
+	requested := RequestedVersion
+	version := tagOrVersion
+	if v, ok := p.DistTags[tagOrVersion]; ok {
+		requested = RequestedTag
+		version = v
+	}
+
+	if _, ok := p.Versions[version]; !ok && requested == RequestedVersion && isSemverRange(version) {
+		versions := make([]string, 0, len(p.Versions))
+		for v := range p.Versions {
+			versions = append(versions, v)
+		}
+		best, found, err := maxSatisfying(versions, version)
+		if err != nil {
+			return nil, "", err
+		}
+		if !found {
+			return nil, "", fmt.Errorf("%s has no version matching %q", p.Name, version)
+		}
+		requested = RequestedRange
+		version = best
+	}
+
+	v, ok := p.Versions[version]
+	if !ok {
+		tags := make([]string, 0, len(p.DistTags))
+		for tag := range p.DistTags {
+			tags = append(tags, tag)
+		}
+		return nil, "", fmt.Errorf("%s has no version or dist-tag %q (available dist-tags: %s)",
+			p.Name, tagOrVersion, strings.Join(tags, ", "))
+	}
+
+	return &v, requested, nil
+}
+
+// fetchDistTags retrieves the current dist-tags of an NPM package from
+// the registry's dist-tags endpoint, which unlike package metadata is
+// not served from caches that may hold an older mapping.
+func fetchDistTags(client *http.Client, name string) (map[string]string, error) {
+	escaped := strings.Replace(name, "/", "%2f", 1)
+	config := loadNPMConfig()
+	req, err := http.NewRequest(http.MethodGet, config.registryFor(name)+"/-/package/"+escaped+"/dist-tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	config.authorize(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("could not get the dist-tags of %s: got status %d, expected 200", name, resp.StatusCode)
+	}
+
+	var tags map[string]string
+	if err = json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("could not decode the dist-tags of %s: %w", name, err)
+	}
+	return tags, nil
+}
+
+// updateDistTags replaces the dist-tags of p with those the registry's
+// dist-tags endpoint serves, as tags move between releases, keeping
+// the ones in p when the endpoint fails or the metadata does not yet
+// have the version.
+func (p *npmPackument) updateDistTags(client *http.Client) {
+	tags, err := fetchDistTags(client, p.Name)
+	if err != nil {
+		return
+	}
+	if p.DistTags == nil {
+		p.DistTags = make(map[string]string, len(tags))
+	}
+	for tag, version := range tags {
+		if _, ok := p.Versions[version]; ok {
+			p.DistTags[tag] = version
+		}
+	}
+}
+
+// isDistTag reports whether a release names a dist-tag, such as latest,
+// next, or beta, rather than a version or range. NPM does not allow
+// tags that are valid ranges. An empty release is the latest tag.
+func isDistTag(release string) bool {
+	if release == "" {
+		return true
+	}
+	if _, ok := parseSemver(release); ok {
+		return false
+	}
+	_, err := parseSemverRange(release)
+	return err != nil || release == "latest"
+}
+
+// readPackageVersion returns the version declared in a
+// directory's package.json, or an empty string.
+func readPackageVersion(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return ""
+	}
+	var pkg struct {
+		Version string `json:"version"`
+	}
+	if err = json.Unmarshal(data, &pkg); err != nil {
+		return ""
+	}
+	return pkg.Version
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveDistTags(t *testing.T) {
+	withProjectConfig(t, "")
+	t.Setenv("HOME", t.TempDir())
+	requests := 0
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.EscapedPath() != "/-/package/@apexlang%2fcore/dist-tags" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"latest": "1.1.0", "next": "2.0.0-beta.2", "beta": "9.9.9"}`)
+	}))
+	defer registry.Close()
+	t.Setenv("NPM_REGISTRY", registry.URL)
+
+	// Cached or mirrored metadata may have tags that have since moved.
+	packument := func() *npmPackument {
+		p := npmPackument{
+			Name:     "@apexlang/core",
+			DistTags: map[string]string{"latest": "1.0.0", "next": "2.0.0-beta.1", "beta": "2.0.0-beta.1"},
+			Versions: map[string]npmPackageVersion{},
+		}
+		for _, version := range []string{"1.0.0", "1.1.0", "2.0.0-beta.1", "2.0.0-beta.2"} {
+			p.Versions[version] = npmPackageVersion{Name: p.Name, Version: version}
+		}
+		return &p
+	}
+
+	tests := []struct {
+		release   string
+		version   string
+		requested string
+		fetched   bool
+	}{
+		{"", "1.1.0", RequestedTag, true},
+		{"latest", "1.1.0", RequestedTag, true},
+		{"next", "2.0.0-beta.2", RequestedTag, true},
+		// Tags are not moved to versions the metadata does not have.
+		{"beta", "2.0.0-beta.1", RequestedTag, true},
+		{"1.0.0", "1.0.0", RequestedVersion, false},
+		{"^1.0", "1.1.0", RequestedRange, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.release, func(t *testing.T) {
+			requests = 0
+			v, requested, err := packument().resolve(registry.Client(), tc.release)
+			require.NoError(t, err)
+			assert.Equal(t, tc.version, v.Version)
+			assert.Equal(t, tc.requested, requested)
+			assert.Equal(t, tc.fetched, requests > 0)
+		})
+	}
+
+	// Offline, the tags in the metadata are used.
+	requests = 0
+	v, _, err := packument().resolve(nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", v.Version)
+	assert.Zero(t, requests)
+}
+
+func TestIsDistTag(t *testing.T) {
+	for release, tag := range map[string]bool{
+		"":       true,
+		"latest": true,
+		"next":   true,
+		"beta":   true,
+		"1.2.3":  false,
+		"^1.2":   false,
+		"1.x":    false,
+	} {
+		assert.Equal(t, tag, isDistTag(release), release)
+	}
+}
@@ -0,0 +1,326 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bufio"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// NPMTokenEnv is the environment variable holding a bearer token
+// sent to the NPM registry, overriding any token in .npmrc.
+const NPMTokenEnv = "APEX_NPM_TOKEN"
+
+// NPMMirrorsEnv is a comma-separated list of registry mirrors tried,
+// before those in ~/.apex/config.yaml and .apexrc.yaml, when the
+// default registry fails.
+const NPMMirrorsEnv = "APEX_NPM_MIRRORS"
+
+// npmConfig is the subset of .npmrc settings used to
+// reach private registries.
+type npmConfig struct {
+	registry string
+	// scopes maps a scope, such as @myorg, to its registry.
+	scopes map[string]string
+	// auth is keyed by registry URLs without their scheme, such
+	// as //npm.example.com/, the same way npm matches credentials.
+	auth map[string]*npmAuth
+}
+
+type npmAuth struct {
+	token    string
+	basic    string
+	username string
+	password string
+}
+
+func (a *npmAuth) empty() bool {
+	return a.token == "" && a.basic == "" && (a.username == "" || a.password == "")
+}
+
+var (
+	npmrc     *npmConfig
+	npmrcOnce sync.Once
+)
+
+// loadNPMConfig reads the project .npmrc in the working directory
+// and the user's ~/.npmrc, or NPM_CONFIG_USERCONFIG, with project
+// settings taking precedence, then the tokens stored with apex login.
+func loadNPMConfig() *npmConfig {
+	npmrcOnce.Do(func() {
+		npmrc = &npmConfig{
+			scopes: map[string]string{},
+			auth:   map[string]*npmAuth{},
+		}
+		userConfig := os.Getenv("NPM_CONFIG_USERCONFIG")
+		if userConfig == "" {
+			if home, err := homedir.Dir(); err == nil {
+				userConfig = filepath.Join(home, ".npmrc")
+			}
+		}
+		for _, filename := range []string{userConfig, ".npmrc"} {
+			if filename != "" {
+				npmrc.read(filename)
+			}
+		}
+		// Tokens stored with apex login are used for registries
+		// .npmrc has no credentials for.
+		creds := readCredentials()
+		for _, prefix := range creds.registries() {
+			if auth, ok := npmrc.auth[prefix]; ok && !auth.empty() {
+				continue
+			}
+			if token := creds.token(prefix); token != "" {
+				npmrc.auth[prefix] = &npmAuth{token: token}
+			}
+		}
+	})
+	return npmrc
+}
+
+// read applies the settings in filename over those already read.
+// Missing files are ignored, as they are by npm.
+func (n *npmConfig) read(filename string) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = os.ExpandEnv(strings.Trim(strings.TrimSpace(value), `"'`))
+
+		switch {
+		case key == "registry":
+			n.registry = strings.TrimRight(value, "/")
+		case strings.HasPrefix(key, "@") && strings.HasSuffix(key, ":registry"):
+			n.scopes[strings.TrimSuffix(key, ":registry")] = strings.TrimRight(value, "/")
+		case strings.HasPrefix(key, "//"):
+			i := strings.LastIndex(key, ":")
+			if i == -1 {
+				continue
+			}
+			prefix, setting := key[:i], key[i+1:]
+			if !strings.HasSuffix(prefix, "/") {
+				prefix += "/"
+			}
+			auth, ok := n.auth[prefix]
+			if !ok {
+				auth = &npmAuth{}
+				n.auth[prefix] = auth
+			}
+			switch setting {
+			case "_authToken":
+				auth.token = value
+			case "_auth":
+				auth.basic = value
+			case "username":
+				auth.username = value
+			case "_password":
+				// Passwords are stored base64 encoded.
+				if password, err := base64.StdEncoding.DecodeString(value); err == nil {
+					auth.password = string(password)
+				}
+			}
+		}
+	}
+}
+
+// defaultRegistry is the public NPM registry.
+const defaultRegistry = "https://registry.npmjs.org"
+
+// registryFor returns the registry that serves the package name.
+// Scoped packages use the registry of their scope, if one is set.
+// Otherwise NPM_REGISTRY overrides the default registry from .npmrc,
+// which overrides the registry in .apexrc.yaml.
+func (n *npmConfig) registryFor(name string) string {
+	if i := strings.Index(name, "/"); i != -1 && strings.HasPrefix(name, "@") {
+		if registry, ok := n.scopeRegistries()[name[:i]]; ok {
+			return registry
+		}
+	}
+	if registry := n.userRegistry(); registry != "" {
+		return registry
+	}
+	if project, err := readProjectConfig(); err == nil && project.Registry != "" {
+		return strings.TrimRight(project.Registry, "/")
+	}
+	return defaultRegistry
+}
+
+// userRegistry returns the default registry set by NPM_REGISTRY or
+// .npmrc, or an empty string when neither sets one.
+func (n *npmConfig) userRegistry() string {
+	if registry, ok := os.LookupEnv("NPM_REGISTRY"); ok {
+		return strings.TrimRight(registry, "/")
+	}
+	return n.registry
+}
+
+// authorize adds the credentials for the request's URL. Credentials
+// are only sent to the registries they were configured for.
+func (n *npmConfig) authorize(req *http.Request) {
+	target := "//" + req.URL.Host + req.URL.Path
+
+	var auth *npmAuth
+	longest := 0
+	for prefix, a := range n.auth {
+		if strings.HasPrefix(target, prefix) && len(prefix) > longest {
+			auth, longest = a, len(prefix)
+		}
+	}
+
+	if token := os.Getenv(NPMTokenEnv); token != "" && n.isRegistryHost(req.URL.Host) {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return
+	}
+	if auth == nil {
+		return
+	}
+	switch {
+	case auth.token != "":
+		req.Header.Set("Authorization", "Bearer "+auth.token)
+	case auth.basic != "":
+		req.Header.Set("Authorization", "Basic "+auth.basic)
+	case auth.username != "" && auth.password != "":
+		req.SetBasicAuth(auth.username, auth.password)
+	}
+}
+
+// isRegistryHost reports whether host serves one of the registries
+// APEX_NPM_TOKEN is sent to: those of NPM_REGISTRY, .npmrc, and
+// ~/.apex/config.yaml. The registries of .apexrc.yaml are not since
+// it is committed with a repository, which could otherwise collect
+// the token by naming its own host.
+func (n *npmConfig) isRegistryHost(host string) bool {
+	registry := n.userRegistry()
+	if registry == "" {
+		registry = defaultRegistry
+	}
+	registries := []string{registry}
+	for _, registry := range n.scopes {
+		registries = append(registries, registry)
+	}
+	if user, err := readUserConfig(); err == nil {
+		for _, registry := range user.Scopes {
+			registries = append(registries, registry)
+		}
+	}
+	for _, registry := range registries {
+		if u, err := url.Parse(registry); err == nil && u.Host == host {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeRegistries returns the registry of each scope, such as @myorg,
+// from .npmrc, then the scopes of ~/.apex/config.yaml, and then those
+// of .apexrc.yaml, so that modules under a private scope resolve
+// against an internal registry while other modules do not.
+func (n *npmConfig) scopeRegistries() map[string]string {
+	registries := make(map[string]string, len(n.scopes))
+	add := func(scopes map[string]string) {
+		for scope, registry := range scopes {
+			if !strings.HasPrefix(scope, "@") {
+				scope = "@" + scope
+			}
+			scope = strings.TrimSuffix(scope, "/")
+			if _, ok := registries[scope]; !ok && registry != "" {
+				registries[scope] = strings.TrimRight(registry, "/")
+			}
+		}
+	}
+	add(n.scopes)
+	if user, err := readUserConfig(); err == nil {
+		add(user.Scopes)
+	}
+	if project, err := readProjectConfig(); err == nil {
+		add(project.Scopes)
+	}
+	return registries
+}
+
+// mirrors returns the registry mirrors from APEX_NPM_MIRRORS, then
+// ~/.apex/config.yaml, and then .apexrc.yaml, without duplicates.
+func (n *npmConfig) mirrors() []string {
+	var mirrors []string
+	seen := make(map[string]struct{})
+	add := func(values []string) {
+		for _, mirror := range values {
+			mirror = strings.TrimRight(strings.TrimSpace(mirror), "/")
+			if _, ok := seen[mirror]; !ok && mirror != "" {
+				seen[mirror] = struct{}{}
+				mirrors = append(mirrors, mirror)
+			}
+		}
+	}
+	if env := os.Getenv(NPMMirrorsEnv); env != "" {
+		add(strings.Split(env, ","))
+	}
+	if user, err := readUserConfig(); err == nil {
+		add(user.Mirrors)
+	}
+	if project, err := readProjectConfig(); err == nil {
+		add(project.Mirrors)
+	}
+	return mirrors
+}
+
+// mirrorsFor returns the mirrors to try for a package when its registry
+// fails. Packages in scopes with their own registry are not mirrored.
+func (n *npmConfig) mirrorsFor(name string) []string {
+	if i := strings.Index(name, "/"); i != -1 && strings.HasPrefix(name, "@") {
+		if _, ok := n.scopeRegistries()[name[:i]]; ok {
+			return nil
+		}
+	}
+	return n.mirrors()
+}
+
+// mirrorURLs returns the URLs of a tarball on each mirror when it is
+// served by the default registry, such as https://registry.npmjs.org.
+func (n *npmConfig) mirrorURLs(tarballURL string) []string {
+	registry := n.registryFor("")
+	if !strings.HasPrefix(tarballURL, registry+"/") {
+		return nil
+	}
+	mirrors := n.mirrors()
+	urls := make([]string, len(mirrors))
+	for i, mirror := range mirrors {
+		urls[i] = mirror + strings.TrimPrefix(tarballURL, registry)
+	}
+	return urls
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withProjectConfig runs a test in a repository holding the given
+// .apexrc.yaml, with a home directory of its own.
+func withProjectConfig(t *testing.T, apexrc string) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, ".git"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ProjectConfigFile), []byte(apexrc), 0644))
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	projectConfigOnce = sync.Once{}
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		projectConfigOnce = sync.Once{}
+	})
+	t.Setenv(HomeEnv, filepath.Join(dir, "home"))
+	if registry, ok := os.LookupEnv("NPM_REGISTRY"); ok {
+		os.Unsetenv("NPM_REGISTRY")
+		t.Cleanup(func() { os.Setenv("NPM_REGISTRY", registry) })
+	}
+}
+
+func TestNPMTokenNotSentToProjectRegistries(t *testing.T) {
+	withProjectConfig(t, `registry: https://project.example.com
+scopes:
+  "@project": https://scope.example.com
+`)
+	t.Setenv(NPMTokenEnv, "secret")
+	n := &npmConfig{
+		scopes: map[string]string{"@trusted": "https://npm.example.com"},
+		auth:   map[string]*npmAuth{},
+	}
+
+	// The registries of .apexrc.yaml are used, only without the token.
+	assert.Equal(t, "https://project.example.com", n.registryFor("@apexlang/core"))
+	assert.Equal(t, "https://scope.example.com", n.registryFor("@project/module"))
+
+	for _, test := range []struct {
+		url        string
+		authorized bool
+	}{
+		{"https://project.example.com/@apexlang%2fcore", false},
+		{"https://scope.example.com/@project%2fmodule", false},
+		{"https://registry.npmjs.org/@apexlang%2fcore", true},
+		{"https://npm.example.com/@trusted%2fmodule", true},
+	} {
+		req, err := http.NewRequest(http.MethodGet, test.url, nil)
+		require.NoError(t, err)
+		n.authorize(req)
+		assert.Equal(t, test.authorized, req.Header.Get("Authorization") != "", test.url)
+	}
+}
+
+func TestNPMTokenSentToUserRegistry(t *testing.T) {
+	withProjectConfig(t, "registry: https://project.example.com\n")
+	t.Setenv(NPMTokenEnv, "secret")
+	t.Setenv("NPM_REGISTRY", "https://npm.example.com/")
+	n := &npmConfig{scopes: map[string]string{}, auth: map[string]*npmAuth{}}
+
+	req, err := http.NewRequest(http.MethodGet, "https://npm.example.com/@apexlang%2fcore", nil)
+	require.NoError(t, err)
+	n.authorize(req)
+	assert.Equal(t, "Bearer secret", req.Header.Get("Authorization"))
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// OfflineEnv enables offline installs like --offline when set to
+// a true value such as 1, for builds without network access.
+const OfflineEnv = "APEX_OFFLINE"
+
+// errOffline is returned when an offline install needs the network.
+var errOffline = errors.New("not available offline")
+
+// offline returns whether installs must only use the caches of
+// previously downloaded archives and registry metadata.
+func (c *InstallCmd) offline() bool {
+	if c.Offline {
+		return true
+	}
+	offline, _ := strconv.ParseBool(os.Getenv(OfflineEnv))
+	return offline
+}
+
+// downloadCacheDir holds archives by the hash of their URL.
+func downloadCacheDir(homeDir string) string {
+	return filepath.Join(homeDir, "cache", "downloads")
+}
+
+// metadataCacheDir holds the NPM registry metadata of
+// each package by name.
+func metadataCacheDir(homeDir string) string {
+	return filepath.Join(homeDir, "cache", "registry")
+}
+
+// cachePath returns where the download of url is cached in dir.
+func cachePath(dir, url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(dir, hex.EncodeToString(sum[:]))
+}
+
+// cachedDownload returns the cached download of url
+// instead of fetching it when offline.
+func (c *InstallCmd) cachedDownload(url, module string) (string, error) {
+	cached := cachePath(downloadCacheDir(c.homeDir), url)
+	if fi, err := os.Stat(cached); err != nil || fi.IsDir() {
+		return "", fmt.Errorf("%s is %w: %s has not been downloaded before; install it once with network access", module, errOffline, url)
+	}
+	c.summary.add(func(s *Summary) { s.CacheHits++ })
+	return cached, nil
+}
+
+// saveDownload copies a download into the cache so that it can
+// be installed offline later. Failing to do so is not an error
+// since the install itself succeeded.
+func (c *InstallCmd) saveDownload(url, archive string) {
+	dir := downloadCacheDir(c.homeDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	src, err := os.Open(archive)
+	if err != nil {
+		return
+	}
+	defer src.Close()
+	dst, err := os.CreateTemp(dir, "save-*")
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(dst.Name(), cachePath(dir, url))
+	}
+	if err != nil {
+		os.Remove(dst.Name())
+	}
+}
+
+// packument returns the registry metadata for an NPM package, which
+// is cached when fetched and read from the cache when offline.
+func (c *InstallCmd) packument(name string) (*npmPackument, error) {
+	path := filepath.Join(metadataCacheDir(c.homeDir), strings.Replace(name, "/", "%2f", 1)+".json")
+	if c.offline() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("NPM package info for %s is %w: install it once with network access", name, errOffline)
+		}
+		var p npmPackument
+		if err = json.Unmarshal(data, &p); err != nil {
+			return nil, fmt.Errorf("could not decode cached NPM package info for %s: %w", name, err)
+		}
+		return &p, nil
+	}
+
+	p, err := fetchPackument(&c.netClient, name)
+	if err != nil {
+		return nil, err
+	}
+	if data, err := json.Marshal(p); err == nil {
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+			os.WriteFile(path, data, 0644)
+		}
+	}
+	return p, nil
+}
+
+// checkOnline fails fast when an install from location would need
+// network access while offline.
+func (c *InstallCmd) checkOnline(location string) error {
+	if !c.offline() {
+		return nil
+	}
+	return fmt.Errorf("resolving %s is %w; install it from the lockfile with --locked or use an NPM package", location, errOffline)
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// encodeOutput applies the target's line ending and encoding
+// options to formatted source, returning the bytes to write.
+// An empty lineEndings leaves line endings as generated and an
+// empty encoding writes UTF-8 without a byte order mark.
+func encodeOutput(source, lineEndings, encoding string) ([]byte, error) {
+	source, err := convertLineEndings(source, lineEndings)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(encoding) {
+	case "", "utf-8", "utf8":
+		return []byte(source), nil
+	case "utf-8-bom", "utf8-bom":
+		return append([]byte{0xEF, 0xBB, 0xBF}, source...), nil
+	case "utf-16le", "utf16le":
+		return encodeUTF16(source, false), nil
+	case "utf-16be", "utf16be":
+		return encodeUTF16(source, true), nil
+	case "latin1", "latin-1", "iso-8859-1":
+		return encodeSingleByte(source, 0xFF, encoding)
+	case "ascii", "us-ascii":
+		return encodeSingleByte(source, 0x7F, encoding)
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+}
+
+func convertLineEndings(source, lineEndings string) (string, error) {
+	switch strings.ToLower(lineEndings) {
+	case "":
+		return source, nil
+	case "lf":
+		return strings.ReplaceAll(source, "\r\n", "\n"), nil
+	case "crlf":
+		source = strings.ReplaceAll(source, "\r\n", "\n")
+		return strings.ReplaceAll(source, "\n", "\r\n"), nil
+	case "platform":
+		if runtime.GOOS == "windows" {
+			return convertLineEndings(source, "crlf")
+		}
+		return convertLineEndings(source, "lf")
+	default:
+		return "", fmt.Errorf("unsupported line endings %q (expected lf, crlf, or platform)", lineEndings)
+	}
+}
+
+func encodeUTF16(source string, bigEndian bool) []byte {
+	units := utf16.Encode([]rune(source))
+	buf := make([]byte, 0, 2+len(units)*2)
+	// Write a byte order mark so editors can detect the encoding.
+	units = append([]uint16{0xFEFF}, units...)
+	for _, u := range units {
+		if bigEndian {
+			buf = append(buf, byte(u>>8), byte(u))
+		} else {
+			buf = append(buf, byte(u), byte(u>>8))
+		}
+	}
+	return buf
+}
+
+func encodeSingleByte(source string, max rune, encoding string) ([]byte, error) {
+	buf := make([]byte, 0, len(source))
+	for _, r := range source {
+		if r > max {
+			return nil, fmt.Errorf("character %q cannot be represented in %s", r, encoding)
+		}
+		buf = append(buf, byte(r))
+	}
+	return buf, nil
+}
+
+// reencodeFile rewrites a file that was written as UTF-8 with the
+// target's line ending and encoding options. This is used after
+// CLI-based formatters have run since they expect UTF-8 input.
+func reencodeFile(filename string, target Target) error {
+	if target.LineEndings == "" && target.Encoding == "" {
+		return nil
+	}
+
+	stat, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	source, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	data, err := encodeOutput(string(source), target.LineEndings, target.Encoding)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(data, source) {
+		return nil
+	}
+
+	return os.WriteFile(filename, data, stat.Mode())
+}
+
+// restoreModTime sets the modification time of filename back to
+// modTime if its contents are the same as previous.
+func restoreModTime(filename string, previous []byte, modTime time.Time) error {
+	data, err := os.ReadFile(filename)
+	if err != nil || !bytes.Equal(data, previous) {
+		return err
+	}
+	return os.Chtimes(filename, time.Now(), modTime)
+}
+
+// appendFile adds data to the end of filename, creating it if needed.
+func appendFile(filename string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, perm)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
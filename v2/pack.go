@@ -0,0 +1,179 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type PackCmd struct {
+	Dir    string `arg:"" help:"The module directory to pack." type:"existingdir" default:"."`
+	Output string `help:"The tarball to write. Defaults to <name>-<version>.tgz."`
+}
+
+// packModTime is used for all tarball entries so
+// that packing the same contents is reproducible.
+var packModTime = time.Date(1985, time.October, 26, 8, 15, 0, 0, time.UTC)
+
+func (c *PackCmd) Run(ctx *Context) error {
+	dir := filepath.Clean(c.Dir)
+
+	// Build the module the same way install would
+	// when the dist directory does not exist.
+	if _, err := os.Stat(filepath.Join(dir, "dist")); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		fmt.Printf("Building %s...\n", dir)
+		if err = buildModule(dir, false); err != nil {
+			return err
+		}
+	}
+
+	packageJSONBytes, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return err
+	}
+	var pkg struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	if err = json.Unmarshal(packageJSONBytes, &pkg); err != nil {
+		return err
+	}
+	if pkg.Name == "" {
+		return fmt.Errorf("package.json in %s does not contain a name", dir)
+	}
+
+	output := c.Output
+	if output == "" {
+		name := strings.ReplaceAll(strings.TrimPrefix(pkg.Name, "@"), "/", "-")
+		output = fmt.Sprintf("%s-%s.tgz", name, pkg.Version)
+	}
+
+	// The tarball is written to a temporary file renamed once it is
+	// complete. Both are skipped since they may be in the module,
+	// such as with the default output when packing the working
+	// directory.
+	f, err := os.CreateTemp(filepath.Dir(output), ".apex-pack-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+	skip := make(map[string]struct{}, 2)
+	for _, path := range []string{output, f.Name()} {
+		if abs, err := filepath.Abs(path); err == nil {
+			skip[abs] = struct{}{}
+		}
+	}
+
+	gzw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gzw)
+
+	fmt.Printf("Packing %s@%s\n", pkg.Name, pkg.Version)
+	var fileCount int
+	var totalSize int64
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	files, err := loadPackageFiles(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if isExcludedModuleEntry(entry.Name()) {
+			continue
+		}
+		if err = filepath.Walk(filepath.Join(dir, entry.Name()), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			relPath, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			name := filepath.ToSlash(relPath)
+			if info.IsDir() && files.skipDir(name) {
+				return filepath.SkipDir
+			}
+			if !info.Mode().IsRegular() || !files.includes(name) {
+				return nil
+			}
+			if abs, err := filepath.Abs(path); err == nil {
+				if _, ok := skip[abs]; ok {
+					return nil
+				}
+			}
+			if err = tw.WriteHeader(&tar.Header{
+				Name:     "package/" + name,
+				Mode:     int64(info.Mode().Perm()),
+				Size:     info.Size(),
+				ModTime:  packModTime,
+				Typeflag: tar.TypeReg,
+			}); err != nil {
+				return err
+			}
+			src, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+			if _, err = io.Copy(tw, src); err != nil {
+				return err
+			}
+
+			fmt.Printf("%8d %s\n", info.Size(), name)
+			fileCount++
+			totalSize += info.Size()
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	if err = gzw.Close(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	// Temporary files are only readable by their owner.
+	if err = os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	if err = os.Rename(f.Name(), output); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %s (%d files, %d bytes unpacked)\n", output, fileCount, totalSize)
+	return nil
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// packageFiles selects the files of a module that NPM publishes, from
+// the files field of its package.json and its .npmignore. A nil
+// packageFiles, for modules with neither, selects every file.
+type packageFiles struct {
+	// files are the patterns of the files field, or nil to
+	// include every file not ignored.
+	files *gitIgnore
+	// ignore are the patterns of .npmignore.
+	ignore *gitIgnore
+	// main is the module's entry point, which is always published.
+	main string
+}
+
+// loadPackageFiles reads the files field and .npmignore of the module
+// in dir. It returns nil when the module has neither.
+func loadPackageFiles(dir string) (*packageFiles, error) {
+	var pkg struct {
+		Files []string `json:"files"`
+		Main  string   `json:"main"`
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		// Modules with an invalid package.json fail when they are read.
+		_ = json.Unmarshal(data, &pkg)
+	}
+
+	p := &packageFiles{main: path.Clean(filepath.ToSlash(pkg.Main))}
+	if len(pkg.Files) > 0 {
+		p.files = &gitIgnore{}
+		for _, file := range pkg.Files {
+			// Entries of the files field are relative to the module.
+			negate := strings.HasPrefix(file, "!")
+			file = "/" + strings.TrimPrefix(strings.TrimPrefix(file, "!"), "./")
+			if negate {
+				file = "!" + file
+			}
+			if pattern, ok := compileIgnorePattern(file, ""); ok {
+				p.files.patterns = append(p.files.patterns, pattern)
+			}
+		}
+	}
+
+	data, err = os.ReadFile(filepath.Join(dir, ".npmignore"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		p.ignore = &gitIgnore{}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			if pattern, ok := compileIgnorePattern(scanner.Text(), ""); ok {
+				p.ignore.patterns = append(p.ignore.patterns, pattern)
+			}
+		}
+		if err = scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.files == nil && p.ignore == nil {
+		return nil, nil
+	}
+	return p, nil
+}
+
+// includes reports whether the file at rel, a slash-separated path
+// relative to the module, is published.
+func (p *packageFiles) includes(rel string) bool {
+	if p == nil || p.always(rel) {
+		return true
+	}
+	if p.files != nil && !listed(p.files, rel) {
+		return false
+	}
+	return !matchesPath(p.ignore, rel)
+}
+
+// skipDir reports whether nothing in the directory at rel is published
+// because .npmignore ignores it.
+func (p *packageFiles) skipDir(rel string) bool {
+	return p != nil && p.ignore != nil && matchesPath(p.ignore, rel+"/")
+}
+
+// always reports whether NPM publishes a file regardless of the files
+// field and .npmignore.
+func (p *packageFiles) always(rel string) bool {
+	if rel == p.main {
+		return true
+	}
+	if strings.Contains(rel, "/") {
+		return false
+	}
+	if rel == "package.json" || rel == "npm-shrinkwrap.json" {
+		return true
+	}
+	name := strings.ToUpper(rel)
+	return strings.HasPrefix(name, "README") ||
+		strings.HasPrefix(name, "LICENSE") ||
+		strings.HasPrefix(name, "LICENCE")
+}
+
+// listed reports whether the files field lists the file at rel. As in
+// the files field of NPM, a directory lists everything in it and later
+// patterns, such as !dist/*.map, override earlier ones.
+func listed(g *gitIgnore, rel string) bool {
+	included := false
+	for _, pattern := range g.patterns {
+		matched := pattern.re.MatchString(rel) && !pattern.dirOnly
+		for i := strings.Index(rel, "/"); i != -1 && !matched; {
+			matched = pattern.re.MatchString(rel[:i])
+			next := strings.Index(rel[i+1:], "/")
+			if next == -1 {
+				break
+			}
+			i += next + 1
+		}
+		if matched {
+			included = !pattern.negate
+		}
+	}
+	return included
+}
+
+// matchesPath reports whether the patterns match rel or one of its
+// parent directories. A trailing slash marks rel as a directory.
+func matchesPath(g *gitIgnore, rel string) bool {
+	if g == nil {
+		return false
+	}
+	isDir := strings.HasSuffix(rel, "/")
+	rel = strings.TrimSuffix(rel, "/")
+	for i := strings.Index(rel, "/"); i != -1; {
+		if g.match(rel[:i], true) {
+			return true
+		}
+		next := strings.Index(rel[i+1:], "/")
+		if next == -1 {
+			break
+		}
+		i += next + 1
+	}
+	return g.match(rel, isDir)
+}
+
+// copyPackageFiles copies the published files below rel in the module
+// at root to destination.
+func (c *InstallCmd) copyPackageFiles(files *packageFiles, root, rel, destination string) error {
+	source := filepath.Join(root, rel)
+	if files == nil {
+		return c.copyRecursive(source, destination)
+	}
+	return filepath.Walk(source, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if info.IsDir() {
+			if files.skipDir(relPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !files.includes(relPath) {
+			return nil
+		}
+
+		sub, err := filepath.Rel(source, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(destination, sub)
+		if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, info.Mode())
+	})
+}
@@ -0,0 +1,260 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/apexlang/cli/v2/internal/extract"
+	"gopkg.in/yaml.v3"
+)
+
+// InstallPolicy restricts where modules may be installed from. It is
+// read from ~/.apex/policy.yaml, the file named by APEX_POLICY_FILE, or
+// install's --policy-file flag.
+//
+// Patterns match module sources written as npm:<package>,
+// github.com/<org>/<repo>, gitlab.com/<group>/<project>,
+// bitbucket.org/<workspace>/<repo>, git+<url>, or file:<dir>, and download hosts
+// written as host:<hostname>. A * matches any characters. Deny patterns
+// take precedence. When Allow has patterns of a kind, sources or hosts
+// must match one of them.
+//
+// Verifying signatures of downloaded archives is opt-in, enabled by
+// RequireSigned or by configuring who signs modules.
+type InstallPolicy struct {
+	Allow []string `json:"allow,omitempty" yaml:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty" yaml:"deny,omitempty"`
+	// Rego is an optional OPA policy evaluated with the opa CLI. The
+	// query data.apex.install.allow must be true for each source and host.
+	Rego string `json:"rego,omitempty" yaml:"rego,omitempty"`
+	// RequireSigned refuses modules whose downloads do not have a
+	// sigstore bundle (<url>.sigstore.json) or detached signature
+	// (<url>.sig) next to them, verified with the cosign CLI.
+	RequireSigned bool `json:"requireSigned,omitempty" yaml:"requireSigned,omitempty"`
+	// PublicKey verifies signatures made with a key, such as cosign.pub.
+	PublicKey string `json:"publicKey,omitempty" yaml:"publicKey,omitempty"`
+	// CertificateIdentity and CertificateOIDCIssuer are regular
+	// expressions matching the signers of keyless signatures.
+	CertificateIdentity   string `json:"certificateIdentity,omitempty" yaml:"certificateIdentity,omitempty"`
+	CertificateOIDCIssuer string `json:"certificateOidcIssuer,omitempty" yaml:"certificateOidcIssuer,omitempty"`
+	// Extraction limits what downloaded archives may contain.
+	Extraction *ExtractionPolicy `json:"extraction,omitempty" yaml:"extraction,omitempty"`
+
+	file string
+}
+
+// ExtractionPolicy overrides how module archives are extracted. Unset
+// fields keep the defaults: 100000 files, 256MB per file, 1GB in total,
+// links skipped, and modes sanitized to 0644 or 0755.
+type ExtractionPolicy struct {
+	MaxFiles int `json:"maxFiles,omitempty" yaml:"maxFiles,omitempty"`
+	// MaxFileSize and MaxTotalSize are sizes such as 64MB.
+	MaxFileSize  string `json:"maxFileSize,omitempty" yaml:"maxFileSize,omitempty"`
+	MaxTotalSize string `json:"maxTotalSize,omitempty" yaml:"maxTotalSize,omitempty"`
+	// Symlinks is skip, resolve (only links to files within the
+	// module), or error.
+	Symlinks  string `json:"symlinks,omitempty" yaml:"symlinks,omitempty"`
+	KeepModes bool   `json:"keepModes,omitempty" yaml:"keepModes,omitempty"`
+}
+
+// PolicyViolation is returned when an install is not allowed by policy.
+type PolicyViolation struct {
+	Source     string
+	Rule       string
+	PolicyFile string
+}
+
+func (v *PolicyViolation) Error() string {
+	return fmt.Sprintf("installing from %s is not allowed by policy %s (%s)", v.Source, v.PolicyFile, v.Rule)
+}
+
+// loadInstallPolicy reads the policy file, returning nil if
+// no policy applies. An explicitly named file must exist. The
+// policy is always read from the user's home directory, not the
+// one modules are installed in, so that projects installing into
+// their own .apex directory cannot replace it.
+func loadInstallPolicy(policyFile string) (*InstallPolicy, error) {
+	if policyFile == "" {
+		policyFile = os.Getenv("APEX_POLICY_FILE")
+	}
+	explicit := policyFile != ""
+	if !explicit {
+		homeDir, err := apexHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		policyFile = filepath.Join(homeDir, "policy.yaml")
+	}
+
+	data, err := readLocalFile(policyFile, MaxConfigSize)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read policy: %w", err)
+	}
+	var policy InstallPolicy
+	if err = yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("could not parse policy %s: %w", policyFile, err)
+	}
+	policy.file = policyFile
+	if policy.Rego != "" && !filepath.IsAbs(policy.Rego) {
+		policy.Rego = filepath.Join(filepath.Dir(policyFile), policy.Rego)
+	}
+	if policy.PublicKey != "" && !filepath.IsAbs(policy.PublicKey) {
+		policy.PublicKey = filepath.Join(filepath.Dir(policyFile), policy.PublicKey)
+	}
+	return &policy, nil
+}
+
+// extraction returns the policy for extracting archives,
+// which is the default when there is no install policy.
+func (p *InstallPolicy) extraction() (extract.Policy, error) {
+	policy := extract.DefaultPolicy()
+	if p == nil || p.Extraction == nil {
+		return policy, nil
+	}
+	e := p.Extraction
+	if e.MaxFiles > 0 {
+		policy.MaxFiles = e.MaxFiles
+	}
+	var err error
+	if e.MaxFileSize != "" {
+		if policy.MaxFileSize, err = parseByteSize(e.MaxFileSize); err != nil {
+			return policy, fmt.Errorf("policy %s: maxFileSize: %w", p.file, err)
+		}
+	}
+	if e.MaxTotalSize != "" {
+		if policy.MaxTotalSize, err = parseByteSize(e.MaxTotalSize); err != nil {
+			return policy, fmt.Errorf("policy %s: maxTotalSize: %w", p.file, err)
+		}
+	}
+	switch symlinks := extract.SymlinkPolicy(e.Symlinks); symlinks {
+	case "":
+	case extract.SymlinksSkip, extract.SymlinksResolve, extract.SymlinksError:
+		policy.Symlinks = symlinks
+	default:
+		return policy, fmt.Errorf("policy %s: symlinks must be skip, resolve, or error", p.file)
+	}
+	policy.KeepModes = e.KeepModes
+	return policy, nil
+}
+
+// moduleSource returns the policy source for an install location.
+// Modules in a subdirectory of a repository have its source.
+func moduleSource(location string) string {
+	if isRepositoryLocation(location) {
+		repository, _, _ := splitSubdirectory(location)
+		return repository
+	}
+	if strings.HasPrefix(location, "file:") ||
+		strings.HasPrefix(location, gitPrefix) ||
+		isArchiveLocation(location) {
+		return location
+	}
+	return "npm:" + location
+}
+
+// checkSource checks a module source such as npm:@apexlang/core.
+func (p *InstallPolicy) checkSource(source string) error {
+	return p.check(source, "module")
+}
+
+// checkURL checks the host of a download URL.
+func (p *InstallPolicy) checkURL(downloadURL string) error {
+	u, err := url.Parse(downloadURL)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	return p.check("host:"+u.Hostname(), "host")
+}
+
+func (p *InstallPolicy) check(source, kind string) error {
+	if p == nil {
+		return nil
+	}
+
+	for _, pattern := range p.Deny {
+		if policyMatch(pattern, source) {
+			return &PolicyViolation{Source: source, Rule: "denied by " + pattern, PolicyFile: p.file}
+		}
+	}
+
+	isHost := strings.HasPrefix(source, "host:")
+	restricted := false
+	for _, pattern := range p.Allow {
+		if strings.HasPrefix(pattern, "host:") != isHost {
+			continue
+		}
+		restricted = true
+		if policyMatch(pattern, source) {
+			restricted = false
+			break
+		}
+	}
+	if restricted {
+		return &PolicyViolation{Source: source, Rule: "not in the allow list", PolicyFile: p.file}
+	}
+
+	if p.Rego != "" {
+		allowed, err := evalRego(p.Rego, map[string]string{"source": source, "kind": kind})
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return &PolicyViolation{Source: source, Rule: "denied by " + p.Rego, PolicyFile: p.file}
+		}
+	}
+
+	return nil
+}
+
+// policyMatch matches a source against a pattern where * matches
+// any characters, including slashes.
+func policyMatch(pattern, source string) bool {
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+	matched, _ := regexp.MatchString(expr, source)
+	return matched
+}
+
+// evalRego evaluates data.apex.install.allow with the opa CLI.
+func evalRego(regoFile string, input interface{}) (bool, error) {
+	inputJSON, err := json.Marshal(input)
+	if err != nil {
+		return false, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("opa", "eval", "--format", "raw", "--stdin-input",
+		"--data", regoFile, "data.apex.install.allow")
+	cmd.Stdin = bytes.NewReader(inputJSON)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		return false, fmt.Errorf("could not evaluate policy %s with opa: %w: %s", regoFile, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()) == "true", nil
+}
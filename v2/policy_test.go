@@ -0,0 +1,205 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePolicy(t *testing.T, policy string) string {
+	filename := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(filename, []byte(policy), 0644))
+	return filename
+}
+
+func TestInstallPolicy(t *testing.T) {
+	policy, err := loadInstallPolicy(writePolicy(t, `
+allow:
+  - npm:@apexlang/*
+  - github.com/apexlang/*
+  - host:registry.npmjs.org
+  - host:*.github.com
+deny:
+  - npm:@apexlang/untrusted
+`))
+	require.NoError(t, err)
+	require.NotNil(t, policy)
+
+	tests := []struct {
+		location string
+		rule     string
+	}{
+		{"@apexlang/core", ""},
+		{"github.com/apexlang/codegen", ""},
+		{"github.com/apexlang/monorepo//packages/module", ""},
+		{"@apexlang/untrusted", "denied by npm:@apexlang/untrusted"},
+		{"@other/core", "not in the allow list"},
+		{"github.com/other/codegen", "not in the allow list"},
+		{"github.com/other/monorepo//apexlang", "not in the allow list"},
+		{"gitlab.com/apexlang/codegen", "not in the allow list"},
+		{"git+https://github.com/apexlang/codegen.git", "not in the allow list"},
+		{"file:./module", "not in the allow list"},
+		{"https://example.com/apexlang/module.tgz", "not in the allow list"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.location, func(t *testing.T) {
+			assertPolicy(t, policy.checkSource(moduleSource(tc.location)), tc.rule)
+		})
+	}
+
+	hosts := []struct {
+		url  string
+		rule string
+	}{
+		{"https://registry.npmjs.org/@apexlang/core/-/core-1.0.0.tgz", ""},
+		{"https://codeload.github.com/apexlang/codegen/tar.gz/v1.0.0", ""},
+		{"https://registry.npmjs.org.example.com/core-1.0.0.tgz", "not in the allow list"},
+		{"https://example.com/core-1.0.0.tgz", "not in the allow list"},
+		{"http://127.0.0.1:8080/core-1.0.0.tgz", "not in the allow list"},
+	}
+	for _, tc := range hosts {
+		t.Run(tc.url, func(t *testing.T) {
+			assertPolicy(t, policy.checkURL(tc.url), tc.rule)
+		})
+	}
+}
+
+func assertPolicy(t *testing.T, err error, rule string) {
+	t.Helper()
+	if rule == "" {
+		assert.NoError(t, err)
+		return
+	}
+	var violation *PolicyViolation
+	require.True(t, errors.As(err, &violation), "expected a policy violation, got %v", err)
+	assert.Equal(t, rule, violation.Rule)
+}
+
+func TestInstallPolicyDenyOnly(t *testing.T) {
+	policy, err := loadInstallPolicy(writePolicy(t, `
+deny:
+  - github.com/*
+  - host:*.example.com
+`))
+	require.NoError(t, err)
+
+	assert.NoError(t, policy.checkSource(moduleSource("@apexlang/core")))
+	assertPolicy(t, policy.checkSource(moduleSource("github.com/apexlang/codegen")), "denied by github.com/*")
+	assert.NoError(t, policy.checkURL("https://registry.npmjs.org/core-1.0.0.tgz"))
+	assertPolicy(t, policy.checkURL("https://cdn.example.com/core-1.0.0.tgz"), "denied by host:*.example.com")
+}
+
+func TestLoadInstallPolicy(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv(HomeEnv, homeDir)
+	t.Setenv("APEX_POLICY_FILE", "")
+
+	// Without a policy, everything is allowed.
+	policy, err := loadInstallPolicy("")
+	require.NoError(t, err)
+	assert.Nil(t, policy)
+	assert.NoError(t, policy.checkSource("npm:anything"))
+
+	// A policy that was asked for must exist rather than allowing
+	// everything when it is missing.
+	missing := filepath.Join(homeDir, "missing.yaml")
+	_, err = loadInstallPolicy(missing)
+	assert.ErrorContains(t, err, "could not read policy")
+	t.Setenv("APEX_POLICY_FILE", missing)
+	_, err = loadInstallPolicy("")
+	assert.ErrorContains(t, err, "could not read policy")
+
+	t.Setenv("APEX_POLICY_FILE", "")
+	_, err = loadInstallPolicy(writePolicy(t, "allow: [unterminated"))
+	assert.ErrorContains(t, err, "could not parse policy")
+
+	require.NoError(t, os.WriteFile(filepath.Join(homeDir, "policy.yaml"), []byte("deny: [npm:*]\n"), 0644))
+	policy, err = loadInstallPolicy("")
+	require.NoError(t, err)
+	assertPolicy(t, policy.checkSource("npm:@apexlang/core"), "denied by npm:*")
+}
+
+func TestProjectInstallUsesUserPolicy(t *testing.T) {
+	withProjectConfig(t, "")
+	t.Setenv("APEX_POLICY_FILE", "")
+	userHome := os.Getenv(HomeEnv)
+	require.NoError(t, os.MkdirAll(userHome, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(userHome, "policy.yaml"), []byte("deny: [npm:*]\n"), 0644))
+
+	// The repository's own policy would allow everything.
+	projectHome, err := ensureProjectHomeDirectory()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(projectHome, "policy.yaml"), []byte("allow: [\"*\"]\n"), 0644))
+
+	install := InstallCmd{Location: "@apexlang/core", Project: true, Offline: true}
+	assertPolicy(t, install.doRun(&Context{}, projectHome), "denied by npm:*")
+}
+
+func TestInstallPolicyRedirect(t *testing.T) {
+	withProjectConfig(t, "")
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("module"))
+	}))
+	defer target.Close()
+	targetURL, err := url.Parse(target.URL)
+	require.NoError(t, err)
+	targetURL.Host = "localhost:" + targetURL.Port()
+	allowed := httptest.NewServer(http.RedirectHandler(targetURL.String(), http.StatusFound))
+	defer allowed.Close()
+
+	install := InstallCmd{}
+	require.NoError(t, install.createHTTPClient())
+	install.policy, err = loadInstallPolicy(writePolicy(t, "deny: [host:localhost]\n"))
+	require.NoError(t, err)
+	_, err = install.netClient.Get(allowed.URL)
+	assertPolicy(t, err, "denied by host:localhost")
+
+	install.policy = nil
+	resp, err := install.netClient.Get(allowed.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestInstallPolicyExtraction(t *testing.T) {
+	policy, err := loadInstallPolicy(writePolicy(t, `
+extraction:
+  maxFiles: 10
+  maxFileSize: 1MB
+  symlinks: error
+`))
+	require.NoError(t, err)
+	extraction, err := policy.extraction()
+	require.NoError(t, err)
+	assert.Equal(t, 10, extraction.MaxFiles)
+	assert.Equal(t, int64(1<<20), extraction.MaxFileSize)
+	assert.Equal(t, int64(1<<30), extraction.MaxTotalSize)
+	assert.EqualValues(t, "error", extraction.Symlinks)
+
+	policy.Extraction.Symlinks = "follow"
+	_, err = policy.extraction()
+	assert.ErrorContains(t, err, "symlinks must be skip, resolve, or error")
+}
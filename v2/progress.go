@@ -0,0 +1,127 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Install phases reported as progress events.
+const (
+	PhaseResolve  = "resolve"
+	PhaseDownload = "download"
+	PhaseVerify   = "verify"
+	PhaseExtract  = "extract"
+	PhaseBuild    = "build"
+	PhaseCopy     = "copy"
+	PhaseDone     = "done"
+)
+
+// ProgressEvent is a single machine-readable progress update
+// emitted as a line of NDJSON.
+type ProgressEvent struct {
+	Time    time.Time `json:"time"`
+	Phase   string    `json:"phase"`
+	Module  string    `json:"module,omitempty"`
+	Message string    `json:"message,omitempty"`
+	Bytes   int64     `json:"bytes,omitempty"`
+	Total   int64     `json:"total,omitempty"`
+}
+
+// progressReporter writes progress events. A nil reporter
+// discards all events so callers do not need to check.
+type progressReporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	// fn, when set, receives events instead of enc.
+	fn func(ProgressEvent)
+}
+
+// newProgressReporter returns a reporter writing events to stderr, so
+// they are not mixed with the messages commands print on stdout.
+func newProgressReporter(format string) *progressReporter {
+	if format != "json" {
+		return nil
+	}
+	return &progressReporter{enc: json.NewEncoder(os.Stderr)}
+}
+
+func (p *progressReporter) emit(event ProgressEvent) {
+	if p == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fn != nil {
+		p.fn(event)
+		return
+	}
+	p.enc.Encode(event)
+}
+
+func (p *progressReporter) phase(phase, module, message string) {
+	p.emit(ProgressEvent{
+		Phase:   phase,
+		Module:  module,
+		Message: message,
+	})
+}
+
+// downloadReader wraps a reader to emit download events as bytes
+// are read. Events are throttled to avoid flooding consumers.
+func (p *progressReporter) downloadReader(r io.Reader, module string, total int64) io.Reader {
+	if p == nil {
+		return r
+	}
+	return &progressReader{
+		r:        r,
+		progress: p,
+		module:   module,
+		total:    total,
+	}
+}
+
+type progressReader struct {
+	r        io.Reader
+	progress *progressReporter
+	module   string
+	read     int64
+	total    int64
+	last     time.Time
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.read += int64(n)
+	if err == io.EOF || time.Since(r.last) >= 250*time.Millisecond {
+		r.last = time.Now()
+		r.progress.emit(ProgressEvent{
+			Phase:  PhaseDownload,
+			Module: r.module,
+			Bytes:  r.read,
+			Total:  r.total,
+		})
+	}
+	return n, err
+}
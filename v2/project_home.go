@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ProjectHomeDir is where install --project puts modules, relative to
+// the working directory, so a project does not depend on what is
+// installed in the user's home directory. It has the same layout.
+const ProjectHomeDir = ".apex"
+
+// projectHomeDirectory returns the project's home directory,
+// or an empty string if nothing is installed in the project.
+func projectHomeDirectory() string {
+	dir, err := filepath.Abs(ProjectHomeDir)
+	if err != nil {
+		return ""
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "node_modules")); err != nil || !fi.IsDir() {
+		return ""
+	}
+	return dir
+}
+
+// ensureProjectHomeDirectory creates the project's home directory.
+func ensureProjectHomeDirectory() (string, error) {
+	dir, err := filepath.Abs(ProjectHomeDir)
+	if err != nil {
+		return "", err
+	}
+	for _, sub := range []string{"node_modules", "templates", "definitions"} {
+		if err = os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// moduleHomes returns the directories that modules and definitions are
+// resolved from: the project's vendor and home directories, if any,
+// then homeDir.
+func moduleHomes(homeDir string) []string {
+	homes := installHomes(homeDir)
+	if vendor := vendorDirectory(); vendor != "" {
+		homes = append([]string{vendor}, homes...)
+	}
+	return homes
+}
+
+// installHomes returns the directories modules are installed in:
+// the project's home directory, if any, then homeDir.
+func installHomes(homeDir string) []string {
+	project := projectHomeDirectory()
+	if project == "" || project == homeDir {
+		return []string{homeDir}
+	}
+	return []string{project, homeDir}
+}
+
+// findTemplate returns the directory of an installed template, preferring
+// the project's. If it is not installed, the path in homeDir is returned.
+func findTemplate(homeDir, templatePart string) string {
+	for _, home := range moduleHomes(homeDir) {
+		path := filepath.Join(home, "templates", templatePart)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(homeDir, "templates", templatePart)
+}
+
+// installedModuleDir returns the package directory of an installed
+// module, preferring the project's, or false if it is not installed.
+func installedModuleDir(homeDir, module string) (string, bool) {
+	for _, home := range moduleHomes(homeDir) {
+		dir := filepath.Join(home, "node_modules", filepath.FromSlash(module))
+		if _, err := os.Stat(filepath.Join(dir, "package.json")); err == nil {
+			return dir, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,262 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// GenerateReport records checksums of the inputs and outputs
+// of a generate run so that it can be verified later.
+type GenerateReport struct {
+	Config  ReportFile     `json:"config"`
+	Inputs  []ReportFile   `json:"inputs"`
+	Outputs []ReportOutput `json:"outputs"`
+
+	// mu guards Inputs and Outputs while configs generate in parallel.
+	mu sync.Mutex
+}
+
+// ReportFile is the checksum and size of a file or directory.
+// Version is set for modules that contain a package.json.
+type ReportFile struct {
+	Path    string `json:"path"`
+	SHA256  string `json:"sha256"`
+	Size    int64  `json:"size"`
+	Version string `json:"version,omitempty"`
+}
+
+// ReportOutput is a generated file and the generator that produced it.
+type ReportOutput struct {
+	ReportFile
+	Engine       string `json:"engine,omitempty"`
+	Module       string `json:"module,omitempty"`
+	VisitorClass string `json:"visitorClass,omitempty"`
+	Template     string `json:"template,omitempty"`
+}
+
+type VerifyCmd struct {
+	Report string `arg:"" help:"The report written by generate --report." type:"existingfile"`
+}
+
+func (c *VerifyCmd) Run(ctx *Context) error {
+	reportBytes, err := os.ReadFile(c.Report)
+	if err != nil {
+		return err
+	}
+	var expected GenerateReport
+	if err = json.Unmarshal(reportBytes, &expected); err != nil {
+		return fmt.Errorf("could not parse report %s: %w", c.Report, err)
+	}
+
+	outputDir, err := os.MkdirTemp("", "apex-verify-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(outputDir)
+
+	// Verifying reproduces the outputs, so modules
+	// are run rather than read from the cache.
+	g := GenerateCmd{
+		Config:       expected.Config.Path,
+		Force:        true,
+		outputDir:    outputDir,
+		skipRunAfter: true,
+		report:       &GenerateReport{},
+	}
+	if err = g.Run(ctx); err != nil {
+		return err
+	}
+	actual := g.report
+
+	if actual.Config.SHA256 != expected.Config.SHA256 {
+		fmt.Printf("Input changed: %s\n", expected.Config.Path)
+	}
+	actualInputs := make(map[string]ReportFile, len(actual.Inputs))
+	for _, input := range actual.Inputs {
+		actualInputs[input.Path] = input
+	}
+	for _, input := range expected.Inputs {
+		if a, ok := actualInputs[input.Path]; !ok || a.SHA256 != input.SHA256 {
+			fmt.Printf("Input changed: %s\n", input.Path)
+		}
+	}
+
+	actualOutputs := make(map[string]ReportOutput, len(actual.Outputs))
+	for _, output := range actual.Outputs {
+		actualOutputs[output.Path] = output
+	}
+	mismatches := 0
+	for _, output := range expected.Outputs {
+		a, ok := actualOutputs[output.Path]
+		switch {
+		case !ok:
+			fmt.Printf("Missing:  %s\n", output.Path)
+			mismatches++
+		case a.SHA256 != output.SHA256:
+			fmt.Printf("Mismatch: %s (expected %s, got %s)\n", output.Path, output.SHA256, a.SHA256)
+			mismatches++
+		default:
+			fmt.Printf("OK:       %s\n", output.Path)
+		}
+		delete(actualOutputs, output.Path)
+	}
+	for path := range actualOutputs {
+		fmt.Printf("Extra:    %s\n", path)
+		mismatches++
+	}
+
+	if mismatches > 0 {
+		return fmt.Errorf("%d generated file(s) do not match %s", mismatches, c.Report)
+	}
+
+	fmt.Printf("All %d generated file(s) match %s\n", len(expected.Outputs), c.Report)
+	return nil
+}
+
+// addInput records an input once, ignoring duplicates.
+func (r *GenerateReport) addInput(input ReportFile) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.Inputs {
+		if existing.Path == input.Path {
+			return
+		}
+	}
+	r.Inputs = append(r.Inputs, input)
+}
+
+func (r *GenerateReport) addOutput(output ReportOutput) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Outputs = append(r.Outputs, output)
+}
+
+func (r *GenerateReport) sort() {
+	sort.Slice(r.Inputs, func(i, j int) bool {
+		return r.Inputs[i].Path < r.Inputs[j].Path
+	})
+	sort.Slice(r.Outputs, func(i, j int) bool {
+		return r.Outputs[i].Path < r.Outputs[j].Path
+	})
+}
+
+func (r *GenerateReport) write(filename string) error {
+	r.sort()
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0644)
+}
+
+func hashBytes(path string, data []byte) ReportFile {
+	sum := sha256.Sum256(data)
+	return ReportFile{
+		Path:   path,
+		SHA256: hex.EncodeToString(sum[:]),
+		Size:   int64(len(data)),
+	}
+}
+
+// hashDir computes a stable checksum over the relative paths and
+// contents of all files in a directory, excluding node_modules.
+func hashDir(path, dir string) (ReportFile, error) {
+	h := sha256.New()
+	var size int64
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == "node_modules" && p != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(h, "%s\x00%x\n", filepath.ToSlash(rel), sum)
+		size += int64(len(data))
+		return nil
+	})
+	if err != nil {
+		return ReportFile{}, err
+	}
+
+	return ReportFile{
+		Path:   path,
+		SHA256: hex.EncodeToString(h.Sum(nil)),
+		Size:   size,
+	}, nil
+}
+
+// hashModule checksums the module used by a target. Relative
+// modules are hashed in place and installed modules are hashed
+// from their package directory.
+func hashModule(homeDir, module string) (ReportFile, bool) {
+	workingDir, err := os.Getwd()
+	if err != nil {
+		workingDir = "."
+	}
+
+	var candidates []string
+	if strings.HasPrefix(module, ".") || filepath.IsAbs(module) {
+		candidates = []string{filepath.Join(workingDir, filepath.FromSlash(module))}
+	} else {
+		candidates = []string{filepath.Join(workingDir, filepath.FromSlash(module))}
+		for _, home := range moduleHomes(homeDir) {
+			candidates = append(candidates, filepath.Join(home, "node_modules", filepath.FromSlash(module)))
+		}
+	}
+
+	for _, candidate := range candidates {
+		fi, err := os.Stat(candidate)
+		if err != nil {
+			continue
+		}
+		if fi.IsDir() {
+			rf, err := hashDir(module, candidate)
+			if err != nil {
+				continue
+			}
+			rf.Version = readPackageVersion(candidate)
+			return rf, true
+		}
+		if data, err := os.ReadFile(candidate); err == nil {
+			return hashBytes(module, data), true
+		}
+	}
+
+	return ReportFile{}, false
+}
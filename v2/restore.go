@@ -0,0 +1,154 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type RestoreCmd struct {
+	Config   string `arg:"" help:"The code generation configuration listing the dependencies." type:"existingfile" default:"apex.yaml"`
+	Project  bool   `help:"Install missing modules into the project's .apex directory. This is the default when the project already has one."`
+	Offline  bool   `help:"Install only from previously downloaded archives and registry metadata, without network access."`
+	Lockfile string `help:"The lockfile recording installed modules." default:"apex.lock"`
+	// NoScripts builds modules without a dist directory with esbuild.
+	NoScripts bool `help:"Never run package scripts when installing missing modules."`
+}
+
+func (c *RestoreCmd) Run(ctx *Context) error {
+	configData, err := readFile(c.Config, MaxConfigSize)
+	if err != nil {
+		return err
+	}
+	installed, err := restoreDependencies(ctx, configData, InstallCmd{
+		Project:       c.Project,
+		Offline:       c.Offline,
+		Lockfile:      c.Lockfile,
+		NoScripts:     c.NoScripts,
+		SummaryFormat: "text",
+	})
+	if err == nil && !installed {
+		fmt.Println(msg("restore.up_to_date"))
+	}
+	return err
+}
+
+// restoreDependencies installs the dependencies of a configuration that
+// are missing or whose installed version is outside the required range,
+// with the options of install. It returns whether any were installed.
+func restoreDependencies(ctx *Context, configData []byte, install InstallCmd) (bool, error) {
+	dependencies, err := readDependencies(configData)
+	if err != nil || len(dependencies) == 0 {
+		return false, err
+	}
+	homeDir, err := getHomeDirectory()
+	if err != nil {
+		return false, err
+	}
+
+	names := make([]string, 0, len(dependencies))
+	for name := range dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var missing []WorkspaceModule
+	for _, name := range names {
+		location, release := dependencyLocation(name, dependencies[name])
+		if dir, ok := installedModuleDir(homeDir, name); ok && installedRelease(dir, release) {
+			continue
+		}
+		missing = append(missing, WorkspaceModule{Location: location, Release: release})
+	}
+	if len(missing) == 0 {
+		return false, nil
+	}
+
+	fmt.Println(msg("restore.installing", len(missing)))
+	if projectHomeDirectory() != "" {
+		install.Project = true
+	}
+	if install.Lockfile == "" {
+		install.Lockfile = "apex.lock"
+	}
+	if install.SummaryFormat == "" {
+		install.SummaryFormat = "none"
+	}
+	install.Concurrency = 4
+	install.Retries = 2
+	install.modules = missing
+	return true, install.Run(ctx)
+}
+
+// readDependencies returns the dependencies of every document
+// of a configuration, which must agree on shared modules.
+func readDependencies(configData []byte) (map[string]string, error) {
+	dependencies := map[string]string{}
+	for _, configYAML := range strings.Split(string(configData), "---") {
+		var config struct {
+			Dependencies map[string]string `yaml:"dependencies"`
+		}
+		if err := yaml.Unmarshal([]byte(configYAML), &config); err != nil {
+			return nil, err
+		}
+		for name, value := range config.Dependencies {
+			if existing, ok := dependencies[name]; ok && existing != value {
+				return nil, fmt.Errorf("dependency %s is both %q and %q", name, existing, value)
+			}
+			dependencies[name] = value
+		}
+	}
+	return dependencies, nil
+}
+
+// dependencyLocation returns where to install a dependency from. Its
+// value is a release of the module name, such as ^0.1, or a location
+// such as github.com/org/repo@v1.0.0.
+func dependencyLocation(name, value string) (string, string) {
+	if strings.ContainsAny(value, "/:") {
+		return splitModuleRelease(value)
+	}
+	return name, value
+}
+
+// installedRelease reports whether the module installed in dir satisfies
+// release. Releases that are not versions, such as tags and branches,
+// are satisfied by any installed version.
+func installedRelease(dir, release string) bool {
+	r, err := parseSemverRange(strings.TrimPrefix(release, "v"))
+	if release == "" || release == "latest" || err != nil {
+		return true
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return false
+	}
+	var pkg struct {
+		Version string `json:"version"`
+	}
+	if err = json.Unmarshal(data, &pkg); err != nil {
+		return false
+	}
+	v, ok := parseSemver(pkg.Version)
+	return ok && r.matches(v)
+}
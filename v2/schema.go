@@ -0,0 +1,147 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+//go:generate go run schema_gen.go
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+)
+
+// Kinds of JSON Schema written by WriteJSONSchema.
+const (
+	// SchemaConfig validates code generation configurations, apex.yaml.
+	SchemaConfig = "config"
+	// SchemaTemplate validates the .template file of project templates.
+	SchemaTemplate = "template"
+)
+
+// schemaRequired are the fields of each type that must be set.
+var schemaRequired = map[string][]string{
+	"Config":   {"spec", "generates"},
+	"Command":  {"command"},
+	"Template": {"name"},
+	"Variable": {"name"},
+}
+
+// schemaEnums are the values allowed for fields, by type and field name.
+var schemaEnums = map[string][]string{
+	"Config.onFormatError": {FormatErrorFail, FormatErrorRaw},
+	"Target.onFormatError": {FormatErrorFail, FormatErrorRaw},
+	"Target.engine":        {EngineVisitor, EngineTemplate},
+	"Target.mode":          {ModeReplace, ModeMerge},
+	"Target.lineEndings":   {"lf", "crlf", "platform"},
+	"Target.encoding": {"utf-8", "utf8", "utf-8-bom", "utf8-bom", "utf-16le", "utf16le",
+		"utf-16be", "utf16be", "latin1", "latin-1", "iso-8859-1", "ascii", "us-ascii"},
+}
+
+// WriteJSONSchema writes the JSON Schema of configurations (SchemaConfig)
+// or of project templates (SchemaTemplate). Schemas are derived from the
+// fields of Config and Template, so editors using them validate the
+// same fields generate and new accept.
+func WriteJSONSchema(w io.Writer, kind string) error {
+	var root reflect.Type
+	var title string
+	switch kind {
+	case SchemaConfig:
+		root, title = reflect.TypeOf(Config{}), "Apex code generation configuration (apex.yaml)"
+	case SchemaTemplate:
+		root, title = reflect.TypeOf(Template{}), "Apex project template (.template)"
+	default:
+		return fmt.Errorf("unknown schema %q: must be %s or %s", kind, SchemaConfig, SchemaTemplate)
+	}
+
+	definitions := make(map[string]interface{})
+	jsonSchema(root, definitions)
+	// The root type is the document itself since editors
+	// ignore the keywords next to a $ref in draft-07.
+	schema := map[string]interface{}{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"title":       title,
+		"definitions": definitions,
+	}
+	for keyword, value := range definitions[root.Name()].(map[string]interface{}) {
+		schema[keyword] = value
+	}
+	return writeJSON(w, schema)
+}
+
+// jsonSchema returns the schema of values of type t, adding the
+// structs it refers to to definitions by their name.
+func jsonSchema(t reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchema(t.Elem(), definitions)}
+	case reflect.Map:
+		schema := map[string]interface{}{"type": "object"}
+		if t.Elem().Kind() != reflect.Interface {
+			schema["additionalProperties"] = jsonSchema(t.Elem(), definitions)
+		}
+		return schema
+	case reflect.Struct:
+		ref := map[string]interface{}{"$ref": "#/definitions/" + t.Name()}
+		if _, ok := definitions[t.Name()]; ok {
+			return ref
+		}
+		// Added before its fields so recursive types end.
+		definition := map[string]interface{}{"type": "object"}
+		definitions[t.Name()] = definition
+		properties := make(map[string]interface{})
+		for name, field := range yamlFields(t) {
+			property := jsonSchema(field.Type, definitions)
+			if values, ok := schemaEnums[t.Name()+"."+name]; ok {
+				property["enum"] = values
+			}
+			properties[name] = property
+		}
+		definition["properties"] = properties
+		// Unknown fields are warned about by generate.
+		definition["additionalProperties"] = false
+		if required, ok := schemaRequired[t.Name()]; ok {
+			required = append([]string(nil), required...)
+			sort.Strings(required)
+			definition["required"] = required
+		}
+		return ref
+	}
+	// Interfaces hold any value.
+	return map[string]interface{}{}
+}
+
+type SchemaCmd struct {
+	Kind string `arg:"" help:"The schema to print: config for apex.yaml or template for .template files." enum:"config,template"`
+}
+
+func (c *SchemaCmd) Run(ctx *Context) error {
+	return WriteJSONSchema(os.Stdout, c.Kind)
+}
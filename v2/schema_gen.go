@@ -0,0 +1,47 @@
+//go:build ignore
+
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// schema_gen writes the JSON Schemas published in the schemas
+// directory so they stay in sync with the configuration types.
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/apexlang/cli/v2"
+)
+
+func main() {
+	for kind, filename := range map[string]string{
+		cli.SchemaConfig:   "apex.schema.json",
+		cli.SchemaTemplate: "template.schema.json",
+	} {
+		f, err := os.Create(filepath.Join("..", "schemas", filename))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err = cli.WriteJSONSchema(f, kind); err != nil {
+			log.Fatal(err)
+		}
+		if err = f.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// semver is a parsed semantic version. Build metadata is ignored.
+type semver struct {
+	major, minor, patch int
+	prerelease          []string
+}
+
+// parseSemver parses a version such as 1.2.3 or v1.2.3-beta.1.
+func parseSemver(s string) (semver, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(s, '+'); i != -1 {
+		s = s[:i]
+	}
+	var v semver
+	if i := strings.IndexByte(s, '-'); i != -1 {
+		v.prerelease = strings.Split(s[i+1:], ".")
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return semver{}, false
+	}
+	nums := []*int{&v.major, &v.minor, &v.patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return semver{}, false
+		}
+		*nums[i] = n
+	}
+	return v, true
+}
+
+func (v semver) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+	if len(v.prerelease) > 0 {
+		s += "-" + strings.Join(v.prerelease, ".")
+	}
+	return s
+}
+
+// compare returns -1, 0, or 1 following semver precedence.
+func (v semver) compare(o semver) int {
+	for _, d := range []int{v.major - o.major, v.minor - o.minor, v.patch - o.patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	switch {
+	case len(v.prerelease) == 0 && len(o.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(o.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.prerelease) && i < len(o.prerelease); i++ {
+		a, b := v.prerelease[i], o.prerelease[i]
+		an, aErr := strconv.Atoi(a)
+		bn, bErr := strconv.Atoi(b)
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		case a != b:
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(v.prerelease) < len(o.prerelease):
+		return -1
+	case len(v.prerelease) > len(o.prerelease):
+		return 1
+	}
+	return 0
+}
+
+type semverComparator struct {
+	op      string
+	version semver
+}
+
+func (c semverComparator) matches(v semver) bool {
+	cmp := v.compare(c.version)
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return cmp == 0
+}
+
+// semverRange is a set of alternatives, separated by ||, each of
+// which is a set of comparators that must all match.
+type semverRange [][]semverComparator
+
+// parseSemverRange parses NPM range syntax, including ^ and ~ ranges,
+// x-ranges such as 1.2.x or 1, hyphen ranges, and comparators.
+func parseSemverRange(s string) (semverRange, error) {
+	var r semverRange
+	for _, alternative := range strings.Split(s, "||") {
+		fields := strings.Fields(alternative)
+		// Join operators separated from their version, as in ">= 1.2".
+		for i := 0; i < len(fields)-1; i++ {
+			if strings.Trim(fields[i], "<>=~^") == "" {
+				fields[i] += fields[i+1]
+				fields = append(fields[:i+1], fields[i+2:]...)
+			}
+		}
+
+		var comparators []semverComparator
+		if len(fields) == 3 && fields[1] == "-" {
+			low, err := parsePartial(fields[0])
+			if err != nil {
+				return nil, err
+			}
+			high, err := parsePartial(fields[2])
+			if err != nil {
+				return nil, err
+			}
+			comparators = append(comparators, semverComparator{">=", low.floor()})
+			comparators = append(comparators, high.upper("<="))
+		} else {
+			for _, field := range fields {
+				c, err := parseComparator(field)
+				if err != nil {
+					return nil, err
+				}
+				comparators = append(comparators, c...)
+			}
+		}
+		if len(comparators) == 0 {
+			comparators = []semverComparator{{">=", semver{}}}
+		}
+		r = append(r, comparators)
+	}
+	return r, nil
+}
+
+// partialVersion is a version where trailing parts may be
+// omitted or wildcards, such as 1.2 or 1.x.
+type partialVersion struct {
+	nums       []int
+	prerelease []string
+}
+
+func parsePartial(s string) (partialVersion, error) {
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(s, '+'); i != -1 {
+		s = s[:i]
+	}
+	var p partialVersion
+	if i := strings.IndexByte(s, '-'); i != -1 {
+		p.prerelease = strings.Split(s[i+1:], ".")
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return p, fmt.Errorf("invalid version %q", s)
+	}
+	// Parts after a wildcard are ignored, as in 1.x.3, but must be valid.
+	wildcard := false
+	for _, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			wildcard = true
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return p, fmt.Errorf("invalid version %q", s)
+		}
+		if !wildcard {
+			p.nums = append(p.nums, n)
+		}
+	}
+	return p, nil
+}
+
+func (p partialVersion) floor() semver {
+	v := semver{prerelease: p.prerelease}
+	nums := []*int{&v.major, &v.minor, &v.patch}
+	for i, n := range p.nums {
+		*nums[i] = n
+	}
+	return v
+}
+
+// upper returns the comparator for the top of a partial version used
+// with op: 1.2 with <= means anything below 1.3.0.
+func (p partialVersion) upper(op string) semverComparator {
+	switch len(p.nums) {
+	case 0:
+		return semverComparator{">=", semver{}}
+	case 1:
+		return semverComparator{"<", semver{major: p.nums[0] + 1, prerelease: []string{"0"}}}
+	case 2:
+		return semverComparator{"<", semver{major: p.nums[0], minor: p.nums[1] + 1, prerelease: []string{"0"}}}
+	}
+	return semverComparator{op, p.floor()}
+}
+
+func parseComparator(s string) ([]semverComparator, error) {
+	op := ""
+	for _, prefix := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(s, prefix) {
+			op = prefix
+			s = s[len(prefix):]
+			break
+		}
+	}
+	p, err := parsePartial(s)
+	if err != nil {
+		return nil, err
+	}
+	floor := p.floor()
+
+	switch op {
+	case "^":
+		// Allow changes that do not modify the left-most non-zero part.
+		upper := semver{prerelease: []string{"0"}}
+		switch {
+		case len(p.nums) == 0:
+			return []semverComparator{{">=", semver{}}}, nil
+		case p.nums[0] > 0 || len(p.nums) == 1:
+			upper.major = p.nums[0] + 1
+		case len(p.nums) == 2 || p.nums[1] > 0:
+			upper.minor = p.nums[1] + 1
+		default:
+			upper.minor = p.nums[1]
+			upper.patch = p.nums[2] + 1
+		}
+		return []semverComparator{{">=", floor}, {"<", upper}}, nil
+	case "~":
+		// Allow patch changes, or minor changes if only a major is given.
+		if len(p.nums) <= 1 {
+			return []semverComparator{{">=", floor}, p.upper("<=")}, nil
+		}
+		return []semverComparator{{">=", floor}, {"<", semver{major: p.nums[0], minor: p.nums[1] + 1, prerelease: []string{"0"}}}}, nil
+	case ">", "<=":
+		if len(p.nums) < 3 {
+			// >1.2 means >=1.3.0 and <=1.2 means <1.3.0.
+			c := p.upper("<=")
+			if op == ">" {
+				c.op = ">="
+			}
+			return []semverComparator{c}, nil
+		}
+		return []semverComparator{{op, floor}}, nil
+	case ">=", "<":
+		return []semverComparator{{op, floor}}, nil
+	}
+	if len(p.nums) < 3 {
+		return []semverComparator{{">=", floor}, p.upper("<=")}, nil
+	}
+	return []semverComparator{{"=", floor}}, nil
+}
+
+// matches reports whether v satisfies the range. Prereleases only match
+// when a comparator in the same alternative has a prerelease for the same
+// major, minor, and patch, as with NPM.
+func (r semverRange) matches(v semver) bool {
+	for _, comparators := range r {
+		ok := true
+		prereleaseAllowed := len(v.prerelease) == 0
+		for _, c := range comparators {
+			if !c.matches(v) {
+				ok = false
+				break
+			}
+			cv := c.version
+			if len(cv.prerelease) > 0 && cv.major == v.major && cv.minor == v.minor && cv.patch == v.patch {
+				prereleaseAllowed = true
+			}
+		}
+		if ok && prereleaseAllowed {
+			return true
+		}
+	}
+	return false
+}
+
+// maxSatisfying returns the highest of versions that satisfies
+// the range, or false if none do.
+func maxSatisfying(versions []string, rangeSpec string) (string, bool, error) {
+	r, err := parseSemverRange(rangeSpec)
+	if err != nil {
+		return "", false, err
+	}
+	var best semver
+	bestVersion := ""
+	for _, version := range versions {
+		v, ok := parseSemver(version)
+		if !ok || !r.matches(v) {
+			continue
+		}
+		if bestVersion == "" || v.compare(best) > 0 {
+			best = v
+			bestVersion = version
+		}
+	}
+	return bestVersion, bestVersion != "", nil
+}
+
+// isSemverRange reports whether s is a version range rather than
+// an exact version or a tag name.
+func isSemverRange(s string) bool {
+	if s == "" || s == "latest" {
+		return false
+	}
+	if _, ok := parseSemver(s); ok {
+		return false
+	}
+	_, err := parseSemverRange(s)
+	return err == nil
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The expected results are those of the semver package used by npm.
+
+func TestParseSemverRange(t *testing.T) {
+	for _, test := range []struct {
+		rangeSpec       string
+		matches, misses []string
+	}{
+		{"^0.0.x", []string{"0.0.0", "0.0.9"}, []string{"0.1.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"^0.1.2", []string{"0.1.2", "0.1.9"}, []string{"0.2.0"}},
+		{"^1.2.3", []string{"1.2.3", "1.9.0"}, []string{"1.2.2", "2.0.0"}},
+		{"^1.2.3", nil, []string{"1.3.0-beta.1"}},
+		{"~1", []string{"1.0.0", "1.9.9"}, []string{"0.9.9", "2.0.0"}},
+		{"~1.2", []string{"1.2.0", "1.2.9"}, []string{"1.3.0"}},
+		{"~1.2.3", []string{"1.2.9"}, []string{"1.2.2", "1.3.0"}},
+		{">1.2", []string{"1.3.0"}, []string{"1.2.9"}},
+		{"<=1.2", []string{"1.2.9"}, []string{"1.3.0"}},
+		{">1.2.3", []string{"1.2.4"}, []string{"1.2.3"}},
+		{">= 1.2", []string{"1.2.0"}, []string{"1.1.9"}},
+		{"1.2.3 - 2", []string{"1.2.3", "2.9.9"}, []string{"1.2.2", "3.0.0"}},
+		{"1.2 - 2.3.4", []string{"1.2.0", "2.3.4"}, []string{"1.1.9", "2.3.5"}},
+		{"1.x", []string{"1.0.0", "1.9.9"}, []string{"0.9.0", "2.0.0"}},
+		{"1.x.3", []string{"1.0.0", "1.9.9"}, []string{"0.9.0", "2.0.0"}},
+		{">=1.2.3-beta.1", []string{"1.2.3-beta.2", "1.2.3"}, []string{"1.2.3-beta.0", "1.2.4-beta.1"}},
+		{"1.x || >=2.5.0", []string{"1.9.0", "2.5.1"}, []string{"2.0.0"}},
+		{"<1.0.0 || 2.0.0 - 2.1", []string{"0.9.0", "2.1.9"}, []string{"1.0.0", "2.2.0"}},
+		{"*", []string{"0.0.1", "9.9.9"}, []string{"1.0.0-rc.1"}},
+		{"", []string{"1.0.0"}, nil},
+	} {
+		r, err := parseSemverRange(test.rangeSpec)
+		require.NoError(t, err, test.rangeSpec)
+		for _, version := range test.matches {
+			v, ok := parseSemver(version)
+			require.True(t, ok, version)
+			assert.True(t, r.matches(v), "%s should satisfy %q", version, test.rangeSpec)
+		}
+		for _, version := range test.misses {
+			v, ok := parseSemver(version)
+			require.True(t, ok, version)
+			assert.False(t, r.matches(v), "%s should not satisfy %q", version, test.rangeSpec)
+		}
+	}
+}
+
+func TestParseSemverRangeInvalid(t *testing.T) {
+	for _, rangeSpec := range []string{"1.2.3.4", "^abc", ">=1.a", "1.2.3 - x.y", "1.x.y"} {
+		_, err := parseSemverRange(rangeSpec)
+		assert.Error(t, err, rangeSpec)
+	}
+}
+
+func TestMaxSatisfying(t *testing.T) {
+	versions := []string{"0.0.3", "0.0.4", "0.1.0", "1.0.0", "1.2.3", "1.3.0-beta.1", "1.9.9", "2.0.0", "2.1.0-rc.1", "2.1.0"}
+	for rangeSpec, expected := range map[string]string{
+		"^0.0.3":       "0.0.3",
+		"^0.0.x":       "0.0.4",
+		"^1.2.3":       "1.9.9",
+		"~1":           "1.9.9",
+		">1.2":         "2.1.0",
+		"1.2.3 - 2":    "2.1.0",
+		"<2.1.0":       "2.0.0",
+		"^2.1.0-rc.0":  "2.1.0",
+		">=3":          "",
+		"1.x || 2.0.x": "2.0.0",
+	} {
+		version, ok, err := maxSatisfying(versions, rangeSpec)
+		require.NoError(t, err, rangeSpec)
+		assert.Equal(t, expected != "", ok, rangeSpec)
+		assert.Equal(t, expected, version, rangeSpec)
+	}
+}
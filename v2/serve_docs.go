@@ -0,0 +1,283 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadPath is the server-sent events endpoint that
+// tells previewed pages to reload after regeneration.
+const reloadPath = "/__apex/reload"
+
+const reloadScript = `<script>
+new EventSource("` + reloadPath + `").addEventListener("reload", function () {
+  location.reload();
+});
+</script>
+`
+
+// reloadDebounce groups the writes of a single save.
+const reloadDebounce = 100 * time.Millisecond
+
+type ServeDocsCmd struct {
+	Dir        string `arg:"" help:"The directory of generated output to serve." type:"existingdir" default:"."`
+	Addr       string `help:"The address to listen on." default:"localhost:8080"`
+	Watch      bool   `help:"Regenerate when the configuration or its specs change and reload open pages."`
+	ConfigFile string `help:"The code generation configuration to regenerate with --watch." default:"apex.yaml"`
+}
+
+func (c *ServeDocsCmd) Run(ctx *Context) error {
+	reloads := &reloadBroker{clients: map[chan struct{}]struct{}{}}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", &previewHandler{root: c.Dir, live: c.Watch})
+	if c.Watch {
+		mux.Handle(reloadPath, reloads)
+	}
+
+	listener, err := net.Listen("tcp", c.Addr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	if c.Watch {
+		watcher, err := c.watch(reloads)
+		if err != nil {
+			return err
+		}
+		defer watcher.Close()
+	}
+
+	fmt.Printf("Serving %s at http://%s\n", c.Dir, listener.Addr())
+	return http.Serve(listener, mux)
+}
+
+// watch regenerates from the configuration when it or one of its specs
+// is written, then tells open pages to reload.
+func (c *ServeDocsCmd) watch(reloads *reloadBroker) (*fsnotify.Watcher, error) {
+	configFile, err := filepath.Abs(c.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []Config
+	load := func() error {
+		loaded, err := readConfigs(configFile, "", ConfigOverrides{})
+		if err != nil {
+			return err
+		}
+		configs = loaded
+		watched := map[string]struct{}{}
+		for _, name := range watcher.WatchList() {
+			watched[name] = struct{}{}
+		}
+		files := []string{configFile}
+		for _, config := range configs {
+			spec, err := filepath.Abs(config.Spec)
+			if err != nil {
+				return err
+			}
+			files = append(files, spec)
+		}
+		for _, file := range files {
+			if _, ok := watched[file]; ok {
+				continue
+			}
+			log.Printf("Watching %s...", file)
+			if err = watcher.Add(file); err != nil {
+				return err
+			}
+			watched[file] = struct{}{}
+		}
+		return nil
+	}
+	if err = load(); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	go func() {
+		var timer <-chan time.Time
+		reload := false
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op&fsnotify.Write != fsnotify.Write {
+					continue
+				}
+				if event.Name == configFile {
+					reload = true
+				}
+				timer = time.After(reloadDebounce)
+			case <-timer:
+				timer = nil
+				if reload {
+					reload = false
+					if err := load(); err != nil {
+						log.Println("error:", err)
+						continue
+					}
+				}
+				for _, config := range configs {
+					g := GenerateCmd{}
+					if err := g.generateConfig(config); err != nil {
+						log.Printf("Error running generate: %v", err)
+					}
+				}
+				log.Println("Reloading pages.")
+				reloads.broadcast()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Println("error:", err)
+			}
+		}
+	}()
+
+	return watcher, nil
+}
+
+// previewHandler serves files from root, adding the live
+// reload script to HTML pages when live is set.
+type previewHandler struct {
+	root string
+	live bool
+}
+
+func (h *previewHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Pages are regenerated so they should not be cached.
+	w.Header().Set("Cache-Control", "no-store")
+	files := http.Dir(h.root)
+	if !h.live {
+		http.FileServer(files).ServeHTTP(w, r)
+		return
+	}
+
+	name := path.Clean("/" + r.URL.Path)
+	if info, err := os.Stat(filepath.Join(h.root, filepath.FromSlash(name))); err == nil && info.IsDir() {
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			http.FileServer(files).ServeHTTP(w, r)
+			return
+		}
+		name = path.Join(name, "index.html")
+	}
+	ext := strings.ToLower(path.Ext(name))
+	if ext != ".html" && ext != ".htm" {
+		http.FileServer(files).ServeHTTP(w, r)
+		return
+	}
+
+	f, err := files.Open(name)
+	if err != nil {
+		http.FileServer(files).ServeHTTP(w, r)
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data = injectReloadScript(data)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+}
+
+// injectReloadScript adds the reload script before the closing
+// body tag, or at the end of pages that do not have one.
+func injectReloadScript(page []byte) []byte {
+	i := bytes.LastIndex(bytes.ToLower(page), []byte("</body>"))
+	if i == -1 {
+		return append(page, reloadScript...)
+	}
+	injected := make([]byte, 0, len(page)+len(reloadScript))
+	injected = append(injected, page[:i]...)
+	injected = append(injected, reloadScript...)
+	return append(injected, page[i:]...)
+}
+
+// reloadBroker streams a reload event to every connected page.
+type reloadBroker struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]struct{}
+}
+
+func (b *reloadBroker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	flusher.Flush()
+
+	events := make(chan struct{}, 1)
+	b.mu.Lock()
+	b.clients[events] = struct{}{}
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.clients, events)
+		b.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-events:
+			fmt.Fprint(w, "event: reload\ndata: {}\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (b *reloadBroker) broadcast() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for events := range b.clients {
+		// A reload already pending covers this one.
+		select {
+		case events <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"path"
+)
+
+// Kinds of npm-shrinkwrap.json packages that install --include adds to
+// the packages needed at runtime and --omit leaves out. As with npm,
+// optional packages are installed unless omitted and dev packages are
+// only installed when included.
+const (
+	IncludeDev      = "dev"
+	IncludeOptional = "optional"
+)
+
+type Shrinkwrap struct {
+	Name            string             `json:"name"`
+	Version         string             `json:"version"`
+	LockfileVersion int                `json:"lockfileVersion"`
+	Requires        bool               `json:"requires"`
+	Packages        map[string]Package `json:"packages"`
+	Dependencies    map[string]Package `json:"dependencies"`
+}
+
+type Package struct {
+	Version    string `json:"version"`
+	Resolved   string `json:"resolved"`
+	Integrity  string `json:"integrity"`
+	Dev        bool   `json:"dev"`
+	Extraneous bool   `json:"extraneous"`
+	// Optional packages may fail to install with npm, and DevOptional
+	// packages are needed for development or are optional.
+	Optional    bool `json:"optional"`
+	DevOptional bool `json:"devOptional"`
+}
+
+// checkShrinkwrapOptions checks the values of install --include,
+// --omit, and --exclude-packages.
+func (c *InstallCmd) checkShrinkwrapOptions() error {
+	for flag, kinds := range map[string][]string{"--include": c.Include, "--omit": c.Omit} {
+		for _, kind := range kinds {
+			if kind != IncludeDev && kind != IncludeOptional {
+				return fmt.Errorf("%s must be dev or optional, not %q", flag, kind)
+			}
+		}
+	}
+	for _, pattern := range c.ExcludePackages {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid --exclude-packages pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// includesPackage reports whether a package of npm-shrinkwrap.json
+// named name is installed. Dev packages are only installed when
+// included, optional packages unless omitted, and packages that are
+// both when either is installed. Excluded names never are. As with
+// npm, kinds that are included are installed even when omitted.
+func (c *InstallCmd) includesPackage(name string, pkg Package) bool {
+	if pkg.Extraneous {
+		return false
+	}
+	dev := containsString(c.Include, IncludeDev)
+	optional := containsString(c.Include, IncludeOptional) || !containsString(c.Omit, IncludeOptional)
+	switch {
+	case pkg.DevOptional && !dev && !optional,
+		pkg.Dev && !dev,
+		pkg.Optional && !optional:
+		return false
+	}
+	for _, pattern := range c.ExcludePackages {
+		if matched, _ := path.Match(pattern, name); matched {
+			return false
+		}
+	}
+	return true
+}
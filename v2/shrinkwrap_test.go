@@ -0,0 +1,93 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncludesPackage(t *testing.T) {
+	packages := map[string]Package{
+		"runtime":     {},
+		"dev":         {Dev: true},
+		"optional":    {Optional: true},
+		"devOptional": {DevOptional: true},
+		"extraneous":  {Extraneous: true},
+	}
+	for _, test := range []struct {
+		name            string
+		include, omit   []string
+		excludePackages []string
+		installed       []string
+	}{
+		{
+			name:      "default",
+			installed: []string{"runtime", "optional", "devOptional"},
+		},
+		{
+			name:      "include dev",
+			include:   []string{IncludeDev},
+			installed: []string{"runtime", "dev", "optional", "devOptional"},
+		},
+		{
+			name:      "omit optional",
+			omit:      []string{IncludeOptional},
+			installed: []string{"runtime"},
+		},
+		{
+			name:      "omit optional include dev",
+			include:   []string{IncludeDev},
+			omit:      []string{IncludeOptional},
+			installed: []string{"runtime", "dev", "devOptional"},
+		},
+		{
+			name:      "include wins over omit",
+			include:   []string{IncludeOptional},
+			omit:      []string{IncludeOptional},
+			installed: []string{"runtime", "optional", "devOptional"},
+		},
+		{
+			name:            "exclude packages",
+			excludePackages: []string{"opt*"},
+			installed:       []string{"runtime", "devOptional"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			c := InstallCmd{Include: test.include, Omit: test.omit, ExcludePackages: test.excludePackages}
+			assert.NoError(t, c.checkShrinkwrapOptions())
+			var installed []string
+			for _, name := range []string{"runtime", "dev", "optional", "devOptional", "extraneous"} {
+				if c.includesPackage(name, packages[name]) {
+					installed = append(installed, name)
+				}
+			}
+			assert.Equal(t, test.installed, installed)
+		})
+	}
+}
+
+func TestCheckShrinkwrapOptions(t *testing.T) {
+	for _, c := range []InstallCmd{
+		{Include: []string{"peer"}},
+		{Omit: []string{"runtime"}},
+		{ExcludePackages: []string{"["}},
+	} {
+		assert.Error(t, c.checkShrinkwrapOptions())
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Suffixes of the files published next to a module archive that
+// sign it: a sigstore bundle, such as written by cosign sign-blob
+// --bundle, or a detached signature.
+const (
+	sigstoreBundleSuffix = ".sigstore.json"
+	signatureSuffix      = ".sig"
+)
+
+// maxSignatureSize limits the size of a signature or bundle.
+const maxSignatureSize = 1 << 20
+
+// verifiesSignatures returns whether downloads are verified,
+// which is opt-in.
+func (p *InstallPolicy) verifiesSignatures() bool {
+	return p != nil && (p.RequireSigned || p.PublicKey != "" || p.CertificateIdentity != "")
+}
+
+// checkUnsigned fails installs that cannot be verified, such
+// as git checkouts, when signatures are required.
+func (p *InstallPolicy) checkUnsigned(source, reason string) error {
+	if p == nil || !p.RequireSigned {
+		return nil
+	}
+	return &PolicyViolation{Source: source, Rule: "signature required: " + reason, PolicyFile: p.file}
+}
+
+// verifySignature verifies the sigstore bundle or detached signature
+// published next to a downloaded archive with the cosign CLI. Archives
+// without either are refused when signatures are required.
+func (c *InstallCmd) verifySignature(downloadURL, archive string) error {
+	if !c.policy.verifiesSignatures() {
+		return nil
+	}
+
+	args := []string{"verify-blob"}
+	var signature []byte
+	var signatureFile string
+	for _, suffix := range []string{sigstoreBundleSuffix, signatureSuffix} {
+		// A detached signature carries no certificate,
+		// so it is only verified with a public key.
+		if suffix == signatureSuffix && c.policy.PublicKey == "" {
+			continue
+		}
+		data, found, err := c.fetchSignature(downloadURL + suffix)
+		if err != nil {
+			return err
+		}
+		if found {
+			signature = data
+			signatureFile = "signature" + suffix
+			if suffix == sigstoreBundleSuffix {
+				args = append(args, "--bundle")
+			} else {
+				args = append(args, "--signature")
+			}
+			break
+		}
+	}
+	if signature == nil {
+		if err := c.policy.checkUnsigned(moduleSource(c.Location), "no signature found for "+downloadURL); err != nil {
+			return err
+		}
+		fmt.Printf("Warning: %s is not signed\n", c.Location)
+		return nil
+	}
+
+	dir, err := os.MkdirTemp("", "apex-signature-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	signatureFile = filepath.Join(dir, signatureFile)
+	if err = os.WriteFile(signatureFile, signature, 0644); err != nil {
+		return err
+	}
+	args = append(args, signatureFile)
+	if c.policy.PublicKey != "" {
+		args = append(args, "--key", c.policy.PublicKey)
+	} else {
+		args = append(args,
+			"--certificate-identity-regexp", c.policy.CertificateIdentity,
+			"--certificate-oidc-issuer-regexp", c.policy.certificateIssuer())
+	}
+	if c.offline() {
+		args = append(args, "--offline")
+	}
+	args = append(args, archive)
+
+	c.progress.phase(PhaseVerify, c.Location, "cosign verify-blob")
+	var stderr bytes.Buffer
+	cmd := exec.Command("cosign", args...)
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("could not verify the signature of %s with cosign: %w: %s", c.Location, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// certificateIssuer returns the OIDC issuers that keyless
+// signatures may be issued by, which is any when not set.
+func (p *InstallPolicy) certificateIssuer() string {
+	if p.CertificateOIDCIssuer == "" {
+		return ".*"
+	}
+	return p.CertificateOIDCIssuer
+}
+
+// fetchSignature downloads a signature, or reads it from the cache of
+// downloads when offline. It returns false if there is none.
+func (c *InstallCmd) fetchSignature(url string) ([]byte, bool, error) {
+	cached := cachePath(downloadCacheDir(c.homeDir), url)
+	if c.offline() {
+		data, err := readLocalFile(cached, maxSignatureSize)
+		return data, err == nil, nil
+	}
+
+	resp, err := c.netClient.Get(url)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, false, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, false, fmt.Errorf("could not download %s: got status %d", url, resp.StatusCode)
+	}
+	data, err := limitReader(url, resp.Body, maxSignatureSize)
+	if err != nil {
+		return nil, false, err
+	}
+	// Signatures are kept so that offline installs verify them too.
+	if err = os.MkdirAll(filepath.Dir(cached), 0755); err == nil {
+		os.WriteFile(cached, data, 0644)
+	}
+	return data, true, nil
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// importPattern finds the definitions a specification imports.
+var importPattern = regexp.MustCompile(`(?m)^\s*import\s+.*\s+from\s+"([^"]+)"`)
+
+// changedFiles returns the absolute paths of files that differ from
+// revision in the working tree, including untracked files.
+func changedFiles(revision string) (map[string]struct{}, error) {
+	root, err := runGit("", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root = strings.TrimSpace(root)
+	diff, err := runGit("", "diff", "--name-only", "--no-renames", revision, "--")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := runGit("", "ls-files", "--others", "--exclude-standard", "--full-name")
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]struct{})
+	for _, name := range strings.Split(diff+"\n"+untracked, "\n") {
+		if name = strings.TrimSpace(name); name != "" {
+			changed[filepath.Join(root, filepath.FromSlash(name))] = struct{}{}
+		}
+	}
+	return changed, nil
+}
+
+// affectedConfigs returns configs with only the targets affected by
+// files changed since c.Since: those whose configuration, spec, the
+// definitions it imports, module, template, dependsOn, or output
+// changed, and the targets depending on their outputs in turn. The
+// other targets are reported as skipped.
+func (c *GenerateCmd) affectedConfigs(configs []Config) ([]Config, error) {
+	changed, err := changedFiles(c.Since)
+	if err != nil {
+		return nil, err
+	}
+	homeDir, err := getHomeDirectory()
+	if err != nil {
+		return nil, err
+	}
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	abs := func(path string) string {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workingDir, path)
+		}
+		return filepath.Clean(path)
+	}
+	isChanged := func(path string) bool {
+		_, ok := changed[abs(path)]
+		return ok
+	}
+	// changedUnder reports whether a file in dir, or dir itself, changed.
+	changedUnder := func(dir string) bool {
+		dir = abs(dir)
+		for path := range changed {
+			if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var definitionsDirs []string
+	for _, home := range moduleHomes(homeDir) {
+		definitionsDirs = append(definitionsDirs, filepath.Join(home, "definitions"))
+	}
+	specChanged := make(map[string]bool)
+	specAffected := func(spec string) bool {
+		if affected, ok := specChanged[spec]; ok {
+			return affected
+		}
+		affected := isChanged(spec)
+		if data, err := readLocalFile(spec, MaxSpecSize); err == nil && !affected {
+			for _, match := range importPattern.FindAllStringSubmatch(string(data), -1) {
+				for _, dir := range definitionsDirs {
+					location := filepath.Join(dir, filepath.FromSlash(match[1]))
+					affected = affected || changedUnder(location) || isChanged(location+".apex")
+				}
+			}
+		}
+		specChanged[spec] = affected
+		return affected
+	}
+	moduleChanged := func(config Config, module string) bool {
+		if module == "" {
+			return false
+		}
+		module = resolveModuleAlias(module, config.Aliases)
+		if strings.HasPrefix(module, ".") || filepath.IsAbs(module) {
+			return changedUnder(module)
+		}
+		pkg := packageName(module)
+		dirs := []string{filepath.Join(workingDir, filepath.FromSlash(pkg))}
+		for _, home := range moduleHomes(homeDir) {
+			dirs = append(dirs, filepath.Join(home, "node_modules", filepath.FromSlash(pkg)))
+		}
+		for _, dir := range dirs {
+			if changedUnder(dir) {
+				return true
+			}
+		}
+		return false
+	}
+
+	configFileChanged := c.Config != "" && isChanged(c.Config)
+	affected := make([]map[string]bool, len(configs))
+	outputs := make(map[string]struct{})
+	for i, config := range configs {
+		affected[i] = make(map[string]bool)
+		configAffected := configFileChanged || specAffected(config.Spec)
+		for _, file := range config.DependsOn {
+			configAffected = configAffected || isChanged(file)
+		}
+		for filename, target := range config.Generates {
+			targetAffected := configAffected || isChanged(filename) ||
+				moduleChanged(config, target.Module) ||
+				(target.Template != "" && isChanged(target.Template))
+			for _, visitor := range target.Visitors {
+				targetAffected = targetAffected || moduleChanged(config, visitor.Module)
+			}
+			for _, file := range target.DependsOn {
+				targetAffected = targetAffected || isChanged(file)
+			}
+			if targetAffected {
+				affected[i][filename] = true
+				outputs[abs(filename)] = struct{}{}
+			}
+		}
+	}
+
+	// Targets that use the output of an affected target, as their
+	// spec or a dependency, or that write the same file, are affected
+	// too, until no more are found.
+	for found := true; found; {
+		found = false
+		isOutput := func(path string) bool {
+			_, ok := outputs[abs(path)]
+			return ok
+		}
+		for i, config := range configs {
+			configAffected := isOutput(config.Spec)
+			for _, file := range config.DependsOn {
+				configAffected = configAffected || isOutput(file)
+			}
+			for filename, target := range config.Generates {
+				if affected[i][filename] {
+					continue
+				}
+				targetAffected := configAffected || isOutput(filename)
+				for _, file := range target.DependsOn {
+					targetAffected = targetAffected || isOutput(file)
+				}
+				if targetAffected {
+					affected[i][filename] = true
+					outputs[abs(filename)] = struct{}{}
+					found = true
+				}
+			}
+		}
+	}
+
+	var filtered []Config
+	var skipped []string
+	for i, config := range configs {
+		generates := make(map[string]Target)
+		for filename, target := range config.Generates {
+			if affected[i][filename] {
+				generates[filename] = target
+			} else {
+				skipped = append(skipped, filename)
+			}
+		}
+		if len(generates) > 0 {
+			config.Generates = generates
+			filtered = append(filtered, config)
+		}
+	}
+	sort.Strings(skipped)
+	for _, filename := range skipped {
+		fmt.Println(msg("generate.unaffected", filename, c.Since))
+	}
+	c.summary.add(func(s *Summary) { s.FilesSkipped += len(skipped) })
+	return filtered, nil
+}
+
+// packageName returns the NPM package of a module import
+// path, such as @apexlang/codegen for @apexlang/codegen/rust.
+func packageName(module string) string {
+	parts := strings.SplitN(module, "/", 3)
+	if strings.HasPrefix(module, "@") && len(parts) > 1 {
+		return parts[0] + "/" + parts[1]
+	}
+	return parts[0]
+}
@@ -0,0 +1,251 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+const parseTemplate = `import { parse } from "{{core}}";
+
+function resolver(location, from) {
+  const source = resolverCallback(location, from);
+  if (source.startsWith("error: ")) {
+    throw source.substring(7);
+  }
+  return source;
+}
+
+export function parseDocument(spec) {
+  const doc = parse(spec, resolver);
+  return JSON.stringify(doc);
+}
+
+js_exports["parse"] = parseDocument;`
+
+type SpecCmd struct {
+	Add    SpecAddCmd    `cmd:"" aliases:"new" help:"Adds definitions to a specification file."`
+	Stats  SpecStatsCmd  `cmd:"" help:"Shows counts of the definitions and annotations in a specification."`
+	Format SpecFormatCmd `cmd:"" help:"Formats specification files in canonical style."`
+}
+
+type SpecAddCmd struct {
+	Interface SpecAddInterfaceCmd `cmd:"" help:"Adds an interface definition."`
+	Type      SpecAddTypeCmd      `cmd:"" help:"Adds a type definition."`
+}
+
+type SpecAddInterfaceCmd struct {
+	Name string   `arg:"" help:"The name of the interface."`
+	Ops  []string `help:"Operations to add (e.g. create,get,list,update,delete)." sep:","`
+	Spec string   `help:"The specification file to modify." type:"existingfile" default:"spec.apex"`
+}
+
+type SpecAddTypeCmd struct {
+	Name   string   `arg:"" help:"The name of the type."`
+	Fields []string `help:"Fields to add as name:type pairs (e.g. id:string,total:f64)." sep:","`
+	Spec   string   `help:"The specification file to modify." type:"existingfile" default:"spec.apex"`
+}
+
+// specDefinition is the subset of a parsed definition needed
+// to place new definitions in a specification.
+type specDefinition struct {
+	Kind string `json:"kind"`
+	Name *struct {
+		Value string `json:"value"`
+	} `json:"name"`
+	Loc *struct {
+		Start int `json:"start"`
+		End   int `json:"end"`
+	} `json:"loc"`
+}
+
+func (c *SpecAddInterfaceCmd) Run(ctx *Context) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "interface %s {\n", c.Name)
+	typeName := singular(c.Name)
+	for _, op := range c.Ops {
+		op = strings.TrimSpace(op)
+		if op == "" {
+			continue
+		}
+		switch op {
+		case "create":
+			fmt.Fprintf(&b, "  create(value: %s): %s\n", typeName, typeName)
+		case "get":
+			fmt.Fprintf(&b, "  get(id: string): %s\n", typeName)
+		case "list":
+			fmt.Fprintf(&b, "  list(): [%s]\n", typeName)
+		case "update":
+			fmt.Fprintf(&b, "  update(value: %s): %s\n", typeName, typeName)
+		case "delete":
+			fmt.Fprintf(&b, "  delete(id: string): %s\n", typeName)
+		default:
+			fmt.Fprintf(&b, "  %s(): string\n", op)
+		}
+	}
+	b.WriteString("}\n")
+
+	return addDefinition(c.Spec, "InterfaceDefinition", c.Name, b.String())
+}
+
+func (c *SpecAddTypeCmd) Run(ctx *Context) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "type %s {\n", c.Name)
+	fields := c.Fields
+	if len(fields) == 0 {
+		fields = []string{"id:string"}
+	}
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		name, fieldType := field, "string"
+		if idx := strings.Index(field, ":"); idx != -1 {
+			name, fieldType = field[:idx], field[idx+1:]
+		}
+		fmt.Fprintf(&b, "  %s: %s\n", strings.TrimSpace(name), strings.TrimSpace(fieldType))
+	}
+	b.WriteString("}\n")
+
+	return addDefinition(c.Spec, "TypeDefinition", c.Name, b.String())
+}
+
+// addDefinition inserts source after the last definition of the same kind
+// in the specification, or appends it when there are none. The resulting
+// specification is parsed before it is written.
+func addDefinition(specFile, kind, name, source string) error {
+	homeDir, err := getHomeDirectory()
+	if err != nil {
+		return err
+	}
+
+	specBytes, err := readLocalFile(specFile, MaxSpecSize)
+	if err != nil {
+		return err
+	}
+	spec := string(specBytes)
+
+	definitions, err := parseDefinitions(homeDir, spec)
+	if err != nil {
+		return fmt.Errorf("could not parse %s: %w", specFile, err)
+	}
+
+	insertAt := -1
+	for _, def := range definitions {
+		if def.Name != nil && def.Name.Value == name {
+			return fmt.Errorf("%s is already defined in %s", name, specFile)
+		}
+		if def.Kind == kind && def.Loc != nil {
+			insertAt = utf16ToByteOffset(spec, def.Loc.End)
+		}
+	}
+
+	var updated string
+	if insertAt == -1 {
+		updated = strings.TrimRight(spec, "\n")
+		if updated != "" {
+			updated += "\n\n"
+		}
+		updated += source
+	} else {
+		updated = spec[:insertAt] + "\n\n" + strings.TrimRight(source, "\n") + spec[insertAt:]
+	}
+
+	if _, err = parseSpec(homeDir, defaultCoreModule, updated); err != nil {
+		return fmt.Errorf("generated definition is invalid: %w", err)
+	}
+
+	stat, err := os.Stat(specFile)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(specFile, []byte(updated), stat.Mode()); err != nil {
+		return err
+	}
+
+	fmt.Printf("Added %s to %s\n", name, specFile)
+	return nil
+}
+
+// parseSpec parses an Apex specification with the given core
+// module and returns the document as JSON.
+func parseSpec(homeDir, core, spec string) (string, error) {
+	return parseSpecContext(context.Background(), homeDir, core, spec)
+}
+
+// parseSpecContext parses like parseSpec, stopping when ctx is done.
+func parseSpecContext(ctx context.Context, homeDir, core, spec string) (string, error) {
+	source := strings.ReplaceAll(parseTemplate, "{{core}}", core)
+	res, err := runScript(ctx, homeDir, false, source, "parse", spec)
+	if err != nil {
+		return "", err
+	}
+	docJSON, ok := res.(string)
+	if !ok {
+		return "", errors.New("could not serialize parsed document")
+	}
+
+	return docJSON, nil
+}
+
+func parseDefinitions(homeDir, spec string) ([]specDefinition, error) {
+	docJSON, err := parseSpec(homeDir, defaultCoreModule, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc struct {
+		Definitions []specDefinition `json:"definitions"`
+	}
+	if err = json.Unmarshal([]byte(docJSON), &doc); err != nil {
+		return nil, fmt.Errorf("could not decode document: %w", err)
+	}
+
+	return doc.Definitions, nil
+}
+
+// utf16ToByteOffset converts a JavaScript string index
+// into a byte offset within s.
+func utf16ToByteOffset(s string, offset int) int {
+	units := 0
+	for i, r := range s {
+		if units >= offset {
+			return i
+		}
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return len(s)
+}
+
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 3:
+		return name[:len(name)-3] + "y"
+	case strings.HasSuffix(name, "ses") && len(name) > 3:
+		return name[:len(name)-2]
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") && len(name) > 1:
+		return name[:len(name)-1]
+	}
+	return name
+}
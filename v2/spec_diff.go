@@ -0,0 +1,270 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Kinds of spec changes.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// SpecChange is a difference between two versions of a specification.
+// Member is empty when a whole definition was added or removed.
+type SpecChange struct {
+	Change     string `json:"change"`
+	Definition string `json:"definition"`
+	Name       string `json:"name"`
+	MemberKind string `json:"memberKind,omitempty"`
+	Member     string `json:"member,omitempty"`
+	From       string `json:"from,omitempty"`
+	To         string `json:"to,omitempty"`
+	Breaking   bool   `json:"breaking"`
+}
+
+// specDefinitionKinds names definition kinds as written in specifications.
+var specDefinitionKinds = map[string]string{
+	"NamespaceDefinition": "namespace",
+	"InterfaceDefinition": "interface",
+	"TypeDefinition":      "type",
+	"EnumDefinition":      "enum",
+	"UnionDefinition":     "union",
+	"AliasDefinition":     "alias",
+	"DirectiveDefinition": "directive",
+}
+
+// diffSpecs compares two parsed documents. Removing or changing
+// anything is considered breaking, as is adding a required field.
+func diffSpecs(fromJSON, toJSON string) ([]SpecChange, error) {
+	from, err := specMembers(fromJSON)
+	if err != nil {
+		return nil, err
+	}
+	to, err := specMembers(toJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []SpecChange
+	for key, def := range from {
+		if _, ok := to[key]; !ok {
+			changes = append(changes, SpecChange{
+				Change:     ChangeRemoved,
+				Definition: def.kind,
+				Name:       def.name,
+				Breaking:   true,
+			})
+		}
+	}
+	for key, def := range to {
+		old, ok := from[key]
+		if !ok {
+			changes = append(changes, SpecChange{
+				Change:     ChangeAdded,
+				Definition: def.kind,
+				Name:       def.name,
+			})
+			continue
+		}
+		for name, member := range old.members {
+			change := SpecChange{
+				Definition: def.kind,
+				Name:       def.name,
+				MemberKind: member.kind,
+				Member:     name,
+				From:       member.signature,
+				Breaking:   true,
+			}
+			if updated, ok := def.members[name]; !ok {
+				change.Change = ChangeRemoved
+				changes = append(changes, change)
+			} else if updated.signature != member.signature {
+				change.Change = ChangeChanged
+				change.To = updated.signature
+				changes = append(changes, change)
+			}
+		}
+		for name, member := range def.members {
+			if _, ok := old.members[name]; !ok {
+				changes = append(changes, SpecChange{
+					Change:     ChangeAdded,
+					Definition: def.kind,
+					Name:       def.name,
+					MemberKind: member.kind,
+					Member:     name,
+					To:         member.signature,
+					Breaking:   member.required,
+				})
+			}
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.Definition != b.Definition {
+			return a.Definition < b.Definition
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Member < b.Member
+	})
+
+	return changes, nil
+}
+
+type specDefinitionMembers struct {
+	kind    string
+	name    string
+	members map[string]specMember
+}
+
+type specMember struct {
+	kind      string
+	signature string
+	// required is set for fields that must be provided, which
+	// makes adding them a breaking change.
+	required bool
+}
+
+func specMembers(docJSON string) (map[string]specDefinitionMembers, error) {
+	var doc struct {
+		Definitions []map[string]interface{} `json:"definitions"`
+	}
+	if err := json.Unmarshal([]byte(docJSON), &doc); err != nil {
+		return nil, fmt.Errorf("could not decode document: %w", err)
+	}
+
+	defs := make(map[string]specDefinitionMembers, len(doc.Definitions))
+	for _, def := range doc.Definitions {
+		kind, ok := specDefinitionKinds[fmt.Sprint(def["kind"])]
+		if !ok {
+			continue
+		}
+		d := specDefinitionMembers{
+			kind:    kind,
+			name:    nameValue(def["name"]),
+			members: map[string]specMember{},
+		}
+		switch kind {
+		case "interface":
+			for _, op := range nodes(def["operations"]) {
+				d.members[nameValue(op["name"])] = specMember{
+					kind:      "operation",
+					signature: operationSignature(op),
+				}
+			}
+		case "type":
+			for _, field := range nodes(def["fields"]) {
+				fieldType, _ := field["type"].(map[string]interface{})
+				d.members[nameValue(field["name"])] = specMember{
+					kind:      "field",
+					signature: nameValue(field["name"]) + ": " + typeSignature(fieldType),
+					required:  fieldType["kind"] != "Optional" && field["default"] == nil,
+				}
+			}
+		case "enum":
+			for _, value := range nodes(def["values"]) {
+				d.members[nameValue(value["name"])] = specMember{
+					kind:      "value",
+					signature: nameValue(value["name"]) + " = " + fmt.Sprint(nodeValue(value["index"])),
+				}
+			}
+		case "union":
+			var types []string
+			for _, t := range nodes(def["types"]) {
+				types = append(types, typeSignature(t))
+			}
+			for _, m := range nodes(def["members"]) {
+				t, _ := m["type"].(map[string]interface{})
+				types = append(types, typeSignature(t))
+			}
+			d.members["types"] = specMember{kind: "types", signature: strings.Join(types, " | ")}
+		case "alias":
+			t, _ := def["type"].(map[string]interface{})
+			d.members["type"] = specMember{kind: "type", signature: typeSignature(t)}
+		case "directive":
+			d.members["signature"] = specMember{kind: "signature", signature: operationSignature(def)}
+		}
+		defs[kind+" "+d.name] = d
+	}
+
+	return defs, nil
+}
+
+func operationSignature(op map[string]interface{}) string {
+	params := nodes(op["parameters"])
+	args := make([]string, len(params))
+	for i, param := range params {
+		t, _ := param["type"].(map[string]interface{})
+		args[i] = nameValue(param["name"]) + ": " + typeSignature(t)
+	}
+	signature := nameValue(op["name"]) + "(" + strings.Join(args, ", ") + ")"
+	if t, ok := op["type"].(map[string]interface{}); ok {
+		signature += ": " + typeSignature(t)
+	}
+	return signature
+}
+
+// typeSignature formats a type node as it is written in a specification.
+func typeSignature(t map[string]interface{}) string {
+	inner := func(key string) string {
+		n, _ := t[key].(map[string]interface{})
+		return typeSignature(n)
+	}
+	switch t["kind"] {
+	case "Named":
+		return nameValue(t["name"])
+	case "ListType":
+		return "[" + inner("type") + "]"
+	case "MapType":
+		return "{" + inner("keyType") + ": " + inner("valueType") + "}"
+	case "Optional":
+		return inner("type") + "?"
+	case "Stream":
+		return "stream " + inner("type")
+	case nil:
+		return "void"
+	}
+	return fmt.Sprint(t["kind"])
+}
+
+func nodes(value interface{}) []map[string]interface{} {
+	list, _ := value.([]interface{})
+	result := make([]map[string]interface{}, 0, len(list))
+	for _, item := range list {
+		if n, ok := item.(map[string]interface{}); ok {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// nodeValue returns the value of a literal node, such as an enum index.
+func nodeValue(node interface{}) interface{} {
+	if n, ok := node.(map[string]interface{}); ok {
+		return n["value"]
+	}
+	return node
+}
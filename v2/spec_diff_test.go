@@ -0,0 +1,74 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The fixtures are documents parsed from a specification
+// before and after changes to each kind of definition.
+func readSpecDiffFixture(t *testing.T, name string) string {
+	data, err := os.ReadFile(filepath.Join("testdata", "spec_diff", name))
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestDiffSpecs(t *testing.T) {
+	changes, err := diffSpecs(readSpecDiffFixture(t, "from.json"), readSpecDiffFixture(t, "to.json"))
+	require.NoError(t, err)
+	assert.Equal(t, []SpecChange{
+		{Change: ChangeAdded, Definition: "alias", Name: "ID"},
+		{Change: ChangeRemoved, Definition: "alias", Name: "UUID", Breaking: true},
+		{Change: ChangeAdded, Definition: "enum", Name: "Role", MemberKind: "value", Member: "guest", To: "guest = 2"},
+		{Change: ChangeAdded, Definition: "interface", Name: "Users", MemberKind: "operation", Member: "createUser",
+			To: "createUser(user: User): User"},
+		{Change: ChangeRemoved, Definition: "interface", Name: "Users", MemberKind: "operation", Member: "deleteUser",
+			From: "deleteUser(id: string)", Breaking: true},
+		{Change: ChangeChanged, Definition: "interface", Name: "Users", MemberKind: "operation", Member: "listUsers",
+			From: "listUsers(limit: u32): [User]", To: "listUsers(limit: u32, offset: u32): [User]", Breaking: true},
+		{Change: ChangeAdded, Definition: "type", Name: "Error"},
+		// Adding a field is breaking unless it is optional or has a default.
+		{Change: ChangeAdded, Definition: "type", Name: "User", MemberKind: "field", Member: "age", To: "age: u8", Breaking: true},
+		{Change: ChangeAdded, Definition: "type", Name: "User", MemberKind: "field", Member: "email", To: "email: string?"},
+		{Change: ChangeChanged, Definition: "type", Name: "User", MemberKind: "field", Member: "name",
+			From: "name: string", To: "name: string?", Breaking: true},
+		{Change: ChangeRemoved, Definition: "type", Name: "User", MemberKind: "field", Member: "nickname",
+			From: "nickname: string?", Breaking: true},
+		{Change: ChangeAdded, Definition: "type", Name: "User", MemberKind: "field", Member: "role", To: "role: Role"},
+		{Change: ChangeChanged, Definition: "union", Name: "Result", MemberKind: "types", Member: "types",
+			From: "User | Error", To: "User | Error | Missing", Breaking: true},
+	}, changes)
+
+	assert.Equal(t, readSpecDiffFixture(t, "changelog.md"), formatChangelog("v1", "v2", changes))
+}
+
+func TestDiffSpecsUnchanged(t *testing.T) {
+	from := readSpecDiffFixture(t, "from.json")
+	changes, err := diffSpecs(from, from)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+	assert.Equal(t, "## API changes from v1 to v1\n\nNo API changes.\n", formatChangelog("v1", "v1", changes))
+
+	_, err = diffSpecs(from, "{")
+	assert.Error(t, err)
+}
@@ -0,0 +1,466 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+type SpecFormatCmd struct {
+	Specs []string `arg:"" optional:"" help:"The specification files to format, or - for stdin. Defaults to spec.apex."`
+	Check bool     `help:"Check that the specifications are formatted without writing them."`
+	Core  string   `help:"The module used to parse the specifications." default:"@apexlang/core"`
+}
+
+func (c *SpecFormatCmd) Run(ctx *Context) error {
+	homeDir, err := getHomeDirectory()
+	if err != nil {
+		return err
+	}
+	core := resolveModuleAlias(c.Core, projectAliases())
+
+	specs := c.Specs
+	if len(specs) == 0 {
+		specs = []string{"spec.apex"}
+	}
+	var unformatted []string
+	for _, specFile := range specs {
+		var specBytes []byte
+		if specFile == "-" {
+			specBytes, err = limitReader("<stdin>", os.Stdin, MaxSpecSize)
+		} else {
+			specBytes, err = readLocalFile(specFile, MaxSpecSize)
+		}
+		if err != nil {
+			return err
+		}
+
+		formatted, err := formatSpec(homeDir, core, string(specBytes))
+		if err != nil {
+			return fmt.Errorf("could not format %s: %w", specFile, err)
+		}
+		switch {
+		case formatted == string(specBytes):
+			continue
+		case c.Check:
+			fmt.Printf("Not formatted: %s\n", specFile)
+			unformatted = append(unformatted, specFile)
+			continue
+		case specFile == "-":
+			fmt.Print(formatted)
+			continue
+		}
+
+		stat, err := os.Stat(specFile)
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(specFile, []byte(formatted), stat.Mode()); err != nil {
+			return err
+		}
+		fmt.Printf("Formatted %s\n", specFile)
+	}
+
+	if len(unformatted) > 0 {
+		return fmt.Errorf("%d specification(s) are not formatted", len(unformatted))
+	}
+	return nil
+}
+
+// formatSpec parses a specification with the core module and prints it
+// in canonical style: two space indentation, one definition per block,
+// and descriptions on the line before what they describe. The result is
+// parsed again and must produce the same document.
+func formatSpec(homeDir, core, spec string) (string, error) {
+	if hasSpecComments(spec) {
+		return "", errors.New("the specification has # comments, which formatting would remove")
+	}
+	docJSON, err := parseSpec(homeDir, core, spec)
+	if err != nil {
+		return "", err
+	}
+	doc, err := decodeSpecDocument(docJSON)
+	if err != nil {
+		return "", err
+	}
+
+	// Multi-line descriptions are printed as block strings unless
+	// that changes their value, which is then kept on one line.
+	for _, blockStrings := range []bool{true, false} {
+		p := specPrinter{spec: spec, blockStrings: blockStrings}
+		formatted := p.print(doc)
+		formattedJSON, err := parseSpec(homeDir, core, formatted)
+		if err == nil {
+			var formattedDoc map[string]interface{}
+			if formattedDoc, err = decodeSpecDocument(formattedJSON); err == nil &&
+				reflect.DeepEqual(withoutLocations(doc), withoutLocations(formattedDoc)) {
+				return formatted, nil
+			}
+		}
+		if !p.usedBlockStrings {
+			break
+		}
+	}
+	return "", errors.New("the specification cannot be formatted without changing its definitions")
+}
+
+func decodeSpecDocument(docJSON string) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	dec := json.NewDecoder(strings.NewReader(docJSON))
+	// Numbers keep their text so that 1.0 is not printed as 1.
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("could not decode document: %w", err)
+	}
+	return doc, nil
+}
+
+// withoutLocations returns a copy of a node without its source locations.
+func withoutLocations(node interface{}) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(n))
+		for key, value := range n {
+			if key != "loc" {
+				copied[key] = withoutLocations(value)
+			}
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(n))
+		for i, value := range n {
+			copied[i] = withoutLocations(value)
+		}
+		return copied
+	}
+	return node
+}
+
+// hasSpecComments reports whether a specification has # comments
+// outside of strings. The parsed document does not include them.
+func hasSpecComments(spec string) bool {
+	for i := 0; i < len(spec); i++ {
+		switch {
+		case strings.HasPrefix(spec[i:], `"""`):
+			for i += 3; i < len(spec) && !strings.HasPrefix(spec[i:], `"""`); i++ {
+				if strings.HasPrefix(spec[i:], `\"""`) {
+					i += 3
+				}
+			}
+			i += 2
+		case spec[i] == '"':
+			for i++; i < len(spec) && spec[i] != '"' && spec[i] != '\n'; i++ {
+				if spec[i] == '\\' {
+					i++
+				}
+			}
+		case spec[i] == '#':
+			return true
+		}
+	}
+	return false
+}
+
+// specPrinter prints a parsed document as a specification.
+type specPrinter struct {
+	b strings.Builder
+	// spec is the source of the document.
+	spec             string
+	blockStrings     bool
+	usedBlockStrings bool
+}
+
+func (p *specPrinter) print(doc map[string]interface{}) string {
+	previous := ""
+	for _, def := range nodes(doc["definitions"]) {
+		kind := fmt.Sprint(def["kind"])
+		// Imports are grouped, and other definitions are
+		// separated by a blank line.
+		if previous != "" && (kind != "ImportDefinition" || previous != kind) {
+			p.b.WriteString("\n")
+		}
+		previous = kind
+		p.definition(def)
+	}
+	return p.b.String()
+}
+
+func (p *specPrinter) definition(def map[string]interface{}) {
+	p.description(def, "")
+	name := nameValue(def["name"])
+	switch def["kind"] {
+	case "NamespaceDefinition":
+		if p.quotedNamespace(def) {
+			name = quoteSpecString(name)
+		}
+		p.b.WriteString("namespace " + name + p.annotations(def) + "\n")
+	case "ImportDefinition":
+		p.b.WriteString("import ")
+		if all, _ := def["all"].(bool); all {
+			p.b.WriteString("*")
+		} else {
+			var names []string
+			for _, n := range nodes(def["names"]) {
+				imported := nameValue(n["name"])
+				if alias := nameValue(n["alias"]); alias != "" {
+					imported += " as " + alias
+				}
+				names = append(names, imported)
+			}
+			p.b.WriteString("{ " + strings.Join(names, ", ") + " }")
+		}
+		p.b.WriteString(" from " + quoteSpecString(nameValue(def["from"])) + p.annotations(def) + "\n")
+	case "AliasDefinition":
+		t, _ := def["type"].(map[string]interface{})
+		p.b.WriteString("alias " + name + " = " + typeSignature(t) + p.annotations(def) + "\n")
+	case "TypeDefinition":
+		p.b.WriteString("type " + name + p.annotations(def) + " {\n")
+		for _, field := range nodes(def["fields"]) {
+			p.description(field, "  ")
+			p.b.WriteString("  " + p.parameter(field) + "\n")
+		}
+		p.b.WriteString("}\n")
+	case "InterfaceDefinition":
+		p.b.WriteString("interface " + name + p.annotations(def) + " {\n")
+		for _, op := range nodes(def["operations"]) {
+			p.description(op, "  ")
+			p.b.WriteString("  " + p.operation(op) + "\n")
+		}
+		p.b.WriteString("}\n")
+	case "UnionDefinition":
+		var types []string
+		for _, t := range nodes(def["types"]) {
+			types = append(types, typeSignature(t))
+		}
+		for _, m := range nodes(def["members"]) {
+			t, _ := m["type"].(map[string]interface{})
+			types = append(types, typeSignature(t)+p.annotations(m))
+		}
+		p.b.WriteString("union " + name + p.annotations(def) + " = " + strings.Join(types, " | ") + "\n")
+	case "EnumDefinition":
+		p.b.WriteString("enum " + name + p.annotations(def) + " {\n")
+		for _, value := range nodes(def["values"]) {
+			p.description(value, "  ")
+			p.b.WriteString("  " + nameValue(value["name"]))
+			if index, ok := value["index"].(map[string]interface{}); ok {
+				p.b.WriteString(" = " + specValue(index))
+			}
+			if display, ok := value["display"].(map[string]interface{}); ok {
+				p.b.WriteString(" as " + specValue(display))
+			}
+			p.b.WriteString(p.annotations(value) + "\n")
+		}
+		p.b.WriteString("}\n")
+	case "DirectiveDefinition":
+		p.b.WriteString("directive @" + name)
+		if params := nodes(def["parameters"]); len(params) > 0 {
+			p.b.WriteString("(" + p.parameters(params, "") + ")")
+		}
+		p.b.WriteString(" on " + joinNames(def["locations"]) + "\n")
+		for _, require := range nodes(def["requires"]) {
+			p.b.WriteString("  require @" + nameValue(require["directive"]) + " on " + joinNames(require["locations"]) + "\n")
+		}
+	}
+}
+
+// quotedNamespace reports whether the namespace is written as a string,
+// which earlier versions of the language required.
+func (p *specPrinter) quotedNamespace(def map[string]interface{}) bool {
+	loc, _ := def["loc"].(map[string]interface{})
+	start, err := jsonInt(loc["start"])
+	if err != nil {
+		return false
+	}
+	source := strings.TrimSpace(p.spec[utf16ToByteOffset(p.spec, start):])
+	source = strings.TrimSpace(strings.TrimPrefix(source, "namespace"))
+	return strings.HasPrefix(source, `"`)
+}
+
+func (p *specPrinter) operation(op map[string]interface{}) string {
+	params := nodes(op["parameters"])
+	openParams, closeParams := "(", ")"
+	if unary, _ := op["unary"].(bool); unary {
+		openParams, closeParams = "[", "]"
+	}
+	signature := nameValue(op["name"]) + openParams + p.parameters(params, "  ") + closeParams
+	if t, ok := op["type"].(map[string]interface{}); ok {
+		signature += ": " + typeSignature(t)
+	}
+	return signature + p.annotations(op)
+}
+
+// parameters prints parameters on one line, or one per line below indent
+// when they have descriptions.
+func (p *specPrinter) parameters(params []map[string]interface{}, indent string) string {
+	described := false
+	for _, param := range params {
+		described = described || param["description"] != nil
+	}
+	printed := make([]string, len(params))
+	for i, param := range params {
+		printed[i] = p.parameter(param)
+	}
+	if !described {
+		return strings.Join(printed, ", ")
+	}
+
+	var b strings.Builder
+	b.WriteString("\n")
+	for i, param := range params {
+		if description, ok := param["description"].(map[string]interface{}); ok {
+			b.WriteString(p.descriptionString(description, indent+"  ") + "\n")
+		}
+		b.WriteString(indent + "  " + printed[i])
+		if i < len(params)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(indent)
+	return b.String()
+}
+
+// parameter prints a field or parameter with its default and annotations.
+func (p *specPrinter) parameter(param map[string]interface{}) string {
+	t, _ := param["type"].(map[string]interface{})
+	printed := nameValue(param["name"]) + ": " + typeSignature(t)
+	if value, ok := param["default"].(map[string]interface{}); ok {
+		printed += " = " + specValue(value)
+	}
+	return printed + p.annotations(param)
+}
+
+func (p *specPrinter) annotations(node map[string]interface{}) string {
+	var b strings.Builder
+	for _, annotation := range nodes(node["annotations"]) {
+		b.WriteString(" @" + nameValue(annotation["name"]))
+		args := nodes(annotation["arguments"])
+		if len(args) == 0 {
+			continue
+		}
+		value, _ := args[0]["value"].(map[string]interface{})
+		// A single argument named value is written without its name.
+		if len(args) == 1 && nameValue(args[0]["name"]) == "value" {
+			b.WriteString("(" + specValue(value) + ")")
+			continue
+		}
+		printed := make([]string, len(args))
+		for i, arg := range args {
+			value, _ := arg["value"].(map[string]interface{})
+			printed[i] = nameValue(arg["name"]) + ": " + specValue(value)
+		}
+		b.WriteString("(" + strings.Join(printed, ", ") + ")")
+	}
+	return b.String()
+}
+
+func (p *specPrinter) description(node map[string]interface{}, indent string) {
+	if description, ok := node["description"].(map[string]interface{}); ok {
+		p.b.WriteString(p.descriptionString(description, indent) + "\n")
+	}
+}
+
+func (p *specPrinter) descriptionString(description map[string]interface{}, indent string) string {
+	value := nameValue(description)
+	if !p.blockStrings || !strings.Contains(value, "\n") {
+		return indent + quoteSpecString(value)
+	}
+	p.usedBlockStrings = true
+	lines := strings.Split(strings.ReplaceAll(value, `"""`, `\"""`), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = indent + line
+		}
+	}
+	return indent + `"""` + "\n" + strings.Join(lines, "\n") + "\n" + indent + `"""`
+}
+
+// specValue prints a value node, such as a default or annotation argument.
+func specValue(value map[string]interface{}) string {
+	switch value["kind"] {
+	case "StringValue":
+		return quoteSpecString(nameValue(value))
+	case "IntValue", "EnumValue", "BooleanValue":
+		return fmt.Sprint(value["value"])
+	case "FloatValue":
+		printed := fmt.Sprint(value["value"])
+		if !strings.ContainsAny(printed, ".eE") {
+			printed += ".0"
+		}
+		return printed
+	case "ListValue":
+		items := nodes(value["value"])
+		printed := make([]string, len(items))
+		for i, item := range items {
+			printed[i] = specValue(item)
+		}
+		return "[" + strings.Join(printed, ", ") + "]"
+	case "MapValue", "ObjectValue":
+		fields := nodes(value["fields"])
+		if value["kind"] == "MapValue" {
+			fields = nodes(value["value"])
+		}
+		printed := make([]string, len(fields))
+		for i, field := range fields {
+			key, _ := field["name"].(map[string]interface{})
+			name := nameValue(key)
+			if key["kind"] == "StringValue" {
+				name = quoteSpecString(name)
+			}
+			item, _ := field["value"].(map[string]interface{})
+			printed[i] = name + ": " + specValue(item)
+		}
+		return "{" + strings.Join(printed, ", ") + "}"
+	}
+	return fmt.Sprint(value["value"])
+}
+
+// quoteSpecString quotes a string, escaping it as JSON does.
+func quoteSpecString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return `""`
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func joinNames(value interface{}) string {
+	var names []string
+	for _, n := range nodes(value) {
+		names = append(names, nameValue(n))
+	}
+	return strings.Join(names, " | ")
+}
+
+func jsonInt(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case json.Number:
+		n, err := v.Int64()
+		return int(n), err
+	case float64:
+		return int(v), nil
+	}
+	return 0, fmt.Errorf("not a number: %v", value)
+}
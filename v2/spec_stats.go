@@ -0,0 +1,206 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+type SpecStatsCmd struct {
+	Spec string `arg:"" help:"The specification file." type:"existingfile" default:"spec.apex"`
+	Core string `help:"The module used to parse the specification." default:"@apexlang/core"`
+	// JSON prints the statistics as JSON, like the global --json flag.
+	JSON bool `kong:"-"`
+}
+
+// SpecStats counts the definitions in a specification.
+type SpecStats struct {
+	Namespaces  int            `json:"namespaces"`
+	Interfaces  int            `json:"interfaces"`
+	Operations  int            `json:"operations"`
+	Types       int            `json:"types"`
+	Fields      int            `json:"fields"`
+	Enums       int            `json:"enums"`
+	Unions      int            `json:"unions"`
+	Aliases     int            `json:"aliases"`
+	Directives  int            `json:"directives"`
+	Imports     []string       `json:"imports"`
+	Annotations map[string]int `json:"annotations"`
+}
+
+func (c *SpecStatsCmd) Run(ctx *Context) error {
+	homeDir, err := getHomeDirectory()
+	if err != nil {
+		return err
+	}
+
+	specBytes, err := readLocalFile(c.Spec, MaxSpecSize)
+	if err != nil {
+		return err
+	}
+	docJSON, err := parseSpec(homeDir, resolveModuleAlias(c.Core, projectAliases()), string(specBytes))
+	if err != nil {
+		return fmt.Errorf("could not parse %s: %w", c.Spec, err)
+	}
+	stats, err := specStats(docJSON)
+	if err != nil {
+		return err
+	}
+
+	if c.JSON || (ctx != nil && ctx.JSON) {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	}
+
+	t := table.NewWriter()
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{
+			Name:   "Definition",
+			Colors: text.Colors{text.FgGreen},
+		},
+		{
+			Name:   "Count",
+			Colors: text.Colors{text.FgCyan},
+		},
+	})
+	t.AppendHeader(table.Row{"Definition", "Count"})
+	t.AppendRows([]table.Row{
+		{"Namespaces", stats.Namespaces},
+		{"Interfaces", stats.Interfaces},
+		{"Operations", stats.Operations},
+		{"Types", stats.Types},
+		{"Fields", stats.Fields},
+		{"Enums", stats.Enums},
+		{"Unions", stats.Unions},
+		{"Aliases", stats.Aliases},
+		{"Directives", stats.Directives},
+		{"Imports", len(stats.Imports)},
+	})
+	fmt.Println(t.Render())
+
+	if len(stats.Annotations) > 0 {
+		names := make([]string, 0, len(stats.Annotations))
+		for name := range stats.Annotations {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		t = table.NewWriter()
+		t.SetColumnConfigs([]table.ColumnConfig{
+			{
+				Name:   "Annotation",
+				Colors: text.Colors{text.FgGreen},
+			},
+			{
+				Name:   "Uses",
+				Colors: text.Colors{text.FgCyan},
+			},
+		})
+		t.AppendHeader(table.Row{"Annotation", "Uses"})
+		for _, name := range names {
+			t.AppendRow(table.Row{"@" + name, stats.Annotations[name]})
+		}
+		fmt.Println(t.Render())
+	}
+
+	for _, from := range stats.Imports {
+		fmt.Printf("Imports %s\n", from)
+	}
+
+	return nil
+}
+
+// specStats counts the definitions in a parsed document. Annotations
+// are counted wherever they appear, such as on fields and parameters.
+func specStats(docJSON string) (*SpecStats, error) {
+	var doc struct {
+		Definitions []map[string]interface{} `json:"definitions"`
+	}
+	if err := json.Unmarshal([]byte(docJSON), &doc); err != nil {
+		return nil, fmt.Errorf("could not decode document: %w", err)
+	}
+
+	stats := SpecStats{
+		Imports:     []string{},
+		Annotations: map[string]int{},
+	}
+	for _, def := range doc.Definitions {
+		switch def["kind"] {
+		case "NamespaceDefinition":
+			stats.Namespaces++
+		case "InterfaceDefinition":
+			stats.Interfaces++
+			operations, _ := def["operations"].([]interface{})
+			stats.Operations += len(operations)
+		case "TypeDefinition":
+			stats.Types++
+			fields, _ := def["fields"].([]interface{})
+			stats.Fields += len(fields)
+		case "EnumDefinition":
+			stats.Enums++
+		case "UnionDefinition":
+			stats.Unions++
+		case "AliasDefinition":
+			stats.Aliases++
+		case "DirectiveDefinition":
+			stats.Directives++
+		case "ImportDefinition":
+			stats.Imports = append(stats.Imports, nameValue(def["from"]))
+		}
+		countAnnotations(def, stats.Annotations)
+	}
+	sort.Strings(stats.Imports)
+
+	return &stats, nil
+}
+
+func countAnnotations(node interface{}, counts map[string]int) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if n["kind"] == "Annotation" {
+			counts[nameValue(n["name"])]++
+		}
+		for key, value := range n {
+			// Skip locations and names, which cannot contain annotations.
+			if key == "loc" || key == "name" {
+				continue
+			}
+			countAnnotations(value, counts)
+		}
+	case []interface{}:
+		for _, value := range n {
+			countAnnotations(value, counts)
+		}
+	}
+}
+
+// nameValue returns the value of a Name or StringValue node.
+func nameValue(node interface{}) string {
+	if n, ok := node.(map[string]interface{}); ok {
+		if value, ok := n["value"].(string); ok {
+			return value
+		}
+	}
+	return ""
+}
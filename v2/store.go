@@ -0,0 +1,272 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// homeRedirectFile is left in a home directory moved with store move and
+// holds its new location, so processes still using the old one find it.
+const homeRedirectFile = "redirect"
+
+// maxHomeRedirects limits how many moves are followed, in case
+// redirects point at each other.
+const maxHomeRedirects = 8
+
+// followHomeRedirect returns where a home directory was moved to,
+// or dir if it was not moved.
+func followHomeRedirect(dir string) (string, error) {
+	for i := 0; i < maxHomeRedirects; i++ {
+		data, err := readLocalFile(filepath.Join(dir, homeRedirectFile), MaxConfigSize)
+		if os.IsNotExist(err) {
+			return dir, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		target := strings.TrimSpace(string(data))
+		if !filepath.IsAbs(target) {
+			return "", fmt.Errorf("%s must hold an absolute path", filepath.Join(dir, homeRedirectFile))
+		}
+		dir = target
+	}
+	return "", fmt.Errorf("home directory redirects from %s do not end", dir)
+}
+
+// StoreCmd manages the home directory holding installed modules,
+// caches, and configuration.
+type StoreCmd struct {
+	Move StoreMoveCmd `cmd:"" help:"Moves the home directory, such as to a larger disk."`
+}
+
+type StoreMoveCmd struct {
+	Destination string `arg:"" help:"The new location of the home directory, which must not exist or be empty."`
+}
+
+func (c *StoreMoveCmd) Run(ctx *Context) error {
+	src, err := apexHomeDir()
+	if err != nil {
+		return err
+	}
+	if fi, err := os.Stat(src); err != nil || !fi.IsDir() {
+		return fmt.Errorf("there is no home directory at %s to move", src)
+	}
+	dest, err := homedir.Expand(c.Destination)
+	if err != nil {
+		return err
+	}
+	if dest, err = filepath.Abs(dest); err != nil {
+		return err
+	}
+	if isWithin(src, dest) || isWithin(dest, src) {
+		return fmt.Errorf("cannot move %s to %s", src, dest)
+	}
+	created := true
+	if entries, err := os.ReadDir(dest); err == nil {
+		if len(entries) > 0 {
+			return fmt.Errorf("%s is not empty", dest)
+		}
+		created = false
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	// Checksums taken before the move are compared
+	// with the moved files before anything is removed.
+	before, err := hashTree(src)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Moving %s to %s\n", src, dest)
+	if err = os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	copied := false
+	if err = os.Rename(src, dest); err != nil {
+		// Directories on other file systems are copied.
+		if err = copyTree(src, dest); err != nil {
+			removeCopy(dest, created)
+			return fmt.Errorf("could not copy %s to %s: %w", src, dest, err)
+		}
+		copied = true
+	}
+
+	after, err := hashTree(dest)
+	if err == nil {
+		err = compareTrees(before, after)
+	}
+	if err != nil {
+		if copied {
+			removeCopy(dest, created)
+		}
+		return fmt.Errorf("the home directory moved to %s does not match %s: %w", dest, src, err)
+	}
+	if copied {
+		if err = os.RemoveAll(src); err != nil {
+			return fmt.Errorf("the home directory was copied to %s but %s could not be removed: %w", dest, src, err)
+		}
+	}
+
+	if err = os.MkdirAll(src, 0700); err != nil {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(src, homeRedirectFile), []byte(dest+"\n"), 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("Moved the home directory to %s\n", dest)
+	if os.Getenv(HomeEnv) != "" {
+		fmt.Printf("Update %s to %s where it is set, such as in your shell profile.\n", HomeEnv, dest)
+	} else {
+		fmt.Printf("%s redirects to the new location. Set %s=%s to use it directly.\n", src, HomeEnv, dest)
+	}
+	return nil
+}
+
+// isWithin reports whether path is dir or inside of it.
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// removeCopy removes an incomplete copy, keeping the
+// destination directory when it existed before.
+func removeCopy(dest string, created bool) {
+	if created {
+		os.RemoveAll(dest)
+		return
+	}
+	entries, _ := os.ReadDir(dest)
+	for _, entry := range entries {
+		os.RemoveAll(filepath.Join(dest, entry.Name()))
+	}
+}
+
+// hashTree returns a digest of each entry under dir by its relative
+// path: the checksum of files and the target of symbolic links.
+func hashTree(dir string) (map[string]string, error) {
+	tree := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			tree[rel] = "dir"
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			tree[rel] = "link:" + target
+		case !d.Type().IsRegular():
+			// Sockets and pipes are not moved.
+		default:
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			h := sha256.New()
+			if _, err = io.Copy(h, f); err != nil {
+				return err
+			}
+			tree[rel] = hex.EncodeToString(h.Sum(nil))
+		}
+		return nil
+	})
+	return tree, err
+}
+
+// compareTrees returns an error naming an entry that
+// differs between two trees from hashTree.
+func compareTrees(before, after map[string]string) error {
+	for path, digest := range before {
+		moved, ok := after[path]
+		if !ok {
+			return fmt.Errorf("%s is missing", path)
+		}
+		if moved != digest {
+			return fmt.Errorf("%s differs", path)
+		}
+	}
+	if len(after) != len(before) {
+		return errors.New("it has files that were not moved")
+	}
+	return nil
+}
+
+// copyTree copies dir to dest, keeping the modes of
+// files and directories and symbolic links as they are.
+func copyTree(dir, dest string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case !info.Mode().IsRegular():
+			return nil
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(out, src); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
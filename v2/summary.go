@@ -0,0 +1,137 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How a command prints its summary when it finishes. Commands
+// run from Go print nothing unless a format is set.
+const (
+	SummaryText = "text"
+	SummaryJSON = "json"
+	SummaryNone = "none"
+)
+
+// Summary is the outcome of a generate, install, or upgrade command.
+// It is printed when the command finishes and is available afterwards
+// from the command's Summary method.
+type Summary struct {
+	Command string `json:"command"`
+	// Generated files are counted by whether they were new, changed,
+	// unchanged, or skipped because they already existed.
+	FilesWritten     int `json:"filesWritten"`
+	FilesUpdated     int `json:"filesUpdated"`
+	FilesUnchanged   int `json:"filesUnchanged"`
+	FilesSkipped     int `json:"filesSkipped"`
+	TargetsSucceeded int `json:"targetsSucceeded"`
+	TargetsFailed    int `json:"targetsFailed"`
+	ModulesInstalled int `json:"modulesInstalled"`
+	ModulesFailed    int `json:"modulesFailed"`
+	// CacheHits counts downloads and generated files served
+	// from a cache.
+	CacheHits int           `json:"cacheHits"`
+	Duration  time.Duration `json:"-"`
+
+	started time.Time
+	mu      sync.Mutex
+}
+
+func newSummary(command string) *Summary {
+	return &Summary{
+		Command: command,
+		started: time.Now(),
+	}
+}
+
+// add updates the summary, which may be shared by concurrent
+// workers. It does nothing for a nil summary.
+func (s *Summary) add(update func(s *Summary)) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	update(s)
+}
+
+// failTargets counts every target of a config that could not be generated.
+func (s *Summary) failTargets(config Config) {
+	s.add(func(s *Summary) { s.TargetsFailed += len(config.Generates) })
+}
+
+// install counts a module by whether it installed.
+func (s *Summary) install(err error) {
+	s.add(func(s *Summary) {
+		if err != nil {
+			s.ModulesFailed++
+		} else {
+			s.ModulesInstalled++
+		}
+	})
+}
+
+// finish records the duration and prints the summary in format.
+func (s *Summary) finish(format string) {
+	s.Duration = time.Since(s.started)
+
+	switch format {
+	case SummaryText:
+		fmt.Println(s.String())
+	case SummaryJSON:
+		data, err := json.Marshal(struct {
+			*Summary
+			DurationMillis int64 `json:"durationMs"`
+		}{s, s.Duration.Milliseconds()})
+		if err != nil {
+			fmt.Printf("Could not write summary: %v\n", err)
+			return
+		}
+		fmt.Println(string(data))
+	}
+}
+
+// String returns a single line with the counts that are
+// relevant to the command and its duration.
+func (s *Summary) String() string {
+	var parts []string
+	add := func(count int, format string, always bool) {
+		if count > 0 || always {
+			parts = append(parts, fmt.Sprintf(format, count))
+		}
+	}
+	switch s.Command {
+	case "generate":
+		add(s.FilesWritten, "%d written", true)
+		add(s.FilesUpdated, "%d updated", true)
+		add(s.FilesUnchanged, "%d unchanged", false)
+		add(s.FilesSkipped, "%d skipped", true)
+		add(s.TargetsSucceeded, "%d targets succeeded", true)
+		add(s.TargetsFailed, "%d failed", true)
+	default:
+		add(s.ModulesInstalled, "%d modules installed", true)
+		add(s.ModulesFailed, "%d failed", true)
+		add(s.CacheHits, "%d cache hits", false)
+	}
+	return fmt.Sprintf("%s: %s in %s", capitalize(s.Command), strings.Join(parts, ", "),
+		s.Duration.Round(time.Millisecond))
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// TemplateData is passed to text/templates rendered by
+// targets using the template engine.
+type TemplateData struct {
+	// Document is the parsed spec as generic JSON.
+	Document interface{}
+	// Config is the merged global and target config.
+	Config map[string]interface{}
+	// Filename is the output filename.
+	Filename string
+}
+
+var templateFuncs = template.FuncMap{
+	"lower":     strings.ToLower,
+	"upper":     strings.ToUpper,
+	"title":     strings.Title,
+	"join":      strings.Join,
+	"replace":   strings.ReplaceAll,
+	"hasPrefix": strings.HasPrefix,
+	"hasSuffix": strings.HasSuffix,
+	"json": func(v interface{}) (string, error) {
+		b, err := json.MarshalIndent(v, "", "  ")
+		return string(b), err
+	},
+}
+
+// renderTemplate parses the spec with the target's core module and renders the
+// target's Go text/template with the resulting document.
+func (c *GenerateCmd) renderTemplate(ctx context.Context, homeDir, spec, filename string, target Target, configMap map[string]interface{}) (string, error) {
+	templateBytes, err := os.ReadFile(target.Template)
+	if err != nil {
+		return "", err
+	}
+
+	tmpl, err := template.New(filepath.Base(target.Template)).
+		Funcs(templateFuncs).
+		Parse(string(templateBytes))
+	if err != nil {
+		return "", err
+	}
+
+	docJSON, err := parseSpecContext(ctx, homeDir, target.Core, spec)
+	if err != nil {
+		return "", err
+	}
+
+	var doc interface{}
+	if err = json.Unmarshal([]byte(docJSON), &doc); err != nil {
+		return "", fmt.Errorf("could not decode document: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, TemplateData{
+		Document: doc,
+		Config:   configMap,
+		Filename: filename,
+	}); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
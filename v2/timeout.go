@@ -0,0 +1,87 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The steps of generation that a timeout can interrupt.
+const (
+	phaseBundling   = "bundling"
+	phaseFormatting = "formatting"
+	phaseCommand    = "running a command"
+)
+
+// TimeoutError is returned when a step of generation is interrupted
+// by the target's timeoutSeconds or the --timeout of generate.
+type TimeoutError struct {
+	// Phase is the step that was interrupted, such as formatting.
+	Phase string
+	// Filename is the target being generated, if known.
+	Filename string
+	Err      error
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Filename == "" {
+		return fmt.Sprintf("timed out %s: %v", e.Phase, e.Err)
+	}
+	return fmt.Sprintf("timed out %s for %s: %v", e.Phase, e.Filename, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// timeoutError returns a *TimeoutError for phase when ctx is done,
+// and otherwise err unchanged.
+func timeoutError(ctx context.Context, phase string, err error) error {
+	if ctx.Err() == nil {
+		return err
+	}
+	return &TimeoutError{Phase: phase, Err: ctx.Err()}
+}
+
+// withFilename sets the target of a *TimeoutError in err.
+func withFilename(err error, filename string) error {
+	var terr *TimeoutError
+	if errors.As(err, &terr) && terr.Filename == "" {
+		terr.Filename = filename
+	}
+	return err
+}
+
+// context returns the context that bounds the whole run by --timeout.
+func (c *GenerateCmd) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// stepContext returns a context for one step of generating target,
+// bounded by its timeoutSeconds as well as --timeout.
+func (c *GenerateCmd) stepContext(target Target) (context.Context, context.CancelFunc) {
+	if target.TimeoutSeconds <= 0 {
+		return context.WithCancel(c.context())
+	}
+	return context.WithTimeout(c.context(), time.Duration(target.TimeoutSeconds)*time.Second)
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// TmpDirEnv is set to the target's temporary workspace
+// while its runAfter commands run.
+const TmpDirEnv = "APEX_TMPDIR"
+
+// tmpdirPlaceholder is replaced with the target's temporary
+// workspace in its runAfter commands and their directories.
+const tmpdirPlaceholder = "{{tmpdir}}"
+
+// unsafeWorkspaceChars are replaced in the names of
+// temporary workspaces created for targets.
+var unsafeWorkspaceChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// newTargetWorkspace creates an empty temporary directory for the
+// target generating filename. Each target gets its own directory, so
+// targets and concurrent runs never share scratch files. The returned
+// function removes the directory and everything written to it.
+func newTargetWorkspace(filename string) (string, func(), error) {
+	name := unsafeWorkspaceChars.ReplaceAllString(filepath.Base(filename), "_")
+	dir, err := os.MkdirTemp("", "apex-"+name+"-")
+	if err != nil {
+		return "", nil, err
+	}
+	return dir, func() { os.RemoveAll(dir) }, nil
+}
+
+// inWorkspace returns the command with {{tmpdir}} replaced by
+// the workspace, which is also set in the environment as
+// APEX_TMPDIR.
+func (c Command) inWorkspace(dir string) Command {
+	c.Command = strings.ReplaceAll(c.Command, tmpdirPlaceholder, dir)
+	c.Dir = strings.ReplaceAll(c.Dir, tmpdirPlaceholder, dir)
+	c.env = append(c.env, TmpDirEnv+"="+dir)
+	return c
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CABundleEnv lists PEM files of extra root certificates to trust,
+// separated like PATH, such as for a registry using an internal CA
+// or a proxy that intercepts TLS.
+const CABundleEnv = "APEX_CA_BUNDLE"
+
+var (
+	rootCAs     *x509.CertPool
+	rootCAsErr  error
+	rootCAsOnce sync.Once
+)
+
+// caBundles returns the files of extra root certificates from
+// APEX_CA_BUNDLE, ~/.apex/config.yaml, and .apexrc.yaml.
+func caBundles() ([]string, error) {
+	var bundles []string
+	if env := os.Getenv(CABundleEnv); env != "" {
+		for _, path := range filepath.SplitList(env) {
+			if path != "" {
+				bundles = append(bundles, path)
+			}
+		}
+	}
+
+	user, err := readUserConfig()
+	if err != nil {
+		return nil, err
+	}
+	if user.CABundle != "" {
+		bundles = append(bundles, user.CABundle)
+	}
+
+	project, err := readProjectConfig()
+	if err != nil {
+		return nil, err
+	}
+	if project.CABundle != "" {
+		path := project.CABundle
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(project.dir, path)
+		}
+		bundles = append(bundles, path)
+	}
+
+	return bundles, nil
+}
+
+// loadRootCAs returns the system's root certificates with those of
+// the CA bundles added, or nil when there are no bundles so the
+// system's are used unchanged.
+func loadRootCAs() (*x509.CertPool, error) {
+	rootCAsOnce.Do(func() {
+		bundles, err := caBundles()
+		if err != nil || len(bundles) == 0 {
+			rootCAsErr = err
+			return
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, bundle := range bundles {
+			data, err := os.ReadFile(bundle)
+			if err != nil {
+				rootCAsErr = fmt.Errorf("could not read CA bundle: %w", err)
+				return
+			}
+			if !pool.AppendCertsFromPEM(data) {
+				rootCAsErr = fmt.Errorf("no PEM certificates found in CA bundle %s", bundle)
+				return
+			}
+		}
+		rootCAs = pool
+	})
+	return rootCAs, rootCAsErr
+}
+
+// newHTTPTransport returns a transport that connects through the proxy
+// set by HTTP_PROXY, HTTPS_PROXY, and NO_PROXY and trusts the extra
+// root certificates. Every HTTP client in the CLI starts from one.
+func newHTTPTransport() (*http.Transport, error) {
+	roots, err := loadRootCAs()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if roots != nil {
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	}
+	return transport, nil
+}
+
+// newHTTPClient returns a client for requests outside of installs,
+// such as reading remote specs and configurations.
+func newHTTPClient() (*http.Client, error) {
+	transport, err := newHTTPTransport()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: transport}, nil
+}
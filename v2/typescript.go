@@ -0,0 +1,169 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// typeScriptEntrypoints are checked, in order, for modules
+// that ship TypeScript sources without a prebuilt dist.
+var typeScriptEntrypoints = []string{
+	"src/index.ts",
+	"index.ts",
+}
+
+// resolveModuleImport returns the import path to use for a target's module.
+// Relative and absolute paths (including .ts files) are bundled as-is. For
+// installed packages whose declared entrypoint has not been built, the
+// TypeScript sources are imported directly so esbuild compiles them.
+func resolveModuleImport(homeDir, workingDir, module string) string {
+	if strings.HasPrefix(module, ".") || filepath.IsAbs(module) {
+		return module
+	}
+
+	bases := []string{workingDir}
+	for _, home := range moduleHomes(homeDir) {
+		bases = append(bases, filepath.Join(home, "node_modules"))
+	}
+	for _, base := range bases {
+		pkgDir := filepath.Join(base, filepath.FromSlash(module))
+		if _, err := os.Stat(filepath.Join(pkgDir, "package.json")); err != nil {
+			continue
+		}
+		if hasBuiltEntrypoint(pkgDir) {
+			return module
+		}
+		if entry, ok := findTypeScriptEntrypoint(pkgDir); ok {
+			return filepath.ToSlash(entry)
+		}
+		return module
+	}
+
+	return module
+}
+
+// hasBuiltEntrypoint reports whether the file referenced by the
+// package's "module" or "main" field exists. Packages with an
+// "exports" map are assumed to be resolvable by esbuild.
+func hasBuiltEntrypoint(pkgDir string) bool {
+	data, err := os.ReadFile(filepath.Join(pkgDir, "package.json"))
+	if err != nil {
+		return false
+	}
+	var pkg struct {
+		Exports json.RawMessage `json:"exports"`
+		Module  string          `json:"module"`
+		Main    string          `json:"main"`
+	}
+	if err = json.Unmarshal(data, &pkg); err != nil {
+		return false
+	}
+	if len(pkg.Exports) > 0 {
+		return true
+	}
+
+	candidates := []string{pkg.Module, pkg.Main}
+	if pkg.Module == "" && pkg.Main == "" {
+		candidates = []string{"index.js"}
+	}
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		path := filepath.Join(pkgDir, filepath.FromSlash(candidate))
+		for _, p := range []string{path, path + ".js", filepath.Join(path, "index.js")} {
+			if fi, err := os.Stat(p); err == nil && !fi.IsDir() {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func findTypeScriptEntrypoint(pkgDir string) (string, bool) {
+	for _, entry := range typeScriptEntrypoints {
+		path := filepath.Join(pkgDir, filepath.FromSlash(entry))
+		if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// buildTypeScript compiles the TypeScript sources next to a module's
+// entrypoint into its dist directory with esbuild, keeping the directory
+// layout so src/index.ts becomes dist/index.js. Unlike the npm build, no
+// package scripts run. Imports of other packages are left to be resolved
+// when the module is bundled during generation.
+func buildTypeScript(pkgDir, entrypoint string) error {
+	srcDir := filepath.Dir(entrypoint)
+	var entryPoints []string
+	err := filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != srcDir && (name == "dist" || name == "node_modules" || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isTypeScriptSource(name) {
+			entryPoints = append(entryPoints, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	result := api.Build(api.BuildOptions{
+		EntryPoints:   entryPoints,
+		Outbase:       srcDir,
+		Outdir:        filepath.Join(pkgDir, "dist"),
+		AbsWorkingDir: pkgDir,
+		Platform:      api.PlatformNeutral,
+		Format:        api.FormatESModule,
+		Sourcemap:     api.SourceMapLinked,
+		LogLevel:      api.LogLevelWarning,
+		Write:         true,
+	})
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("esbuild returned errors: %v", result.Errors)
+	}
+	return nil
+}
+
+// isTypeScriptSource reports whether a file is compiled by buildTypeScript,
+// which excludes declarations and tests.
+func isTypeScriptSource(name string) bool {
+	if !strings.HasSuffix(name, ".ts") || strings.HasSuffix(name, ".d.ts") {
+		return false
+	}
+	base := strings.TrimSuffix(name, ".ts")
+	return !strings.HasSuffix(base, ".test") && !strings.HasSuffix(base, ".spec")
+}
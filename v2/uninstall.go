@@ -0,0 +1,207 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// installManifestFile is written to an installed module's root
+// and lists everything the install added to the home directory.
+const installManifestFile = ".apex-install.json"
+
+type installManifest struct {
+	// Location is where the module was installed from and Tag is
+	// the release that was installed, which for NPM is its version.
+	Location string `json:"location,omitempty"`
+	Tag      string `json:"tag,omitempty"`
+	Version  string `json:"version,omitempty"`
+	// Requested is the tag or version asked for at install time.
+	Requested     string `json:"requested,omitempty"`
+	RequestedType string `json:"requestedType,omitempty"`
+	// InstalledAt is when the module was installed.
+	InstalledAt time.Time `json:"installedAt"`
+	// Parts are those selected with install --only, or all when empty.
+	Parts []string `json:"parts,omitempty"`
+	// Paths are relative to the home directory and use forward slashes.
+	Paths []string `json:"paths"`
+}
+
+func (m *installManifest) write(moduleRoot string) error {
+	sort.Strings(m.Paths)
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(moduleRoot, installManifestFile), append(data, '\n'), 0644)
+}
+
+// hasParts reports whether the parts were installed.
+func (m *installManifest) hasParts(parts []string) bool {
+	if len(m.Parts) == 0 {
+		return true
+	}
+	if len(parts) == 0 {
+		parts = []string{PartCode, PartDefinitions, PartTemplates}
+	}
+	for _, part := range parts {
+		if !containsString(m.Parts, part) {
+			return false
+		}
+	}
+	return true
+}
+
+func readInstallManifest(moduleRoot string) (*installManifest, error) {
+	data, err := os.ReadFile(filepath.Join(moduleRoot, installManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var m installManifest
+	if err = json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", installManifestFile, err)
+	}
+	return &m, nil
+}
+
+type UninstallCmd struct {
+	Module  string `arg:"" help:"The installed module to remove (e.g. @apexlang/codegen)."`
+	DryRun  bool   `help:"Show what would be removed without removing it."`
+	Project bool   `help:"Remove the module from the project's .apex directory instead of the user's home directory. This is the default when .apexrc.yaml sets local."`
+	// Local is an alias of Project.
+	Local bool `help:"Alias for --project." hidden:""`
+}
+
+func (c *UninstallCmd) Run(ctx *Context) error {
+	module := resolveModuleAlias(c.Module, projectAliases())
+	if module == "" || strings.Contains(module, "..") || filepath.IsAbs(module) {
+		return fmt.Errorf("invalid module %s", c.Module)
+	}
+
+	// Use the home directory as is so base
+	// dependencies are not installed first.
+	homeDir, err := ensureHomeDirectory()
+	if c.Project || c.Local || projectLocal() {
+		homeDir, err = filepath.Abs(ProjectHomeDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	paths, err := uninstallPaths(homeDir, module)
+	if err != nil {
+		return err
+	}
+
+	if c.DryRun {
+		for _, path := range paths {
+			fmt.Printf("Would remove %s\n", filepath.Join(homeDir, path))
+		}
+		return nil
+	}
+
+	if err = removeAtomically(homeDir, paths); err != nil {
+		return err
+	}
+	for _, path := range paths {
+		fmt.Printf("Removed %s\n", filepath.Join(homeDir, path))
+	}
+
+	// Clean up empty organization directories.
+	for _, path := range paths {
+		parent := filepath.Dir(filepath.Join(homeDir, path))
+		if filepath.Dir(parent) != homeDir && strings.HasPrefix(filepath.Base(parent), "@") {
+			os.Remove(parent) // Fails unless empty.
+		}
+	}
+
+	return nil
+}
+
+// uninstallPaths returns the paths, relative to the home directory,
+// that were populated when module was installed.
+func uninstallPaths(homeDir, module string) ([]string, error) {
+	moduleRoot := filepath.Join(homeDir, "node_modules", filepath.FromSlash(module))
+	if _, err := os.Stat(moduleRoot); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("module %s is not installed", module)
+		}
+		return nil, err
+	}
+
+	var paths []string
+	manifest, err := readInstallManifest(moduleRoot)
+	switch {
+	case err == nil:
+		paths = manifest.Paths
+	case errors.Is(err, os.ErrNotExist):
+		// Modules installed before manifests were written.
+		if deps, ok := baseDependencies[module]; ok {
+			paths = deps
+		} else {
+			paths = []string{filepath.ToSlash(filepath.Join("node_modules", module))}
+			fmt.Printf("%s was installed without a manifest; its templates and definitions, if any, must be removed manually\n", module)
+		}
+	default:
+		return nil, err
+	}
+
+	existing := make([]string, 0, len(paths))
+	for _, path := range paths {
+		clean := filepath.Clean(filepath.FromSlash(path))
+		if clean == "." || strings.HasPrefix(clean, "..") || filepath.IsAbs(clean) {
+			return nil, fmt.Errorf("invalid path %q in manifest for %s", path, module)
+		}
+		if _, err := os.Stat(filepath.Join(homeDir, clean)); err == nil {
+			existing = append(existing, clean)
+		}
+	}
+	sort.Strings(existing)
+
+	return existing, nil
+}
+
+// removeAtomically moves paths into a staging directory before deleting
+// them, restoring everything already moved if any move fails.
+func removeAtomically(homeDir string, paths []string) error {
+	staging, err := os.MkdirTemp(homeDir, "uninstall-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	moved := make([]string, 0, len(paths))
+	for i, path := range paths {
+		staged := filepath.Join(staging, fmt.Sprint(i))
+		if err = os.Rename(filepath.Join(homeDir, path), staged); err != nil {
+			for j := len(moved) - 1; j >= 0; j-- {
+				os.Rename(filepath.Join(staging, fmt.Sprint(j)), filepath.Join(homeDir, moved[j]))
+			}
+			return fmt.Errorf("could not remove %s: %w", path, err)
+		}
+		moved = append(moved, path)
+	}
+
+	return nil
+}
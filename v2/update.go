@@ -0,0 +1,172 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+type UpdateCmd struct {
+	Modules  []string `arg:"" optional:"" help:"Only update these modules."`
+	DryRun   bool     `help:"List outdated modules without reinstalling them."`
+	Progress string   `help:"Emit machine-readable progress events on stderr (none or json)." enum:"none,json" default:"none"`
+}
+
+// Update statuses shown for each module.
+const (
+	updateCurrent  = "up to date"
+	updateOutdated = "outdated"
+	updatePinned   = "pinned"
+	updateLocal    = "local"
+	updateUnknown  = "unknown"
+)
+
+type moduleUpdate struct {
+	name      string
+	location  string
+	requested string
+	installed string
+	latest    string
+	status    string
+}
+
+func (c *UpdateCmd) Run(ctx *Context) error {
+	homeDir, err := ensureHomeDirectory()
+	if err != nil {
+		return err
+	}
+
+	modules, err := installedModules(homeDir)
+	if err != nil {
+		return err
+	}
+	only := make(map[string]bool, len(c.Modules))
+	aliases := projectAliases()
+	for _, module := range c.Modules {
+		only[resolveModuleAlias(module, aliases)] = true
+	}
+
+	install := InstallCmd{}
+	if err := install.createHTTPClient(); err != nil {
+		return err
+	}
+
+	var updates []moduleUpdate
+	for _, m := range modules {
+		if len(only) > 0 && !only[m.Name] {
+			continue
+		}
+		updates = append(updates, checkModuleUpdate(&install, homeDir, m))
+	}
+
+	t := table.NewWriter()
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{
+			Name:   "Module",
+			Colors: text.Colors{text.FgGreen},
+		},
+		{
+			Name:   "Latest",
+			Colors: text.Colors{text.FgCyan},
+		},
+	})
+	t.AppendHeader(table.Row{"Module", "Installed", "Latest", "Status"})
+	outdated := 0
+	for _, u := range updates {
+		t.AppendRow(table.Row{u.name, u.installed, u.latest, u.status})
+		if u.status == updateOutdated {
+			outdated++
+		}
+	}
+	fmt.Println(t.Render())
+
+	if outdated == 0 {
+		fmt.Println("All modules are up to date.")
+		return nil
+	}
+	if c.DryRun {
+		fmt.Printf("%d module(s) are out of date.\n", outdated)
+		return nil
+	}
+
+	for _, u := range updates {
+		if u.status != updateOutdated {
+			continue
+		}
+		cmd := InstallCmd{
+			Location: u.location,
+			Release:  u.requested,
+			Progress: c.Progress,
+		}
+		if err = cmd.doRun(ctx, homeDir); err != nil {
+			return fmt.Errorf("could not update %s: %w", u.name, err)
+		}
+	}
+
+	return nil
+}
+
+// checkModuleUpdate compares the release recorded when a module was
+// installed with the latest release of the tag it was installed from.
+// Modules installed at a specific version are pinned and not updated.
+func checkModuleUpdate(install *InstallCmd, homeDir string, m *installedModule) moduleUpdate {
+	u := moduleUpdate{
+		name:      m.Name,
+		location:  m.Name,
+		installed: m.Installed,
+	}
+
+	manifest, err := readInstallManifest(filepath.Join(homeDir, "node_modules", filepath.FromSlash(m.Name)))
+	if err == nil {
+		if manifest.Location != "" {
+			u.location = manifest.Location
+		}
+		if manifest.Tag != "" {
+			u.installed = manifest.Tag
+		}
+		u.requested = manifest.Requested
+		switch {
+		case strings.HasPrefix(u.location, "file:"):
+			u.status = updateLocal
+			return u
+		case manifest.RequestedType == RequestedVersion,
+			isRepositoryLocation(u.location) && u.requested != "" && u.requested != "latest" &&
+				manifest.RequestedType != RequestedRange:
+			u.status = updatePinned
+			return u
+		}
+	}
+
+	install.Release = u.requested
+	release, err := install.getReleaseInfo(u.location, u.requested)
+	if err != nil {
+		u.status = updateUnknown
+		return u
+	}
+	u.latest = release.Tag
+	if u.latest == u.installed {
+		u.status = updateCurrent
+	} else {
+		u.status = updateOutdated
+	}
+	return u
+}
@@ -0,0 +1,347 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+type UpgradeCmd struct {
+	Modules       []string `arg:"" optional:"" help:"Only upgrade these modules."`
+	Progress      string   `help:"Emit machine-readable progress events on stderr (none or json)." enum:"none,json" default:"none"`
+	Review        bool     `help:"Show installed modules with their available versions and choose which to upgrade."`
+	Changelog     bool     `help:"Show GitHub release notes for modules with upgrades available."`
+	Yes           bool     `short:"y" help:"Upgrade every module with an upgrade available without prompting."`
+	SummaryFormat string   `name:"summary" help:"How to print a summary when finished: text, json, or none." enum:"text,json,none" default:"text"`
+
+	summary *Summary
+}
+
+// Summary returns the outcome of the last run.
+func (c *UpgradeCmd) Summary() *Summary {
+	return c.summary
+}
+
+// installedModule is a module in the home directory's node_modules.
+type installedModule struct {
+	Name       string
+	Installed  string
+	Available  string
+	Repository string
+	Base       bool
+}
+
+// upgradable reports whether the available release is newer than the
+// one installed. Releases that are not versions, such as git refs, are
+// upgradable when they differ.
+func (m *installedModule) upgradable() bool {
+	if m.Available == "" {
+		return false
+	}
+	available, ok := parseSemver(m.Available)
+	installed, installedOK := parseSemver(m.Installed)
+	if !ok || !installedOK {
+		return m.Available != m.Installed
+	}
+	return available.compare(installed) > 0
+}
+
+// moduleLocation returns the location a module was installed from,
+// which is its name when it was installed from npm.
+func moduleLocation(homeDir, name string) string {
+	manifest, err := readInstallManifest(filepath.Join(homeDir, "node_modules", filepath.FromSlash(name)))
+	if err != nil || manifest.Location == "" {
+		return name
+	}
+	return manifest.Location
+}
+
+func (c *UpgradeCmd) Run(ctx *Context) error {
+	homeDir, err := ensureHomeDirectory()
+	if err != nil {
+		return err
+	}
+
+	c.summary = newSummary("upgrade")
+	defer func() { c.summary.finish(c.SummaryFormat) }()
+
+	if !c.Review && !c.Changelog {
+		modules := c.Modules
+		if len(modules) == 0 {
+			// Reinstall the base dependencies.
+			for name := range baseDependencies {
+				modules = append(modules, name)
+			}
+			sort.Strings(modules)
+		}
+		return c.upgrade(ctx, homeDir, modules)
+	}
+
+	modules, err := installedModules(homeDir)
+	if err != nil {
+		return err
+	}
+	install := InstallCmd{}
+	if err := install.createHTTPClient(); err != nil {
+		return err
+	}
+	// The latest release is resolved from where each module was
+	// installed from, as with update.
+	for _, m := range modules {
+		location := moduleLocation(homeDir, m.Name)
+		if strings.HasPrefix(location, "file:") {
+			continue
+		}
+		if release, err := install.getReleaseInfo(location, ""); err == nil {
+			m.Available = release.Tag
+		}
+	}
+
+	t := table.NewWriter()
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{
+			Name:   "Module",
+			Colors: text.Colors{text.FgGreen},
+		},
+		{
+			Name:   "Available",
+			Colors: text.Colors{text.FgCyan},
+		},
+	})
+	t.AppendHeader(table.Row{"#", "Module", "Installed", "Available", "Base"})
+	for i, m := range modules {
+		available := m.Available
+		switch {
+		case available == "":
+			available = "unknown"
+		case !m.upgradable():
+			available = "up to date"
+		}
+		base := ""
+		if m.Base {
+			base = "yes"
+		}
+		t.AppendRow(table.Row{i + 1, m.Name, m.Installed, available, base})
+	}
+	fmt.Println(t.Render())
+
+	if c.Changelog {
+		for _, m := range modules {
+			if m.upgradable() {
+				printReleaseNotes(m)
+			}
+		}
+	}
+
+	var selected []string
+	switch {
+	case len(c.Modules) > 0:
+		selected = c.Modules
+	case c.Yes:
+		for _, m := range modules {
+			if m.upgradable() {
+				selected = append(selected, m.Name)
+			}
+		}
+	case c.Review:
+		if selected, err = promptModules(modules); err != nil {
+			return err
+		}
+	}
+	if len(selected) == 0 {
+		fmt.Println("Nothing to upgrade.")
+		return nil
+	}
+
+	return c.upgrade(ctx, homeDir, selected)
+}
+
+func (c *UpgradeCmd) upgrade(ctx *Context, homeDir string, modules []string) error {
+	for _, module := range modules {
+		cmd := InstallCmd{
+			Location: moduleLocation(homeDir, module),
+			Progress: c.Progress,
+			summary:  c.summary,
+		}
+		err := cmd.doRun(ctx, homeDir)
+		c.summary.install(err)
+		if err != nil {
+			return fmt.Errorf("could not upgrade %s: %w", module, err)
+		}
+	}
+	return nil
+}
+
+// promptModules asks which modules to upgrade by their number in the table.
+func promptModules(modules []*installedModule) ([]string, error) {
+	fmt.Print("Upgrade which modules? Enter numbers separated by commas, \"all\", or nothing to cancel: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return nil, nil
+	}
+	line = strings.TrimSpace(line)
+
+	var selected []string
+	if line == "all" {
+		for _, m := range modules {
+			if m.upgradable() {
+				selected = append(selected, m.Name)
+			}
+		}
+		return selected, nil
+	}
+	for _, field := range strings.Split(line, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 1 || n > len(modules) {
+			return nil, fmt.Errorf("invalid selection %q", field)
+		}
+		selected = append(selected, modules[n-1].Name)
+	}
+	return selected, nil
+}
+
+// installedModules lists the top-level modules in the home
+// directory's node_modules, including scoped packages.
+func installedModules(homeDir string) ([]*installedModule, error) {
+	root := filepath.Join(homeDir, "node_modules")
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if strings.HasPrefix(name, "@") {
+			scoped, err := os.ReadDir(filepath.Join(root, name))
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range scoped {
+				if s.IsDir() {
+					names = append(names, name+"/"+s.Name())
+				}
+			}
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	modules := make([]*installedModule, 0, len(names))
+	for _, name := range names {
+		dir := filepath.Join(root, filepath.FromSlash(name))
+		data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+		if err != nil {
+			continue
+		}
+		var pkg struct {
+			Version    string          `json:"version"`
+			Repository json.RawMessage `json:"repository"`
+		}
+		if err = json.Unmarshal(data, &pkg); err != nil {
+			continue
+		}
+		_, base := baseDependencies[name]
+		modules = append(modules, &installedModule{
+			Name:       name,
+			Installed:  pkg.Version,
+			Repository: packageRepository(pkg.Repository),
+			Base:       base,
+		})
+	}
+
+	return modules, nil
+}
+
+// packageRepository returns the URL or shorthand of
+// a package.json repository field.
+func packageRepository(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var obj struct {
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal(raw, &obj); err == nil {
+		return obj.URL
+	}
+	return ""
+}
+
+// githubRepository extracts the owner and name of a GitHub repository
+// from a package.json repository URL or shorthand.
+func githubRepository(repository string) (string, string, bool) {
+	r := strings.TrimSuffix(repository, ".git")
+	switch {
+	case strings.HasPrefix(r, "github:"):
+		r = strings.TrimPrefix(r, "github:")
+	case strings.Contains(r, "github.com"):
+		r = r[strings.Index(r, "github.com")+len("github.com")+1:]
+	case strings.Count(r, "/") == 1 && !strings.Contains(r, ":"):
+		// owner/repo shorthand
+	default:
+		return "", "", false
+	}
+	parts := strings.Split(r, "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func printReleaseNotes(m *installedModule) {
+	owner, repo, ok := githubRepository(m.Repository)
+	if !ok {
+		return
+	}
+	client, err := newGitHubClient()
+	if err != nil {
+		return
+	}
+	for _, tag := range []string{"v" + m.Available, m.Available} {
+		release, _, err := client.Repositories.GetReleaseByTag(context.Background(), owner, repo, tag)
+		if err != nil {
+			continue
+		}
+		fmt.Printf("\n%s %s\n\n%s\n", m.Name, m.Available, strings.TrimSpace(release.GetBody()))
+		return
+	}
+}
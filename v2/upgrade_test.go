@@ -0,0 +1,44 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpgradable(t *testing.T) {
+	for _, test := range []struct {
+		installed, available string
+		upgradable           bool
+	}{
+		{"1.2.3", "1.10.0", true},
+		{"1.10.0", "1.9.0", false},
+		{"1.2.3", "1.2.3", false},
+		{"1.2.3", "v1.2.3", false},
+		{"1.0.0-beta.1", "1.0.0", true},
+		{"1.0.0", "1.0.1-beta.1", true},
+		{"1.2.3", "", false},
+		// Refs that are not versions are compared as they are.
+		{"main", "v1.0.0", true},
+		{"abc123", "abc123", false},
+	} {
+		m := installedModule{Installed: test.installed, Available: test.available}
+		assert.Equal(t, test.upgradable, m.upgradable(), "%s to %s", test.installed, test.available)
+	}
+}
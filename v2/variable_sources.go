@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+)
+
+// Built-in sources of template variable defaults, referenced by a
+// variable's defaultFrom. Sources with a prefix take the rest of the
+// name as a key, such as git.user.email or env.AUTHOR.
+const (
+	sourceGitPrefix = "git."
+	sourceEnvPrefix = "env."
+	sourceGitHub    = "github.user"
+	sourceOSUser    = "os.user"
+)
+
+// variableDefault returns the value of the first source of defaultFrom,
+// a comma-separated list such as "git.user.name,os.user", that is set.
+// It returns an empty string when none are set.
+func variableDefault(defaultFrom string) (string, error) {
+	for _, source := range strings.Split(defaultFrom, ",") {
+		source = strings.TrimSpace(source)
+		if source == "" {
+			continue
+		}
+		value, err := variableSource(source)
+		if err != nil {
+			return "", err
+		}
+		if value != "" {
+			return value, nil
+		}
+	}
+	return "", nil
+}
+
+// variableSource reads a single source, returning an empty string when
+// it is not set and an error when the source is unknown.
+func variableSource(source string) (string, error) {
+	switch {
+	case strings.HasPrefix(source, sourceGitPrefix):
+		return gitConfig(strings.TrimPrefix(source, sourceGitPrefix)), nil
+	case strings.HasPrefix(source, sourceEnvPrefix):
+		return os.Getenv(strings.TrimPrefix(source, sourceEnvPrefix)), nil
+	case source == sourceGitHub:
+		if name := os.Getenv("GITHUB_USER"); name != "" {
+			return name, nil
+		}
+		// Set in GitHub Actions workflows.
+		return os.Getenv("GITHUB_ACTOR"), nil
+	case source == sourceOSUser:
+		current, err := user.Current()
+		if err != nil {
+			return "", nil
+		}
+		return current.Username, nil
+	}
+	return "", fmt.Errorf("unknown variable source %q: expected git.<key>, env.<name>, %s, or %s", source, sourceGitHub, sourceOSUser)
+}
+
+// gitConfig returns a value of the user's git configuration, or an empty
+// string when git is not installed or the key is not set.
+func gitConfig(key string) string {
+	if key == "" {
+		return ""
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return ""
+	}
+	out, err := exec.Command("git", "config", "--get", key).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
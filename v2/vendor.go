@@ -0,0 +1,242 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// VendorDir is where vendor copies the modules a project uses, relative
+// to the working directory. It has the layout of the home directory and
+// modules are resolved from it before any other, so it can be committed
+// for builds that do not depend on what is installed.
+const VendorDir = "vendor/apex"
+
+// vendorDirectory returns the project's vendor directory,
+// or an empty string if nothing is vendored.
+func vendorDirectory() string {
+	dir, err := filepath.Abs(filepath.FromSlash(VendorDir))
+	if err != nil {
+		return ""
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "node_modules")); err != nil || !fi.IsDir() {
+		return ""
+	}
+	return dir
+}
+
+type VendorCmd struct {
+	Config string `arg:"" help:"The code generation configuration whose modules are vendored." type:"existingfile" default:"apex.yaml"`
+}
+
+func (c *VendorCmd) Run(ctx *Context) error {
+	homeDir, err := getHomeDirectory()
+	if err != nil {
+		return err
+	}
+	configs, err := readConfigs(c.Config, "", ConfigOverrides{})
+	if err != nil {
+		return err
+	}
+
+	// Modules are copied from where they are installed, not from
+	// a previous vendor directory, which is replaced.
+	homes := installHomes(homeDir)
+	modules := make(map[string]string)
+	queue := configModules(configs)
+	for len(queue) > 0 {
+		module := queue[0]
+		queue = queue[1:]
+		if _, ok := modules[module]; ok {
+			continue
+		}
+		home, ok := moduleHome(homes, module)
+		if !ok {
+			return fmt.Errorf("%s is not installed; install it or run apex restore first", module)
+		}
+		modules[module] = home
+		for _, dependency := range packageDependencies(filepath.Join(home, "node_modules", filepath.FromSlash(module))) {
+			// Dependencies that are not installed are bundled
+			// with the module or not needed to generate.
+			if _, ok := moduleHome(homes, dependency); ok {
+				queue = append(queue, dependency)
+			}
+		}
+	}
+
+	dest, err := filepath.Abs(filepath.FromSlash(VendorDir))
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dest), ".apex-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	for _, sub := range []string{"node_modules", "templates", "definitions"} {
+		if err = os.MkdirAll(filepath.Join(tmp, sub), 0755); err != nil {
+			return err
+		}
+	}
+
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	copied := make(map[string]bool)
+	for _, name := range names {
+		home := modules[name]
+		for _, rel := range modulePaths(home, name) {
+			if isCopied(copied, rel) {
+				continue
+			}
+			src := filepath.Join(home, filepath.FromSlash(rel))
+			if _, err := os.Lstat(src); os.IsNotExist(err) {
+				continue
+			}
+			target := filepath.Join(tmp, filepath.FromSlash(rel))
+			if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err = copyTree(src, target); err != nil {
+				return fmt.Errorf("could not vendor %s: %w", name, err)
+			}
+			copied[rel] = true
+		}
+		version := readPackageVersion(filepath.Join(home, "node_modules", filepath.FromSlash(name)))
+		fmt.Printf("Vendored %s %s\n", name, version)
+	}
+
+	if err = os.RemoveAll(dest); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, dest); err != nil {
+		return err
+	}
+	fmt.Printf("Vendored %d module(s) into %s\n", len(names), VendorDir)
+	return nil
+}
+
+// configModules returns the installed modules that configurations use
+// to parse specifications and generate targets, and their dependencies.
+// Modules given as relative or absolute paths are part of the project.
+func configModules(configs []Config) []string {
+	seen := make(map[string]bool)
+	var modules []string
+	add := func(config Config, module string) {
+		if module == "" {
+			return
+		}
+		module = resolveModuleAlias(module, config.Aliases)
+		if strings.HasPrefix(module, ".") || filepath.IsAbs(module) {
+			return
+		}
+		if name := packageName(module); !seen[name] {
+			seen[name] = true
+			modules = append(modules, name)
+		}
+	}
+	for _, config := range configs {
+		core := config.Core
+		if core == "" {
+			core = defaultCoreModule
+		}
+		add(config, core)
+		for filename := range config.Generates {
+			target := config.Generates[filename]
+			add(config, target.Core)
+			add(config, target.Module)
+			for _, visitor := range target.Visitors {
+				add(config, visitor.Module)
+			}
+		}
+		for name := range config.Dependencies {
+			add(config, name)
+		}
+	}
+	sort.Strings(modules)
+	return modules
+}
+
+// moduleHome returns the first of homes a module is installed in.
+func moduleHome(homes []string, module string) (string, bool) {
+	for _, home := range homes {
+		if _, err := os.Stat(filepath.Join(home, "node_modules", filepath.FromSlash(module), "package.json")); err == nil {
+			return home, true
+		}
+	}
+	return "", false
+}
+
+// packageDependencies returns the dependencies and peer
+// dependencies in the package.json of dir.
+func packageDependencies(dir string) []string {
+	data, err := readLocalFile(filepath.Join(dir, "package.json"), MaxConfigSize)
+	if err != nil {
+		return nil
+	}
+	var pkg struct {
+		Dependencies     map[string]string `json:"dependencies"`
+		PeerDependencies map[string]string `json:"peerDependencies"`
+	}
+	if err = json.Unmarshal(data, &pkg); err != nil {
+		return nil
+	}
+	var names []string
+	for name := range pkg.Dependencies {
+		names = append(names, name)
+	}
+	for name := range pkg.PeerDependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// modulePaths returns the paths, relative to home, that were installed
+// for a module, or only its package directory when it was installed
+// without a manifest.
+func modulePaths(home, module string) []string {
+	packagePath := "node_modules/" + module
+	manifest, err := readInstallManifest(filepath.Join(home, filepath.FromSlash(packagePath)))
+	if err != nil || len(manifest.Paths) == 0 {
+		return []string{packagePath}
+	}
+	paths := append([]string(nil), manifest.Paths...)
+	sort.Strings(paths)
+	return paths
+}
+
+// isCopied reports whether path, or a directory containing it, was copied.
+func isCopied(copied map[string]bool, rel string) bool {
+	for p := rel; p != "." && p != "/"; p = path.Dir(p) {
+		if copied[p] {
+			return true
+		}
+	}
+	return false
+}
@@ -16,19 +16,12 @@ limitations under the License.
 
 package cli
 
-type Shrinkwrap struct {
-	Name            string             `json:"name"`
-	Version         string             `json:"version"`
-	LockfileVersion int                `json:"lockfileVersion"`
-	Requires        bool               `json:"requires"`
-	Packages        map[string]Package `json:"packages"`
-	Dependencies    map[string]Package `json:"dependencies"`
-}
+// Version is the version of the CLI embedding this package.
+// It is set by the main package at startup.
+var Version = "edge"
 
-type Package struct {
-	Version    string `json:"version"`
-	Resolved   string `json:"resolved"`
-	Integrity  string `json:"integrity"`
-	Dev        bool   `json:"dev"`
-	Extraneous bool   `json:"extraneous"`
-}
+// APIVersion is the semantic version of the Go API of this package.
+// It follows the policy described in the package documentation and
+// is independent of the CLI version. The minor version is bumped
+// by releases that add to the API.
+const APIVersion = "2.0.0"
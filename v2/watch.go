@@ -0,0 +1,344 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+type WatchCmd struct {
+	Configs   []string `arg:"" help:"The code generation configuration files" type:"existingfile" optional:""`
+	Recursive bool     `help:"Watch every apex.yaml under the current directory, skipping paths ignored by git."`
+	// Projects are watched in one process, each generated from its
+	// own directory as if watch was run there.
+	Projects []string `help:"Watch the apex.yaml of each of these project directories in one process." type:"existingdir" sep:","`
+	From     string   `help:"Watch the projects listed in a workspace file." type:"existingfile"`
+	// LiveReload serves the LiveReload protocol to browsers.
+	LiveReload        bool     `help:"Tell browsers connected with LiveReload to refresh after each successful generation."`
+	LiveReloadAddr    string   `help:"The address LiveReload listens on." default:"localhost:35729"`
+	LiveReloadTargets []string `help:"Only refresh browsers for generated files matching these patterns (e.g. docs/*.html)." sep:","`
+	ConfigOverrides
+}
+
+// watchedConfig is a configuration and the project directory it is
+// generated from, which is empty for the working directory.
+type watchedConfig struct {
+	config  Config
+	project string
+}
+
+// watchProject is a directory with configurations that are watched.
+type watchProject struct {
+	dir     string
+	configs []string
+}
+
+// projects returns the directories watched and their configuration
+// files, which are absolute.
+func (c *WatchCmd) projects() ([]watchProject, error) {
+	dirs := c.Projects
+	if c.From != "" {
+		workspace, err := readWorkspace(c.From)
+		if err != nil {
+			return nil, err
+		}
+		if len(workspace.Projects) == 0 {
+			return nil, fmt.Errorf("%s does not list any projects", c.From)
+		}
+		// Projects are relative to the workspace file.
+		for _, dir := range workspace.Projects {
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(filepath.Dir(c.From), dir)
+			}
+			dirs = append(dirs, dir)
+		}
+	}
+
+	var projects []watchProject
+	if len(dirs) == 0 || len(c.Configs) > 0 || c.Recursive {
+		configs := c.Configs
+		if c.Recursive {
+			found, err := findConfigs(".", "apex.yaml")
+			if err != nil {
+				return nil, err
+			}
+			if len(found) == 0 {
+				return nil, errors.New("no apex.yaml files found")
+			}
+			configs = append(configs, found...)
+		}
+		if len(configs) == 0 {
+			configs = append(configs, "apex.yaml")
+		}
+		projects = append(projects, watchProject{configs: configs})
+	}
+	for _, dir := range dirs {
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		config := filepath.Join(dir, "apex.yaml")
+		if _, err := os.Stat(config); err != nil {
+			return nil, fmt.Errorf("project %s does not have an apex.yaml", dir)
+		}
+		projects = append(projects, watchProject{dir: dir, configs: []string{config}})
+	}
+	for _, project := range projects {
+		for i, config := range project.configs {
+			config, err := filepath.Abs(config)
+			if err != nil {
+				return nil, err
+			}
+			project.configs[i] = config
+		}
+	}
+	return projects, nil
+}
+
+// projectName returns how log lines name a project.
+func projectName(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	if rel, err := filepath.Rel(".", dir); err == nil && !strings.HasPrefix(rel, "..") {
+		return "[" + rel + "] "
+	}
+	return "[" + dir + "] "
+}
+
+// Run watches every project in one process, so the TypeScript formatter
+// is loaded and the LiveReload server is started once however many are
+// watched.
+func (c *WatchCmd) Run(ctx *Context) error {
+	projects, err := c.projects()
+	if err != nil {
+		return err
+	}
+	workDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	// configProjects maps each configuration file to its project.
+	configProjects := make(map[string]string)
+	for _, project := range projects {
+		for _, config := range project.configs {
+			configProjects[config] = project.dir
+		}
+	}
+
+	configs := make(map[string][]string)
+	specs := make(map[string][]watchedConfig)
+
+	reloadConfigs := func() error {
+		configs = make(map[string][]string)
+		specs = make(map[string][]watchedConfig)
+
+		for config, project := range configProjects {
+			fileConfigs, err := readConfigs(config, "", c.ConfigOverrides)
+			if err != nil {
+				return err
+			}
+
+			configSpecs := []string{}
+			for _, config := range fileConfigs {
+				specFile := config.Spec
+				if project != "" && !filepath.IsAbs(specFile) {
+					specFile = filepath.Join(project, specFile)
+				}
+				specFile, err := filepath.Abs(specFile)
+				if err != nil {
+					return err
+				}
+				configSpecs = append(configSpecs, specFile)
+				specs[specFile] = append(specs[specFile], watchedConfig{config: config, project: project})
+			}
+			configs[config] = configSpecs
+		}
+
+		return nil
+	}
+
+	configWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer configWatcher.Close()
+
+	specWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer specWatcher.Close()
+
+	syncWatchers := func() error {
+		currentSpecs := make(map[string]struct{})
+		removeSpecs := make(map[string]struct{})
+		for _, name := range specWatcher.WatchList() {
+			currentSpecs[name] = struct{}{}
+			removeSpecs[name] = struct{}{}
+		}
+		for _, specs := range configs {
+			for _, spec := range specs {
+				if _, exists := currentSpecs[spec]; exists {
+					delete(removeSpecs, spec)
+					continue
+				}
+				log.Printf("Watching %s...", spec)
+				if err = specWatcher.Add(spec); err != nil {
+					return err
+				}
+				currentSpecs[spec] = struct{}{}
+			}
+		}
+		for name := range removeSpecs {
+			log.Printf("Unwatching %s...", name)
+			specWatcher.Remove(name)
+		}
+
+		return nil
+	}
+
+	var reloads *liveReloadServer
+	if c.LiveReload {
+		reloads = newLiveReloadServer()
+		listener, err := reloads.listen(c.LiveReloadAddr)
+		if err != nil {
+			return err
+		}
+		defer listener.Close()
+		log.Printf("LiveReload listening on ws://%s%s (script at http://%s/livereload.js)",
+			listener.Addr(), liveReloadPath, listener.Addr())
+	}
+
+	// Projects are generated one at a time from their directory,
+	// so paths in their configurations are relative to it. They
+	// share a GenerateCmd, which keeps the formatter it loads.
+	g := GenerateCmd{}
+	defer func() {
+		if g.prettier != nil {
+			g.prettier.Dispose()
+		}
+	}()
+	generate := func(watched watchedConfig) {
+		config := watched.config
+		if watched.project != "" {
+			if err := os.Chdir(watched.project); err != nil {
+				log.Printf("%sError running generate: %v", projectName(watched.project), err)
+				return
+			}
+			defer os.Chdir(workDir)
+		}
+		started := time.Now()
+		if err := g.generate(config); err != nil {
+			log.Printf("%sError running generate: %v", projectName(watched.project), err)
+			return
+		}
+		if watched.project != "" {
+			log.Printf("%sGenerated %s in %s", projectName(watched.project), config.Spec, time.Since(started).Round(time.Millisecond))
+		}
+		if reloads != nil {
+			if paths := liveReloadPaths(config, c.LiveReloadTargets); len(paths) > 0 {
+				reloads.reload(paths)
+			}
+		}
+	}
+
+	done := make(chan bool)
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-configWatcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op&fsnotify.Write != fsnotify.Write {
+					continue
+				}
+
+				log.Println("Modified config:", event.Name)
+				// Modules may have changed with the configuration.
+				g.schemas = nil
+				if err := reloadConfigs(); err != nil {
+					log.Println("error:", err)
+					return
+				}
+				if err := syncWatchers(); err != nil {
+					log.Println("error:", err)
+					return
+				}
+
+				if eventSpecs, ok := configs[event.Name]; ok {
+					for _, eventSpec := range eventSpecs {
+						for _, watched := range specs[eventSpec] {
+							generate(watched)
+						}
+					}
+				}
+
+			case event, ok := <-specWatcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op&fsnotify.Write != fsnotify.Write {
+					continue
+				}
+
+				log.Println("Modified spec:", event.Name)
+				for _, watched := range specs[event.Name] {
+					generate(watched)
+				}
+
+				log.Println("Watching for file changes.")
+
+			case err, ok := <-configWatcher.Errors:
+				if !ok {
+					return
+				}
+				log.Println("error:", err)
+
+			case err, ok := <-specWatcher.Errors:
+				if !ok {
+					return
+				}
+				log.Println("error:", err)
+			}
+		}
+	}()
+
+	reloadConfigs()
+	for config := range configs {
+		log.Printf("Watching %s...", config)
+		if err = configWatcher.Add(config); err != nil {
+			log.Fatal(err)
+		}
+	}
+	syncWatchers()
+
+	log.Println("Watching for file changes.")
+	<-done
+
+	return nil
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	"gopkg.in/yaml.v3"
+)
+
+// Workspace lists the modules to install when
+// bootstrapping a development environment.
+type Workspace struct {
+	Modules []WorkspaceModule `json:"modules" yaml:"modules"`
+	// Projects are directories, relative to the workspace file,
+	// watched together with watch --from.
+	Projects []string `json:"projects,omitempty" yaml:"projects,omitempty"`
+}
+
+type WorkspaceModule struct {
+	Location string `json:"location" yaml:"location"`
+	Release  string `json:"release,omitempty" yaml:"release,omitempty"`
+}
+
+// readWorkspace reads the workspace file.
+func readWorkspace(filename string) (*Workspace, error) {
+	workspaceBytes, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var workspace Workspace
+	if err = yaml.Unmarshal(workspaceBytes, &workspace); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", filename, err)
+	}
+	return &workspace, nil
+}
+
+type workspaceResult struct {
+	module   WorkspaceModule
+	attempts int
+	err      error
+}
+
+// installWorkspace installs every module listed in the workspace file.
+func (c *InstallCmd) installWorkspace(ctx *Context, homeDir string) error {
+	workspace, err := readWorkspace(c.From)
+	if err != nil {
+		return err
+	}
+	if len(workspace.Modules) == 0 {
+		return fmt.Errorf("%s does not list any modules", c.From)
+	}
+	return c.installModules(ctx, homeDir, workspace.Modules)
+}
+
+// installModules installs modules concurrently. Downloads share a cache
+// so that a retried install resumes where it left off and an optional
+// bandwidth budget.
+func (c *InstallCmd) installModules(ctx *Context, homeDir string, modules []WorkspaceModule) error {
+
+	var limiter *rateLimiter
+	if c.RateLimit != "" {
+		bytesPerSec, err := parseByteSize(c.RateLimit)
+		if err != nil {
+			return err
+		}
+		limiter = newRateLimiter(bytesPerSec)
+	}
+
+	concurrency := c.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]workspaceResult, len(modules))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, module := range modules {
+		wg.Add(1)
+		go func(i int, module WorkspaceModule) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			result := workspaceResult{module: module}
+			for attempt := 0; attempt <= c.Retries; attempt++ {
+				if attempt > 0 {
+					time.Sleep(time.Duration(attempt) * time.Second)
+					fmt.Printf("Retrying %s (attempt %d)...\n", module.Location, attempt+1)
+				}
+				install := InstallCmd{
+					Location:        module.Location,
+					Release:         module.Release,
+					Progress:        c.Progress,
+					PolicyFile:      c.PolicyFile,
+					NoVerify:        c.NoVerify,
+					HTTPRetries:     c.HTTPRetries,
+					HTTPTimeout:     c.HTTPTimeout,
+					Offline:         c.Offline,
+					Project:         c.Project,
+					Build:           c.Build,
+					NoScripts:       c.NoScripts,
+					Force:           c.Force,
+					Only:            c.Only,
+					Overwrite:       c.Overwrite,
+					KeepExisting:    c.KeepExisting,
+					Include:         c.Include,
+					Omit:            c.Omit,
+					ExcludePackages: c.ExcludePackages,
+					cacheDir:        downloadCacheDir(homeDir),
+					limiter:         limiter,
+					lock:            c.lock,
+					summary:         c.summary,
+				}
+				result.attempts = attempt + 1
+				if result.err = install.doRun(ctx, homeDir); result.err == nil {
+					break
+				}
+			}
+			results[i] = result
+		}(i, module)
+	}
+	wg.Wait()
+
+	failed := 0
+	t := table.NewWriter()
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{
+			Name:   "Module",
+			Colors: text.Colors{text.FgGreen},
+		},
+	})
+	t.AppendHeader(table.Row{"Module", "Status", "Attempts"})
+	for _, result := range results {
+		c.summary.install(result.err)
+		status := text.FgGreen.Sprint("installed")
+		if result.err != nil {
+			failed++
+			status = text.FgRed.Sprintf("failed: %v", result.err)
+		}
+		t.AppendRow(table.Row{result.module.Location, status, result.attempts})
+	}
+	fmt.Println(t.Render())
+	fmt.Printf("%d succeeded, %d failed\n", len(results)-failed, failed)
+
+	if failed > 0 {
+		return fmt.Errorf("%d module(s) failed to install", failed)
+	}
+	return nil
+}
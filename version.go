@@ -0,0 +1,24 @@
+/*
+Copyright 2022 The Apex Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+// APIVersion is the semantic version of the Go API of this package,
+// which adapts the API of v2 with the same identifiers. It follows the
+// policy described in the package documentation and is independent of
+// the CLI version. The minor version is bumped by releases that add
+// to the API, including additions generated from v2.
+const APIVersion = "1.3.0"